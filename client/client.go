@@ -0,0 +1,166 @@
+// Package client is a Go client for the gostreampuller HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// The stream trailers the server sends after a live stream body.
+const (
+	streamStatusTrailer = "X-Stream-Status" // "complete" or "error"
+	streamErrorTrailer  = "X-Stream-Error"  // Failure reason, only with status "error"
+)
+
+// Credentials are the AUTH_USERNAME and AUTH_PASSWORD of the server, sent
+// with Basic Auth. Leave them empty for a server in LOCAL_MODE.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// APIError is returned when the server answers with an error status.
+type APIError struct {
+	StatusCode int
+	Message    string // The server's error message, or the response body
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gostreampuller: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// StreamError is returned at the end of a stream the server could not
+// finish, e.g. when yt-dlp failed halfway; the data read before it is
+// incomplete.
+type StreamError struct {
+	Message string // The server's failure reason
+}
+
+func (e *StreamError) Error() string {
+	return "gostreampuller: stream failed: " + e.Message
+}
+
+// Client calls a gostreampuller server. Its methods are safe for concurrent
+// use.
+type Client struct {
+	baseURL string
+	creds   Credentials
+	// HTTPClient sends the requests; set it before use to configure
+	// timeouts or transports. Keep its timeout off for streams.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at baseURL, e.g.
+// "http://localhost:8080".
+func New(baseURL string, creds Credentials) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		creds:      creds,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// GetVideoInfo returns the metadata of the video at url, without
+// downloading it.
+func (c *Client) GetVideoInfo(ctx context.Context, url string) (*VideoInfo, error) {
+	var resp getVideoInfoResponse
+	if err := c.postJSON(ctx, "/download/video/info", getVideoInfoRequest{URL: url}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.VideoInfo, nil
+}
+
+// DownloadVideo downloads a video to the server's download directory.
+func (c *Client) DownloadVideo(ctx context.Context, req DownloadVideoRequest) (*DownloadVideoResponse, error) {
+	var resp DownloadVideoResponse
+	if err := c.postJSON(ctx, "/download/video", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StreamVideo starts a live video stream. The caller must close the
+// returned body; the stream's Content-Type follows req.Format. A stream the
+// server fails to finish ends with a *StreamError instead of io.EOF.
+func (c *Client) StreamVideo(ctx context.Context, req StreamVideoRequest) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/stream/video", req)
+	if err != nil {
+		return nil, err
+	}
+	return &streamBody{resp: resp}, nil
+}
+
+// streamBody is the body of a live stream, which reports the outcome the
+// server sends in the stream trailers once the body is read to the end.
+type streamBody struct {
+	resp *http.Response
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.resp.Body.Read(p)
+	// Trailers are only set once the body hit EOF
+	if err == io.EOF && b.resp.Trailer.Get(streamStatusTrailer) == "error" {
+		return n, &StreamError{Message: b.resp.Trailer.Get(streamErrorTrailer)}
+	}
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	return b.resp.Body.Close()
+}
+
+// postJSON posts body as JSON to path and decodes the response into out.
+func (c *Client) postJSON(ctx context.Context, path string, body, out any) error {
+	resp, err := c.do(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("gostreampuller: decoding %s response: %w", path, err)
+	}
+	return nil
+}
+
+// do sends a request with body as JSON, returning the response when its
+// status is a success and an APIError otherwise.
+func (c *Client) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("gostreampuller: encoding %s request: %w", path, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.creds.Username != "" {
+		req.SetBasicAuth(c.creds.Username, c.creds.Password)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp, nil
+}
+
+// newAPIError reads the error message of a failed response. Handlers send
+// an ErrorResponse, but middleware may answer in plain text.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Message != "" {
+		return &APIError{StatusCode: resp.StatusCode, Message: errResp.Message}
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/config"
+	"gostreampuller/handler"
+	"gostreampuller/router"
+	"gostreampuller/service"
+)
+
+// fakeYTDLPScript answers info dumps, streams "0123456789" to stdout and
+// writes it to the output file of downloads.
+const fakeYTDLPScript = `#!/bin/sh
+out=""
+prev=""
+for a in "$@"; do
+	case "$prev" in --output|-o) out="$a" ;; esac
+	case "$a" in --dump-json) echo '{"id":"abc123","title":"Test Video","ext":"mp4","duration":42}'; exit 0 ;; esac
+	prev="$a"
+done
+if [ "$out" = "-" ]; then
+	printf '0123456789'
+elif [ -n "$out" ]; then
+	printf '0123456789' > "$out"
+fi
+`
+
+// newTestServer runs the whole API in-process against a fake yt-dlp and
+// returns a client for it, along with the server's configuration.
+func newTestServer(t *testing.T) (*Client, *config.Config) {
+	t.Helper()
+	ytdlp := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(ytdlp, []byte(fakeYTDLPScript), 0755); err != nil {
+		t.Fatalf("failed to write fake yt-dlp: %v", err)
+	}
+	cfg := &config.Config{
+		LocalMode:      true,
+		YTDLPPath:      ytdlp,
+		FFMPEGPath:     "true",
+		DownloadDir:    t.TempDir(),
+		OnExists:       "unique",
+		EnableDownload: true,
+		EnableStream:   true,
+	}
+	server := httptest.NewServer(router.New(cfg).Handler())
+	t.Cleanup(server.Close)
+	return New(server.URL+"/", Credentials{}), cfg
+}
+
+func TestClient_GetVideoInfo(t *testing.T) {
+	c, _ := newTestServer(t)
+
+	info, err := c.GetVideoInfo(context.Background(), "https://example.com/v")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", info.ID)
+	assert.Equal(t, "Test Video", info.Title)
+}
+
+func TestClient_DownloadVideo(t *testing.T) {
+	c, cfg := newTestServer(t)
+
+	resp, err := c.DownloadVideo(context.Background(), DownloadVideoRequest{URL: "https://example.com/v", Format: "mp4"})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", resp.VideoInfo.ID)
+	content, err := os.ReadFile(filepath.Join(cfg.DownloadDir, resp.FilePath))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+}
+
+func TestClient_StreamVideo(t *testing.T) {
+	c, _ := newTestServer(t)
+
+	stream, err := c.StreamVideo(context.Background(), StreamVideoRequest{URL: "https://example.com/v"})
+	assert.NoError(t, err)
+	defer stream.Close()
+	content, err := io.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+}
+
+func TestClient_StreamVideoError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", handler.StreamStatusTrailer+", "+handler.StreamErrorTrailer)
+		w.Write([]byte("01234"))
+		w.Header().Set(handler.StreamStatusTrailer, "error")
+		w.Header().Set(handler.StreamErrorTrailer, "yt-dlp exited with status 1")
+	}))
+	defer server.Close()
+
+	stream, err := New(server.URL, Credentials{}).StreamVideo(context.Background(), StreamVideoRequest{URL: "https://example.com/v"})
+	assert.NoError(t, err)
+	defer stream.Close()
+	content, err := io.ReadAll(stream)
+	assert.Equal(t, "01234", string(content))
+	var streamErr *StreamError
+	if assert.True(t, errors.As(err, &streamErr)) {
+		assert.Equal(t, "yt-dlp exited with status 1", streamErr.Message)
+	}
+}
+
+func TestClient_APIError(t *testing.T) {
+	c, _ := newTestServer(t)
+
+	_, err := c.DownloadVideo(context.Background(), DownloadVideoRequest{})
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Equal(t, "URL is required", apiErr.Message)
+	}
+
+	_, err = c.StreamVideo(context.Background(), StreamVideoRequest{URL: "https://example.com/v", Preset: "8k"})
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Contains(t, apiErr.Message, `unknown preset "8k"`)
+	}
+}
+
+func TestClient_Credentials(t *testing.T) {
+	var username, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ = r.BasicAuth()
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, Credentials{Username: "admin", Password: "secret"}).GetVideoInfo(context.Background(), "https://example.com/v")
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+		assert.Equal(t, "Unauthorized", apiErr.Message)
+	}
+	assert.Equal(t, "admin", username)
+	assert.Equal(t, "secret", password)
+}
+
+// jsonFields lists the JSON field names of struct type t, in order.
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	return names
+}
+
+// TestTypesMatchServer checks that the client's copies of the API types
+// have the same JSON fields as the server's.
+func TestTypesMatchServer(t *testing.T) {
+	pairs := []struct{ client, server any }{
+		{VideoInfo{}, service.VideoInfo{}},
+		{Thumbnail{}, service.Thumbnail{}},
+		{SelectedFormat{}, service.SelectedFormat{}},
+		{DownloadVideoRequest{}, handler.DownloadVideoRequest{}},
+		{DownloadVideoResponse{}, handler.DownloadVideoResponse{}},
+		{StreamVideoRequest{}, handler.StreamVideoRequest{}},
+		{getVideoInfoRequest{}, handler.GetVideoInfoRequest{}},
+		{getVideoInfoResponse{}, handler.GetVideoInfoResponse{}},
+		{errorResponse{}, handler.ErrorResponse{}},
+	}
+	for _, pair := range pairs {
+		clientType, serverType := reflect.TypeOf(pair.client), reflect.TypeOf(pair.server)
+		assert.Equal(t, jsonFields(serverType), jsonFields(clientType), "client.%s differs from %s", clientType.Name(), serverType)
+	}
+}
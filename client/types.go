@@ -0,0 +1,137 @@
+package client
+
+// The request and response types of the API. They mirror the server's
+// handler and service types field for field, without importing the server
+// and its dependencies; the tests check they stay in sync.
+
+// VideoInfo is the metadata of a video.
+type VideoInfo struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	OriginalURL string `json:"original_url"`
+	Ext         string `json:"ext"`
+	Duration    int    `json:"duration"` // in seconds
+	Uploader    string `json:"uploader"`
+	UploadDate  string `json:"upload_date"` // YYYYMMDD
+	Thumbnail   string `json:"thumbnail"`   // URL to thumbnail
+	// Thumbnails lists every thumbnail size available
+	Thumbnails []Thumbnail `json:"thumbnails,omitempty"`
+	// IsLive is set for a live broadcast
+	IsLive bool `json:"is_live,omitempty"`
+	// Extractor is the yt-dlp extractor that handled the URL, e.g.
+	// "youtube", and ExtractorKey its class name, e.g. "Youtube"
+	Extractor    string `json:"extractor,omitempty"`
+	ExtractorKey string `json:"extractor_key,omitempty"`
+	// Format details, for the video itself and each of its Formats
+	DirectStreamURL string      `json:"url"`
+	FileSize        int64       `json:"filesize"`
+	FormatID        string      `json:"format_id"`
+	FormatNote      string      `json:"format_note"`
+	Protocol        string      `json:"protocol"` // e.g. "https", "m3u8_native", "http_dash_segments"
+	VCodec          string      `json:"vcodec"`
+	ACodec          string      `json:"acodec"`
+	FPS             float64     `json:"fps"`
+	Width           int         `json:"width"`
+	Height          int         `json:"height"`
+	Formats         []VideoInfo `json:"formats"`
+	// Languages of the subtitles and automatic captions available
+	Language          string   `json:"language,omitempty"`
+	Subtitles         []string `json:"subtitles,omitempty"`
+	AutomaticCaptions []string `json:"automatic_captions,omitempty"`
+	// SubtitleLang is the subtitle language actually obtained for a download
+	SubtitleLang string `json:"subtitleLang,omitempty"`
+	// AudioLanguages are the audio tracks muxed into an all-languages download
+	AudioLanguages []string `json:"audioLanguages,omitempty"`
+	// SelectedFormat is the format yt-dlp picked for a video download
+	SelectedFormat *SelectedFormat `json:"selectedFormat,omitempty"`
+}
+
+// Thumbnail is one size of a video's thumbnail.
+type Thumbnail struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// SelectedFormat is the format yt-dlp downloaded.
+type SelectedFormat struct {
+	FormatID string  `json:"formatId"`
+	Ext      string  `json:"ext"`
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	FPS      float64 `json:"fps,omitempty"`
+	VCodec   string  `json:"vcodec,omitempty"`
+	ACodec   string  `json:"acodec,omitempty"`
+	FileSize int64   `json:"filesize,omitempty"` // Bytes, exact or estimated by yt-dlp
+}
+
+// DownloadVideoRequest is the body of a video download. See the API
+// documentation of POST /download/video for each option.
+type DownloadVideoRequest struct {
+	URL                 string `json:"url"`
+	Format              string `json:"format"`
+	Resolution          string `json:"resolution"`
+	Codec               string `json:"codec"`
+	BurnSubtitles       bool   `json:"burnSubtitles"`
+	SubtitleLang        string `json:"subtitleLang"`
+	StrictFormat        bool   `json:"strictFormat"`
+	StrictQuality       bool   `json:"strictQuality"`
+	Progressive         bool   `json:"progressive"`
+	FormatSort          string `json:"formatSort"`
+	FormatID            string `json:"formatId"`
+	OnExists            string `json:"onExists"`
+	Preset              string `json:"preset"`
+	SeparateAudio       bool   `json:"separateAudio"`
+	AllAudioLanguages   bool   `json:"allAudioLanguages"`
+	Watermark           string `json:"watermark"`
+	WatermarkPosition   string `json:"watermarkPosition"`
+	TargetSizeMB        int    `json:"targetSizeMB"`
+	ExpectedSHA256      string `json:"expectedSha256"`
+	ProgressCallbackURL string `json:"progressCallbackUrl"`
+}
+
+// DownloadVideoResponse is the result of a video download. File paths are
+// the names the server serves the files by.
+type DownloadVideoResponse struct {
+	FilePath       string          `json:"filePath"`
+	AudioFilePath  string          `json:"audioFilePath,omitempty"`
+	VideoInfo      *VideoInfo      `json:"videoInfo"`
+	Message        string          `json:"message"`
+	Warnings       []string        `json:"warnings,omitempty"`
+	SHA256         string          `json:"sha256"`
+	AudioSHA256    string          `json:"audioSha256,omitempty"`
+	SelectedFormat *SelectedFormat `json:"selectedFormat,omitempty"`
+}
+
+// StreamVideoRequest is the body of a video stream. See the API
+// documentation of POST /stream/video for each option.
+type StreamVideoRequest struct {
+	URL          string `json:"url"`
+	Format       string `json:"format"`
+	Resolution   string `json:"resolution"`
+	Codec        string `json:"codec"`
+	Progressive  bool   `json:"progressive"`
+	StrictFormat bool   `json:"strictFormat"`
+	FormatSort   string `json:"formatSort"`
+	Save         bool   `json:"save"`
+	Preset       string `json:"preset"`
+}
+
+// getVideoInfoRequest and getVideoInfoResponse are the body and result of
+// a video info request.
+type getVideoInfoRequest struct {
+	URL   string `json:"url"`
+	Light bool   `json:"light"`
+}
+
+type getVideoInfoResponse struct {
+	VideoInfo *VideoInfo `json:"videoInfo"`
+	Message   string     `json:"message"`
+}
+
+// errorResponse is the body of the server's error answers.
+type errorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
@@ -1,13 +1,20 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/google/shlex"
 	"github.com/num30/config" // Updated import
 )
 
@@ -22,6 +29,121 @@ type Config struct {
 	FFMPEGPath   string `envvar:"FFMPEG_PATH" default:"ffmpeg"`
 	DownloadDir  string `envvar:"DOWNLOAD_DIR" default:"./data"`
 	AppBaseURL   string `envvar:"APP_BASE_URL"`
+	// VideoDir and AudioDir are where video and audio downloads are written,
+	// to keep them apart; both default to DownloadDir. Subtitles and info
+	// archives always go to DownloadDir.
+	VideoDir string `envvar:"VIDEO_DIR"`
+	AudioDir string `envvar:"AUDIO_DIR"`
+	// TrustedRedirectHosts lists hosts, comma-separated, that redirects may
+	// point to besides the APP_BASE_URL host.
+	TrustedRedirectHosts string `envvar:"TRUSTED_REDIRECT_HOSTS"`
+	// AllowedExtractors lists the yt-dlp extractors, comma-separated, whose
+	// videos may be fetched, e.g. "youtube"; empty allows every extractor.
+	AllowedExtractors string `envvar:"ALLOWED_EXTRACTORS"`
+	// CallbackAllowedHosts lists hosts, comma-separated, that progress
+	// callbacks may target even though they are not public, e.g. a webhook
+	// receiver on the internal network.
+	CallbackAllowedHosts string `envvar:"CALLBACK_ALLOWED_HOSTS"`
+	// ExtraYTDLPArgs holds operator-controlled flags appended to every yt-dlp
+	// invocation, written with shell-like quoting (e.g. `--proxy "socks5://h:1080"`).
+	ExtraYTDLPArgs string `envvar:"YTDLP_EXTRA_ARGS"`
+	// CookiesFile is a Netscape-format cookies file passed to every yt-dlp
+	// invocation, for age-restricted and login-gated videos.
+	CookiesFile string `envvar:"COOKIES_FILE"`
+	// HTTP server timeouts. WriteTimeout covers the whole response, so it is
+	// disabled (0) by default: any non-zero value cuts off long video streams
+	// and slow downloads once it elapses.
+	ReadTimeout  time.Duration `envvar:"READ_TIMEOUT" default:"30s"`
+	WriteTimeout time.Duration `envvar:"WRITE_TIMEOUT" default:"0s"`
+	IdleTimeout  time.Duration `envvar:"IDLE_TIMEOUT" default:"120s"`
+	// InfoCacheTTL is how long yt-dlp video info is reused per URL. Direct
+	// stream URLs in the info expire, so keep it well under a few hours.
+	// 0 disables the cache.
+	InfoCacheTTL time.Duration `envvar:"INFO_CACHE_TTL" default:"10m"`
+	// StreamSaveTimeout bounds a stream sent with save=true, which keeps
+	// running after its client disconnects. 0 disables it.
+	StreamSaveTimeout time.Duration `envvar:"STREAM_SAVE_TIMEOUT" default:"1h"`
+	// InfoTimeout bounds each yt-dlp info fetch, so a hanging extractor fails
+	// fast instead of holding up the download or stream behind it. 0 disables it.
+	InfoTimeout time.Duration `envvar:"INFO_TIMEOUT" default:"60s"`
+	// ProgressBufferSize is how many progress events are queued per SSE
+	// client before intermediate events start being dropped.
+	ProgressBufferSize int `envvar:"PROGRESS_BUFFER_SIZE" default:"32"`
+	// SSEConnectedEvent starts each progress stream with a "connected"
+	// event; without it, streams start with an SSE comment, which clients
+	// ignore. The connected query parameter overrides it per stream.
+	SSEConnectedEvent bool `envvar:"SSE_CONNECTED_EVENT" default:"true"`
+	// OnExists is the default filename collision strategy for downloads:
+	// "unique" (timestamped names, never collide), "overwrite", "rename" or "error".
+	OnExists string `envvar:"ON_EXISTS" default:"unique"`
+	// ExternalDownloader makes yt-dlp fetch file downloads (never streams)
+	// with another program, e.g. "aria2c" for segmented downloads.
+	// ExternalDownloaderArgs are passed to it.
+	ExternalDownloader     string `envvar:"YTDLP_EXTERNAL_DOWNLOADER"`
+	ExternalDownloaderArgs string `envvar:"YTDLP_EXTERNAL_DOWNLOADER_ARGS" default:"-x16 -s16"`
+	// PlaylistMaxEntries caps how many entries a playlist listing returns.
+	PlaylistMaxEntries int `envvar:"PLAYLIST_MAX_ENTRIES" default:"200"`
+	// InfoBatchConcurrency is how many yt-dlp info fetches one batch info
+	// request runs at once.
+	InfoBatchConcurrency int `envvar:"INFO_BATCH_CONCURRENCY" default:"4"`
+	// Presets adds or replaces named quality presets, as a JSON object from
+	// name to QualityPreset, e.g. {"hd":{"resolution":"720","codec":"vp9"}}.
+	// See QualityPresets.
+	Presets string `envvar:"QUALITY_PRESETS"`
+	// OrganizeByDate writes downloads into DownloadDir/YYYY/MM/DD/ subfolders.
+	OrganizeByDate bool `envvar:"ORGANIZE_BY_DATE" default:"false"`
+	// StrictJSON rejects API request bodies with unknown fields, so client
+	// typos are reported instead of silently ignored.
+	StrictJSON bool `envvar:"STRICT_JSON" default:"false"`
+	// PartialCleanupAge is how old a partial download (.part, .ytdl, .tmp)
+	// must be to be removed at startup; 0 disables the cleanup.
+	PartialCleanupAge time.Duration `envvar:"PARTIAL_CLEANUP_AGE" default:"1h"`
+	// MaxConcurrentStreams and MaxConcurrentDownloads cap the live streams
+	// and file downloads running at once, separately since streams are
+	// long-lived; requests beyond a cap get 503. 0 means no limit.
+	MaxConcurrentStreams   int `envvar:"MAX_CONCURRENT_STREAMS" default:"0"`
+	MaxConcurrentDownloads int `envvar:"MAX_CONCURRENT_DOWNLOADS" default:"0"`
+	// ConcurrencyWait is how long a stream or download beyond its cap waits
+	// for a slot to free up before getting 503; 0 refuses it at once.
+	ConcurrencyWait time.Duration `envvar:"CONCURRENCY_WAIT" default:"0s"`
+	// MaxInflightRequests caps the requests handled at once by the whole
+	// server, health checks and progress streams aside; requests beyond it
+	// get 503. 0 means no limit.
+	MaxInflightRequests int `envvar:"MAX_INFLIGHT_REQUESTS" default:"0"`
+	// AnonymousMaxResolution caps the video height served by the web routes
+	// to users who did not send the AUTH_USERNAME/AUTH_PASSWORD credentials;
+	// authenticated users, and everyone in local mode, are not capped. 0
+	// disables the cap. Browsers log in at /web/login. The JSON API routes
+	// are never capped.
+	AnonymousMaxResolution int `envvar:"ANONYMOUS_MAX_RESOLUTION" default:"480"`
+	// MaxDuration rejects downloads of longer videos and cuts streams of them
+	// short; 0 disables the limit.
+	MaxDuration time.Duration `envvar:"MAX_DURATION" default:"0s"`
+	// AssetsDir holds the images clients may overlay on downloads as
+	// watermarks; watermarks are disabled when it is empty.
+	AssetsDir string `envvar:"ASSETS_DIR"`
+	// RecodeRetries is how many times a recode that failed for a transient
+	// reason, such as a full temp directory, is run again with ffmpeg on the
+	// file already downloaded; 0 disables retries.
+	RecodeRetries int `envvar:"RECODE_RETRIES" default:"2"`
+	// FormatFallbacks lists yt-dlp format selectors, semicolon-separated as
+	// selectors use commas, tried in order when a video download fails over
+	// its format (e.g. YouTube formats yt-dlp cannot fetch); empty disables
+	// the fallbacks.
+	FormatFallbacks string `envvar:"FORMAT_FALLBACKS" default:"best;worst"`
+	// FFmpegLogLevel is the -loglevel passed to the ffmpeg runs of yt-dlp;
+	// empty leaves ffmpeg's own default.
+	FFmpegLogLevel string `envvar:"FFMPEG_LOG_LEVEL" default:"warning"`
+	// OTelEnabled exports OpenTelemetry traces of requests and of their
+	// download stages over OTLP/HTTP to OTelEndpoint (e.g.
+	// http://collector:4318); the exporter's defaults apply when it is empty.
+	OTelEnabled  bool   `envvar:"OTEL_ENABLED" default:"false"`
+	OTelEndpoint string `envvar:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	// Feature flags: the routes of a disabled feature are not registered and
+	// answer 404, e.g. to run a download-only instance.
+	EnableDownload bool `envvar:"ENABLE_DOWNLOAD" default:"true"`
+	EnableStream   bool `envvar:"ENABLE_STREAM" default:"true"`
+	EnableWeb      bool `envvar:"ENABLE_WEB" default:"true"`
 }
 
 // New creates a new Config with values from environment variables.
@@ -49,33 +171,119 @@ func New() (*Config, error) {
 		}
 	}
 
-	// Verify yt-dlp and ffmpeg executables
-	if err := checkExecutable(cfg.YTDLPPath, "yt-dlp", "--version"); err != nil {
+	// Make sure the extra yt-dlp arguments can be parsed before anything runs
+	if _, err := cfg.YTDLPExtraArgs(); err != nil {
 		return nil, err
 	}
-	if err := checkExecutable(cfg.FFMPEGPath, "ffmpeg", "-version"); err != nil {
+	if _, err := cfg.QualityPresets(); err != nil {
 		return nil, err
 	}
 
-	// Verify and prepare download directory
-	absDownloadDir, err := filepath.Abs(cfg.DownloadDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path for download directory '%s': %w", cfg.DownloadDir, err)
+	switch cfg.OnExists {
+	case "unique", "overwrite", "rename", "error":
+	default:
+		return nil, fmt.Errorf("invalid ON_EXISTS '%s': expected unique, overwrite, rename or error", cfg.OnExists)
 	}
-	cfg.DownloadDir = absDownloadDir // Update config with absolute path
 
-	if err := os.MkdirAll(cfg.DownloadDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create download directory '%s': %w", cfg.DownloadDir, err)
+	// Redirects are only allowed to the APP_BASE_URL host, so it must have one
+	if cfg.AppBaseURL != "" {
+		u, err := url.Parse(cfg.AppBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid APP_BASE_URL '%s': expected an absolute http or https URL", cfg.AppBaseURL)
+		}
 	}
 
-	// Check if directory is writable
-	testFile := filepath.Join(cfg.DownloadDir, ".test_write")
-	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
-		return nil, fmt.Errorf("download directory '%s' is not writable: %w", cfg.DownloadDir, err)
+	if cfg.OTelEndpoint != "" {
+		u, err := url.Parse(cfg.OTelEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_ENDPOINT '%s': expected an absolute http or https URL", cfg.OTelEndpoint)
+		}
+	}
+
+	switch cfg.FFmpegLogLevel {
+	case "", "quiet", "panic", "fatal", "error", "warning", "info", "verbose", "debug", "trace":
+	default:
+		return nil, fmt.Errorf("invalid FFMPEG_LOG_LEVEL '%s': expected quiet, panic, fatal, error, warning, info, verbose, debug or trace", cfg.FFmpegLogLevel)
+	}
+
+	if cfg.AssetsDir != "" {
+		if info, err := os.Stat(cfg.AssetsDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid ASSETS_DIR '%s': not a directory", cfg.AssetsDir)
+		}
+	}
+
+	if cfg.CookiesFile != "" {
+		info, err := os.Stat(cfg.CookiesFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid COOKIES_FILE '%s': %w", cfg.CookiesFile, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("invalid COOKIES_FILE '%s': not a regular file", cfg.CookiesFile)
+		}
+	}
+
+	if cfg.MaxConcurrentStreams < 0 {
+		return nil, fmt.Errorf("invalid MAX_CONCURRENT_STREAMS %d: must be 0 (no limit) or more", cfg.MaxConcurrentStreams)
+	}
+	if cfg.MaxConcurrentDownloads < 0 {
+		return nil, fmt.Errorf("invalid MAX_CONCURRENT_DOWNLOADS %d: must be 0 (no limit) or more", cfg.MaxConcurrentDownloads)
+	}
+	if cfg.ConcurrencyWait < 0 {
+		return nil, fmt.Errorf("invalid CONCURRENCY_WAIT %s: must be 0 (no wait) or more", cfg.ConcurrencyWait)
+	}
+
+	if cfg.MaxDuration < 0 {
+		return nil, fmt.Errorf("invalid MAX_DURATION %s: must be 0 (no limit) or more", cfg.MaxDuration)
+	}
+
+	if cfg.RecodeRetries < 0 {
+		return nil, fmt.Errorf("invalid RECODE_RETRIES %d: must be 0 (no retries) or more", cfg.RecodeRetries)
+	}
+
+	if cfg.AnonymousMaxResolution < 0 {
+		return nil, fmt.Errorf("invalid ANONYMOUS_MAX_RESOLUTION %d: must be 0 (no cap) or more", cfg.AnonymousMaxResolution)
+	}
+
+	if cfg.MaxInflightRequests < 0 {
+		return nil, fmt.Errorf("invalid MAX_INFLIGHT_REQUESTS %d: must be 0 (no limit) or more", cfg.MaxInflightRequests)
 	}
-	os.Remove(testFile) // Clean up test file
 
+	if cfg.PlaylistMaxEntries < 1 {
+		return nil, fmt.Errorf("invalid PLAYLIST_MAX_ENTRIES %d: must be at least 1", cfg.PlaylistMaxEntries)
+	}
+	if cfg.InfoBatchConcurrency < 1 {
+		return nil, fmt.Errorf("invalid INFO_BATCH_CONCURRENCY %d: must be at least 1", cfg.InfoBatchConcurrency)
+	}
+
+	// Verify yt-dlp and ffmpeg executables
+	if err := checkExecutable(cfg.YTDLPPath, "yt-dlp", "--version"); err != nil {
+		return nil, err
+	}
+	if err := checkExecutable(cfg.FFMPEGPath, "ffmpeg", "-version"); err != nil {
+		return nil, err
+	}
+	if cfg.ExternalDownloader != "" {
+		if _, err := exec.LookPath(cfg.ExternalDownloader); err != nil {
+			return nil, fmt.Errorf("external downloader '%s' not found: %w", cfg.ExternalDownloader, err)
+		}
+		slog.Info(fmt.Sprintf("Using external downloader %s for file downloads", cfg.ExternalDownloader))
+	}
+
+	// Verify and prepare the download directories, with absolute paths
+	if cfg.DownloadDir, err = prepareDir("download directory", cfg.DownloadDir); err != nil {
+		return nil, err
+	}
 	slog.Info(fmt.Sprintf("Download directory set to: %s", cfg.DownloadDir))
+	if cfg.VideoDir == "" {
+		cfg.VideoDir = cfg.DownloadDir
+	} else if cfg.VideoDir, err = prepareDir("video directory", cfg.VideoDir); err != nil {
+		return nil, err
+	}
+	if cfg.AudioDir == "" {
+		cfg.AudioDir = cfg.DownloadDir
+	} else if cfg.AudioDir, err = prepareDir("audio directory", cfg.AudioDir); err != nil {
+		return nil, err
+	}
 
 	// Configure global logger based on debug mode
 	logLevel := slog.LevelInfo
@@ -89,6 +297,237 @@ func New() (*Config, error) {
 	return &cfg, nil
 }
 
+// prepareDir creates the directory dir, called name in errors, if needed,
+// checks that it is writable and returns its absolute path.
+func prepareDir(name, dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %s '%s': %w", name, dir, err)
+	}
+
+	if err := os.MkdirAll(absDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s '%s': %w", name, absDir, err)
+	}
+
+	// Check if directory is writable
+	testFile := filepath.Join(absDir, ".test_write")
+	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
+		return "", fmt.Errorf("%s '%s' is not writable: %w", name, absDir, err)
+	}
+	os.Remove(testFile) // Clean up test file
+	return absDir, nil
+}
+
+// MediaDirs returns the directories downloads are written to, without
+// duplicates: DownloadDir, VideoDir and AudioDir.
+func (c *Config) MediaDirs() []string {
+	var dirs []string
+	for _, dir := range []string{c.DownloadDir, c.VideoDir, c.AudioDir} {
+		if dir != "" && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Reload reads the configuration again, as on SIGHUP, and returns a copy of
+// c with the settings that can change while the server runs replaced: the
+// concurrency limits and wait, the in-flight, duration and anonymous
+// resolution limits, the extractor and callback host allowlists, info lookup
+// settings, request defaults, the SSE connected event, quality presets, recode
+// retries, format fallbacks, the ffmpeg log level and the feature flags.
+// Everything else, such as credentials, tool paths, directories, the port and
+// timeouts of the server itself, keeps its value from c until a restart. The
+// whole configuration is validated as at startup; on error c stays in effect.
+func (c *Config) Reload() (*Config, error) {
+	next, err := New()
+	if err != nil {
+		return nil, err
+	}
+	reloaded := *c
+	reloaded.MaxConcurrentStreams = next.MaxConcurrentStreams
+	reloaded.MaxConcurrentDownloads = next.MaxConcurrentDownloads
+	reloaded.ConcurrencyWait = next.ConcurrencyWait
+	reloaded.MaxInflightRequests = next.MaxInflightRequests
+	reloaded.MaxDuration = next.MaxDuration
+	reloaded.RecodeRetries = next.RecodeRetries
+	reloaded.FormatFallbacks = next.FormatFallbacks
+	reloaded.AnonymousMaxResolution = next.AnonymousMaxResolution
+	reloaded.AllowedExtractors = next.AllowedExtractors
+	reloaded.CallbackAllowedHosts = next.CallbackAllowedHosts
+	reloaded.InfoTimeout = next.InfoTimeout
+	reloaded.InfoBatchConcurrency = next.InfoBatchConcurrency
+	reloaded.PlaylistMaxEntries = next.PlaylistMaxEntries
+	reloaded.OnExists = next.OnExists
+	reloaded.StrictJSON = next.StrictJSON
+	reloaded.SSEConnectedEvent = next.SSEConnectedEvent
+	reloaded.Presets = next.Presets
+	reloaded.FFmpegLogLevel = next.FFmpegLogLevel
+	reloaded.EnableDownload = next.EnableDownload
+	reloaded.EnableStream = next.EnableStream
+	reloaded.EnableWeb = next.EnableWeb
+	return &reloaded, nil
+}
+
+// YTDLPExtraArgs splits ExtraYTDLPArgs into individual arguments,
+// honouring shell-like quoting. It returns nil when no extra args are set.
+func (c *Config) YTDLPExtraArgs() ([]string, error) {
+	if c.ExtraYTDLPArgs == "" {
+		return nil, nil
+	}
+	args, err := shlex.Split(c.ExtraYTDLPArgs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YTDLP_EXTRA_ARGS '%s': %w", c.ExtraYTDLPArgs, err)
+	}
+	return args, nil
+}
+
+// QualityPreset is a named bundle of quality settings that clients can ask
+// for instead of spelling each one out. Empty fields leave the request's
+// own value, or the default.
+type QualityPreset struct {
+	Resolution   string `json:"resolution,omitempty"`   // Video height, e.g. "720"
+	Codec        string `json:"codec,omitempty"`        // Video codec, e.g. "avc1"
+	Format       string `json:"format,omitempty"`       // Video container, e.g. "mp4"
+	AudioFormat  string `json:"audioFormat,omitempty"`  // Audio output format, e.g. "mp3"
+	AudioBitrate string `json:"audioBitrate,omitempty"` // Audio bitrate, e.g. "128k"
+}
+
+// defaultQualityPresets are the presets available without QUALITY_PRESETS.
+var defaultQualityPresets = map[string]QualityPreset{
+	"mobile": {Resolution: "360", Codec: "avc1", Format: "mp4", AudioFormat: "mp3", AudioBitrate: "64k"},
+	"sd":     {Resolution: "480", Codec: "avc1", Format: "mp4", AudioFormat: "mp3", AudioBitrate: "128k"},
+	"hd":     {Resolution: "720", Codec: "avc1", Format: "mp4", AudioFormat: "mp3", AudioBitrate: "192k"},
+	"fhd":    {Resolution: "1080", Codec: "avc1", Format: "mp4", AudioFormat: "mp3", AudioBitrate: "320k"},
+}
+
+// QualityPresets returns the quality presets by name: the built-in mobile,
+// sd, hd and fhd, with those of QUALITY_PRESETS added or replacing them.
+func (c *Config) QualityPresets() (map[string]QualityPreset, error) {
+	presets := make(map[string]QualityPreset, len(defaultQualityPresets))
+	for name, preset := range defaultQualityPresets {
+		presets[name] = preset
+	}
+	if c.Presets == "" {
+		return presets, nil
+	}
+	var custom map[string]QualityPreset
+	if err := json.Unmarshal([]byte(c.Presets), &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse QUALITY_PRESETS: %w", err)
+	}
+	for name, preset := range custom {
+		if name == "" {
+			return nil, errors.New("invalid QUALITY_PRESETS: preset names cannot be empty")
+		}
+		presets[name] = preset
+	}
+	return presets, nil
+}
+
+// QualityPresetNames returns the names of the quality presets, sorted.
+func (c *Config) QualityPresetNames() []string {
+	presets, _ := c.QualityPresets()
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// RedirectHosts returns the hosts redirects may point to: the APP_BASE_URL
+// host, if set, followed by TRUSTED_REDIRECT_HOSTS, all lowercased.
+func (c *Config) RedirectHosts() []string {
+	var hosts []string
+	if u, err := url.Parse(c.AppBaseURL); err == nil && u.Hostname() != "" {
+		hosts = append(hosts, strings.ToLower(u.Hostname()))
+	}
+	for _, host := range strings.Split(c.TrustedRedirectHosts, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// ExtractorAllowlist returns the extractors of ALLOWED_EXTRACTORS,
+// lowercased, or nil when every extractor is allowed.
+func (c *Config) ExtractorAllowlist() []string {
+	var extractors []string
+	for _, extractor := range strings.Split(c.AllowedExtractors, ",") {
+		if extractor = strings.ToLower(strings.TrimSpace(extractor)); extractor != "" {
+			extractors = append(extractors, extractor)
+		}
+	}
+	return extractors
+}
+
+// CallbackAllowlist returns the hosts of CALLBACK_ALLOWED_HOSTS, lowercased.
+func (c *Config) CallbackAllowlist() []string {
+	var hosts []string
+	for _, host := range strings.Split(c.CallbackAllowedHosts, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// FormatFallbackChain returns the format selectors of FormatFallbacks, in
+// order.
+func (c *Config) FormatFallbackChain() []string {
+	var selectors []string
+	for _, selector := range strings.Split(c.FormatFallbacks, ";") {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+	return selectors
+}
+
+// redactedPlaceholder replaces the value of secret settings in Redacted.
+const redactedPlaceholder = "[REDACTED]"
+
+// secretEnvVars are settings never shown by Redacted. YTDLP_EXTRA_ARGS is
+// included because proxy flags in it often embed credentials.
+var secretEnvVars = map[string]bool{
+	"AUTH_PASSWORD":    true,
+	"YTDLP_EXTRA_ARGS": true,
+}
+
+// Redacted returns the effective configuration keyed by environment variable,
+// with the values of secret settings replaced. Unset secrets stay empty so
+// operators can still tell whether they are configured.
+func (c *Config) Redacted() map[string]string {
+	values := make(map[string]string)
+	v := reflect.ValueOf(*c)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("envvar")
+		if name == "" {
+			continue
+		}
+		value := fmt.Sprint(v.Field(i).Interface())
+		if isSecretEnvVar(name) && value != "" {
+			value = redactedPlaceholder
+		}
+		values[name] = value
+	}
+	return values
+}
+
+// isSecretEnvVar reports whether the setting named by an env var is secret.
+func isSecretEnvVar(name string) bool {
+	if secretEnvVars[name] {
+		return true
+	}
+	for _, marker := range []string{"PASSWORD", "SECRET", "TOKEN", "API_KEY"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // checkExecutable verifies if an executable exists and is runnable.
 func checkExecutable(path, name, versionCmd string) error {
 	cmd := exec.Command(path, versionCmd) // Use --version to check if it's runnable
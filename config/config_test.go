@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -268,6 +269,13 @@ func TestAppURL(t *testing.T) {
 		assert.Equal(t, customURL, cfg.AppBaseURL, "Expected AppURL to be the custom value")
 	})
 
+	t.Run("InvalidAppURL", func(t *testing.T) {
+		os.Setenv("APP_BASE_URL", "my.custom.domain/gsp")
+		_, err := New()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid APP_BASE_URL")
+	})
+
 	t.Run("EmptyAppURL", func(t *testing.T) {
 		os.Setenv("APP_BASE_URL", "") // Test empty string, should fall back to default
 		cfg, err := New()
@@ -275,3 +283,443 @@ func TestAppURL(t *testing.T) {
 		assert.Empty(t, cfg.AppBaseURL, "Expected AppURL to fall back to default when empty")
 	})
 }
+
+func TestRedirectHosts(t *testing.T) {
+	cfg := &Config{AppBaseURL: "https://My.Custom.Domain:8443/gsp", TrustedRedirectHosts: " cdn.example , ,Auth.Example"}
+	assert.Equal(t, []string{"my.custom.domain", "cdn.example", "auth.example"}, cfg.RedirectHosts())
+
+	assert.Empty(t, (&Config{}).RedirectHosts())
+}
+
+func TestExtractorAllowlist(t *testing.T) {
+	cfg := &Config{AllowedExtractors: " YouTube, ,youtube:tab"}
+	assert.Equal(t, []string{"youtube", "youtube:tab"}, cfg.ExtractorAllowlist())
+
+	assert.Nil(t, (&Config{}).ExtractorAllowlist())
+}
+
+func TestCallbackAllowlist(t *testing.T) {
+	cfg := &Config{CallbackAllowedHosts: " Hooks.Internal, ,10.0.0.5"}
+	assert.Equal(t, []string{"hooks.internal", "10.0.0.5"}, cfg.CallbackAllowlist())
+
+	assert.Nil(t, (&Config{}).CallbackAllowlist())
+}
+
+func TestFormatFallbackChain(t *testing.T) {
+	cfg := &Config{FormatFallbacks: " bv*+ba/b ; ;best[height<=480],worst"}
+	assert.Equal(t, []string{"bv*+ba/b", "best[height<=480],worst"}, cfg.FormatFallbackChain())
+
+	assert.Nil(t, (&Config{}).FormatFallbackChain())
+}
+
+func TestExtraYTDLPArgs(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	t.Run("QuotedArgs", func(t *testing.T) {
+		t.Setenv("YTDLP_EXTRA_ARGS", `--user-agent "Mozilla/5.0 (X11)" --force-ipv4`)
+		cfg, err := New()
+		assert.NoError(t, err)
+
+		args, err := cfg.YTDLPExtraArgs()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"--user-agent", "Mozilla/5.0 (X11)", "--force-ipv4"}, args)
+	})
+
+	t.Run("UnterminatedQuote", func(t *testing.T) {
+		t.Setenv("YTDLP_EXTRA_ARGS", `--user-agent "Mozilla`)
+		_, err := New()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse YTDLP_EXTRA_ARGS")
+	})
+}
+
+func TestServerTimeouts(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, err := New()
+		assert.NoError(t, err)
+		assert.Equal(t, 30*time.Second, cfg.ReadTimeout)
+		assert.Equal(t, time.Duration(0), cfg.WriteTimeout, "WriteTimeout must default to 0 to keep streams alive")
+		assert.Equal(t, 120*time.Second, cfg.IdleTimeout)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		t.Setenv("READ_TIMEOUT", "10s")
+		t.Setenv("WRITE_TIMEOUT", "1h")
+		t.Setenv("IDLE_TIMEOUT", "1m")
+		cfg, err := New()
+		assert.NoError(t, err)
+		assert.Equal(t, 10*time.Second, cfg.ReadTimeout)
+		assert.Equal(t, time.Hour, cfg.WriteTimeout)
+		assert.Equal(t, time.Minute, cfg.IdleTimeout)
+	})
+}
+
+func TestOnExists(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, "unique", cfg.OnExists)
+
+	t.Setenv("ON_EXISTS", "rename")
+	cfg, err = New()
+	assert.NoError(t, err)
+	assert.Equal(t, "rename", cfg.OnExists)
+
+	t.Setenv("ON_EXISTS", "skip")
+	_, err = New()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid ON_EXISTS")
+}
+
+func TestFFmpegLogLevel(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, "warning", cfg.FFmpegLogLevel)
+
+	t.Setenv("FFMPEG_LOG_LEVEL", "loud")
+	_, err = New()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid FFMPEG_LOG_LEVEL")
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+	t.Setenv("MAX_CONCURRENT_STREAMS", "4")
+	t.Setenv("MAX_CONCURRENT_DOWNLOADS", "2")
+	t.Setenv("CONCURRENCY_WAIT", "30s")
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cfg.MaxConcurrentStreams)
+	assert.Equal(t, 2, cfg.MaxConcurrentDownloads)
+	assert.Equal(t, 30*time.Second, cfg.ConcurrencyWait)
+
+	t.Setenv("CONCURRENCY_WAIT", "-1s")
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid CONCURRENCY_WAIT")
+
+	t.Setenv("CONCURRENCY_WAIT", "0s")
+	t.Setenv("MAX_CONCURRENT_DOWNLOADS", "-1")
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid MAX_CONCURRENT_DOWNLOADS")
+}
+
+func TestAssetsDir(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	assets := t.TempDir()
+	t.Setenv("ASSETS_DIR", assets)
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, assets, cfg.AssetsDir)
+
+	t.Setenv("ASSETS_DIR", filepath.Join(assets, "missing"))
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid ASSETS_DIR")
+}
+
+func TestMediaDirs(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	downloads := t.TempDir()
+	t.Setenv("DOWNLOAD_DIR", downloads)
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, downloads, cfg.VideoDir)
+	assert.Equal(t, downloads, cfg.AudioDir)
+	assert.Equal(t, []string{downloads}, cfg.MediaDirs())
+
+	videos := filepath.Join(t.TempDir(), "videos")
+	audio := filepath.Join(t.TempDir(), "audio")
+	t.Setenv("VIDEO_DIR", videos)
+	t.Setenv("AUDIO_DIR", audio)
+	cfg, err = New()
+	assert.NoError(t, err)
+	assert.Equal(t, videos, cfg.VideoDir)
+	assert.Equal(t, audio, cfg.AudioDir)
+	assert.DirExists(t, videos, "VIDEO_DIR should be created at startup")
+	assert.DirExists(t, audio, "AUDIO_DIR should be created at startup")
+	assert.Equal(t, []string{downloads, videos, audio}, cfg.MediaDirs())
+
+	blocker := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(blocker, nil, 0644))
+	t.Setenv("AUDIO_DIR", filepath.Join(blocker, "audio"))
+	_, err = New()
+	assert.ErrorContains(t, err, "failed to create audio directory")
+}
+
+func TestCookiesFile(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.CookiesFile)
+
+	cookies := filepath.Join(t.TempDir(), "cookies.txt")
+	assert.NoError(t, os.WriteFile(cookies, []byte("# Netscape HTTP Cookie File\n"), 0600))
+	t.Setenv("COOKIES_FILE", cookies)
+	cfg, err = New()
+	assert.NoError(t, err)
+	assert.Equal(t, cookies, cfg.CookiesFile)
+
+	t.Setenv("COOKIES_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid COOKIES_FILE")
+	assert.ErrorContains(t, err, "no such file or directory")
+
+	t.Setenv("COOKIES_FILE", t.TempDir())
+	_, err = New()
+	assert.ErrorContains(t, err, "not a regular file")
+}
+
+func TestFeatureFlags(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.True(t, cfg.EnableDownload)
+	assert.True(t, cfg.EnableStream)
+	assert.True(t, cfg.EnableWeb)
+
+	t.Setenv("ENABLE_STREAM", "false")
+	cfg, err = New()
+	assert.NoError(t, err)
+	assert.True(t, cfg.EnableDownload)
+	assert.False(t, cfg.EnableStream)
+	assert.True(t, cfg.EnableWeb)
+}
+
+func TestPlaylistMaxEntries(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, 200, cfg.PlaylistMaxEntries)
+
+	t.Setenv("PLAYLIST_MAX_ENTRIES", "0")
+	_, err = New()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid PLAYLIST_MAX_ENTRIES")
+}
+
+func TestExternalDownloader(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	t.Setenv("YTDLP_EXTERNAL_DOWNLOADER", "echo")
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, "echo", cfg.ExternalDownloader)
+	assert.Equal(t, "-x16 -s16", cfg.ExternalDownloaderArgs)
+
+	t.Setenv("YTDLP_EXTERNAL_DOWNLOADER", "/nonexistent/aria2c")
+	_, err = New()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "external downloader")
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		Port:         "8080",
+		AuthUsername: "admin",
+		AuthPassword: "hunter2",
+		ReadTimeout:  30 * time.Second,
+	}
+
+	values := cfg.Redacted()
+	assert.Equal(t, "[REDACTED]", values["AUTH_PASSWORD"])
+	assert.Equal(t, "", values["YTDLP_EXTRA_ARGS"], "unset secrets stay empty")
+	assert.Equal(t, "admin", values["AUTH_USERNAME"])
+	assert.Equal(t, "8080", values["PORT"])
+	assert.Equal(t, "30s", values["READ_TIMEOUT"])
+	assert.Contains(t, values, "DOWNLOAD_DIR")
+}
+
+func TestRecodeRetries(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cfg.RecodeRetries)
+
+	t.Setenv("RECODE_RETRIES", "0")
+	cfg, err = New()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.RecodeRetries)
+
+	t.Setenv("RECODE_RETRIES", "-1")
+	_, err = New()
+	assert.ErrorContains(t, err, "RECODE_RETRIES")
+}
+
+func TestMaxDuration(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.MaxDuration)
+
+	t.Setenv("MAX_DURATION", "2h")
+	cfg, err = New()
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, cfg.MaxDuration)
+
+	t.Setenv("MAX_DURATION", "-1m")
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid MAX_DURATION")
+}
+
+func TestOTelEndpoint(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	t.Setenv("OTEL_ENABLED", "true")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.True(t, cfg.OTelEnabled)
+	assert.Equal(t, "http://collector:4318", cfg.OTelEndpoint)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "collector:4318")
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid OTEL_EXPORTER_OTLP_ENDPOINT")
+}
+
+func TestMaxInflightRequests(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+	t.Setenv("MAX_INFLIGHT_REQUESTS", "64")
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, 64, cfg.MaxInflightRequests)
+
+	t.Setenv("MAX_INFLIGHT_REQUESTS", "-1")
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid MAX_INFLIGHT_REQUESTS")
+}
+
+func TestInfoBatchConcurrency(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cfg.InfoBatchConcurrency)
+
+	t.Setenv("INFO_BATCH_CONCURRENCY", "0")
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid INFO_BATCH_CONCURRENCY")
+}
+
+func TestQualityPresets(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+	t.Setenv("QUALITY_PRESETS", `{"hd":{"resolution":"720","codec":"vp9","format":"webm"},"radio":{"audioFormat":"opus","audioBitrate":"96k"}}`)
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	presets, err := cfg.QualityPresets()
+	assert.NoError(t, err)
+	assert.Equal(t, QualityPreset{Resolution: "360", Codec: "avc1", Format: "mp4", AudioFormat: "mp3", AudioBitrate: "64k"}, presets["mobile"])
+	assert.Equal(t, QualityPreset{Resolution: "720", Codec: "vp9", Format: "webm"}, presets["hd"])
+	assert.Equal(t, QualityPreset{AudioFormat: "opus", AudioBitrate: "96k"}, presets["radio"])
+	assert.Equal(t, []string{"fhd", "hd", "mobile", "radio", "sd"}, cfg.QualityPresetNames())
+
+	t.Setenv("QUALITY_PRESETS", `{"hd":"720p"}`)
+	_, err = New()
+	assert.ErrorContains(t, err, "failed to parse QUALITY_PRESETS")
+}
+
+func TestReload(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+	t.Setenv("MAX_CONCURRENT_STREAMS", "2")
+
+	cfg, err := New()
+	assert.NoError(t, err)
+
+	// Limits and flags are picked up, paths need a restart
+	t.Setenv("MAX_CONCURRENT_STREAMS", "5")
+	t.Setenv("ENABLE_WEB", "false")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+	t.Setenv("YTDLP_PATH", "true")
+	reloaded, err := cfg.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, reloaded.MaxConcurrentStreams)
+	assert.False(t, reloaded.EnableWeb)
+	assert.Equal(t, cfg.DownloadDir, reloaded.DownloadDir)
+	assert.Equal(t, "echo", reloaded.YTDLPPath)
+	assert.Equal(t, 2, cfg.MaxConcurrentStreams, "the current config must not change")
+
+	t.Setenv("MAX_CONCURRENT_STREAMS", "-1")
+	_, err = cfg.Reload()
+	assert.ErrorContains(t, err, "invalid MAX_CONCURRENT_STREAMS")
+}
+
+func TestAnonymousMaxResolution(t *testing.T) {
+	t.Setenv("LOCAL_MODE", "true")
+	t.Setenv("YTDLP_PATH", "echo")
+	t.Setenv("FFMPEG_PATH", "echo")
+	t.Setenv("DOWNLOAD_DIR", t.TempDir())
+
+	cfg, err := New()
+	assert.NoError(t, err)
+	assert.Equal(t, 480, cfg.AnonymousMaxResolution)
+
+	t.Setenv("ANONYMOUS_MAX_RESOLUTION", "-1")
+	_, err = New()
+	assert.ErrorContains(t, err, "invalid ANONYMOUS_MAX_RESOLUTION")
+}
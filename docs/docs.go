@@ -39,9 +39,148 @@ const docTemplate = `{
                 }
             }
         },
+        "/admin/cache": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Removes all entries from the video info cache so the next requests re-run yt-dlp.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Clear the cache",
+                "responses": {
+                    "200": {
+                        "description": "Cache cleared",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CacheResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/cache/prune": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Removes expired entries from the video info cache and reports how many were removed and how many remain.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Prune expired cache entries",
+                "responses": {
+                    "200": {
+                        "description": "Expired entries removed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CacheResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/config": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Returns the running instance's configuration, keyed by environment variable, with secrets redacted, plus the yt-dlp and ffmpeg versions in use.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Show effective configuration",
+                "responses": {
+                    "200": {
+                        "description": "Effective configuration",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ConfigResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/download/archive": {
+            "post": {
+                "description": "Writes the video's .info.json and thumbnail to the server's download directory without downloading the media, for cataloging.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Archive video metadata",
+                "parameters": [
+                    {
+                        "description": "Video archive request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.GetVideoInfoRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Metadata archived successfully",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ArchiveResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or missing URL",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error during archiving",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/download/audio": {
             "post": {
-                "description": "Downloads an audio file from a given URL to the server's download directory.",
+                "description": "Downloads an audio file from a given URL to the server's download directory. Set podcast for a 64k mono mp3 with normalized loudness and embedded ID3 tags, chapters and cover art; explicit format, codec, bitrate and channels override the preset. Set sampleFormat (s16le, s24le or f32le) for uncompressed PCM in a wav file, e.g. for audio-processing pipelines.",
                 "consumes": [
                     "application/json"
                 ],
@@ -71,7 +210,25 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request payload or missing URL",
+                        "description": "Invalid request payload, missing URL, unknown preset or onExists strategy, invalid silence settings, volume out of range, codec not valid for the output format, unknown sample format or one combined with another format or codec, invalid progress callback URL or invalid expected SHA-256",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "File already exists and onExists is error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Video is longer than MAX_DURATION, or the file does not match expectedSha256 (it is deleted)",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -81,6 +238,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Too many concurrent downloads (MAX_CONCURRENT_DOWNLOADS)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -132,9 +295,68 @@ const docTemplate = `{
                 }
             }
         },
+        "/download/audio/{filename}/waveform": {
+            "get": {
+                "description": "Returns the waveform of an audio file in the download directory as peak levels between 0 and 1, one per slice of the file, for drawing a scrubber. The file is decoded with ffmpeg, taking one of the MAX_CONCURRENT_DOWNLOADS slots, and results are cached until the file changes.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Get a downloaded audio file's waveform",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filename of the downloaded audio",
+                        "name": "filename",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of peaks to return (1 to 10000, default 800)",
+                        "name": "points",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Waveform peaks",
+                        "schema": {
+                            "$ref": "#/definitions/handler.WaveformResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing filename or invalid points",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent downloads (MAX_CONCURRENT_DOWNLOADS)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/download/delete/{filename}": {
             "delete": {
-                "description": "Deletes a file from the server's download directory given its filename.",
+                "description": "Deletes a file from the server's download directory given its filename, along with the storyboard sprites generated for it.",
                 "produces": [
                     "application/json"
                 ],
@@ -181,7 +403,7 @@ const docTemplate = `{
         },
         "/download/list": {
             "get": {
-                "description": "Lists all files present in the server's configured download directory.",
+                "description": "Lists the files present in the server's configured download directories (DOWNLOAD_DIR, VIDEO_DIR and AUDIO_DIR), including their YYYY/MM/DD subfolders when ORGANIZE_BY_DATE is on. type=video or type=audio lists only VIDEO_DIR or AUDIO_DIR, which are the download directory unless configured. Files are sorted by name, size or date (modification time), ties broken by name, and paginated with limit and offset; total counts every file.",
                 "produces": [
                     "application/json"
                 ],
@@ -189,6 +411,38 @@ const docTemplate = `{
                     "download"
                 ],
                 "summary": "List downloaded files",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Media type: video or audio; all by default",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort key: name (default), size or date",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order: asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Files per page; 0 or absent for all",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Files to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Successfully listed downloaded files",
@@ -196,6 +450,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ListDownloadedFilesResponse"
                         }
                     },
+                    "400": {
+                        "description": "Invalid type, sort, order, limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error during file listing",
                         "schema": {
@@ -205,45 +465,52 @@ const docTemplate = `{
                 }
             }
         },
-        "/download/video": {
-            "post": {
-                "description": "Downloads a video from a given URL to the server's download directory.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/download/playlist/info": {
+            "get": {
+                "description": "Lists the title and entries (id, title, duration, url) of a playlist without downloading or extracting each video. At most PLAYLIST_MAX_ENTRIES entries are returned; truncated is set when there are more.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "download"
                 ],
-                "summary": "Download a video",
+                "summary": "Get playlist information",
                 "parameters": [
                     {
-                        "description": "Video download request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handler.DownloadVideoRequest"
-                        }
+                        "type": "string",
+                        "description": "Playlist URL",
+                        "name": "url",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Video downloaded successfully",
+                        "description": "Playlist information retrieved successfully",
                         "schema": {
-                            "$ref": "#/definitions/handler.DownloadVideoResponse"
+                            "$ref": "#/definitions/handler.GetPlaylistInfoResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request payload or missing URL",
+                        "description": "Missing URL",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "The playlist's extractor is not in ALLOWED_EXTRACTORS",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error during video download",
+                        "description": "Internal server error during playlist info retrieval",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "yt-dlp took longer than INFO_TIMEOUT",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -251,9 +518,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/download/video/info": {
+        "/download/schedule": {
             "post": {
-                "description": "Retrieves metadata for a video from a given URL without downloading the file.",
+                "description": "Queues a video download, with the options of /download/video, to start at startAt, e.g. off-peak. The options are checked right away; the download itself runs in the background, waiting for a free MAX_CONCURRENT_DOWNLOADS slot if needed, and its outcome is read from /download/schedule/{id}. Jobs are kept in memory, so those still waiting are lost on restart, and finished ones are forgotten after an hour.",
                 "consumes": [
                     "application/json"
                 ],
@@ -263,33 +530,33 @@ const docTemplate = `{
                 "tags": [
                     "download"
                 ],
-                "summary": "Get video information",
+                "summary": "Schedule a video download",
                 "parameters": [
                     {
-                        "description": "Video info request",
+                        "description": "Scheduled download request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handler.GetVideoInfoRequest"
+                            "$ref": "#/definitions/handler.ScheduleDownloadRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Video information retrieved successfully",
+                    "202": {
+                        "description": "Download scheduled",
                         "schema": {
-                            "$ref": "#/definitions/handler.GetVideoInfoResponse"
+                            "$ref": "#/definitions/handler.ScheduledJobResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request payload or missing URL",
+                        "description": "Invalid request payload, missing URL or startAt, startAt more than 7 days ahead, or invalid download options",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error during video info retrieval",
+                    "429": {
+                        "description": "Too many scheduled downloads pending (100)",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -297,136 +564,237 @@ const docTemplate = `{
                 }
             }
         },
-        "/download/video/{filename}": {
+        "/download/schedule/{id}": {
             "get": {
-                "description": "Serves a video file from the server's download directory given its filename.",
+                "description": "Returns the state of a scheduled download: scheduled, running, done (with its file paths) or failed (with the error), or cancelled.",
                 "produces": [
-                    "video/mp4"
+                    "application/json"
                 ],
                 "tags": [
                     "download"
                 ],
-                "summary": "Serve a downloaded video file",
+                "summary": "Get a scheduled download",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Filename of the video to serve",
-                        "name": "filename",
+                        "description": "Job ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully served video file",
-                        "schema": {
-                            "type": "file"
-                        }
-                    },
-                    "400": {
-                        "description": "Missing filename",
+                        "description": "Job state",
                         "schema": {
-                            "$ref": "#/definitions/handler.ErrorResponse"
+                            "$ref": "#/definitions/handler.ScheduledJobResponse"
                         }
                     },
                     "404": {
-                        "description": "File not found",
-                        "schema": {
-                            "$ref": "#/definitions/handler.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
+                        "description": "Unknown job",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     }
                 }
-            }
-        },
-        "/load-info": {
-            "post": {
-                "description": "Receives a video URL, fetches its metadata, and redirects the user to the main streaming/downloading page with the info pre-populated.",
-                "consumes": [
-                    "application/x-www-form-urlencoded"
+            },
+            "delete": {
+                "description": "Cancels a scheduled download before its start time. Downloads already started cannot be cancelled.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "download"
                 ],
+                "summary": "Cancel a scheduled download",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ScheduledJobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown job",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Job already started",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/download/subtitles/list": {
+            "get": {
+                "description": "Returns the subtitle languages a video offers, split between manual subtitles and automatic captions, to choose a subtitleLang before downloading. Manual subtitles win over automatic captions of the same language. language is the video's own, which \"auto\" stands for.",
                 "produces": [
-                    "text/html"
+                    "application/json"
                 ],
                 "tags": [
-                    "web"
+                    "download"
                 ],
-                "summary": "Load video information and redirect to stream page",
+                "summary": "List available subtitle languages",
                 "parameters": [
                     {
                         "type": "string",
                         "description": "Video URL",
                         "name": "url",
-                        "in": "formData",
+                        "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "302": {
-                        "description": "Redirect to /web with video info",
+                    "200": {
+                        "description": "Available subtitle languages",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ListSubtitlesResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Missing URL",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error during video info retrieval",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "yt-dlp took longer than INFO_TIMEOUT",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/stream/audio": {
+        "/download/supported": {
+            "get": {
+                "description": "Runs a lightweight yt-dlp simulation to tell whether the URL is downloadable and by which extractor. URLs from extractors outside ALLOWED_EXTRACTORS are reported as not supported, with a reason.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Check whether a URL is supported",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video URL",
+                        "name": "url",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Support check result",
+                        "schema": {
+                            "$ref": "#/definitions/handler.SupportedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing URL",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "yt-dlp could not be run",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/download/video": {
             "post": {
-                "description": "Streams an audio file directly from the source URL.",
+                "description": "Downloads a video from a given URL to the server's download directory. With separateAudio, the best video-only and audio-only formats are saved as two files without merging them: filePath is the video and audioFilePath the audio.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "audio/mpeg"
+                    "application/json"
                 ],
                 "tags": [
-                    "stream"
+                    "download"
                 ],
-                "summary": "Stream an audio file",
+                "summary": "Download a video",
                 "parameters": [
                     {
-                        "description": "Audio stream request",
+                        "description": "Video download request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handler.StreamAudioRequest"
+                            "$ref": "#/definitions/handler.DownloadVideoRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully streamed audio",
+                        "description": "Video downloaded successfully",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/handler.DownloadVideoResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request payload or missing URL",
+                        "description": "Invalid request payload, missing URL, unknown preset, format sort field or onExists strategy, an invalid format ID, a format that cannot hold all audio languages, an invalid progress callback URL, an invalid watermark, target size or expected SHA-256, separateAudio with an option needing a merged file, or with strictQuality a resolution or codec the video does not offer",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "File already exists and onExists is error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Video is longer than MAX_DURATION, or the file does not match expectedSha256 (it is deleted)",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error during audio streaming",
+                        "description": "Internal server error during video download",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent downloads (MAX_CONCURRENT_DOWNLOADS)",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -434,35 +802,35 @@ const docTemplate = `{
                 }
             }
         },
-        "/stream/video": {
+        "/download/video/info": {
             "post": {
-                "description": "Streams a video directly from the source URL.",
+                "description": "Retrieves metadata for a video from a given URL without downloading the file. With light, only the title, duration, thumbnail, uploader and upload date are fetched, skipping the slow resolution of every format; formats and subtitles are then left out.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "video/mp4"
+                    "application/json"
                 ],
                 "tags": [
-                    "stream"
+                    "download"
                 ],
-                "summary": "Stream a video",
+                "summary": "Get video information",
                 "parameters": [
                     {
-                        "description": "Video stream request",
+                        "description": "Video info request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handler.StreamVideoRequest"
+                            "$ref": "#/definitions/handler.GetVideoInfoRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully streamed video",
+                        "description": "Video information retrieved successfully",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/handler.GetVideoInfoResponse"
                         }
                     },
                     "400": {
@@ -471,8 +839,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
                     "500": {
-                        "description": "Internal server error during video streaming",
+                        "description": "Internal server error during video info retrieval",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "yt-dlp took longer than INFO_TIMEOUT",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -480,234 +860,325 @@ const docTemplate = `{
                 }
             }
         },
-        "/web": {
-            "get": {
-                "description": "Serves an HTML page that displays video information and allows streaming/downloading.",
+        "/download/video/info/batch": {
+            "post": {
+                "description": "Retrieves metadata for up to 100 URLs, fetching INFO_BATCH_CONCURRENCY of them at once and reusing the info cache. A URL that fails does not fail the request: its result carries the error instead of the info. Results are in the order of the request.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
-                    "text/html"
+                    "application/json"
                 ],
                 "tags": [
-                    "web"
+                    "download"
                 ],
-                "summary": "Serve web streaming page with video info",
+                "summary": "Get information for several videos",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Video URL",
-                        "name": "url",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Unique ID for the operation to track",
-                        "name": "progressID",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "JSON string of VideoInfo",
-                        "name": "videoInfo",
-                        "in": "query",
-                        "required": true
+                        "description": "Batch video info request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.BatchVideoInfoRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "HTML page for video streaming",
+                        "description": "Video information retrieved, possibly with per-URL errors",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.BatchVideoInfoResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid request payload, no URLs, an empty URL or more than 100 URLs",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/web/download/audio": {
+        "/download/video/info/raw": {
             "get": {
-                "description": "Streams audio content directly to the browser, triggering a download.",
+                "description": "Returns yt-dlp's --dump-json output for a video unchanged, with every field the extractor provides. Unlike /download/video/info it is never served from the info cache.",
                 "produces": [
-                    "audio/mpeg"
+                    "application/json"
                 ],
                 "tags": [
-                    "web"
+                    "download"
                 ],
-                "summary": "Download audio to browser",
+                "summary": "Get raw yt-dlp video information",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Audio URL",
+                        "description": "Video URL",
                         "name": "url",
                         "in": "query",
                         "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Output format (e.g., mp3, aac)",
-                        "name": "outputFormat",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Audio Codec (e.g., libmp3lame)",
-                        "name": "codec",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Audio Bitrate (e.g., 128k)",
-                        "name": "bitrate",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Unique ID for progress tracking",
-                        "name": "progressID",
-                        "in": "query",
-                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully streamed audio for download",
+                        "description": "Raw yt-dlp info JSON",
                         "schema": {
-                            "type": "file"
+                            "type": "object"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Missing URL",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error during video info retrieval",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "yt-dlp took longer than INFO_TIMEOUT",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/web/download/video": {
+        "/download/video/{filename}": {
             "get": {
-                "description": "Streams video content directly to the browser, triggering a download.",
+                "description": "Serves a video file from the server's download directory given its filename.",
                 "produces": [
                     "video/mp4"
                 ],
                 "tags": [
-                    "web"
+                    "download"
                 ],
-                "summary": "Download video to browser",
+                "summary": "Serve a downloaded video file",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Video URL",
-                        "name": "url",
-                        "in": "query",
+                        "description": "Filename of the video to serve",
+                        "name": "filename",
+                        "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully served video file",
+                        "schema": {
+                            "type": "file"
+                        }
                     },
-                    {
-                        "type": "string",
-                        "description": "Video Resolution (e.g., 720, 1080)",
-                        "name": "resolution",
-                        "in": "query"
+                    "400": {
+                        "description": "Missing filename",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
                     },
-                    {
-                        "type": "string",
-                        "description": "Video Codec (e.g., avc1, vp9)",
-                        "name": "codec",
-                        "in": "query"
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
                     },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/download/video/{filename}/chapters.vtt": {
+            "get": {
+                "description": "Returns the chapters of a video in the download directory as a WebVTT chapters track. Chapters come from the yt-dlp .info.json sidecar next to the file (as written by /download/archive) or, failing that, from markers embedded in the file.",
+                "produces": [
+                    "text/vtt"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Get a downloaded video's chapters",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "Unique ID for progress tracking",
-                        "name": "progressID",
-                        "in": "query",
+                        "description": "Filename of the downloaded video",
+                        "name": "filename",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully streamed video for download",
+                        "description": "WebVTT chapters track",
                         "schema": {
-                            "type": "file"
+                            "type": "string"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Missing filename",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found or video has no chapters",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/web/play": {
+        "/download/video/{filename}/storyboard.vtt": {
             "get": {
-                "description": "Streams the video content directly to the browser based on query parameters.",
+                "description": "Returns a WebVTT thumbnails track for a video in the download directory. Each cue covers one interval and points to its thumbnail in a sprite image, with a #xywh= fragment; the sprite URL is relative to this track. The interval is rounded up to 1, 2, 5, 10, 15, 30 or 60 seconds. The sprite is generated with ffmpeg on first request, taking one of the MAX_CONCURRENT_DOWNLOADS slots, and kept next to the video until the video is deleted. For long videos the interval is stretched so a sprite holds at most 400 thumbnails.",
                 "produces": [
-                    "video/mp4"
+                    "text/vtt"
                 ],
                 "tags": [
-                    "web"
+                    "download"
                 ],
-                "summary": "Play web stream",
+                "summary": "Get a downloaded video's storyboard",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Video URL",
-                        "name": "url",
-                        "in": "query",
+                        "description": "Filename of the downloaded video",
+                        "name": "filename",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Video Resolution (e.g., 720, 1080)",
-                        "name": "resolution",
+                        "type": "integer",
+                        "description": "Seconds between thumbnails, 1 to 60 (default 10)",
+                        "name": "interval",
                         "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "WebVTT thumbnails track",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing filename or invalid interval",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
                     },
+                    "503": {
+                        "description": "Too many concurrent downloads (MAX_CONCURRENT_DOWNLOADS)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/download/video/{filename}/{sprite}": {
+            "get": {
+                "description": "Returns a storyboard sprite image generated by /download/video/{filename}/storyboard.vtt. Sprite names come from the cues of that track.",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Get a downloaded video's storyboard sprite",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "Video Codec (e.g., avc1, vp9)",
-                        "name": "codec",
-                        "in": "query"
+                        "description": "Filename of the downloaded video",
+                        "name": "filename",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Unique ID for progress tracking",
-                        "name": "progressID",
-                        "in": "query",
+                        "description": "Sprite name from the storyboard track",
+                        "name": "sprite",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully streamed video",
+                        "description": "Storyboard sprite",
                         "schema": {
                             "type": "file"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Sprite not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Returns OK as long as the HTTP server is able to respond.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
+                    }
+                }
+            },
+            "head": {
+                "description": "Returns OK as long as the HTTP server is able to respond.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "string"
                         }
@@ -715,178 +1186,1545 @@ const docTemplate = `{
                 }
             }
         },
-        "/web/progress": {
-            "get": {
-                "description": "Establishes an SSE connection to stream real-time progress updates for download/stream operations.",
+        "/load-info": {
+            "post": {
+                "description": "Receives a video URL, fetches its metadata, and redirects the user to the main streaming/downloading page with the info pre-populated. With \"Accept: application/json\" it returns the info and progress ID as JSON instead; the URL may then also be sent as a JSON body.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
                 "produces": [
-                    "text/event-stream"
+                    "text/html",
+                    "application/json"
                 ],
                 "tags": [
                     "web"
                 ],
-                "summary": "Get progress updates via SSE",
+                "summary": "Load video information and redirect to stream page",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Unique ID for the operation to track",
-                        "name": "progressID",
-                        "in": "query",
+                        "description": "Video URL",
+                        "name": "url",
+                        "in": "formData",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Event stream of progress updates",
+                        "description": "Video info, when JSON is accepted",
+                        "schema": {
+                            "$ref": "#/definitions/handler.LoadInfoResponse"
+                        }
+                    },
+                    "302": {
+                        "description": "Redirect to /web with video info",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "400": {
-                        "description": "Missing progressID",
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handler.DownloadAudioRequest": {
+        },
+        "/ready": {
+            "get": {
+                "description": "Checks that yt-dlp and ffmpeg are runnable and the download directories (including VIDEO_DIR and AUDIO_DIR when set apart) are writable. The result is cached for 5 seconds.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Service is ready to accept work",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "One or more checks failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReadinessResponse"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Checks that yt-dlp and ffmpeg are runnable and the download directories (including VIDEO_DIR and AUDIO_DIR when set apart) are writable. The result is cached for 5 seconds.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Service is ready to accept work",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "One or more checks failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReadinessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/status": {
+            "get": {
+                "description": "Reports the running streams, file downloads and requests in flight against MAX_CONCURRENT_STREAMS, MAX_CONCURRENT_DOWNLOADS and MAX_INFLIGHT_REQUESTS. Requests over a limit are refused with 503, streams and downloads after waiting up to CONCURRENCY_WAIT for a slot. Answers 503, with Retry-After, while any limit is saturated, so load balancers can route around the instance.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Concurrency status",
+                "responses": {
+                    "200": {
+                        "description": "No limit reached",
+                        "schema": {
+                            "$ref": "#/definitions/handler.StatusResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "At least one limit reached",
+                        "schema": {
+                            "$ref": "#/definitions/handler.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/audio": {
+            "post": {
+                "description": "Streams an audio file directly from the source URL. Set save to also write the stream to the download directory in the same pass; X-Saved-File names the file, which is completed even if the client disconnects, within STREAM_SAVE_TIMEOUT. Live sources and videos over MAX_DURATION cannot be saved.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "audio/mpeg"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "Stream an audio file",
+                "parameters": [
+                    {
+                        "description": "Audio stream request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.StreamAudioRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully streamed audio",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Saved-File": {
+                                "type": "string",
+                                "description": "Name of the saved file, only with save"
+                            },
+                            "X-Stream-Bytes": {
+                                "type": "string",
+                                "description": "Trailer: bytes of media sent"
+                            },
+                            "X-Stream-Status": {
+                                "type": "string",
+                                "description": "Trailer: complete or error, sent after the body"
+                            },
+                            "X-Video-Info": {
+                                "type": "string",
+                                "description": "Base64-encoded JSON metadata of the source video"
+                            },
+                            "icy-br": {
+                                "type": "string",
+                                "description": "Bitrate in kbit/s, only with icy and a lossy format"
+                            },
+                            "icy-name": {
+                                "type": "string",
+                                "description": "Title of the source video, only with icy"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload, missing URL, unknown preset or codec not valid for the output format",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "save requested for a live source or a video longer than MAX_DURATION",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error during audio streaming",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent streams (MAX_CONCURRENT_STREAMS)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/video": {
+            "post": {
+                "description": "Streams a video directly from the source URL. Send a Range header to get a seekable 206 response instead of the live stream. Set save to also write the live stream to the download directory in the same pass; X-Saved-File names the file, which is completed even if the client disconnects, within STREAM_SAVE_TIMEOUT. Live sources and videos over MAX_DURATION cannot be saved.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "video/mp4",
+                    "video/webm",
+                    "video/x-matroska"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "Stream a video",
+                "parameters": [
+                    {
+                        "description": "Video stream request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.StreamVideoRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully streamed video",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Saved-File": {
+                                "type": "string",
+                                "description": "Name of the saved file, only with save"
+                            },
+                            "X-Stream-Bytes": {
+                                "type": "string",
+                                "description": "Trailer: bytes of media sent"
+                            },
+                            "X-Stream-Status": {
+                                "type": "string",
+                                "description": "Trailer: complete or error, sent after the body"
+                            },
+                            "X-Video-Info": {
+                                "type": "string",
+                                "description": "Base64-encoded JSON metadata of the video"
+                            }
+                        }
+                    },
+                    "206": {
+                        "description": "Requested byte range of the video",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload, missing URL, unknown preset or format sort field, or a live stream format other than mp4, webm or mkv",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "save requested for a live source or a video longer than MAX_DURATION",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error during video streaming",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent streams (MAX_CONCURRENT_STREAMS)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/video/multipart": {
+            "post": {
+                "description": "Returns a multipart/mixed response: first an application/x-subrip part with the subtitles (Content-Language tells which language was picked), then a part with the live video stream (video/mp4, or video/webm or video/x-matroska for those formats). The boundary is in the Content-Type header.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "multipart/mixed"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "Stream a video with its subtitles",
+                "parameters": [
+                    {
+                        "description": "Video and subtitle stream request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.StreamWithSubtitlesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Multipart response with subtitle and video parts",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "X-Video-Info": {
+                                "type": "string",
+                                "description": "Base64-encoded JSON metadata of the video"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload, missing URL, or a format other than mp4, webm or mkv",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Subtitles unavailable or internal server error during streaming",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent streams (MAX_CONCURRENT_STREAMS)",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/video/prefetch": {
+            "post": {
+                "description": "Fetches and caches the video info for a URL in the background, so a following stream or web play request starts faster. Returns immediately.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "Prefetch stream info",
+                "parameters": [
+                    {
+                        "description": "Prefetch request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.PrefetchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Prefetch started",
+                        "schema": {
+                            "$ref": "#/definitions/handler.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or missing URL",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/web": {
+            "get": {
+                "description": "Serves an HTML page that displays video information and allows streaming/downloading.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "web"
+                ],
+                "summary": "Serve web streaming page with video info",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video URL",
+                        "name": "url",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique ID for the operation to track",
+                        "name": "progressID",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON string of VideoInfo",
+                        "name": "videoInfo",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "HTML page for video streaming",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/web/download/audio": {
+            "get": {
+                "description": "Streams audio content directly to the browser, triggering a download.",
+                "produces": [
+                    "audio/mpeg"
+                ],
+                "tags": [
+                    "web"
+                ],
+                "summary": "Download audio to browser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Audio URL",
+                        "name": "url",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Output format (e.g., mp3, aac)",
+                        "name": "outputFormat",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Audio Codec (e.g., libmp3lame)",
+                        "name": "codec",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Audio Bitrate (e.g., 128k)",
+                        "name": "bitrate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique ID for progress tracking",
+                        "name": "progressID",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Send icy-name and icy-br headers for internet-radio clients",
+                        "name": "icy",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully streamed audio for download",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Video is longer than MAX_DURATION",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent downloads",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/web/download/video": {
+            "get": {
+                "description": "Streams video content directly to the browser, triggering a download.",
+                "produces": [
+                    "video/mp4"
+                ],
+                "tags": [
+                    "web"
+                ],
+                "summary": "Download video to browser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video URL",
+                        "name": "url",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Video Resolution (e.g., 720, 1080); capped at ANONYMOUS_MAX_RESOLUTION without Basic Auth credentials",
+                        "name": "resolution",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Video Codec (e.g., avc1, vp9)",
+                        "name": "codec",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique ID for progress tracking",
+                        "name": "progressID",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully streamed video for download",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Video is longer than MAX_DURATION",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent downloads",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/web/login": {
+            "get": {
+                "description": "Asks the browser for Basic Auth credentials, then redirects to next, a path on this server, or to the main page. Authenticated users are not capped at ANONYMOUS_MAX_RESOLUTION.",
+                "tags": [
+                    "web"
+                ],
+                "summary": "Log in to the web UI",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path to return to, e.g. /web?url=...",
+                        "name": "next",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to next or the main page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/web/play": {
+            "get": {
+                "description": "Streams the video content directly to the browser based on query parameters. The stream is muxed on the fly into the requested container, mp4 by default.",
+                "produces": [
+                    "video/mp4",
+                    "video/webm",
+                    "video/x-matroska"
+                ],
+                "tags": [
+                    "web"
+                ],
+                "summary": "Play web stream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Video URL",
+                        "name": "url",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Video Resolution (e.g., 720, 1080); capped at ANONYMOUS_MAX_RESOLUTION without Basic Auth credentials",
+                        "name": "resolution",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Video Codec (e.g., avc1, vp9)",
+                        "name": "codec",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Container to stream (mp4, webm or mkv; default mp4)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique ID for progress tracking",
+                        "name": "progressID",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully streamed video",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request, or a format other than mp4, webm or mkv",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent streams",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/web/progress": {
+            "get": {
+                "description": "Establishes an SSE connection to stream real-time progress updates for download/stream operations. An operation that ends always ends its stream with its complete or error event.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "web"
+                ],
+                "summary": "Get progress updates via SSE",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unique ID for the operation to track",
+                        "name": "progressID",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the last event received, to replay the events missed since",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Start with a synthetic connected event (default SSE_CONNECTED_EVENT)",
+                        "name": "connected",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream of progress updates",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing progressID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handler.ArchiveResponse": {
+            "type": "object",
+            "properties": {
+                "infoPath": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "thumbnailPath": {
+                    "type": "string"
+                },
+                "videoInfo": {
+                    "$ref": "#/definitions/service.VideoInfo"
+                }
+            }
+        },
+        "handler.BatchVideoInfoRequest": {
+            "type": "object",
+            "properties": {
+                "urls": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handler.BatchVideoInfoResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.BatchVideoInfoResult"
+                    }
+                }
+            }
+        },
+        "handler.BatchVideoInfoResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "timedOut": {
+                    "type": "boolean"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "videoInfo": {
+                    "$ref": "#/definitions/service.VideoInfo"
+                }
+            }
+        },
+        "handler.CacheResponse": {
+            "type": "object",
+            "properties": {
+                "remaining": {
+                    "type": "integer"
+                },
+                "removed": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.ConfigResponse": {
+            "type": "object",
+            "properties": {
+                "config": {
+                    "description": "Env var name to effective value, secrets redacted",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "tools": {
+                    "description": "Tool name to version, or the reason it could not be run",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handler.DownloadAudioRequest": {
+            "type": "object",
+            "properties": {
+                "bitrate": {
+                    "type": "string"
+                },
+                "channels": {
+                    "description": "Output channel count, e.g. 1 for mono; 0 keeps the source",
+                    "type": "integer"
+                },
+                "codec": {
+                    "type": "string"
+                },
+                "expectedSha256": {
+                    "description": "ExpectedSHA256 fails the download, deleting the file, unless the file\nhas this SHA-256 (hex)",
+                    "type": "string"
+                },
+                "onExists": {
+                    "description": "unique, overwrite, rename or error; defaults to ON_EXISTS",
+                    "type": "string"
+                },
+                "outputFormat": {
+                    "type": "string"
+                },
+                "podcast": {
+                    "description": "64k mono mp3, loudness-normalized, with ID3 tags, chapters and cover art",
+                    "type": "boolean"
+                },
+                "preset": {
+                    "description": "Named quality preset, e.g. \"hd\"; explicit outputFormat and bitrate win",
+                    "type": "string"
+                },
+                "progressCallbackUrl": {
+                    "description": "ProgressCallbackURL receives a POST with each progress event, at most\none per second plus the final \"complete\" or \"error\" event. Its host\nmust be public unless listed in CALLBACK_ALLOWED_HOSTS, and redirects\nare not followed",
+                    "type": "string"
+                },
+                "sampleFormat": {
+                    "description": "SampleFormat exports uncompressed PCM in a wav file: s16le, s24le or\nf32le. outputFormat defaults to wav and codec to the matching pcm_ one",
+                    "type": "string"
+                },
+                "silenceDuration": {
+                    "type": "string"
+                },
+                "silenceThreshold": {
+                    "type": "string"
+                },
+                "trimSilence": {
+                    "description": "TrimSilence strips leading and trailing silence quieter than\nSilenceThreshold (default \"-50dB\") lasting SilenceDuration seconds (default \"0.5\")",
+                    "type": "boolean"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "volumeDb": {
+                    "description": "VolumeDB applies a fixed gain in dB, from -30 to 30",
+                    "type": "number"
+                }
+            }
+        },
+        "handler.DownloadAudioResponse": {
+            "type": "object",
+            "properties": {
+                "filePath": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "description": "Checksum of the file",
+                    "type": "string"
+                },
+                "videoInfo": {
+                    "description": "Re-use VideoInfo for audio metadata",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.VideoInfo"
+                        }
+                    ]
+                },
+                "warnings": {
+                    "description": "Non-fatal yt-dlp warnings",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handler.DownloadVideoRequest": {
+            "type": "object",
+            "properties": {
+                "allAudioLanguages": {
+                    "description": "AllAudioLanguages muxes the best audio track of every language into one\nfile (mkv by default); videoInfo.audioLanguages lists the ones included",
+                    "type": "boolean"
+                },
+                "burnSubtitles": {
+                    "description": "Hardcode subtitles into the video",
+                    "type": "boolean"
+                },
+                "codec": {
+                    "type": "string"
+                },
+                "expectedSha256": {
+                    "description": "ExpectedSHA256 fails the download, deleting the file, unless the file\nhas this SHA-256 (hex); not available with separateAudio",
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "formatId": {
+                    "description": "Literal yt-dlp format selector, e.g. \"137+140\"; overrides resolution, codec and progressive",
+                    "type": "string"
+                },
+                "formatSort": {
+                    "description": "yt-dlp --format-sort value, e.g. \"res,fps,codec:av01\"",
+                    "type": "string"
+                },
+                "onExists": {
+                    "description": "unique, overwrite, rename or error; defaults to ON_EXISTS",
+                    "type": "string"
+                },
+                "preset": {
+                    "description": "Named quality preset, e.g. \"hd\"; explicit format, resolution and codec win",
+                    "type": "string"
+                },
+                "progressCallbackUrl": {
+                    "description": "ProgressCallbackURL receives a POST with each progress event, at most\none per second plus the final \"complete\" or \"error\" event. Its host\nmust be public unless listed in CALLBACK_ALLOWED_HOSTS, and redirects\nare not followed",
+                    "type": "string"
+                },
+                "progressive": {
+                    "description": "Pick a single pre-muxed file, skipping the ffmpeg merge",
+                    "type": "boolean"
+                },
+                "resolution": {
+                    "type": "string"
+                },
+                "separateAudio": {
+                    "description": "SeparateAudio downloads the best video-only and audio-only formats as\ntwo files instead of merging them; audioFilePath names the audio one",
+                    "type": "boolean"
+                },
+                "strictFormat": {
+                    "description": "Fail instead of falling back when the format can't be honoured",
+                    "type": "boolean"
+                },
+                "strictQuality": {
+                    "description": "Fail with the available options instead of using the closest resolution/codec",
+                    "type": "boolean"
+                },
+                "subtitleLang": {
+                    "description": "Comma-separated preference chain, e.g. \"en,en-US,auto\"",
+                    "type": "string"
+                },
+                "targetSizeMB": {
+                    "description": "TargetSizeMB re-encodes the video in two passes to about that many MB,\ne.g. 25 for Discord's upload limit; the bitrate follows the duration",
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "watermark": {
+                    "description": "Watermark is an image in the server's ASSETS_DIR to overlay on the\nvideo, in the WatermarkPosition corner: top-left, top-right,\nbottom-left or bottom-right (the default)",
+                    "type": "string"
+                },
+                "watermarkPosition": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.DownloadVideoResponse": {
+            "type": "object",
+            "properties": {
+                "audioFilePath": {
+                    "description": "With separateAudio, the audio file; filePath is then video-only",
+                    "type": "string"
+                },
+                "audioSha256": {
+                    "type": "string"
+                },
+                "filePath": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "selectedFormat": {
+                    "description": "SelectedFormat is the format yt-dlp actually downloaded, when known",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.SelectedFormat"
+                        }
+                    ]
+                },
+                "sha256": {
+                    "description": "SHA256 is the checksum of the file, and AudioSHA256 of the audio file",
+                    "type": "string"
+                },
+                "videoInfo": {
+                    "$ref": "#/definitions/service.VideoInfo"
+                },
+                "warnings": {
+                    "description": "Non-fatal yt-dlp warnings",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handler.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.FileInfo": {
+            "type": "object",
+            "properties": {
+                "modTime": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.GetPlaylistInfoResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "playlist": {
+                    "$ref": "#/definitions/service.PlaylistInfo"
+                }
+            }
+        },
+        "handler.GetVideoInfoRequest": {
+            "type": "object",
+            "properties": {
+                "light": {
+                    "description": "Light only fetches the title, duration, thumbnail, uploader and upload\ndate, which is much faster; formats and subtitles are left out",
+                    "type": "boolean"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.GetVideoInfoResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "videoInfo": {
+                    "$ref": "#/definitions/service.VideoInfo"
+                }
+            }
+        },
+        "handler.LimitStatus": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "description": "0 when unlimited",
+                    "type": "integer"
+                },
+                "running": {
+                    "type": "integer"
+                },
+                "saturated": {
+                    "description": "New operations of this kind get 503",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handler.ListDownloadedFilesResponse": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.FileInfo"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Files in the directory, across all pages",
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.ListSubtitlesResponse": {
+            "type": "object",
+            "properties": {
+                "automatic": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "language": {
+                    "description": "Language is the video's own spoken language, which \"auto\" stands for\nin a preference chain, when the extractor knows it",
+                    "type": "string"
+                },
+                "manual": {
+                    "description": "Manual are the subtitles uploaded with the video, and Automatic the\ncaptions generated from its speech",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.LoadInfoResponse": {
             "type": "object",
             "properties": {
-                "bitrate": {
+                "progressID": {
+                    "type": "string"
+                },
+                "videoInfo": {
+                    "$ref": "#/definitions/service.VideoInfo"
+                }
+            }
+        },
+        "handler.PrefetchRequest": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.ReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "description": "Check name to \"ok\" or the failure reason",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "description": "\"ready\" or \"not_ready\"",
                     "type": "string"
+                }
+            }
+        },
+        "handler.ScheduleDownloadRequest": {
+            "type": "object",
+            "properties": {
+                "allAudioLanguages": {
+                    "description": "AllAudioLanguages muxes the best audio track of every language into one\nfile (mkv by default); videoInfo.audioLanguages lists the ones included",
+                    "type": "boolean"
+                },
+                "burnSubtitles": {
+                    "description": "Hardcode subtitles into the video",
+                    "type": "boolean"
                 },
                 "codec": {
                     "type": "string"
                 },
-                "outputFormat": {
+                "expectedSha256": {
+                    "description": "ExpectedSHA256 fails the download, deleting the file, unless the file\nhas this SHA-256 (hex); not available with separateAudio",
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "formatId": {
+                    "description": "Literal yt-dlp format selector, e.g. \"137+140\"; overrides resolution, codec and progressive",
+                    "type": "string"
+                },
+                "formatSort": {
+                    "description": "yt-dlp --format-sort value, e.g. \"res,fps,codec:av01\"",
+                    "type": "string"
+                },
+                "onExists": {
+                    "description": "unique, overwrite, rename or error; defaults to ON_EXISTS",
                     "type": "string"
                 },
+                "preset": {
+                    "description": "Named quality preset, e.g. \"hd\"; explicit format, resolution and codec win",
+                    "type": "string"
+                },
+                "progressCallbackUrl": {
+                    "description": "ProgressCallbackURL receives a POST with each progress event, at most\none per second plus the final \"complete\" or \"error\" event. Its host\nmust be public unless listed in CALLBACK_ALLOWED_HOSTS, and redirects\nare not followed",
+                    "type": "string"
+                },
+                "progressive": {
+                    "description": "Pick a single pre-muxed file, skipping the ffmpeg merge",
+                    "type": "boolean"
+                },
+                "resolution": {
+                    "type": "string"
+                },
+                "separateAudio": {
+                    "description": "SeparateAudio downloads the best video-only and audio-only formats as\ntwo files instead of merging them; audioFilePath names the audio one",
+                    "type": "boolean"
+                },
+                "startAt": {
+                    "description": "RFC 3339, e.g. \"2025-01-31T02:00:00Z\"; a past time starts at once",
+                    "type": "string"
+                },
+                "strictFormat": {
+                    "description": "Fail instead of falling back when the format can't be honoured",
+                    "type": "boolean"
+                },
+                "strictQuality": {
+                    "description": "Fail with the available options instead of using the closest resolution/codec",
+                    "type": "boolean"
+                },
+                "subtitleLang": {
+                    "description": "Comma-separated preference chain, e.g. \"en,en-US,auto\"",
+                    "type": "string"
+                },
+                "targetSizeMB": {
+                    "description": "TargetSizeMB re-encodes the video in two passes to about that many MB,\ne.g. 25 for Discord's upload limit; the bitrate follows the duration",
+                    "type": "integer"
+                },
                 "url": {
                     "type": "string"
+                },
+                "watermark": {
+                    "description": "Watermark is an image in the server's ASSETS_DIR to overlay on the\nvideo, in the WatermarkPosition corner: top-left, top-right,\nbottom-left or bottom-right (the default)",
+                    "type": "string"
+                },
+                "watermarkPosition": {
+                    "type": "string"
                 }
             }
         },
-        "handler.DownloadAudioResponse": {
+        "handler.ScheduledJobResponse": {
             "type": "object",
             "properties": {
-                "filePath": {
-                    "type": "string"
+                "job": {
+                    "$ref": "#/definitions/service.ScheduledJob"
                 },
                 "message": {
                     "type": "string"
+                }
+            }
+        },
+        "handler.StatusResponse": {
+            "type": "object",
+            "properties": {
+                "limits": {
+                    "description": "\"streams\", \"downloads\" and \"requests\"",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/handler.LimitStatus"
+                    }
                 },
-                "videoInfo": {
-                    "description": "Re-use VideoInfo for audio metadata",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/service.VideoInfo"
-                        }
-                    ]
+                "status": {
+                    "description": "\"available\" or \"saturated\"",
+                    "type": "string"
                 }
             }
         },
-        "handler.DownloadVideoRequest": {
+        "handler.StreamAudioRequest": {
             "type": "object",
             "properties": {
+                "bitrate": {
+                    "type": "string"
+                },
                 "codec": {
                     "type": "string"
                 },
-                "format": {
+                "icy": {
+                    "description": "Send icy-name and icy-br headers for internet-radio clients",
+                    "type": "boolean"
+                },
+                "outputFormat": {
                     "type": "string"
                 },
-                "resolution": {
+                "preset": {
+                    "description": "Named quality preset, e.g. \"hd\"; explicit outputFormat and bitrate win",
                     "type": "string"
                 },
+                "save": {
+                    "description": "Also save the stream to the download directory",
+                    "type": "boolean"
+                },
                 "url": {
                     "type": "string"
                 }
             }
         },
-        "handler.DownloadVideoResponse": {
+        "handler.StreamVideoRequest": {
             "type": "object",
             "properties": {
-                "filePath": {
+                "codec": {
                     "type": "string"
                 },
-                "message": {
+                "format": {
                     "type": "string"
                 },
-                "videoInfo": {
-                    "$ref": "#/definitions/service.VideoInfo"
+                "formatSort": {
+                    "description": "yt-dlp --format-sort value, e.g. \"res,fps,codec:av01\"",
+                    "type": "string"
+                },
+                "preset": {
+                    "description": "Named quality preset, e.g. \"hd\"; explicit format, resolution and codec win",
+                    "type": "string"
+                },
+                "progressive": {
+                    "description": "Pick a single pre-muxed file, skipping the ffmpeg merge",
+                    "type": "boolean"
+                },
+                "resolution": {
+                    "type": "string"
+                },
+                "save": {
+                    "description": "Also save the stream to the download directory",
+                    "type": "boolean"
+                },
+                "strictFormat": {
+                    "description": "Fail instead of falling back when no progressive format matches",
+                    "type": "boolean"
+                },
+                "url": {
+                    "type": "string"
                 }
             }
         },
-        "handler.ErrorResponse": {
+        "handler.StreamWithSubtitlesRequest": {
             "type": "object",
             "properties": {
-                "error": {
+                "codec": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "resolution": {
+                    "type": "string"
+                },
+                "subtitleLang": {
+                    "description": "Comma-separated preference chain, e.g. \"en,en-US,auto\"",
                     "type": "string"
                 },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.SuccessResponse": {
+            "type": "object",
+            "properties": {
                 "message": {
                     "type": "string"
                 }
             }
         },
-        "handler.FileInfo": {
+        "handler.SupportedResponse": {
             "type": "object",
             "properties": {
-                "modTime": {
+                "extractor": {
                     "type": "string"
                 },
-                "name": {
+                "reason": {
+                    "description": "Reason explains why a URL yt-dlp can handle is still not supported",
                     "type": "string"
                 },
-                "size": {
-                    "type": "integer"
+                "supported": {
+                    "type": "boolean"
                 }
             }
         },
-        "handler.GetVideoInfoRequest": {
+        "handler.WaveformResponse": {
             "type": "object",
             "properties": {
-                "url": {
+                "filename": {
                     "type": "string"
+                },
+                "peaks": {
+                    "description": "Peak level of each slice of the file, from 0 to 1",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
                 }
             }
         },
-        "handler.GetVideoInfoResponse": {
+        "service.PlaylistEntry": {
             "type": "object",
             "properties": {
-                "message": {
+                "duration": {
+                    "type": "number"
+                },
+                "id": {
                     "type": "string"
                 },
-                "videoInfo": {
-                    "$ref": "#/definitions/service.VideoInfo"
+                "title": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
                 }
             }
         },
-        "handler.ListDownloadedFilesResponse": {
+        "service.PlaylistInfo": {
             "type": "object",
             "properties": {
-                "files": {
+                "entries": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/handler.FileInfo"
+                        "$ref": "#/definitions/service.PlaylistEntry"
                     }
                 },
-                "message": {
+                "extractor": {
+                    "description": "Extractor and ExtractorKey name the yt-dlp extractor of the playlist",
+                    "type": "string"
+                },
+                "extractor_key": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "truncated": {
+                    "description": "Truncated is set when the playlist has more entries than were returned",
+                    "type": "boolean"
+                },
+                "uploader": {
                     "type": "string"
                 }
             }
         },
-        "handler.StreamAudioRequest": {
+        "service.ScheduledJob": {
             "type": "object",
             "properties": {
-                "bitrate": {
+                "audioFilePath": {
                     "type": "string"
                 },
-                "codec": {
+                "error": {
                     "type": "string"
                 },
-                "outputFormat": {
+                "filePath": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "description": "Checksum of FilePath",
+                    "type": "string"
+                },
+                "startAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "scheduled, running, done, failed or cancelled",
                     "type": "string"
                 },
                 "url": {
@@ -894,28 +2732,50 @@ const docTemplate = `{
                 }
             }
         },
-        "handler.StreamVideoRequest": {
+        "service.SelectedFormat": {
             "type": "object",
             "properties": {
-                "codec": {
+                "acodec": {
                     "type": "string"
                 },
-                "format": {
+                "ext": {
                     "type": "string"
                 },
-                "resolution": {
+                "filesize": {
+                    "description": "Bytes, exact or estimated by yt-dlp",
+                    "type": "integer"
+                },
+                "formatId": {
                     "type": "string"
                 },
-                "url": {
+                "fps": {
+                    "type": "number"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "vcodec": {
                     "type": "string"
+                },
+                "width": {
+                    "type": "integer"
                 }
             }
         },
-        "handler.SuccessResponse": {
+        "service.Thumbnail": {
             "type": "object",
             "properties": {
-                "message": {
+                "height": {
+                    "type": "integer"
+                },
+                "id": {
                     "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
                 }
             }
         },
@@ -925,6 +2785,19 @@ const docTemplate = `{
                 "acodec": {
                     "type": "string"
                 },
+                "audioLanguages": {
+                    "description": "AudioLanguages are the audio tracks muxed into an all-languages download",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "automatic_captions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "duration": {
                     "description": "in seconds",
                     "type": "integer"
@@ -932,6 +2805,13 @@ const docTemplate = `{
                 "ext": {
                     "type": "string"
                 },
+                "extractor": {
+                    "description": "Extractor is the yt-dlp extractor that handled the URL, e.g.\n\"youtube\", and ExtractorKey its class name, e.g. \"Youtube\"",
+                    "type": "string"
+                },
+                "extractor_key": {
+                    "type": "string"
+                },
                 "filesize": {
                     "type": "integer"
                 },
@@ -957,13 +2837,50 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "is_live": {
+                    "description": "IsLive is set for a live broadcast, which has no end to wait for",
+                    "type": "boolean"
+                },
+                "language": {
+                    "description": "Subtitle availability, used to resolve the subtitle language chain",
+                    "type": "string"
+                },
                 "original_url": {
                     "type": "string"
                 },
+                "protocol": {
+                    "description": "e.g. \"https\", \"m3u8_native\", \"http_dash_segments\"",
+                    "type": "string"
+                },
+                "selectedFormat": {
+                    "description": "SelectedFormat is the format yt-dlp picked for a video download",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.SelectedFormat"
+                        }
+                    ]
+                },
+                "subtitleLang": {
+                    "description": "SubtitleLang is the subtitle language actually obtained for a download",
+                    "type": "string"
+                },
+                "subtitles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "thumbnail": {
                     "description": "URL to thumbnail",
                     "type": "string"
                 },
+                "thumbnails": {
+                    "description": "Thumbnails lists every thumbnail size available, so clients can pick one",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.Thumbnail"
+                    }
+                },
                 "title": {
                     "type": "string"
                 },
@@ -986,6 +2903,11 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "BasicAuth": {
+            "type": "basic"
+        }
     }
 }`
 
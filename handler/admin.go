@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"gostreampuller/config"
+	"gostreampuller/service"
+)
+
+// AdminHandler handles operator maintenance requests.
+type AdminHandler struct {
+	downloader *service.Downloader
+	cfg        *config.Config
+}
+
+// NewAdminHandler creates a new AdminHandler.
+func NewAdminHandler(downloader *service.Downloader, cfg *config.Config) *AdminHandler {
+	return &AdminHandler{
+		downloader: downloader,
+		cfg:        cfg,
+	}
+}
+
+// CacheResponse represents the result of a cache maintenance operation.
+type CacheResponse struct {
+	Removed   int `json:"removed"`
+	Remaining int `json:"remaining"`
+}
+
+// PruneCache removes expired entries from the video info cache.
+//
+//	@Summary		Prune expired cache entries
+//	@Description	Removes expired entries from the video info cache and reports how many were removed and how many remain.
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BasicAuth
+//	@Success		200	{object}	CacheResponse	"Expired entries removed"
+//	@Failure		401	{string}	string			"Unauthorized"
+//	@Router			/admin/cache/prune [post]
+func (h *AdminHandler) PruneCache(w http.ResponseWriter, _ *http.Request) {
+	removed, remaining := h.downloader.PruneInfoCache()
+	slog.Info("Pruned video info cache", "removed", removed, "remaining", remaining)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CacheResponse{Removed: removed, Remaining: remaining})
+}
+
+// ClearCache removes every entry from the video info cache, e.g. to force
+// fresh extractions after a yt-dlp update.
+//
+//	@Summary		Clear the cache
+//	@Description	Removes all entries from the video info cache so the next requests re-run yt-dlp.
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BasicAuth
+//	@Success		200	{object}	CacheResponse	"Cache cleared"
+//	@Failure		401	{string}	string			"Unauthorized"
+//	@Router			/admin/cache [delete]
+func (h *AdminHandler) ClearCache(w http.ResponseWriter, _ *http.Request) {
+	removed := h.downloader.ClearInfoCache()
+	slog.Info("Cleared video info cache", "removed", removed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CacheResponse{Removed: removed})
+}
+
+// ConfigResponse represents the effective configuration of the running instance.
+type ConfigResponse struct {
+	Config map[string]string `json:"config"` // Env var name to effective value, secrets redacted
+	Tools  map[string]string `json:"tools"`  // Tool name to version, or the reason it could not be run
+}
+
+// Config reports the effective configuration and external tool versions.
+//
+//	@Summary		Show effective configuration
+//	@Description	Returns the running instance's configuration, keyed by environment variable, with secrets redacted, plus the yt-dlp and ffmpeg versions in use.
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BasicAuth
+//	@Success		200	{object}	ConfigResponse	"Effective configuration"
+//	@Failure		401	{string}	string			"Unauthorized"
+//	@Router			/admin/config [get]
+func (h *AdminHandler) Config(w http.ResponseWriter, r *http.Request) {
+	tools := make(map[string]string)
+	for name, tool := range map[string][2]string{
+		"yt-dlp": {h.cfg.YTDLPPath, "--version"},
+		"ffmpeg": {h.cfg.FFMPEGPath, "-version"},
+	} {
+		version, err := toolVersion(r.Context(), tool[0], tool[1])
+		if err != nil {
+			version = err.Error()
+		}
+		tools[name] = version
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ConfigResponse{
+		Config: h.downloader.Config().Redacted(), // Reflects reloads
+		Tools:  tools,
+	})
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"gostreampuller/service"
+)
+
+// ServeChapters serves the chapters of a downloaded video as a WebVTT
+// chapters track, for players that show chapter markers.
+//
+//	@Summary		Get a downloaded video's chapters
+//	@Description	Returns the chapters of a video in the download directory as a WebVTT chapters track. Chapters come from the yt-dlp .info.json sidecar next to the file (as written by /download/archive) or, failing that, from markers embedded in the file.
+//	@Tags			download
+//	@Produce		text/vtt
+//	@Param			filename	path		string			true	"Filename of the downloaded video"
+//	@Success		200			{string}	string			"WebVTT chapters track"
+//	@Failure		400			{object}	ErrorResponse	"Missing filename"
+//	@Failure		404			{object}	ErrorResponse	"File not found or video has no chapters"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/download/video/{filename}/chapters.vtt [get]
+func (h *DownloadVideoHandler) ServeChapters(w http.ResponseWriter, r *http.Request) {
+	filename := r.PathValue("filename")
+	if filename == "" {
+		slog.Error("Missing filename for serving chapters")
+		http.Error(w, NewErrorResponse("Filename is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	filePath := h.downloader.ResolveDownload(filename)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		slog.Warn("Downloaded video file not found", "filePath", filePath)
+		http.Error(w, NewErrorResponse("File not found").ToJson(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.Error("Error checking file existence", "filePath", filePath, "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Error accessing file: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	chapters, err := h.downloader.Chapters(r.Context(), filePath)
+	if errors.Is(err, service.ErrNoChapters) {
+		http.Error(w, NewErrorResponse("Video has no chapters").ToJson(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.Error("Failed to read chapters", "filePath", filePath, "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to read chapters: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	if err := service.WriteWebVTTChapters(w, chapters); err != nil {
+		slog.Error("Error while writing chapters", "filePath", filePath, "error", err)
+	}
+}
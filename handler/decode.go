@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// decodeJSONBody decodes the request body into v. When strict is set,
+// unknown fields are rejected so a typo such as "resolutoin" gets a 400
+// naming the field instead of being silently ignored.
+func decodeJSONBody(r *http.Request, v any, strict bool) error {
+	decoder := json.NewDecoder(r.Body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		// encoding/json has no typed error for this case, only the message
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("unknown field %s", field)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeJSONBody(t *testing.T) {
+	body := `{"url":"https://example.com/v","resolutoin":"720"}`
+
+	var req StreamVideoRequest
+	err := decodeJSONBody(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), &req, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/v", req.URL)
+
+	err = decodeJSONBody(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), &req, true)
+	assert.EqualError(t, err, `unknown field "resolutoin"`)
+}
+
+func TestStrictJSON_UnknownFieldIsRejected(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	cfg.StrictJSON = true
+	h := NewDownloadVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/download/video", strings.NewReader(`{"url":"https://example.com/v","resolutoin":"720"}`))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var resp ErrorResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, `Invalid request payload: unknown field "resolutoin"`, resp.Message)
+}
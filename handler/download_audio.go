@@ -2,11 +2,11 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath" // Import filepath
 
 	"gostreampuller/service"
 )
@@ -29,30 +29,59 @@ type DownloadAudioRequest struct {
 	OutputFormat string `json:"outputFormat"`
 	Codec        string `json:"codec"`
 	Bitrate      string `json:"bitrate"`
+	Channels     int    `json:"channels"` // Output channel count, e.g. 1 for mono; 0 keeps the source
+	Podcast      bool   `json:"podcast"`  // 64k mono mp3, loudness-normalized, with ID3 tags, chapters and cover art
+	OnExists     string `json:"onExists"` // unique, overwrite, rename or error; defaults to ON_EXISTS
+	Preset       string `json:"preset"`   // Named quality preset, e.g. "hd"; explicit outputFormat and bitrate win
+	// TrimSilence strips leading and trailing silence quieter than
+	// SilenceThreshold (default "-50dB") lasting SilenceDuration seconds (default "0.5")
+	TrimSilence      bool   `json:"trimSilence"`
+	SilenceThreshold string `json:"silenceThreshold"`
+	SilenceDuration  string `json:"silenceDuration"`
+	// VolumeDB applies a fixed gain in dB, from -30 to 30
+	VolumeDB float64 `json:"volumeDb"`
+	// SampleFormat exports uncompressed PCM in a wav file: s16le, s24le or
+	// f32le. outputFormat defaults to wav and codec to the matching pcm_ one
+	SampleFormat string `json:"sampleFormat"`
+	// ExpectedSHA256 fails the download, deleting the file, unless the file
+	// has this SHA-256 (hex)
+	ExpectedSHA256 string `json:"expectedSha256"`
+	// ProgressCallbackURL receives a POST with each progress event, at most
+	// one per second plus the final "complete" or "error" event. Its host
+	// must be public unless listed in CALLBACK_ALLOWED_HOSTS, and redirects
+	// are not followed
+	ProgressCallbackURL string `json:"progressCallbackUrl"`
 }
 
 // DownloadAudioResponse represents the response body for audio download.
+// FilePath is the name /download/audio/{filename} serves the file by.
 type DownloadAudioResponse struct {
 	FilePath  string             `json:"filePath"`
 	VideoInfo *service.VideoInfo `json:"videoInfo"` // Re-use VideoInfo for audio metadata
 	Message   string             `json:"message"`
+	Warnings  []string           `json:"warnings,omitempty"` // Non-fatal yt-dlp warnings
+	SHA256    string             `json:"sha256"`             // Checksum of the file
 }
 
 // Handle handles the audio download request.
 //
 //	@Summary		Download an audio file
-//	@Description	Downloads an audio file from a given URL to the server's download directory.
+//	@Description	Downloads an audio file from a given URL to the server's download directory. Set podcast for a 64k mono mp3 with normalized loudness and embedded ID3 tags, chapters and cover art; explicit format, codec, bitrate and channels override the preset. Set sampleFormat (s16le, s24le or f32le) for uncompressed PCM in a wav file, e.g. for audio-processing pipelines.
 //	@Tags			download
 //	@Accept			json
 //	@Produce		json
 //	@Param			request	body		DownloadAudioRequest	true	"Audio download request"
 //	@Success		200		{object}	DownloadAudioResponse	"Audio downloaded successfully"
-//	@Failure		400		{object}	ErrorResponse			"Invalid request payload or missing URL"
+//	@Failure		400		{object}	ErrorResponse			"Invalid request payload, missing URL, unknown preset or onExists strategy, invalid silence settings, volume out of range, codec not valid for the output format, unknown sample format or one combined with another format or codec, invalid progress callback URL or invalid expected SHA-256"
+//	@Failure		403		{object}	ErrorResponse			"URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS"
+//	@Failure		409		{object}	ErrorResponse			"File already exists and onExists is error"
+//	@Failure		422		{object}	ErrorResponse			"Video is longer than MAX_DURATION, or the file does not match expectedSha256 (it is deleted)"
 //	@Failure		500		{object}	ErrorResponse			"Internal server error during audio download"
+//	@Failure		503		{object}	ErrorResponse			"Too many concurrent downloads (MAX_CONCURRENT_DOWNLOADS)"
 //	@Router			/download/audio [post]
 func (h *DownloadAudioHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var req DownloadAudioRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
 		slog.Error("Failed to decode request body", "error", err)
 		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
 		return
@@ -64,20 +93,87 @@ func (h *DownloadAudioHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Info("Attempting to download audio", "url", req.URL, "outputFormat", req.OutputFormat, "codec", req.Codec, "bitrate", req.Bitrate)
+	preset, err := h.downloader.QualityPreset(req.Preset)
+	if err != nil {
+		slog.Error("Unknown quality preset", "error", err, "preset", req.Preset)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+	req.applyPreset(preset)
+
+	if req.ProgressCallbackURL != "" {
+		if err := h.downloader.ValidateCallbackURL(req.ProgressCallbackURL); err != nil {
+			slog.Error("Invalid progress callback URL", "error", err)
+			http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Pass an empty string for progressID as this API endpoint doesn't have an SSE client
-	filePath, videoInfo, err := h.downloader.DownloadAudioToFile(r.Context(), req.URL, req.OutputFormat, req.Codec, req.Bitrate, "")
+	if err := service.ValidateSHA256(req.ExpectedSHA256); err != nil {
+		slog.Error("Invalid expected checksum", "error", err)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.ValidateOnExists(req.OnExists); err != nil {
+		slog.Error("Invalid onExists strategy", "error", err, "onExists", req.OnExists)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Attempting to download audio", "url", req.URL, "outputFormat", req.OutputFormat, "codec", req.Codec, "bitrate", req.Bitrate, "podcast", req.Podcast)
+
+	opts := service.AudioOptions{
+		Format:           req.OutputFormat,
+		Codec:            req.Codec,
+		Bitrate:          req.Bitrate,
+		Channels:         req.Channels,
+		Podcast:          req.Podcast,
+		OnExists:         req.OnExists,
+		TrimSilence:      req.TrimSilence,
+		SilenceThreshold: req.SilenceThreshold,
+		SilenceDuration:  req.SilenceDuration,
+		VolumeDB:         req.VolumeDB,
+		SampleFormat:     req.SampleFormat,
+	}
+	if err := opts.Validate(); err != nil {
+		slog.Error("Invalid audio options", "error", err)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	// This API endpoint has no SSE client, so progress only goes to the callback, if any
+	progressID := ""
+	if req.ProgressCallbackURL != "" {
+		var release func()
+		progressID, release = h.downloader.RegisterProgressCallback(req.ProgressCallbackURL)
+		defer release()
+	}
+	filePath, videoInfo, warnings, err := h.downloader.DownloadAudioToFile(r.Context(), req.URL, opts, progressID)
+	if errors.Is(err, service.ErrFileExists) {
+		slog.Warn("Audio file already exists", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to download audio", "error", err, "url", req.URL)
-		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to download audio: %v", err)).ToJson(), http.StatusInternalServerError)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to download audio: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+
+	sum, err := service.VerifyDownload(filePath, req.ExpectedSHA256)
+	if err != nil {
+		slog.Error("Failed to verify downloaded audio", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to verify downloaded audio: %v", err)).ToJson(), errorStatus(err))
 		return
 	}
 
 	resp := DownloadAudioResponse{
-		FilePath:  filePath,
+		FilePath:  downloadName(filePath),
 		VideoInfo: videoInfo,
 		Message:   "Audio downloaded successfully",
+		Warnings:  warnings,
+		SHA256:    sum,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -105,7 +201,7 @@ func (h *DownloadAudioHandler) ServeDownloadedAudio(w http.ResponseWriter, r *ht
 		return
 	}
 
-	filePath := filepath.Join(h.downloader.GetDownloadDir(), filename)
+	filePath := h.downloader.ResolveDownload(filename)
 
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
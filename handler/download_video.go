@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -25,33 +26,78 @@ func NewDownloadVideoHandler(downloader *service.Downloader) *DownloadVideoHandl
 
 // DownloadVideoRequest represents the request body for video download.
 type DownloadVideoRequest struct {
-	URL        string `json:"url"`
-	Format     string `json:"format"`
-	Resolution string `json:"resolution"`
-	Codec      string `json:"codec"`
+	URL           string `json:"url"`
+	Format        string `json:"format"`
+	Resolution    string `json:"resolution"`
+	Codec         string `json:"codec"`
+	BurnSubtitles bool   `json:"burnSubtitles"` // Hardcode subtitles into the video
+	SubtitleLang  string `json:"subtitleLang"`  // Comma-separated preference chain, e.g. "en,en-US,auto"
+	StrictFormat  bool   `json:"strictFormat"`  // Fail instead of falling back when the format can't be honoured
+	StrictQuality bool   `json:"strictQuality"` // Fail with the available options instead of using the closest resolution/codec
+	Progressive   bool   `json:"progressive"`   // Pick a single pre-muxed file, skipping the ffmpeg merge
+	FormatSort    string `json:"formatSort"`    // yt-dlp --format-sort value, e.g. "res,fps,codec:av01"
+	FormatID      string `json:"formatId"`      // Literal yt-dlp format selector, e.g. "137+140"; overrides resolution, codec and progressive
+	OnExists      string `json:"onExists"`      // unique, overwrite, rename or error; defaults to ON_EXISTS
+	Preset        string `json:"preset"`        // Named quality preset, e.g. "hd"; explicit format, resolution and codec win
+
+	// SeparateAudio downloads the best video-only and audio-only formats as
+	// two files instead of merging them; audioFilePath names the audio one
+	SeparateAudio bool `json:"separateAudio"`
+	// AllAudioLanguages muxes the best audio track of every language into one
+	// file (mkv by default); videoInfo.audioLanguages lists the ones included
+	AllAudioLanguages bool `json:"allAudioLanguages"`
+	// Watermark is an image in the server's ASSETS_DIR to overlay on the
+	// video, in the WatermarkPosition corner: top-left, top-right,
+	// bottom-left or bottom-right (the default)
+	Watermark         string `json:"watermark"`
+	WatermarkPosition string `json:"watermarkPosition"`
+	// TargetSizeMB re-encodes the video in two passes to about that many MB,
+	// e.g. 25 for Discord's upload limit; the bitrate follows the duration
+	TargetSizeMB int `json:"targetSizeMB"`
+	// ExpectedSHA256 fails the download, deleting the file, unless the file
+	// has this SHA-256 (hex); not available with separateAudio
+	ExpectedSHA256 string `json:"expectedSha256"`
+	// ProgressCallbackURL receives a POST with each progress event, at most
+	// one per second plus the final "complete" or "error" event. Its host
+	// must be public unless listed in CALLBACK_ALLOWED_HOSTS, and redirects
+	// are not followed
+	ProgressCallbackURL string `json:"progressCallbackUrl"`
 }
 
 // DownloadVideoResponse represents the response body for video download.
+// File paths are the names /download/video/{filename} serves the files by,
+// never their location on the server.
 type DownloadVideoResponse struct {
-	FilePath  string            `json:"filePath"`
-	VideoInfo *service.VideoInfo `json:"videoInfo"`
-	Message   string            `json:"message"`
+	FilePath      string             `json:"filePath"`
+	AudioFilePath string             `json:"audioFilePath,omitempty"` // With separateAudio, the audio file; filePath is then video-only
+	VideoInfo     *service.VideoInfo `json:"videoInfo"`
+	Message       string             `json:"message"`
+	Warnings      []string           `json:"warnings,omitempty"` // Non-fatal yt-dlp warnings
+	// SHA256 is the checksum of the file, and AudioSHA256 of the audio file
+	SHA256      string `json:"sha256"`
+	AudioSHA256 string `json:"audioSha256,omitempty"`
+	// SelectedFormat is the format yt-dlp actually downloaded, when known
+	SelectedFormat *service.SelectedFormat `json:"selectedFormat,omitempty"`
 }
 
 // Handle handles the video download request.
 //	@Summary		Download a video
-//	@Description	Downloads a video from a given URL to the server's download directory.
+//	@Description	Downloads a video from a given URL to the server's download directory. With separateAudio, the best video-only and audio-only formats are saved as two files without merging them: filePath is the video and audioFilePath the audio.
 //	@Tags			download
 //	@Accept			json
 //	@Produce		json
 //	@Param			request	body		DownloadVideoRequest	true	"Video download request"
 //	@Success		200		{object}	DownloadVideoResponse	"Video downloaded successfully"
-//	@Failure		400		{object}	ErrorResponse			"Invalid request payload or missing URL"
+//	@Failure		400		{object}	ErrorResponse			"Invalid request payload, missing URL, unknown preset, format sort field or onExists strategy, an invalid format ID, a format that cannot hold all audio languages, an invalid progress callback URL, an invalid watermark, target size or expected SHA-256, separateAudio with an option needing a merged file, or with strictQuality a resolution or codec the video does not offer"
+//	@Failure		403		{object}	ErrorResponse			"URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS"
+//	@Failure		409		{object}	ErrorResponse			"File already exists and onExists is error"
+//	@Failure		422		{object}	ErrorResponse			"Video is longer than MAX_DURATION, or the file does not match expectedSha256 (it is deleted)"
 //	@Failure		500		{object}	ErrorResponse			"Internal server error during video download"
+//	@Failure		503		{object}	ErrorResponse			"Too many concurrent downloads (MAX_CONCURRENT_DOWNLOADS)"
 //	@Router			/download/video [post]
 func (h *DownloadVideoHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var req DownloadVideoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
 		slog.Error("Failed to decode request body", "error", err)
 		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
 		return
@@ -63,20 +109,66 @@ func (h *DownloadVideoHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Info("Attempting to download video", "url", req.URL, "format", req.Format, "resolution", req.Resolution, "codec", req.Codec)
+	opts, err := h.videoOptions(&req)
+	if err != nil {
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Attempting to download video", "url", req.URL, "format", req.Format, "resolution", req.Resolution, "codec", req.Codec, "burnSubtitles", req.BurnSubtitles)
 
-	// Pass an empty string for progressID as this API endpoint doesn't have an SSE client
-	filePath, videoInfo, err := h.downloader.DownloadVideoToFile(r.Context(), req.URL, req.Format, req.Resolution, req.Codec, "")
+	// This API endpoint has no SSE client, so progress only goes to the callback, if any
+	progressID := ""
+	if req.ProgressCallbackURL != "" {
+		var release func()
+		progressID, release = h.downloader.RegisterProgressCallback(req.ProgressCallbackURL)
+		defer release()
+	}
+	var filePath, audioFilePath string
+	var videoInfo *service.VideoInfo
+	var warnings []string
+	if req.SeparateAudio {
+		filePath, audioFilePath, videoInfo, warnings, err = h.downloader.DownloadSeparateStreams(r.Context(), req.URL, opts, progressID)
+	} else {
+		filePath, videoInfo, warnings, err = h.downloader.DownloadVideoToFile(r.Context(), req.URL, opts, progressID)
+	}
+	if errors.Is(err, service.ErrFileExists) {
+		slog.Warn("Video file already exists", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusConflict)
+		return
+	}
+	var qualityErr *service.QualityUnavailableError
+	if errors.As(err, &qualityErr) {
+		slog.Warn("Requested quality not available", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to download video", "error", err, "url", req.URL)
-		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to download video: %v", err)).ToJson(), http.StatusInternalServerError)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to download video: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+
+	sum, err := service.VerifyDownload(filePath, req.ExpectedSHA256)
+	var audioSum string
+	if err == nil && audioFilePath != "" {
+		audioSum, err = service.FileSHA256(audioFilePath)
+	}
+	if err != nil {
+		slog.Error("Failed to verify downloaded video", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to verify downloaded video: %v", err)).ToJson(), errorStatus(err))
 		return
 	}
 
 	resp := DownloadVideoResponse{
-		FilePath:  filePath,
-		VideoInfo: videoInfo,
-		Message:   "Video downloaded successfully",
+		FilePath:       downloadName(filePath),
+		AudioFilePath:  downloadName(audioFilePath),
+		VideoInfo:      videoInfo,
+		Message:        "Video downloaded successfully",
+		Warnings:       warnings,
+		SHA256:         sum,
+		AudioSHA256:    audioSum,
+		SelectedFormat: videoInfo.SelectedFormat,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -84,6 +176,101 @@ func (h *DownloadVideoHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Video downloaded successfully", "filePath", filePath)
 }
 
+// downloadName returns the name a downloaded file is known by to clients:
+// its base name, which the /download routes resolve in the download
+// directory. The server's own paths are kept out of responses. An empty
+// path stays empty.
+func downloadName(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Base(path)
+}
+
+// videoOptions applies the preset of a video download request and checks
+// its options, returning those to download with. Its errors are the
+// client's, to be answered with 400.
+func (h *DownloadVideoHandler) videoOptions(req *DownloadVideoRequest) (service.VideoOptions, error) {
+	preset, err := h.downloader.QualityPreset(req.Preset)
+	if err != nil {
+		slog.Error("Unknown quality preset", "error", err, "preset", req.Preset)
+		return service.VideoOptions{}, err
+	}
+	req.applyPreset(preset)
+
+	if err := service.ValidateFormatSort(req.FormatSort); err != nil {
+		slog.Error("Invalid format sort", "error", err, "formatSort", req.FormatSort)
+		return service.VideoOptions{}, err
+	}
+
+	if err := service.ValidateFormatID(req.FormatID); err != nil {
+		slog.Error("Invalid format ID", "error", err, "formatId", req.FormatID)
+		return service.VideoOptions{}, err
+	}
+
+	if req.ProgressCallbackURL != "" {
+		if err := h.downloader.ValidateCallbackURL(req.ProgressCallbackURL); err != nil {
+			slog.Error("Invalid progress callback URL", "error", err)
+			return service.VideoOptions{}, err
+		}
+	}
+
+	if err := service.ValidateOnExists(req.OnExists); err != nil {
+		slog.Error("Invalid onExists strategy", "error", err, "onExists", req.OnExists)
+		return service.VideoOptions{}, err
+	}
+
+	if req.AllAudioLanguages {
+		if err := service.ValidateMultiAudio(req.Format, req.Progressive); err != nil {
+			slog.Error("Invalid multi-audio request", "error", err, "format", req.Format)
+			return service.VideoOptions{}, err
+		}
+	}
+
+	if err := h.downloader.ValidateWatermark(req.Watermark, req.WatermarkPosition); err != nil {
+		slog.Error("Invalid watermark", "error", err, "watermark", req.Watermark)
+		return service.VideoOptions{}, err
+	}
+
+	if err := service.ValidateTargetSize(req.TargetSizeMB); err != nil {
+		slog.Error("Invalid target size", "error", err, "targetSizeMB", req.TargetSizeMB)
+		return service.VideoOptions{}, err
+	}
+
+	if err := service.ValidateSHA256(req.ExpectedSHA256); err != nil {
+		slog.Error("Invalid expected checksum", "error", err)
+		return service.VideoOptions{}, err
+	}
+	if req.SeparateAudio && req.ExpectedSHA256 != "" {
+		return service.VideoOptions{}, errors.New("separate audio cannot be combined with expectedSha256, which covers a single file")
+	}
+
+	opts := service.VideoOptions{
+		Format:            req.Format,
+		Resolution:        req.Resolution,
+		Codec:             req.Codec,
+		BurnSubtitles:     req.BurnSubtitles,
+		SubtitleLang:      req.SubtitleLang,
+		StrictFormat:      req.StrictFormat,
+		StrictQuality:     req.StrictQuality,
+		Progressive:       req.Progressive,
+		FormatSort:        req.FormatSort,
+		FormatID:          req.FormatID,
+		OnExists:          req.OnExists,
+		AllAudioLanguages: req.AllAudioLanguages,
+		Watermark:         req.Watermark,
+		WatermarkPosition: req.WatermarkPosition,
+		TargetSizeMB:      req.TargetSizeMB,
+	}
+	if req.SeparateAudio {
+		if err := service.ValidateSeparateAudio(opts); err != nil {
+			slog.Error("Invalid separate audio request", "error", err)
+			return service.VideoOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
 // ServeDownloadedVideo serves a previously downloaded video file.
 //	@Summary		Serve a downloaded video file
 //	@Description	Serves a video file from the server's download directory given its filename.
@@ -103,7 +290,7 @@ func (h *DownloadVideoHandler) ServeDownloadedVideo(w http.ResponseWriter, r *ht
 		return
 	}
 
-	filePath := filepath.Join(h.downloader.GetDownloadDir(), filename)
+	filePath := h.downloader.ResolveDownload(filename)
 
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -123,6 +310,9 @@ func (h *DownloadVideoHandler) ServeDownloadedVideo(w http.ResponseWriter, r *ht
 // GetVideoInfoRequest represents the request body for getting video info.
 type GetVideoInfoRequest struct {
 	URL string `json:"url"`
+	// Light only fetches the title, duration, thumbnail, uploader and upload
+	// date, which is much faster; formats and subtitles are left out
+	Light bool `json:"light"`
 }
 
 // GetVideoInfoResponse represents the response body for getting video info.
@@ -133,18 +323,20 @@ type GetVideoInfoResponse struct {
 
 // GetVideoInfo handles requests to get video information without downloading.
 //	@Summary		Get video information
-//	@Description	Retrieves metadata for a video from a given URL without downloading the file.
+//	@Description	Retrieves metadata for a video from a given URL without downloading the file. With light, only the title, duration, thumbnail, uploader and upload date are fetched, skipping the slow resolution of every format; formats and subtitles are then left out.
 //	@Tags			download
 //	@Accept			json
 //	@Produce		json
 //	@Param			request	body		GetVideoInfoRequest		true	"Video info request"
 //	@Success		200		{object}	GetVideoInfoResponse	"Video information retrieved successfully"
 //	@Failure		400		{object}	ErrorResponse			"Invalid request payload or missing URL"
+//	@Failure		403		{object}	ErrorResponse			"URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS"
 //	@Failure		500		{object}	ErrorResponse			"Internal server error during video info retrieval"
+//	@Failure		504		{object}	ErrorResponse			"yt-dlp took longer than INFO_TIMEOUT"
 //	@Router			/download/video/info [post]
 func (h *DownloadVideoHandler) GetVideoInfo(w http.ResponseWriter, r *http.Request) {
 	var req GetVideoInfoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
 		slog.Error("Failed to decode request body for video info", "error", err)
 		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
 		return
@@ -158,11 +350,23 @@ func (h *DownloadVideoHandler) GetVideoInfo(w http.ResponseWriter, r *http.Reque
 
 	slog.Info("Attempting to get video info", "url", req.URL)
 
-	// Pass an empty string for progressID as this API endpoint doesn't have an SSE client
-	videoInfo, err := h.downloader.GetVideoInfo(r.Context(), req.URL, "")
+	var videoInfo *service.VideoInfo
+	var err error
+	if req.Light {
+		videoInfo, err = h.downloader.GetVideoInfoLight(r.Context(), req.URL)
+	} else {
+		// Pass an empty string for progressID as this API endpoint doesn't have an SSE client
+		videoInfo, err = h.downloader.GetVideoInfo(r.Context(), req.URL, "")
+	}
+	var timeoutErr *service.InfoTimeoutError
+	if errors.As(err, &timeoutErr) {
+		slog.Error("Timed out getting video info", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusGatewayTimeout)
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to get video info", "error", err, "url", req.URL)
-		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to get video info: %v", err)).ToJson(), http.StatusInternalServerError)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to get video info: %v", err)).ToJson(), errorStatus(err))
 		return
 	}
 
@@ -176,9 +380,148 @@ func (h *DownloadVideoHandler) GetVideoInfo(w http.ResponseWriter, r *http.Reque
 	slog.Info("Video information retrieved successfully", "videoID", videoInfo.ID)
 }
 
+// GetRawVideoInfo returns the full yt-dlp info JSON for a URL, for clients
+// that need more than the curated VideoInfo fields.
+//	@Summary		Get raw yt-dlp video information
+//	@Description	Returns yt-dlp's --dump-json output for a video unchanged, with every field the extractor provides. Unlike /download/video/info it is never served from the info cache.
+//	@Tags			download
+//	@Produce		json
+//	@Param			url	query		string			true	"Video URL"
+//	@Success		200	{object}	object			"Raw yt-dlp info JSON"
+//	@Failure		400	{object}	ErrorResponse	"Missing URL"
+//	@Failure		403	{object}	ErrorResponse	"URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error during video info retrieval"
+//	@Failure		504	{object}	ErrorResponse	"yt-dlp took longer than INFO_TIMEOUT"
+//	@Router			/download/video/info/raw [get]
+func (h *DownloadVideoHandler) GetRawVideoInfo(w http.ResponseWriter, r *http.Request) {
+	videoURL := r.URL.Query().Get("url")
+	if videoURL == "" {
+		slog.Error("Missing URL in raw video info request")
+		http.Error(w, NewErrorResponse("URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Attempting to get raw video info", "url", videoURL)
+
+	raw, err := h.downloader.GetRawVideoInfo(r.Context(), videoURL)
+	var timeoutErr *service.InfoTimeoutError
+	if errors.As(err, &timeoutErr) {
+		slog.Error("Timed out getting raw video info", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusGatewayTimeout)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get raw video info", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to get video info: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(raw)
+	slog.Info("Raw video information retrieved successfully", "url", videoURL, "bytes", len(raw))
+}
+
+// ArchiveResponse represents the response body for an info archive. The
+// paths are file names in the download directory.
+type ArchiveResponse struct {
+	InfoPath      string             `json:"infoPath"`
+	ThumbnailPath string             `json:"thumbnailPath,omitempty"`
+	VideoInfo     *service.VideoInfo `json:"videoInfo"`
+	Message       string             `json:"message"`
+}
+
+// Archive saves a video's metadata and thumbnail without the media.
+//
+//	@Summary		Archive video metadata
+//	@Description	Writes the video's .info.json and thumbnail to the server's download directory without downloading the media, for cataloging.
+//	@Tags			download
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		GetVideoInfoRequest	true	"Video archive request"
+//	@Success		200		{object}	ArchiveResponse		"Metadata archived successfully"
+//	@Failure		400		{object}	ErrorResponse		"Invalid request payload or missing URL"
+//	@Failure		500		{object}	ErrorResponse		"Internal server error during archiving"
+//	@Router			/download/archive [post]
+func (h *DownloadVideoHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	var req GetVideoInfoRequest
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
+		slog.Error("Failed to decode request body for archive", "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == "" {
+		slog.Error("Missing URL in archive request")
+		http.Error(w, NewErrorResponse("URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	infoPath, thumbnailPath, videoInfo, err := h.downloader.ArchiveInfo(r.Context(), req.URL)
+	if err != nil {
+		slog.Error("Failed to archive video info", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to archive video info: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := ArchiveResponse{
+		InfoPath:      downloadName(infoPath),
+		ThumbnailPath: downloadName(thumbnailPath),
+		VideoInfo:     videoInfo,
+		Message:       "Metadata archived successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SupportedResponse represents the response body for the URL support probe.
+type SupportedResponse struct {
+	Supported bool   `json:"supported"`
+	Extractor string `json:"extractor,omitempty"`
+	// Reason explains why a URL yt-dlp can handle is still not supported
+	Reason string `json:"reason,omitempty"`
+}
+
+// CheckSupported tells whether a URL can be downloaded, without fetching full info.
+//	@Summary		Check whether a URL is supported
+//	@Description	Runs a lightweight yt-dlp simulation to tell whether the URL is downloadable and by which extractor. URLs from extractors outside ALLOWED_EXTRACTORS are reported as not supported, with a reason.
+//	@Tags			download
+//	@Produce		json
+//	@Param			url	query		string				true	"Video URL"
+//	@Success		200	{object}	SupportedResponse	"Support check result"
+//	@Failure		400	{object}	ErrorResponse		"Missing URL"
+//	@Failure		500	{object}	ErrorResponse		"yt-dlp could not be run"
+//	@Router			/download/supported [get]
+func (h *DownloadVideoHandler) CheckSupported(w http.ResponseWriter, r *http.Request) {
+	videoURL := r.URL.Query().Get("url")
+	if videoURL == "" {
+		slog.Error("Missing URL in supported check request")
+		http.Error(w, NewErrorResponse("URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	supported, extractor, err := h.downloader.CheckSupported(r.Context(), videoURL)
+	var extractorErr *service.ExtractorNotAllowedError
+	if errors.As(err, &extractorErr) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SupportedResponse{Supported: false, Extractor: extractor, Reason: err.Error()})
+		slog.Info("URL extractor not allowed", "url", videoURL, "extractor", extractor)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to check URL support", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to check URL: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SupportedResponse{Supported: supported, Extractor: extractor})
+	slog.Info("Checked URL support", "url", videoURL, "supported", supported, "extractor", extractor)
+}
+
 // DeleteDownloadedFile deletes a previously downloaded file.
 //	@Summary		Delete a downloaded file
-//	@Description	Deletes a file from the server's download directory given its filename.
+//	@Description	Deletes a file from the server's download directory given its filename, along with the storyboard sprites generated for it.
 //	@Tags			download
 //	@Produce		json
 //	@Param			filename	path		string			true	"Filename of the file to delete"
@@ -195,7 +538,7 @@ func (h *DownloadVideoHandler) DeleteDownloadedFile(w http.ResponseWriter, r *ht
 		return
 	}
 
-	filePath := filepath.Join(h.downloader.GetDownloadDir(), filename)
+	filePath := h.downloader.ResolveDownload(filename)
 
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -213,6 +556,9 @@ func (h *DownloadVideoHandler) DeleteDownloadedFile(w http.ResponseWriter, r *ht
 		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to delete file: %v", err)).ToJson(), http.StatusInternalServerError)
 		return
 	}
+	if err := service.RemoveStoryboards(filePath); err != nil {
+		slog.Warn("Failed to delete storyboard sprites", "filePath", filePath, "error", err)
+	}
 
 	slog.Info("File deleted successfully", "filePath", filePath)
 	w.Header().Set("Content-Type", "application/json")
@@ -222,6 +568,7 @@ func (h *DownloadVideoHandler) DeleteDownloadedFile(w http.ResponseWriter, r *ht
 // ListDownloadedFilesResponse represents the response body for listing downloaded files.
 type ListDownloadedFilesResponse struct {
 	Files   []FileInfo `json:"files"`
+	Total   int        `json:"total"` // Files in the directory, across all pages
 	Message string     `json:"message"`
 }
 
@@ -232,33 +579,47 @@ type FileInfo struct {
 	ModTime string `json:"modTime"`
 }
 
-// ListDownloadedFiles lists all files in the download directory.
+// ListDownloadedFiles lists the files in the download directories, or only
+// the video or audio one, sorted and paginated as the query asks.
 //	@Summary		List downloaded files
-//	@Description	Lists all files present in the server's configured download directory.
+//	@Description	Lists the files present in the server's configured download directories (DOWNLOAD_DIR, VIDEO_DIR and AUDIO_DIR), including their YYYY/MM/DD subfolders when ORGANIZE_BY_DATE is on. type=video or type=audio lists only VIDEO_DIR or AUDIO_DIR, which are the download directory unless configured. Files are sorted by name, size or date (modification time), ties broken by name, and paginated with limit and offset; total counts every file.
 //	@Tags			download
 //	@Produce		json
-//	@Success		200	{object}	ListDownloadedFilesResponse	"Successfully listed downloaded files"
-//	@Failure		500	{object}	ErrorResponse				"Internal server error during file listing"
+//	@Param			type	query		string						false	"Media type: video or audio; all by default"
+//	@Param			sort	query		string						false	"Sort key: name (default), size or date"
+//	@Param			order	query		string						false	"Sort order: asc (default) or desc"
+//	@Param			limit	query		integer						false	"Files per page; 0 or absent for all"
+//	@Param			offset	query		integer						false	"Files to skip"
+//	@Success		200		{object}	ListDownloadedFilesResponse	"Successfully listed downloaded files"
+//	@Failure		400		{object}	ErrorResponse				"Invalid type, sort, order, limit or offset"
+//	@Failure		500		{object}	ErrorResponse				"Internal server error during file listing"
 //	@Router			/download/list [get]
 func (h *DownloadVideoHandler) ListDownloadedFiles(w http.ResponseWriter, r *http.Request) {
-	downloadDir := h.downloader.GetDownloadDir()
-	files, err := os.ReadDir(downloadDir)
+	query, err := parseFileListQuery(r)
+	if err != nil {
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	files, err := h.downloader.DownloadedFiles(query.kind)
 	if err != nil {
-		slog.Error("Failed to read download directory", "directory", downloadDir, "error", err)
+		slog.Error("Failed to read download directory", "type", query.kind, "error", err)
 		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to list files: %v", err)).ToJson(), http.StatusInternalServerError)
 		return
 	}
 
-	var fileInfos []FileInfo
+	var stats []os.FileInfo
 	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		info, err := file.Info()
+		info, err := os.Stat(file)
 		if err != nil {
-			slog.Warn("Could not get file info", "filename", file.Name(), "error", err)
+			slog.Warn("Could not get file info", "filename", filepath.Base(file), "error", err)
 			continue
 		}
+		stats = append(stats, info)
+	}
+
+	fileInfos := []FileInfo{}
+	for _, info := range query.apply(stats) {
 		fileInfos = append(fileInfos, FileInfo{
 			Name:    info.Name(),
 			Size:    info.Size(),
@@ -268,12 +629,13 @@ func (h *DownloadVideoHandler) ListDownloadedFiles(w http.ResponseWriter, r *htt
 
 	resp := ListDownloadedFilesResponse{
 		Files:   fileInfos,
+		Total:   len(stats),
 		Message: "Successfully listed downloaded files",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
-	slog.Info("Successfully listed downloaded files", "count", len(fileInfos))
+	slog.Info("Successfully listed downloaded files", "count", len(fileInfos), "total", len(stats))
 }
 
 // ErrorResponse represents a generic error response.
@@ -0,0 +1,433 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadVideoHandler_CheckSupported(t *testing.T) {
+	tests := []struct {
+		name          string
+		script        string
+		allowed       string
+		wantSupported bool
+		wantExtractor string
+		wantReason    string
+	}{
+		{
+			name:          "Supported",
+			script:        "#!/bin/sh\nprintf 'youtube\\tYoutube\\n'\n",
+			wantSupported: true,
+			wantExtractor: "youtube",
+		},
+		{
+			name:          "Unsupported",
+			script:        "#!/bin/sh\necho 'ERROR: Unsupported URL' >&2\nexit 1\n",
+			wantSupported: false,
+		},
+		{
+			name:          "AllowedByKey",
+			script:        "#!/bin/sh\nprintf 'youtube\\tYoutube\\n'\n",
+			allowed:       "Youtube",
+			wantSupported: true,
+			wantExtractor: "youtube",
+		},
+		{
+			name:          "ExtractorNotAllowed",
+			script:        "#!/bin/sh\nprintf 'vimeo\\tVimeo\\n'\n",
+			allowed:       "youtube",
+			wantSupported: false,
+			wantExtractor: "vimeo",
+			wantReason:    `extractor "vimeo" are not allowed`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloader, cfg := newTestDownloader(t, tt.script)
+			cfg.AllowedExtractors = tt.allowed
+			h := NewDownloadVideoHandler(downloader)
+
+			rec := httptest.NewRecorder()
+			h.CheckSupported(rec, httptest.NewRequest(http.MethodGet, "/download/supported?url=https://example.com/v", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			var resp SupportedResponse
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(t, tt.wantSupported, resp.Supported)
+			assert.Equal(t, tt.wantExtractor, resp.Extractor)
+			if tt.wantReason == "" {
+				assert.Empty(t, resp.Reason)
+			} else {
+				assert.Contains(t, resp.Reason, tt.wantReason)
+			}
+		})
+	}
+
+	t.Run("MissingURL", func(t *testing.T) {
+		downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+		h := NewDownloadVideoHandler(downloader)
+
+		rec := httptest.NewRecorder()
+		h.CheckSupported(rec, httptest.NewRequest(http.MethodGet, "/download/supported", nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestDownloadVideoHandler_RejectsUnknownFormatSort(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	h := NewDownloadVideoHandler(downloader)
+
+	body := strings.NewReader(`{"url":"https://example.com/v","formatSort":"res,bogus"}`)
+	rec := httptest.NewRecorder()
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", body))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "bogus")
+}
+
+func TestDownloadVideoHandler_Archive(t *testing.T) {
+	// Writes the sidecars next to the --output template and no media file
+	script := `#!/bin/sh
+out=""
+prev=""
+for a in "$@"; do
+	case "$prev" in --output) out="$a" ;; esac
+	case "$a" in --dump-json) echo '{"id":"abc123","title":"Test Video"}'; exit 0 ;; esac
+	prev="$a"
+done
+base=$(echo "$out" | sed 's/\.%(ext)s$//')
+printf '{"id":"abc123"}' > "$base.info.json"
+printf 'jpeg' > "$base.jpg"
+`
+	downloader, cfg := newTestDownloader(t, script)
+	h := NewDownloadVideoHandler(downloader)
+
+	rec := httptest.NewRecorder()
+	h.Archive(rec, httptest.NewRequest(http.MethodPost, "/download/archive", strings.NewReader(`{"url":"https://example.com/v"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp ArchiveResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "abc123", resp.VideoInfo.ID)
+	assert.True(t, strings.HasSuffix(resp.InfoPath, ".info.json"))
+	assert.True(t, strings.HasSuffix(resp.ThumbnailPath, ".jpg"))
+	assert.FileExists(t, filepath.Join(cfg.DownloadDir, resp.InfoPath))
+	assert.FileExists(t, filepath.Join(cfg.DownloadDir, resp.ThumbnailPath))
+
+	entries, err := os.ReadDir(cfg.DownloadDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2, "only the sidecar files should be written")
+}
+
+func TestDownloadHandlers_ResponsesHideServerPaths(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	cfg.OrganizeByDate = true // Files land in dated subfolders
+	videos := NewDownloadVideoHandler(downloader)
+	audios := NewDownloadAudioHandler(downloader)
+
+	rec := httptest.NewRecorder()
+	videos.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", strings.NewReader(`{"url":"https://example.com/v"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), cfg.DownloadDir)
+	var videoResp DownloadVideoResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&videoResp))
+	assert.Equal(t, filepath.Base(videoResp.FilePath), videoResp.FilePath)
+
+	// The name is enough to get the file back
+	req := httptest.NewRequest(http.MethodGet, "/download/video/"+videoResp.FilePath, nil)
+	req.SetPathValue("filename", videoResp.FilePath)
+	rec = httptest.NewRecorder()
+	videos.ServeDownloadedVideo(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	audios.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/audio", strings.NewReader(`{"url":"https://example.com/v"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), cfg.DownloadDir)
+	var audioResp DownloadAudioResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&audioResp))
+	assert.Equal(t, filepath.Base(audioResp.FilePath), audioResp.FilePath)
+}
+
+func TestDownloadVideoHandler_ExpectedSHA256(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	h := NewDownloadVideoHandler(downloader)
+	sum := sha256.Sum256([]byte(fakeVideoContent))
+	want := hex.EncodeToString(sum[:])
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"url":"https://example.com/v","expectedSha256":"` + want + `"}`)
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp DownloadVideoResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, want, resp.SHA256)
+	assert.FileExists(t, filepath.Join(cfg.DownloadDir, resp.FilePath))
+
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"url":"https://example.com/w","expectedSha256":"` + strings.Repeat("0", 64) + `"}`)
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", body))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	entries, err := os.ReadDir(cfg.DownloadDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "the mismatching download should be deleted")
+
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"url":"https://example.com/v","expectedSha256":"abc"}`)
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", body))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDownloadVideoHandler_OnExistsError(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	h := NewDownloadVideoHandler(downloader)
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.DownloadDir, "abc123.mp4"), []byte("old"), 0644))
+
+	body := strings.NewReader(`{"url":"https://example.com/v","onExists":"error"}`)
+	rec := httptest.NewRecorder()
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", body))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	body = strings.NewReader(`{"url":"https://example.com/v","onExists":"skip"}`)
+	rec = httptest.NewRecorder()
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", body))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDownloadVideoHandler_StrictQualityUnavailable(t *testing.T) {
+	script := strings.Replace(fakeYTDLPScript, `"uploader":"Tester"}`,
+		`"uploader":"Tester","formats":[{"format_id":"v720","vcodec":"avc1.64001F","height":720}]}`, 1)
+	downloader, _ := newTestDownloader(t, script)
+	h := NewDownloadVideoHandler(downloader)
+
+	body := strings.NewReader(`{"url":"https://example.com/v","resolution":"1080","strictQuality":true}`)
+	rec := httptest.NewRecorder()
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", body))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "available resolutions: 720p")
+}
+
+func TestDownloadVideoHandler_TooLong(t *testing.T) {
+	// fakeYTDLPScript reports a 42s video
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	cfg.MaxDuration = 30 * time.Second
+	h := NewDownloadVideoHandler(downloader)
+
+	rec := httptest.NewRecorder()
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", strings.NewReader(`{"url":"https://example.com/v"}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "downloads are limited to 30s")
+}
+
+func TestDownloadVideoHandler_InvalidWatermark(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	cfg.AssetsDir = t.TempDir()
+	h := NewDownloadVideoHandler(downloader)
+
+	for _, body := range []string{
+		`{"url":"https://example.com/v","watermark":"../../etc/passwd.png"}`,
+		`{"url":"https://example.com/v","watermark":"missing.png"}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestDownloadVideoHandler_ServeChapters(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	h := NewDownloadVideoHandler(downloader)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.DownloadDir, "1-abc.mp4"), []byte(fakeVideoContent), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.DownloadDir, "1-abc.info.json"),
+		[]byte(`{"id":"abc","chapters":[{"start_time":0,"end_time":30,"title":"Intro"},{"start_time":30,"end_time":95.25,"title":"Outro"}]}`), 0644))
+
+	req := httptest.NewRequest(http.MethodGet, "/download/video/1-abc.mp4/chapters.vtt", nil)
+	req.SetPathValue("filename", "1-abc.mp4")
+	rec := httptest.NewRecorder()
+	h.ServeChapters(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/vtt; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "WEBVTT\n"+
+		"\n1\n00:00:00.000 --> 00:00:30.000\nIntro\n"+
+		"\n2\n00:00:30.000 --> 00:01:35.250\nOutro\n", rec.Body.String())
+}
+
+func TestDownloadVideoHandler_ServeChaptersNone(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	h := NewDownloadVideoHandler(downloader)
+
+	// The test config's ffmpeg prints nothing, so the file has no chapters
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.DownloadDir, "plain.mp4"), []byte(fakeVideoContent), 0644))
+
+	req := httptest.NewRequest(http.MethodGet, "/download/video/plain.mp4/chapters.vtt", nil)
+	req.SetPathValue("filename", "plain.mp4")
+	rec := httptest.NewRecorder()
+	h.ServeChapters(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no chapters")
+}
+
+func TestDownloadVideoHandler_GetPlaylistInfo(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, `#!/bin/sh
+echo '{"id":"PL1","title":"My Playlist","entries":[{"id":"a1","title":"First","duration":12,"url":"https://example.com/watch?v=a1"}]}'
+`)
+	cfg.PlaylistMaxEntries = 10
+	h := NewDownloadVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodGet, "/download/playlist/info?url=https://example.com/playlist", nil)
+	rec := httptest.NewRecorder()
+	h.GetPlaylistInfo(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp GetPlaylistInfoResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "My Playlist", resp.Playlist.Title)
+	if assert.Len(t, resp.Playlist.Entries, 1) {
+		assert.Equal(t, "a1", resp.Playlist.Entries[0].ID)
+		assert.Equal(t, 12.0, resp.Playlist.Entries[0].Duration)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetPlaylistInfo(rec, httptest.NewRequest(http.MethodGet, "/download/playlist/info", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDownloadVideoHandler_GetPlaylistInfoExtractorNotAllowed(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, `#!/bin/sh
+echo '{"id":"PL1","title":"My Playlist","extractor":"vimeo:album","extractor_key":"VimeoAlbum","entries":[]}'
+`)
+	cfg.AllowedExtractors = "youtube,youtube:tab"
+	h := NewDownloadVideoHandler(downloader)
+
+	rec := httptest.NewRecorder()
+	h.GetPlaylistInfo(rec, httptest.NewRequest(http.MethodGet, "/download/playlist/info?url=https://vimeo.com/album/1", nil))
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), `extractor \"vimeo:album\" are not allowed`)
+}
+
+func TestDownloadVideoHandler_ProgressCallback(t *testing.T) {
+	events := make(chan string, 16)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct {
+			Status string `json:"status"`
+		}
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event.Status
+	}))
+	defer callback.Close()
+
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	cfg.CallbackAllowedHosts = "127.0.0.1" // httptest servers listen on loopback
+	downloader.UpdateConfig(cfg)
+	h := NewDownloadVideoHandler(downloader)
+
+	body := `{"url":"https://example.com/v","progressCallbackUrl":"` + callback.URL + `"}`
+	rec := httptest.NewRecorder()
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The first event always goes out, then the rest are throttled except the final one
+	assert.Equal(t, "fetching_info", <-events)
+	for {
+		select {
+		case status := <-events:
+			if status != "complete" {
+				continue
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("complete event was not delivered to the callback")
+		}
+		break
+	}
+
+	rec = httptest.NewRecorder()
+	body = `{"url":"https://example.com/v","progressCallbackUrl":"file:///etc/passwd"}`
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	body = `{"url":"https://example.com/v","progressCallbackUrl":"http://169.254.169.254/latest/meta-data"}`
+	h.Handle(rec, httptest.NewRequest(http.MethodPost, "/download/video", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDownloadVideoHandler_ServeDatedFile(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	cfg.OrganizeByDate = true
+	h := NewDownloadVideoHandler(downloader)
+
+	dated := filepath.Join(cfg.DownloadDir, "2026", "01", "02")
+	assert.NoError(t, os.MkdirAll(dated, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dated, "1-abc.mp4"), []byte(fakeVideoContent), 0644))
+
+	req := httptest.NewRequest(http.MethodGet, "/download/video/1-abc.mp4", nil)
+	req.SetPathValue("filename", "1-abc.mp4")
+	rec := httptest.NewRecorder()
+	h.ServeDownloadedVideo(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, fakeVideoContent, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ListDownloadedFiles(rec, httptest.NewRequest(http.MethodGet, "/download/list", nil))
+	var resp ListDownloadedFilesResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	if assert.Len(t, resp.Files, 1) {
+		assert.Equal(t, "1-abc.mp4", resp.Files[0].Name)
+	}
+}
+
+func TestDownloadVideoHandler_GetRawVideoInfo(t *testing.T) {
+	// Field order, spacing and fields VideoInfo does not know must survive
+	const raw = `{"id": "abc123", "title": "Test Video", "like_count": 7, "heatmap": [{"start_time": 0.0, "value": 1.0}]}`
+	downloader, _ := newTestDownloader(t, "#!/bin/sh\necho '"+raw+"'\n")
+	h := NewDownloadVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodGet, "/download/video/info/raw?url=https://example.com/v", nil)
+	rec := httptest.NewRecorder()
+	h.GetRawVideoInfo(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, raw, rec.Body.String())
+}
+
+func TestDownloadVideoHandler_GetRawVideoInfoInvalidJSON(t *testing.T) {
+	downloader, _ := newTestDownloader(t, "#!/bin/sh\necho 'not json'\n")
+	h := NewDownloadVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodGet, "/download/video/info/raw?url=https://example.com/v", nil)
+	rec := httptest.NewRecorder()
+	h.GetRawVideoInfo(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "not valid JSON")
+}
+
+func TestDownloadVideoHandler_GetVideoInfoExtractorNotAllowed(t *testing.T) {
+	const info = `{"id":"v1","title":"Vimeo Video","extractor":"vimeo","extractor_key":"Vimeo"}`
+	downloader, _ := newTestDownloader(t, "#!/bin/sh\necho '"+info+"'\n")
+	downloader.Config().AllowedExtractors = "youtube"
+	h := NewDownloadVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/download/video/info", strings.NewReader(`{"url":"https://vimeo.com/1"}`))
+	rec := httptest.NewRecorder()
+	h.GetVideoInfo(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), `extractor \"vimeo\" are not allowed`)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+
+	"gostreampuller/service"
+)
+
+// Keys a file listing can be sorted by.
+const (
+	sortFilesByName = "name"
+	sortFilesBySize = "size"
+	sortFilesByDate = "date" // Modification time
+)
+
+// fileListQuery is which downloaded files a listing covers and how they are
+// sorted and paginated.
+type fileListQuery struct {
+	kind       string // service.MediaVideo or service.MediaAudio, "" for all
+	sort       string
+	descending bool
+	limit      int // 0 for every file from offset on
+	offset     int
+}
+
+// parseFileListQuery reads the type (video or audio), sort (name, size or
+// date), order (asc or desc), limit and offset query parameters of r. Files
+// of every type are all listed by default, sorted by name, ascending.
+func parseFileListQuery(r *http.Request) (fileListQuery, error) {
+	query := r.URL.Query()
+	q := fileListQuery{sort: sortFilesByName}
+
+	switch kind := query.Get("type"); kind {
+	case "", service.MediaVideo, service.MediaAudio:
+		q.kind = kind
+	default:
+		return q, fmt.Errorf("unknown type %q, expected %s or %s", kind, service.MediaVideo, service.MediaAudio)
+	}
+
+	if value := query.Get("sort"); value != "" {
+		if value != sortFilesByName && value != sortFilesBySize && value != sortFilesByDate {
+			return q, fmt.Errorf("unknown sort %q, expected one of: %s, %s, %s", value, sortFilesByName, sortFilesBySize, sortFilesByDate)
+		}
+		q.sort = value
+	}
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		q.descending = true
+	default:
+		return q, fmt.Errorf("unknown order %q, expected asc or desc", order)
+	}
+
+	var err error
+	if q.limit, err = nonNegativeParam(query.Get("limit"), "limit"); err != nil {
+		return q, err
+	}
+	if q.offset, err = nonNegativeParam(query.Get("offset"), "offset"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// nonNegativeParam parses the query parameter name, 0 when absent.
+func nonNegativeParam(value, name string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a number of 0 or more", name)
+	}
+	return n, nil
+}
+
+// apply sorts files and returns the page of them the query asks for. Files
+// with the same size or date are ordered by name, so pages are stable.
+func (q fileListQuery) apply(files []os.FileInfo) []os.FileInfo {
+	slices.SortFunc(files, func(a, b os.FileInfo) int {
+		var c int
+		switch q.sort {
+		case sortFilesBySize:
+			c = cmp.Compare(a.Size(), b.Size())
+		case sortFilesByDate:
+			c = a.ModTime().Compare(b.ModTime())
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Name(), b.Name())
+		}
+		if q.descending {
+			return -c
+		}
+		return c
+	})
+
+	if q.offset >= len(files) {
+		return nil
+	}
+	files = files[q.offset:]
+	if q.limit > 0 && q.limit < len(files) {
+		files = files[:q.limit]
+	}
+	return files
+}
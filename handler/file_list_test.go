@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadVideoHandler_ListDownloadedFilesSortedAndPaginated(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	h := NewDownloadVideoHandler(downloader)
+
+	// Names, sizes and dates each give a different order
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, file := range []struct {
+		name string
+		size int
+		age  time.Duration
+	}{
+		{"b.mp4", 30, 2 * time.Hour},
+		{"a.mp4", 10, 1 * time.Hour},
+		{"d.mp4", 20, 4 * time.Hour},
+		{"c.mp4", 40, 3 * time.Hour},
+	} {
+		path := filepath.Join(cfg.DownloadDir, file.name)
+		assert.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", file.size)), 0644))
+		assert.NoError(t, os.Chtimes(path, base.Add(-file.age), base.Add(-file.age)))
+	}
+
+	list := func(query string) (int, ListDownloadedFilesResponse) {
+		rec := httptest.NewRecorder()
+		h.ListDownloadedFiles(rec, httptest.NewRequest(http.MethodGet, "/download/list"+query, nil))
+		var resp ListDownloadedFilesResponse
+		if rec.Code == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		}
+		return rec.Code, resp
+	}
+	names := func(resp ListDownloadedFilesResponse) []string {
+		names := []string{}
+		for _, file := range resp.Files {
+			names = append(names, file.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4"}},
+		{"?order=desc", []string{"d.mp4", "c.mp4", "b.mp4", "a.mp4"}},
+		{"?sort=size", []string{"a.mp4", "d.mp4", "b.mp4", "c.mp4"}},
+		{"?sort=size&order=desc", []string{"c.mp4", "b.mp4", "d.mp4", "a.mp4"}},
+		{"?sort=date", []string{"d.mp4", "c.mp4", "b.mp4", "a.mp4"}},
+		{"?sort=date&order=desc&limit=2", []string{"a.mp4", "b.mp4"}},
+		{"?sort=date&order=desc&limit=2&offset=2", []string{"c.mp4", "d.mp4"}},
+		{"?limit=3&offset=3", []string{"d.mp4"}},
+		{"?offset=10", []string{}},
+	}
+	for _, tt := range tests {
+		code, resp := list(tt.query)
+		assert.Equal(t, http.StatusOK, code, tt.query)
+		assert.Equal(t, tt.want, names(resp), tt.query)
+		assert.Equal(t, 4, resp.Total, tt.query)
+	}
+
+	for _, query := range []string{"?sort=color", "?order=up", "?limit=-1", "?offset=x"} {
+		code, _ := list(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestDownloadVideoHandler_ListDownloadedFilesByType(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	cfg.VideoDir, cfg.AudioDir = t.TempDir(), t.TempDir()
+	h := NewDownloadVideoHandler(downloader)
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.VideoDir, "v.mp4"), nil, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.AudioDir, "a.mp3"), nil, 0644))
+
+	for query, want := range map[string][]string{
+		"":            {"a.mp3", "v.mp4"},
+		"?type=video": {"v.mp4"},
+		"?type=audio": {"a.mp3"},
+	} {
+		rec := httptest.NewRecorder()
+		h.ListDownloadedFiles(rec, httptest.NewRequest(http.MethodGet, "/download/list"+query, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, query)
+		var resp ListDownloadedFilesResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		var names []string
+		for _, file := range resp.Files {
+			names = append(names, file.Name)
+		}
+		assert.Equal(t, want, names, query)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ListDownloadedFiles(rec, httptest.NewRequest(http.MethodGet, "/download/list?type=image", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Files are served from their own directory
+	req := httptest.NewRequest(http.MethodGet, "/download/audio/a.mp3", nil)
+	req.SetPathValue("filename", "a.mp3")
+	rec = httptest.NewRecorder()
+	NewDownloadAudioHandler(downloader).ServeDownloadedAudio(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
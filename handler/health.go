@@ -1,20 +1,157 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gostreampuller/config"
 )
 
-// HealthHandler handles health check requests.
-type HealthHandler struct{}
+// readyCheckTimeout bounds each external tool check run by the readiness probe.
+const readyCheckTimeout = 5 * time.Second
+
+// readyCacheTTL is how long a readiness result is reused. The readiness
+// probe is exempt from MAX_INFLIGHT_REQUESTS, so however often it is polled,
+// it runs its checks at most once per readyCacheTTL.
+const readyCacheTTL = 5 * time.Second
+
+// HealthHandler handles liveness and readiness probes.
+type HealthHandler struct {
+	cfg *config.Config
+
+	// The last readiness result, see readiness. readyMu is held while the
+	// checks run, so concurrent probes wait for one result.
+	readyMu     sync.Mutex
+	readyResp   ReadinessResponse
+	readyStatus int
+	readyAt     time.Time
+}
 
 // NewHealthHandler creates a new health check handler.
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+func NewHealthHandler(cfg *config.Config) *HealthHandler {
+	return &HealthHandler{cfg: cfg}
 }
 
-// Handle processes health check requests.
+// Handle processes liveness probes. It never spawns processes or touches the
+// disk, so it stays cheap enough to be polled frequently.
+//
+//	@Summary		Liveness probe
+//	@Description	Returns OK as long as the HTTP server is able to respond.
+//	@Tags			health
+//	@Produce		plain
+//	@Success		200	{string}	string	"OK"
+//	@Router			/health [get]
+//	@Router			/health [head]
 func (h *HealthHandler) Handle(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "OK")
 }
+
+// ReadinessResponse represents the response body of the readiness probe.
+type ReadinessResponse struct {
+	Status string            `json:"status"` // "ready" or "not_ready"
+	Checks map[string]string `json:"checks"` // Check name to "ok" or the failure reason
+}
+
+// Ready processes readiness probes. Unlike Handle, it runs yt-dlp and ffmpeg
+// and writes to the download directory, so its result is reused for
+// readyCacheTTL.
+//
+//	@Summary		Readiness probe
+//	@Description	Checks that yt-dlp and ffmpeg are runnable and the download directories (including VIDEO_DIR and AUDIO_DIR when set apart) are writable. The result is cached for 5 seconds.
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	ReadinessResponse	"Service is ready to accept work"
+//	@Failure		503	{object}	ReadinessResponse	"One or more checks failed"
+//	@Router			/ready [get]
+//	@Router			/ready [head]
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	resp, status := h.readiness(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// readiness returns the readiness result and its status code, running the
+// checks again when the last result is older than readyCacheTTL.
+func (h *HealthHandler) readiness(ctx context.Context) (ReadinessResponse, int) {
+	h.readyMu.Lock()
+	defer h.readyMu.Unlock()
+	if !h.readyAt.IsZero() && time.Since(h.readyAt) < readyCacheTTL {
+		return h.readyResp, h.readyStatus
+	}
+
+	// The result is shared, so a probe hanging up must not fail the checks
+	ctx = context.WithoutCancel(ctx)
+	checks := map[string]string{
+		"yt-dlp":       checkResult(runVersion(ctx, h.cfg.YTDLPPath, "--version")),
+		"ffmpeg":       checkResult(runVersion(ctx, h.cfg.FFMPEGPath, "-version")),
+		"download_dir": checkResult(checkWritable(h.cfg.DownloadDir)),
+	}
+	if h.cfg.VideoDir != "" && h.cfg.VideoDir != h.cfg.DownloadDir {
+		checks["video_dir"] = checkResult(checkWritable(h.cfg.VideoDir))
+	}
+	if h.cfg.AudioDir != "" && h.cfg.AudioDir != h.cfg.DownloadDir {
+		checks["audio_dir"] = checkResult(checkWritable(h.cfg.AudioDir))
+	}
+
+	resp := ReadinessResponse{Status: "ready", Checks: checks}
+	status := http.StatusOK
+	for name, result := range checks {
+		if result != "ok" {
+			slog.Warn("Readiness check failed", "check", name, "reason", result)
+			resp.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	h.readyResp, h.readyStatus, h.readyAt = resp, status, time.Now()
+	return resp, status
+}
+
+// checkResult converts a check error into its reported value.
+func checkResult(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+// runVersion runs an executable with its version flag to prove it is runnable.
+func runVersion(ctx context.Context, path, versionFlag string) error {
+	_, err := toolVersion(ctx, path, versionFlag)
+	return err
+}
+
+// toolVersion runs an executable with its version flag and returns the first
+// line it prints, e.g. "2024.08.06" for yt-dlp.
+func toolVersion(ctx context.Context, path, versionFlag string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, versionFlag).Output()
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not runnable: %w", path, err)
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return version, nil
+}
+
+// checkWritable verifies a file can be created in dir.
+func checkWritable(dir string) error {
+	testFile := filepath.Join(dir, ".ready_check")
+	if err := os.WriteFile(testFile, []byte("ready"), 0644); err != nil {
+		return fmt.Errorf("'%s' is not writable: %w", dir, err)
+	}
+	os.Remove(testFile)
+	return nil
+}
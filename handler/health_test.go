@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/config"
+)
+
+func TestHealthHandler_Liveness(t *testing.T) {
+	// Liveness must not depend on the tools being present.
+	h := NewHealthHandler(&config.Config{YTDLPPath: "/nonexistent/yt-dlp"})
+
+	rec := httptest.NewRecorder()
+	h.Handle(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "OK", rec.Body.String())
+}
+
+func TestHealthHandler_Ready(t *testing.T) {
+	t.Run("AllChecksPass", func(t *testing.T) {
+		h := NewHealthHandler(&config.Config{
+			YTDLPPath:   "true",
+			FFMPEGPath:  "true",
+			DownloadDir: t.TempDir(),
+		})
+
+		rec := httptest.NewRecorder()
+		h.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp ReadinessResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, "ready", resp.Status)
+		assert.Equal(t, "ok", resp.Checks["yt-dlp"])
+		assert.Equal(t, "ok", resp.Checks["ffmpeg"])
+		assert.Equal(t, "ok", resp.Checks["download_dir"])
+	})
+
+	t.Run("MissingTool", func(t *testing.T) {
+		h := NewHealthHandler(&config.Config{
+			YTDLPPath:   "/nonexistent/yt-dlp",
+			FFMPEGPath:  "true",
+			DownloadDir: t.TempDir(),
+		})
+
+		rec := httptest.NewRecorder()
+		h.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		var resp ReadinessResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, "not_ready", resp.Status)
+		assert.Contains(t, resp.Checks["yt-dlp"], "/nonexistent/yt-dlp")
+		assert.Equal(t, "ok", resp.Checks["ffmpeg"])
+	})
+
+	t.Run("MissingDownloadDir", func(t *testing.T) {
+		h := NewHealthHandler(&config.Config{
+			YTDLPPath:   "true",
+			FFMPEGPath:  "true",
+			DownloadDir: "/nonexistent/download/dir",
+		})
+
+		rec := httptest.NewRecorder()
+		h.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
+
+func TestHealthHandler_ReadyCached(t *testing.T) {
+	runs := filepath.Join(t.TempDir(), "runs")
+	tool := writeScript(t, "tool", "#!/bin/sh\necho run >> "+runs+"\n")
+	h := NewHealthHandler(&config.Config{
+		YTDLPPath:   tool,
+		FFMPEGPath:  "true",
+		DownloadDir: t.TempDir(),
+	})
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		h.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	out, err := os.ReadFile(runs)
+	assert.NoError(t, err)
+	assert.Equal(t, "run\n", string(out), "the checks run once per readyCacheTTL")
+
+	// An expired result is checked again
+	h.readyAt = h.readyAt.Add(-readyCacheTTL)
+	h.Ready(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ready", nil))
+	out, _ = os.ReadFile(runs)
+	assert.Equal(t, "run\nrun\n", string(out))
+}
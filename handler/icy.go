@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"gostreampuller/service"
+)
+
+// ICY (Shoutcast) headers shown by internet-radio clients.
+const (
+	ICYNameHeader    = "icy-name" // Station name, here the video title
+	ICYBitrateHeader = "icy-br"   // Bitrate in kbit/s
+)
+
+// setICYHeaders sets the ICY headers for an audio stream of info encoded at
+// bitrate (e.g. "128k"). icy-br is left out when the bitrate is not in
+// kbit/s, as for lossless formats. It must be called before the body is written.
+func setICYHeaders(w http.ResponseWriter, info *service.VideoInfo, bitrate string) {
+	if info == nil {
+		return
+	}
+
+	name := info.Title
+	if name == "" {
+		name = info.ID
+	}
+	if runes := []rune(name); len(runes) > maxHeaderTitleRunes {
+		name = string(runes[:maxHeaderTitleRunes])
+	}
+	w.Header().Set(ICYNameHeader, name)
+
+	// Only "<n>k" bitrates are kbit/s; yt-dlp also takes 0-10 VBR qualities
+	if kbps, ok := strings.CutSuffix(strings.ToLower(bitrate), "k"); ok && kbps != "" {
+		w.Header().Set(ICYBitrateHeader, kbps)
+	}
+}
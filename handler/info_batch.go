@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"gostreampuller/service"
+)
+
+// maxInfoBatchURLs caps the URLs of one batch info request.
+const maxInfoBatchURLs = 100
+
+// BatchVideoInfoRequest represents the request body for a batch info lookup.
+type BatchVideoInfoRequest struct {
+	URLs []string `json:"urls"`
+}
+
+// BatchVideoInfoResult is the info of one URL of a batch, or why it could
+// not be fetched.
+type BatchVideoInfoResult struct {
+	URL       string             `json:"url"`
+	VideoInfo *service.VideoInfo `json:"videoInfo,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	TimedOut  bool               `json:"timedOut,omitempty"`
+}
+
+// BatchVideoInfoResponse represents the response body for a batch info lookup.
+type BatchVideoInfoResponse struct {
+	Results []BatchVideoInfoResult `json:"results"`
+	Failed  int                    `json:"failed"`
+	Message string                 `json:"message"`
+}
+
+// GetVideoInfoBatch handles requests to get the information of several
+// videos at once, e.g. to build a list view.
+//
+//	@Summary		Get information for several videos
+//	@Description	Retrieves metadata for up to 100 URLs, fetching INFO_BATCH_CONCURRENCY of them at once and reusing the info cache. A URL that fails does not fail the request: its result carries the error instead of the info. Results are in the order of the request.
+//	@Tags			download
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		BatchVideoInfoRequest	true	"Batch video info request"
+//	@Success		200		{object}	BatchVideoInfoResponse	"Video information retrieved, possibly with per-URL errors"
+//	@Failure		400		{object}	ErrorResponse			"Invalid request payload, no URLs, an empty URL or more than 100 URLs"
+//	@Router			/download/video/info/batch [post]
+func (h *DownloadVideoHandler) GetVideoInfoBatch(w http.ResponseWriter, r *http.Request) {
+	var req BatchVideoInfoRequest
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
+		slog.Error("Failed to decode request body for batch video info", "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.URLs) == 0 {
+		http.Error(w, NewErrorResponse("At least one URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+	if len(req.URLs) > maxInfoBatchURLs {
+		http.Error(w, NewErrorResponse(fmt.Sprintf("At most %d URLs can be looked up at once", maxInfoBatchURLs)).ToJson(), http.StatusBadRequest)
+		return
+	}
+	for i, url := range req.URLs {
+		if url == "" {
+			http.Error(w, NewErrorResponse(fmt.Sprintf("URL %d is empty", i)).ToJson(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	slog.Info("Attempting to get batch video info", "urls", len(req.URLs))
+
+	resp := BatchVideoInfoResponse{Results: make([]BatchVideoInfoResult, 0, len(req.URLs))}
+	for _, result := range h.downloader.GetVideoInfoBatch(r.Context(), req.URLs) {
+		item := BatchVideoInfoResult{URL: result.URL, VideoInfo: result.VideoInfo}
+		if result.Err != nil {
+			slog.Warn("Failed to get video info in batch", "error", result.Err, "url", result.URL)
+			var timeoutErr *service.InfoTimeoutError
+			item.TimedOut = errors.As(result.Err, &timeoutErr)
+			item.Error = result.Err.Error()
+			resp.Failed++
+		}
+		resp.Results = append(resp.Results, item)
+	}
+	resp.Message = fmt.Sprintf("Video information retrieved for %d of %d URLs", len(req.URLs)-resp.Failed, len(req.URLs))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+	slog.Info("Batch video information retrieved", "urls", len(req.URLs), "failed", resp.Failed)
+}
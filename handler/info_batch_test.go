@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// batchInfoScript answers info lookups with the URL's last path segment as
+// the video id, and fails for URLs containing "broken".
+const batchInfoScript = `#!/bin/sh
+for a in "$@"; do url="$a"; done
+case "$url" in *broken*) echo "ERROR: [generic] Unsupported URL: $url" >&2; exit 1 ;; esac
+echo "{\"id\":\"${url##*/}\",\"title\":\"Video ${url##*/}\"}"
+`
+
+func TestDownloadVideoHandler_GetVideoInfoBatch(t *testing.T) {
+	downloader, _ := newTestDownloader(t, batchInfoScript)
+	h := NewDownloadVideoHandler(downloader)
+
+	body := `{"urls":["https://example.com/one","https://example.com/broken","https://example.com/three"]}`
+	req := httptest.NewRequest(http.MethodPost, "/download/video/info/batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.GetVideoInfoBatch(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp BatchVideoInfoResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, 1, resp.Failed)
+	if assert.Len(t, resp.Results, 3) {
+		assert.Equal(t, "https://example.com/one", resp.Results[0].URL)
+		assert.Equal(t, "one", resp.Results[0].VideoInfo.ID)
+		assert.Empty(t, resp.Results[0].Error)
+
+		assert.Equal(t, "https://example.com/broken", resp.Results[1].URL)
+		assert.Nil(t, resp.Results[1].VideoInfo)
+		assert.Contains(t, resp.Results[1].Error, "Unsupported URL")
+
+		assert.Equal(t, "three", resp.Results[2].VideoInfo.ID)
+	}
+}
+
+func TestDownloadVideoHandler_GetVideoInfoBatchInvalid(t *testing.T) {
+	downloader, _ := newTestDownloader(t, batchInfoScript)
+	h := NewDownloadVideoHandler(downloader)
+
+	tooMany := `{"urls":["https://example.com/v"` + strings.Repeat(`,"https://example.com/v"`, maxInfoBatchURLs) + `]}`
+	for _, body := range []string{`{}`, `{"urls":[]}`, `{"urls":["https://example.com/v",""]}`, tooMany} {
+		req := httptest.NewRequest(http.MethodPost, "/download/video/info/batch", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.GetVideoInfoBatch(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"gostreampuller/service"
+)
+
+// GetPlaylistInfoResponse represents the response body for a playlist listing.
+type GetPlaylistInfoResponse struct {
+	Playlist *service.PlaylistInfo `json:"playlist"`
+	Message  string                `json:"message"`
+}
+
+// GetPlaylistInfo lists a playlist's entries without downloading anything,
+// so clients can see what a playlist holds before fetching its videos.
+//
+//	@Summary		Get playlist information
+//	@Description	Lists the title and entries (id, title, duration, url) of a playlist without downloading or extracting each video. At most PLAYLIST_MAX_ENTRIES entries are returned; truncated is set when there are more.
+//	@Tags			download
+//	@Produce		json
+//	@Param			url	query		string					true	"Playlist URL"
+//	@Success		200	{object}	GetPlaylistInfoResponse	"Playlist information retrieved successfully"
+//	@Failure		400	{object}	ErrorResponse			"Missing URL"
+//	@Failure		403	{object}	ErrorResponse			"The playlist's extractor is not in ALLOWED_EXTRACTORS"
+//	@Failure		500	{object}	ErrorResponse			"Internal server error during playlist info retrieval"
+//	@Failure		504	{object}	ErrorResponse			"yt-dlp took longer than INFO_TIMEOUT"
+//	@Router			/download/playlist/info [get]
+func (h *DownloadVideoHandler) GetPlaylistInfo(w http.ResponseWriter, r *http.Request) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		slog.Error("Missing URL in playlist info request")
+		http.Error(w, NewErrorResponse("URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Attempting to get playlist info", "url", url)
+
+	playlist, err := h.downloader.GetPlaylistInfo(r.Context(), url)
+	var timeoutErr *service.InfoTimeoutError
+	if errors.As(err, &timeoutErr) {
+		slog.Error("Timed out getting playlist info", "error", err, "url", url)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusGatewayTimeout)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get playlist info", "error", err, "url", url)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to get playlist info: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GetPlaylistInfoResponse{
+		Playlist: playlist,
+		Message:  "Playlist information retrieved successfully",
+	})
+	slog.Info("Playlist information retrieved successfully", "playlistID", playlist.ID, "entries", len(playlist.Entries))
+}
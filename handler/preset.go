@@ -0,0 +1,37 @@
+package handler
+
+import "gostreampuller/config"
+
+// fillFromPreset sets *field to the preset's value when the request left it
+// empty, so explicit request fields win over the preset.
+func fillFromPreset(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// applyPreset fills the quality fields the request left empty from p.
+func (r *DownloadVideoRequest) applyPreset(p config.QualityPreset) {
+	fillFromPreset(&r.Format, p.Format)
+	fillFromPreset(&r.Resolution, p.Resolution)
+	fillFromPreset(&r.Codec, p.Codec)
+}
+
+// applyPreset fills the quality fields the request left empty from p.
+func (r *StreamVideoRequest) applyPreset(p config.QualityPreset) {
+	fillFromPreset(&r.Format, p.Format)
+	fillFromPreset(&r.Resolution, p.Resolution)
+	fillFromPreset(&r.Codec, p.Codec)
+}
+
+// applyPreset fills the quality fields the request left empty from p.
+func (r *DownloadAudioRequest) applyPreset(p config.QualityPreset) {
+	fillFromPreset(&r.OutputFormat, p.AudioFormat)
+	fillFromPreset(&r.Bitrate, p.AudioBitrate)
+}
+
+// applyPreset fills the quality fields the request left empty from p.
+func (r *StreamAudioRequest) applyPreset(p config.QualityPreset) {
+	fillFromPreset(&r.OutputFormat, p.AudioFormat)
+	fillFromPreset(&r.Bitrate, p.AudioBitrate)
+}
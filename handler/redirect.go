@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"gostreampuller/config"
+)
+
+// redirect sends a 302 to path (plus query, if any) under APP_BASE_URL. All
+// redirects go through it so none can be turned into an open redirect: the
+// target must be same-origin or on a host from cfg.RedirectHosts, and
+// anything else is refused with a 400.
+func redirect(w http.ResponseWriter, r *http.Request, cfg *config.Config, path string, query url.Values) {
+	target := cfg.AppBaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	if err := checkRedirectTarget(target, cfg.RedirectHosts()); err != nil {
+		slog.Error("Refusing redirect", "target", target, "error", err)
+		http.Error(w, "Bad Request: redirect target not allowed", http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// checkRedirectTarget accepts same-origin paths and absolute http(s) URLs on
+// one of the trusted hosts.
+func checkRedirectTarget(target string, trustedHosts []string) error {
+	// Browsers read "/\evil.example" as "//evil.example"
+	if path, _, _ := strings.Cut(target, "?"); strings.Contains(path, `\`) {
+		return errors.New("backslash in redirect path")
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid redirect target: %w", err)
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return nil
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("redirect scheme %q not allowed", u.Scheme)
+	}
+	if !slices.Contains(trustedHosts, strings.ToLower(u.Hostname())) {
+		return fmt.Errorf("redirect host %q is not trusted", u.Hostname())
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/config"
+)
+
+func TestCheckRedirectTarget(t *testing.T) {
+	trusted := []string{"app.example", "cdn.example"}
+	tests := []struct {
+		target string
+		valid  bool
+	}{
+		{"/web?url=x", true},
+		{"/?error=oops", true},
+		{"https://app.example/web", true},
+		{"http://APP.example:8080/web", true},
+		{"https://cdn.example/", true},
+		{"https://evil.example/web", false},
+		{"//evil.example/web", false},
+		{`/\evil.example/web`, false},
+		{"https://app.example@evil.example/web", false},
+		{"javascript:alert(1)", false},
+	}
+	for _, tt := range tests {
+		err := checkRedirectTarget(tt.target, trusted)
+		if tt.valid {
+			assert.NoError(t, err, tt.target)
+		} else {
+			assert.Error(t, err, tt.target)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	cfg := &config.Config{AppBaseURL: "https://app.example", TrustedRedirectHosts: "cdn.example"}
+
+	t.Run("OnHost", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		redirect(rec, httptest.NewRequest(http.MethodPost, "/load-info", nil), cfg, "/web", url.Values{"url": {"https://example.com/v"}})
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "https://app.example/web?url=https%3A%2F%2Fexample.com%2Fv", rec.Header().Get("Location"))
+	})
+
+	t.Run("OffHostIsRejected", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		redirect(rec, httptest.NewRequest(http.MethodPost, "/load-info", nil), cfg, "@evil.example/web", nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, rec.Header().Get("Location"))
+	})
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime/debug"
+)
+
+// safeCopy is io.Copy for streaming responses that turns a panic in the
+// reader or writer into an error, so a misbehaving stream only ends its own
+// response instead of unwinding through the handler.
+func safeCopy(dst io.Writer, src io.Reader) (written int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Recovered from panic while streaming", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic while streaming: %v", r)
+		}
+	}()
+	return io.Copy(dst, src)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// panickingReader returns its data once, then panics.
+type panickingReader struct {
+	data string
+	read bool
+}
+
+func (r *panickingReader) Read(p []byte) (int, error) {
+	if r.read {
+		panic("reader exploded")
+	}
+	r.read = true
+	return copy(p, r.data), nil
+}
+
+func TestSafeCopy(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := safeCopy(&buf, strings.NewReader(fakeVideoContent))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(fakeVideoContent)), n)
+	assert.Equal(t, fakeVideoContent, buf.String())
+}
+
+func TestSafeCopy_RecoversReaderPanic(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NotPanics(t, func() {
+		_, err := safeCopy(&buf, &panickingReader{data: "partial"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "reader exploded")
+	})
+	assert.Equal(t, "partial", buf.String(), "data copied before the panic is kept")
+}
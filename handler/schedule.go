@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gostreampuller/service"
+)
+
+// ScheduleHandler handles video downloads scheduled for later.
+type ScheduleHandler struct {
+	videos    *DownloadVideoHandler
+	scheduler *service.Scheduler
+}
+
+// NewScheduleHandler creates a new ScheduleHandler.
+func NewScheduleHandler(downloader *service.Downloader, scheduler *service.Scheduler) *ScheduleHandler {
+	return &ScheduleHandler{
+		videos:    NewDownloadVideoHandler(downloader),
+		scheduler: scheduler,
+	}
+}
+
+// ScheduleDownloadRequest represents the request body for scheduling a video
+// download: the fields of a video download, and when to start it.
+type ScheduleDownloadRequest struct {
+	DownloadVideoRequest
+	StartAt time.Time `json:"startAt"` // RFC 3339, e.g. "2025-01-31T02:00:00Z"; a past time starts at once
+}
+
+// ScheduledJobResponse represents the response body for a scheduled job.
+type ScheduledJobResponse struct {
+	Job     service.ScheduledJob `json:"job"`
+	Message string               `json:"message"`
+}
+
+// publicJob returns job as clients see it, with the names of its files
+// rather than their paths on the server.
+func publicJob(job service.ScheduledJob) service.ScheduledJob {
+	job.FilePath = downloadName(job.FilePath)
+	job.AudioFilePath = downloadName(job.AudioFilePath)
+	return job
+}
+
+// Schedule handles requests to download a video at a later time.
+//
+//	@Summary		Schedule a video download
+//	@Description	Queues a video download, with the options of /download/video, to start at startAt, e.g. off-peak. The options are checked right away; the download itself runs in the background, waiting for a free MAX_CONCURRENT_DOWNLOADS slot if needed, and its outcome is read from /download/schedule/{id}. Jobs are kept in memory, so those still waiting are lost on restart, and finished ones are forgotten after an hour.
+//	@Tags			download
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		ScheduleDownloadRequest	true	"Scheduled download request"
+//	@Success		202		{object}	ScheduledJobResponse	"Download scheduled"
+//	@Failure		400		{object}	ErrorResponse			"Invalid request payload, missing URL or startAt, startAt more than 7 days ahead, or invalid download options"
+//	@Failure		429		{object}	ErrorResponse			"Too many scheduled downloads pending (100)"
+//	@Router			/download/schedule [post]
+func (h *ScheduleHandler) Schedule(w http.ResponseWriter, r *http.Request) {
+	var req ScheduleDownloadRequest
+	if err := decodeJSONBody(r, &req, h.videos.downloader.StrictJSON()); err != nil {
+		slog.Error("Failed to decode request body for scheduled download", "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == "" {
+		slog.Error("Missing URL in scheduled download request")
+		http.Error(w, NewErrorResponse("URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+	if req.StartAt.IsZero() {
+		slog.Error("Missing start time in scheduled download request")
+		http.Error(w, NewErrorResponse("startAt is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := h.videos.videoOptions(&req.DownloadVideoRequest)
+	if err != nil {
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.scheduler.Schedule(service.ScheduledDownload{
+		URL:                 req.URL,
+		Options:             opts,
+		SeparateAudio:       req.SeparateAudio,
+		ProgressCallbackURL: req.ProgressCallbackURL,
+		ExpectedSHA256:      req.ExpectedSHA256,
+	}, req.StartAt)
+	if err != nil {
+		slog.Error("Failed to schedule download", "error", err, "url", req.URL)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrStartTooLate):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrTooManyJobs):
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ScheduledJobResponse{Job: publicJob(job), Message: "Download scheduled"})
+}
+
+// GetJob reports the state of a scheduled download.
+//
+//	@Summary		Get a scheduled download
+//	@Description	Returns the state of a scheduled download: scheduled, running, done (with its file paths) or failed (with the error), or cancelled.
+//	@Tags			download
+//	@Produce		json
+//	@Param			id	path		string					true	"Job ID"
+//	@Success		200	{object}	ScheduledJobResponse	"Job state"
+//	@Failure		404	{object}	ErrorResponse			"Unknown job"
+//	@Router			/download/schedule/{id} [get]
+func (h *ScheduleHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.scheduler.Job(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ScheduledJobResponse{Job: publicJob(job), Message: "Job is " + job.Status})
+}
+
+// CancelJob cancels a scheduled download that has not started yet.
+//
+//	@Summary		Cancel a scheduled download
+//	@Description	Cancels a scheduled download before its start time. Downloads already started cannot be cancelled.
+//	@Tags			download
+//	@Produce		json
+//	@Param			id	path		string					true	"Job ID"
+//	@Success		200	{object}	ScheduledJobResponse	"Job cancelled"
+//	@Failure		404	{object}	ErrorResponse			"Unknown job"
+//	@Failure		409	{object}	ErrorResponse			"Job already started"
+//	@Router			/download/schedule/{id} [delete]
+func (h *ScheduleHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.scheduler.Cancel(r.PathValue("id"))
+	if errors.Is(err, service.ErrJobStarted) {
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Cannot cancel job %s: it is %s", job.ID, job.Status)).ToJson(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Scheduled download cancelled by client", "jobID", job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ScheduledJobResponse{Job: publicJob(job), Message: "Job cancelled"})
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/service"
+)
+
+// newTestScheduleHandler returns a ScheduleHandler with a fake yt-dlp.
+func newTestScheduleHandler(t *testing.T) *ScheduleHandler {
+	t.Helper()
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	return NewScheduleHandler(downloader, service.NewScheduler(downloader))
+}
+
+// schedule posts body to h.Schedule and decodes the job it answers with.
+func schedule(t *testing.T, h *ScheduleHandler, body string) (int, service.ScheduledJob) {
+	t.Helper()
+	return serveJob(t, h.Schedule, httptest.NewRequest(http.MethodPost, "/download/schedule", strings.NewReader(body)))
+}
+
+// jobRequest sends a request for the job id to handle and decodes the job
+// it answers with.
+func jobRequest(t *testing.T, handle http.HandlerFunc, method, id string) (int, service.ScheduledJob) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/download/schedule/"+id, nil)
+	req.SetPathValue("id", id)
+	return serveJob(t, handle, req)
+}
+
+func serveJob(t *testing.T, handle http.HandlerFunc, req *http.Request) (int, service.ScheduledJob) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handle(rec, req)
+	var resp ScheduledJobResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	return rec.Code, resp.Job
+}
+
+func TestScheduleHandler_RunsJob(t *testing.T) {
+	h := newTestScheduleHandler(t)
+
+	startAt := time.Now().Add(50 * time.Millisecond).Format(time.RFC3339Nano)
+	code, job := schedule(t, h, `{"url":"https://example.com/v","startAt":"`+startAt+`"}`)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, service.JobScheduled, job.Status)
+
+	assert.Eventually(t, func() bool {
+		code, job = jobRequest(t, h.GetJob, http.MethodGet, job.ID)
+		return code == http.StatusOK && job.Status == service.JobDone
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NotEmpty(t, job.FilePath)
+	assert.Equal(t, filepath.Base(job.FilePath), job.FilePath, "no server path")
+
+	// Too late to cancel
+	code, _ = jobRequest(t, h.CancelJob, http.MethodDelete, job.ID)
+	assert.Equal(t, http.StatusConflict, code)
+}
+
+func TestScheduleHandler_Cancel(t *testing.T) {
+	h := newTestScheduleHandler(t)
+
+	startAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+	_, job := schedule(t, h, `{"url":"https://example.com/v","startAt":"`+startAt+`"}`)
+
+	code, job := jobRequest(t, h.CancelJob, http.MethodDelete, job.ID)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, service.JobCancelled, job.Status)
+
+	code, _ = jobRequest(t, h.GetJob, http.MethodGet, "job-unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestScheduleHandler_Invalid(t *testing.T) {
+	h := newTestScheduleHandler(t)
+
+	startAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+	for _, body := range []string{
+		`{"url":"https://example.com/v"}`,
+		`{"startAt":"` + startAt + `"}`,
+		`{"url":"https://example.com/v","startAt":"tomorrow"}`,
+		`{"url":"https://example.com/v","startAt":"` + startAt + `","onExists":"sometimes"}`,
+		`{"url":"https://example.com/v","startAt":"` + time.Now().Add(service.MaxScheduleAhead+time.Hour).Format(time.RFC3339) + `"}`,
+	} {
+		code, _ := schedule(t, h, body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+}
+
+func TestScheduleHandler_TooManyJobs(t *testing.T) {
+	h := newTestScheduleHandler(t)
+
+	body := `{"url":"https://example.com/v","startAt":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`
+	for range service.MaxPendingJobs {
+		code, _ := schedule(t, h, body)
+		assert.Equal(t, http.StatusAccepted, code)
+	}
+	code, _ := schedule(t, h, body)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+}
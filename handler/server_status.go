@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appMiddleware "gostreampuller/middleware"
+	"gostreampuller/service"
+)
+
+// Statuses reported by /status.
+const (
+	serverAvailable = "available"
+	serverSaturated = "saturated" // At least one limit is reached
+)
+
+// StatusHandler reports how loaded the server is, for load balancers and
+// clients to back off before they get 503s.
+type StatusHandler struct {
+	downloader *service.Downloader
+	inFlight   *appMiddleware.InFlightLimiter
+}
+
+// NewStatusHandler creates a new StatusHandler reporting the downloader's
+// stream and download limits and the in-flight request limit.
+func NewStatusHandler(downloader *service.Downloader, inFlight *appMiddleware.InFlightLimiter) *StatusHandler {
+	return &StatusHandler{downloader: downloader, inFlight: inFlight}
+}
+
+// LimitStatus is how many operations of one kind are running against their
+// limit.
+type LimitStatus struct {
+	Running   int  `json:"running"`
+	Max       int  `json:"max"`       // 0 when unlimited
+	Saturated bool `json:"saturated"` // New operations of this kind get 503
+}
+
+// newLimitStatus reports running operations against max, 0 or less meaning
+// no limit.
+func newLimitStatus(running, max int) LimitStatus {
+	return LimitStatus{
+		Running:   running,
+		Max:       max,
+		Saturated: max > 0 && running >= max,
+	}
+}
+
+// StatusResponse represents the response body of /status.
+type StatusResponse struct {
+	Status string                 `json:"status"` // "available" or "saturated"
+	Limits map[string]LimitStatus `json:"limits"` // "streams", "downloads" and "requests"
+}
+
+// Handle reports whether the server's concurrency limits are reached.
+// Requests over a limit are refused, streams and downloads once they waited
+// CONCURRENCY_WAIT, so a saturated limit means the next request of its kind
+// waits or gets 503.
+//
+//	@Summary		Concurrency status
+//	@Description	Reports the running streams, file downloads and requests in flight against MAX_CONCURRENT_STREAMS, MAX_CONCURRENT_DOWNLOADS and MAX_INFLIGHT_REQUESTS. Requests over a limit are refused with 503, streams and downloads after waiting up to CONCURRENCY_WAIT for a slot. Answers 503, with Retry-After, while any limit is saturated, so load balancers can route around the instance.
+//	@Tags			health
+//	@Produce		json
+//	@Success		200	{object}	StatusResponse	"No limit reached"
+//	@Failure		503	{object}	StatusResponse	"At least one limit reached"
+//	@Router			/status [get]
+func (h *StatusHandler) Handle(w http.ResponseWriter, _ *http.Request) {
+	resp := StatusResponse{
+		Status: serverAvailable,
+		Limits: map[string]LimitStatus{
+			"streams":   newLimitStatus(h.downloader.StreamLoad()),
+			"downloads": newLimitStatus(h.downloader.DownloadLoad()),
+			"requests":  newLimitStatus(h.inFlight.Load()),
+		},
+	}
+	status := http.StatusOK
+	for _, limit := range resp.Limits {
+		if limit.Saturated {
+			resp.Status = serverSaturated
+			status = http.StatusServiceUnavailable
+			w.Header().Set("Retry-After", "1")
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
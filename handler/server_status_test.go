@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	appMiddleware "gostreampuller/middleware"
+	"gostreampuller/service"
+)
+
+func TestStatusHandler(t *testing.T) {
+	cfg := newTestConfig(t, fakeYTDLPScript)
+	cfg.MaxConcurrentStreams = 1
+	downloader := service.NewDownloader(cfg, service.NewProgressManager(0))
+	h := NewStatusHandler(downloader, appMiddleware.NewInFlightLimiter(0))
+
+	get := func() (*httptest.ResponseRecorder, StatusResponse) {
+		rec := httptest.NewRecorder()
+		h.Handle(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+		var resp StatusResponse
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return rec, resp
+	}
+
+	rec, resp := get()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "available", resp.Status)
+	assert.Equal(t, LimitStatus{Running: 0, Max: 1}, resp.Limits["streams"])
+	assert.Equal(t, LimitStatus{}, resp.Limits["downloads"])
+	assert.Equal(t, LimitStatus{}, resp.Limits["requests"])
+
+	// A running stream takes the only stream slot
+	stream, _, err := downloader.StreamVideo(context.Background(), "https://example.com/v", service.VideoOptions{}, "")
+	assert.NoError(t, err)
+	rec, resp = get()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "saturated", resp.Status)
+	assert.Equal(t, LimitStatus{Running: 1, Max: 1, Saturated: true}, resp.Limits["streams"])
+	assert.False(t, resp.Limits["downloads"].Saturated)
+
+	io.Copy(io.Discard, stream)
+	assert.NoError(t, stream.Close())
+	rec, resp = get()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, LimitStatus{Running: 0, Max: 1}, resp.Limits["streams"])
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"gostreampuller/service"
+)
+
+// errorStatus returns the status code for a failed download or stream: 503
+// when a concurrency limit is reached, so clients know to retry later, 422
+// for a video over MAX_DURATION or a download not matching its expected
+// checksum, 403 for a site outside ALLOWED_EXTRACTORS, and 500 for anything
+// else.
+func errorStatus(err error) int {
+	if errors.Is(err, service.ErrTooManyStreams) || errors.Is(err, service.ErrTooManyDownloads) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, service.ErrLiveSave) {
+		return http.StatusUnprocessableEntity
+	}
+	var durationErr *service.DurationLimitError
+	if errors.As(err, &durationErr) {
+		return http.StatusUnprocessableEntity
+	}
+	var checksumErr *service.ChecksumMismatchError
+	if errors.As(err, &checksumErr) {
+		return http.StatusUnprocessableEntity
+	}
+	var extractorErr *service.ExtractorNotAllowedError
+	if errors.As(err, &extractorErr) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
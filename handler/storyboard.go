@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gostreampuller/service"
+)
+
+// defaultStoryboardInterval is the time between storyboard thumbnails when
+// the client does not ask for one.
+const defaultStoryboardInterval = 10 * time.Second
+
+// ServeStoryboard serves the storyboard of a downloaded video as a WebVTT
+// thumbnails track, for players showing previews over the scrubber.
+//
+//	@Summary		Get a downloaded video's storyboard
+//	@Description	Returns a WebVTT thumbnails track for a video in the download directory. Each cue covers one interval and points to its thumbnail in a sprite image, with a #xywh= fragment; the sprite URL is relative to this track. The interval is rounded up to 1, 2, 5, 10, 15, 30 or 60 seconds. The sprite is generated with ffmpeg on first request, taking one of the MAX_CONCURRENT_DOWNLOADS slots, and kept next to the video until the video is deleted. For long videos the interval is stretched so a sprite holds at most 400 thumbnails.
+//	@Tags			download
+//	@Produce		text/vtt
+//	@Param			filename	path		string			true	"Filename of the downloaded video"
+//	@Param			interval	query		int				false	"Seconds between thumbnails, 1 to 60 (default 10)"
+//	@Success		200			{string}	string			"WebVTT thumbnails track"
+//	@Failure		400			{object}	ErrorResponse	"Missing filename or invalid interval"
+//	@Failure		404			{object}	ErrorResponse	"File not found"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Failure		503			{object}	ErrorResponse	"Too many concurrent downloads (MAX_CONCURRENT_DOWNLOADS)"
+//	@Router			/download/video/{filename}/storyboard.vtt [get]
+func (h *DownloadVideoHandler) ServeStoryboard(w http.ResponseWriter, r *http.Request) {
+	filename := r.PathValue("filename")
+	if filename == "" {
+		slog.Error("Missing filename for serving storyboard")
+		http.Error(w, NewErrorResponse("Filename is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	interval := defaultStoryboardInterval
+	if value := r.URL.Query().Get("interval"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || time.Duration(n)*time.Second > service.MaxStoryboardInterval {
+			http.Error(w, NewErrorResponse(fmt.Sprintf("interval must be a whole number of seconds between 1 and %d", int(service.MaxStoryboardInterval.Seconds()))).ToJson(), http.StatusBadRequest)
+			return
+		}
+		interval = time.Duration(n) * time.Second
+	}
+
+	filePath := h.downloader.ResolveDownload(filename)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		slog.Warn("Downloaded video file not found", "filePath", filePath)
+		http.Error(w, NewErrorResponse("File not found").ToJson(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.Error("Error checking file existence", "filePath", filePath, "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Error accessing file: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	_, vtt, err := h.downloader.GenerateStoryboard(r.Context(), filePath, interval)
+	if err != nil {
+		slog.Error("Failed to generate storyboard", "filePath", filePath, "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to generate storyboard: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Write([]byte(vtt))
+}
+
+// ServeStoryboardSprite serves a storyboard sprite generated for a
+// downloaded video, as referenced by its WebVTT track.
+//
+//	@Summary		Get a downloaded video's storyboard sprite
+//	@Description	Returns a storyboard sprite image generated by /download/video/{filename}/storyboard.vtt. Sprite names come from the cues of that track.
+//	@Tags			download
+//	@Produce		image/jpeg
+//	@Param			filename	path		string			true	"Filename of the downloaded video"
+//	@Param			sprite		path		string			true	"Sprite name from the storyboard track"
+//	@Success		200			{file}		file			"Storyboard sprite"
+//	@Failure		404			{object}	ErrorResponse	"Sprite not found"
+//	@Router			/download/video/{filename}/{sprite} [get]
+func (h *DownloadVideoHandler) ServeStoryboardSprite(w http.ResponseWriter, r *http.Request) {
+	filePath := h.downloader.ResolveDownload(r.PathValue("filename"))
+	sprite := r.PathValue("sprite")
+
+	// Only the video's own sprites are served from here
+	prefix := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + ".storyboard-"
+	if filepath.Base(sprite) != sprite || !strings.HasPrefix(sprite, prefix) || !strings.HasSuffix(sprite, ".jpg") {
+		http.Error(w, NewErrorResponse("Sprite not found").ToJson(), http.StatusNotFound)
+		return
+	}
+	spritePath := filepath.Join(filepath.Dir(filePath), sprite)
+	if _, err := os.Stat(spritePath); err != nil {
+		http.Error(w, NewErrorResponse("Sprite not found").ToJson(), http.StatusNotFound)
+		return
+	}
+	http.ServeFile(w, r, spritePath)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/service"
+)
+
+// fakeFFmpegFifteenSeconds describes every input as a 15s video and writes
+// "sprite" to the output file when given one.
+const fakeFFmpegFifteenSeconds = `#!/bin/sh
+for a in "$@"; do out="$a"; done
+case "$out" in *.jpg) printf 'sprite' > "$out"; exit 0 ;; esac
+echo "  Duration: 00:00:15.00, start: 0.000000, bitrate: 1 kb/s" >&2
+exit 1
+`
+
+func TestDownloadVideoHandler_ServeStoryboard(t *testing.T) {
+	cfg := newTestConfig(t, fakeYTDLPScript)
+	cfg.FFMPEGPath = writeScript(t, "ffmpeg", fakeFFmpegFifteenSeconds)
+	h := NewDownloadVideoHandler(service.NewDownloader(cfg, service.NewProgressManager(0)))
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.DownloadDir, "abc123.mp4"), []byte("video"), 0644))
+
+	serve := func(filename, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/download/video/"+filename+"/storyboard.vtt"+query, nil)
+		req.SetPathValue("filename", filename)
+		rec := httptest.NewRecorder()
+		h.ServeStoryboard(rec, req)
+		return rec
+	}
+	serveSprite := func(filename, sprite string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/download/video/"+filename+"/"+sprite, nil)
+		req.SetPathValue("filename", filename)
+		req.SetPathValue("sprite", sprite)
+		rec := httptest.NewRecorder()
+		h.ServeStoryboardSprite(rec, req)
+		return rec
+	}
+
+	rec := serve("abc123.mp4", "?interval=5")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/vtt; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "00:00:10.000 --> 00:00:15.000\nabc123.storyboard-5s.jpg#xywh=320,0,160,90\n")
+
+	rec = serveSprite("abc123.mp4", "abc123.storyboard-5s.jpg")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "sprite", rec.Body.String())
+
+	// Only the video's own sprites are served
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.DownloadDir, "other.jpg"), []byte("other"), 0644))
+	assert.Equal(t, http.StatusNotFound, serveSprite("abc123.mp4", "other.jpg").Code)
+	assert.Equal(t, http.StatusNotFound, serveSprite("abc123.mp4", "abc123.storyboard-60s.jpg").Code)
+
+	assert.Equal(t, http.StatusBadRequest, serve("abc123.mp4", "?interval=0").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("abc123.mp4", "?interval=61").Code)
+	assert.Equal(t, http.StatusNotFound, serve("missing.mp4", "").Code)
+
+	// Sprites are not listed as downloads, and are deleted with their video
+	rec = httptest.NewRecorder()
+	h.ListDownloadedFiles(rec, httptest.NewRequest(http.MethodGet, "/download/list", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "abc123.mp4")
+	assert.NotContains(t, rec.Body.String(), ".storyboard-")
+
+	req := httptest.NewRequest(http.MethodDelete, "/download/delete/abc123.mp4", nil)
+	req.SetPathValue("filename", "abc123.mp4")
+	rec = httptest.NewRecorder()
+	h.DeleteDownloadedFile(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoFileExists(t, filepath.Join(cfg.DownloadDir, "abc123.storyboard-5s.jpg"))
+	assert.FileExists(t, filepath.Join(cfg.DownloadDir, "other.jpg"))
+}
@@ -1,9 +1,7 @@
 package handler
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 
@@ -28,22 +26,36 @@ type StreamAudioRequest struct {
 	OutputFormat string `json:"outputFormat"`
 	Codec        string `json:"codec"`
 	Bitrate      string `json:"bitrate"`
+	Save         bool   `json:"save"`   // Also save the stream to the download directory
+	ICY          bool   `json:"icy"`    // Send icy-name and icy-br headers for internet-radio clients
+	Preset       string `json:"preset"` // Named quality preset, e.g. "hd"; explicit outputFormat and bitrate win
 }
 
 // Handle handles the audio streaming request.
+// With save set, the stream is also written to the download directory as it
+// goes; a client disconnecting does not stop the save.
 //	@Summary		Stream an audio file
-//	@Description	Streams an audio file directly from the source URL.
+//	@Description	Streams an audio file directly from the source URL. Set save to also write the stream to the download directory in the same pass; X-Saved-File names the file, which is completed even if the client disconnects, within STREAM_SAVE_TIMEOUT. Live sources and videos over MAX_DURATION cannot be saved.
 //	@Tags			stream
 //	@Accept			json
 //	@Produce		audio/mpeg
 //	@Param			request	body		StreamAudioRequest	true	"Audio stream request"
 //	@Success		200		{file}		file				"Successfully streamed audio"
-//	@Failure		400		{object}	ErrorResponse		"Invalid request payload or missing URL"
+//	@Header			200		{string}	X-Video-Info		"Base64-encoded JSON metadata of the source video"
+//	@Header			200		{string}	X-Stream-Status		"Trailer: complete or error, sent after the body"
+//	@Header			200		{string}	X-Stream-Bytes		"Trailer: bytes of media sent"
+//	@Header			200		{string}	X-Saved-File		"Name of the saved file, only with save"
+//	@Header			200		{string}	icy-name			"Title of the source video, only with icy"
+//	@Header			200		{string}	icy-br				"Bitrate in kbit/s, only with icy and a lossy format"
+//	@Failure		400		{object}	ErrorResponse		"Invalid request payload, missing URL, unknown preset or codec not valid for the output format"
+//	@Failure		403		{object}	ErrorResponse		"URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS"
+//	@Failure		422		{object}	ErrorResponse		"save requested for a live source or a video longer than MAX_DURATION"
 //	@Failure		500		{object}	ErrorResponse		"Internal server error during audio streaming"
+//	@Failure		503		{object}	ErrorResponse		"Too many concurrent streams (MAX_CONCURRENT_STREAMS)"
 //	@Router			/stream/audio [post]
 func (h *StreamAudioHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var req StreamAudioRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
 		slog.Error("Failed to decode request body", "error", err)
 		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
 		return
@@ -55,27 +67,82 @@ func (h *StreamAudioHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	preset, err := h.downloader.QualityPreset(req.Preset)
+	if err != nil {
+		slog.Error("Unknown quality preset", "error", err, "preset", req.Preset)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+	req.applyPreset(preset)
+
+	opts := service.AudioOptions{Format: req.OutputFormat, Codec: req.Codec, Bitrate: req.Bitrate}
+	if err := opts.Validate(); err != nil {
+		slog.Error("Invalid audio options", "error", err)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
 	slog.Info("Attempting to stream audio", "url", req.URL, "outputFormat", req.OutputFormat, "codec", req.Codec, "bitrate", req.Bitrate)
 
+	ctx, done := r.Context(), func() {}
+	if req.Save {
+		// Detached from the request so the save finishes if the client goes away
+		ctx, done = h.downloader.SaveContext(ctx)
+	}
+
 	// Pass an empty string for progressID as this API endpoint doesn't have an SSE client
-	readCloser, err := h.downloader.StreamAudio(r.Context(), req.URL, req.OutputFormat, req.Codec, req.Bitrate, "")
+	readCloser, videoInfo, err := h.downloader.StreamAudio(ctx, req.URL, req.OutputFormat, req.Codec, req.Bitrate, "")
 	if err != nil {
+		done()
 		slog.Error("Failed to stream audio", "error", err, "url", req.URL)
-		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream audio: %v", err)).ToJson(), http.StatusInternalServerError)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream audio: %v", err)).ToJson(), errorStatus(err))
 		return
 	}
-	defer readCloser.Close()
+
+	var stream *service.SavingStream
+	if req.Save {
+		if err := h.downloader.CheckSavable(videoInfo); err != nil {
+			readCloser.Close()
+			done()
+			slog.Error("Refused to save audio stream", "error", err, "url", req.URL)
+			http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to save audio stream: %v", err)).ToJson(), errorStatus(err))
+			return
+		}
+		ext := req.OutputFormat
+		if ext == "" {
+			ext = "mp3"
+		}
+		if stream, err = h.downloader.SaveStream(readCloser, service.MediaAudio, videoInfo.ID, ext); err != nil {
+			readCloser.Close()
+			done()
+			slog.Error("Failed to save audio stream", "error", err, "url", req.URL)
+			http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to save audio stream: %v", err)).ToJson(), http.StatusInternalServerError)
+			return
+		}
+		setSavedFileHeader(w, stream.Path())
+	} else {
+		defer readCloser.Close()
+	}
 
 	// Set appropriate headers for audio streaming
 	w.Header().Set("Content-Type", "audio/mpeg") // Assuming mp3 for now, can be dynamic
 	w.Header().Set("Transfer-Encoding", "chunked")
 	w.Header().Set("Cache-Control", "no-cache")
+	setVideoInfoHeader(w, videoInfo)
+	if req.ICY {
+		setICYHeaders(w, videoInfo, opts.EffectiveBitrate())
+	}
 
 	slog.Info("Starting audio stream", "url", req.URL)
-	if _, err := io.Copy(w, readCloser); err != nil {
+	if stream != nil {
+		_, err = copyAndSave(w, stream, done)
+	} else {
+		_, err = copyWithTrailers(w, readCloser)
+	}
+	if err != nil {
 		slog.Error("Error while streaming audio", "error", err, "url", req.URL)
 		// Note: Cannot send HTTP error after headers have been written and body started.
-		// The client might just see a broken stream.
+		// The X-Stream-Status trailer tells the client the stream is broken.
 	}
 	slog.Info("Audio stream finished", "url", req.URL)
 }
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamAudioHandler_RejectsCodecMismatch(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamAudioHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/audio", strings.NewReader(`{"url":"https://example.com/v","outputFormat":"m4a","codec":"libmp3lame"}`))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "valid codecs: aac, libfdk_aac, alac")
+}
+
+func TestStreamAudioHandler_ICYHeaders(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamAudioHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/audio", strings.NewReader(`{"url":"https://example.com/v","bitrate":"192k","icy":true}`))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Test Video", rec.Header().Get(ICYNameHeader))
+	assert.Equal(t, "192", rec.Header().Get(ICYBitrateHeader))
+
+	// Without icy, no ICY headers
+	req = httptest.NewRequest(http.MethodPost, "/stream/audio", strings.NewReader(`{"url":"https://example.com/v"}`))
+	rec = httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(ICYNameHeader))
+	assert.Empty(t, rec.Header().Get(ICYBitrateHeader))
+}
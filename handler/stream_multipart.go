@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+
+	"gostreampuller/service"
+)
+
+// StreamWithSubtitlesRequest represents the request body for a video stream
+// bundled with its subtitles.
+type StreamWithSubtitlesRequest struct {
+	URL          string `json:"url"`
+	Format       string `json:"format"`
+	Resolution   string `json:"resolution"`
+	Codec        string `json:"codec"`
+	SubtitleLang string `json:"subtitleLang"` // Comma-separated preference chain, e.g. "en,en-US,auto"
+}
+
+// StreamWithSubtitles streams a video and its subtitles in one
+// multipart/mixed response, saving clients a second round-trip.
+//
+// The response has exactly two parts, in this order:
+//  1. the subtitles, "application/x-subrip", with Content-Language set to the
+//     language picked from the preference chain;
+//  2. the video, "video/mp4" unless another format was asked for, streamed
+//     live from yt-dlp.
+//
+// Subtitles come first because they are small and fully fetched before
+// anything is written, so a missing subtitle track still fails with a normal
+// error response. Both parts carry a Content-Disposition filename.
+//
+//	@Summary		Stream a video with its subtitles
+//	@Description	Returns a multipart/mixed response: first an application/x-subrip part with the subtitles (Content-Language tells which language was picked), then a part with the live video stream (video/mp4, or video/webm or video/x-matroska for those formats). The boundary is in the Content-Type header.
+//	@Tags			stream
+//	@Accept			json
+//	@Produce		multipart/mixed
+//	@Param			request	body		StreamWithSubtitlesRequest	true	"Video and subtitle stream request"
+//	@Success		200		{file}		file						"Multipart response with subtitle and video parts"
+//	@Header			200		{string}	X-Video-Info				"Base64-encoded JSON metadata of the video"
+//	@Failure		400		{object}	ErrorResponse				"Invalid request payload, missing URL, or a format other than mp4, webm or mkv"
+//	@Failure		403		{object}	ErrorResponse				"URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS"
+//	@Failure		500		{object}	ErrorResponse				"Subtitles unavailable or internal server error during streaming"
+//	@Failure		503		{object}	ErrorResponse				"Too many concurrent streams (MAX_CONCURRENT_STREAMS)"
+//	@Router			/stream/video/multipart [post]
+func (h *StreamVideoHandler) StreamWithSubtitles(w http.ResponseWriter, r *http.Request) {
+	var req StreamWithSubtitlesRequest
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
+		slog.Error("Failed to decode request body", "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == "" {
+		slog.Error("Missing URL in multipart stream request")
+		http.Error(w, NewErrorResponse("URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateStreamContainer(req.Format, req.Codec); err != nil {
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Attempting to stream video with subtitles", "url", req.URL, "subtitleLang", req.SubtitleLang)
+
+	lang, subtitles, err := h.downloader.FetchSubtitles(r.Context(), req.URL, req.SubtitleLang)
+	if err != nil {
+		slog.Error("Failed to fetch subtitles", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to fetch subtitles: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Format == "" {
+		req.Format = "mp4"
+	}
+	opts := service.VideoOptions{
+		Format:     req.Format,
+		Resolution: req.Resolution,
+		Codec:      req.Codec,
+	}
+
+	// The video is written into its part, so both parts are created once the
+	// stream is ready
+	mw := multipart.NewWriter(w)
+	part := &partWriter{}
+	started := false
+	_, err = h.downloader.StreamVideoTo(r.Context(), part, req.URL, opts, "", func(videoInfo *service.VideoInfo) error {
+		started = true
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		w.Header().Set("Cache-Control", "no-cache")
+		setVideoInfoHeader(w, videoInfo)
+
+		subtitlePart, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {"application/x-subrip"},
+			"Content-Language":    {lang},
+			"Content-Disposition": {fmt.Sprintf(`attachment; filename="%s.%s.srt"`, videoInfo.ID, lang)},
+		})
+		if err == nil {
+			_, err = subtitlePart.Write(subtitles)
+		}
+		if err != nil {
+			return fmt.Errorf("writing subtitle part: %w", err)
+		}
+
+		part.Writer, err = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {service.StreamContentType(opts.Format)},
+			"Content-Disposition": {fmt.Sprintf(`attachment; filename="%s.%s"`, videoInfo.ID, opts.Format)},
+		})
+		if err != nil {
+			return fmt.Errorf("writing video part: %w", err)
+		}
+		slog.Info("Starting multipart video stream", "url", req.URL, "subtitleLang", lang)
+		return nil
+	})
+	if !started {
+		slog.Error("Failed to stream video", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream video: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+	if err != nil {
+		// Headers are already sent; leaving out the closing boundary tells
+		// the client the response is incomplete.
+		slog.Error("Error while streaming video part", "error", err, "url", req.URL)
+		return
+	}
+	if err := mw.Close(); err != nil {
+		slog.Error("Error while closing multipart stream", "error", err, "url", req.URL)
+	}
+	slog.Info("Multipart video stream finished", "url", req.URL)
+}
+
+// partWriter writes to a multipart part that is only created once the
+// stream has started.
+type partWriter struct {
+	io.Writer
+}
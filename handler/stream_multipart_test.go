@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSubtitleSRT is what fakeSubtitleYTDLPScript writes as subtitles.
+const fakeSubtitleSRT = "1\n00:00:00,000 --> 00:00:01,000\nHello\n"
+
+// fakeSubtitleYTDLPScript is fakeYTDLPScript for a video with English
+// subtitles: --write-subs writes fakeSubtitleSRT next to the output template.
+const fakeSubtitleYTDLPScript = `#!/bin/sh
+out=""
+prev=""
+subs=""
+for a in "$@"; do
+	case "$prev" in --output|-o) out="$a" ;; esac
+	case "$a" in
+	--dump-json) echo '{"id":"abc123","title":"Test Video","ext":"mp4","subtitles":{"en":[]}}'; exit 0 ;;
+	--write-subs) subs=1 ;;
+	esac
+	prev="$a"
+done
+if [ -n "$subs" ]; then
+	printf '` + fakeSubtitleSRT + `' > "${out%.%(ext)s}.en.srt"
+elif [ "$out" = "-" ]; then
+	printf '` + fakeVideoContent + `'
+fi
+`
+
+func TestStreamVideoHandler_StreamWithSubtitles(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeSubtitleYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video/multipart", strings.NewReader(`{"url":"https://example.com/v","subtitleLang":"fr,en"}`))
+	rec := httptest.NewRecorder()
+	h.StreamWithSubtitles(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+
+	part, err := mr.NextPart()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "application/x-subrip", part.Header.Get("Content-Type"))
+	assert.Equal(t, "en", part.Header.Get("Content-Language"))
+	assert.Equal(t, "abc123.en.srt", part.FileName())
+	data, err := io.ReadAll(part)
+	assert.NoError(t, err)
+	assert.Equal(t, fakeSubtitleSRT, string(data))
+
+	part, err = mr.NextPart()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "video/mp4", part.Header.Get("Content-Type"))
+	assert.Equal(t, "abc123.mp4", part.FileName())
+	data, err = io.ReadAll(part)
+	assert.NoError(t, err)
+	assert.Equal(t, fakeVideoContent, string(data))
+
+	_, err = mr.NextPart()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestStreamVideoHandler_StreamWithSubtitlesUnavailable(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeSubtitleYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video/multipart", strings.NewReader(`{"url":"https://example.com/v","subtitleLang":"de"}`))
+	rec := httptest.NewRecorder()
+	h.StreamWithSubtitles(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no subtitles available")
+}
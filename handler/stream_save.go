@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"gostreampuller/service"
+)
+
+// SavedFileHeader names the file a stream sent with save=true is saved to in
+// the download directory. The file only exists once the stream is complete.
+const SavedFileHeader = "X-Saved-File"
+
+// clientWriter remembers the first write error, telling a client that went
+// away apart from a failing source.
+type clientWriter struct {
+	io.Writer
+	err error
+}
+
+func (c *clientWriter) Write(p []byte) (int, error) {
+	n, err := c.Writer.Write(p)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// copyAndSave is copyWithTrailers for streams that are also saved to disk.
+// When the client disconnects mid-stream the save is not abandoned: the rest
+// of the stream is read into the file in the background. The source must
+// therefore run under Downloader.SaveContext rather than the request
+// context; done, its cancel function, is called once the save is over.
+func copyAndSave(w http.ResponseWriter, stream *service.SavingStream, done func()) (int64, error) {
+	declareStreamTrailers(w)
+
+	client := &clientWriter{Writer: w}
+	written, err := safeCopy(client, stream)
+	if client.err != nil {
+		slog.Info("Client disconnected, finishing the save in the background", "filePath", stream.Path())
+		go func() {
+			defer done()
+			if err := stream.Finish(); err != nil {
+				slog.Error("Failed to finish saving stream", "filePath", stream.Path(), "error", err)
+			}
+		}()
+		return written, err
+	}
+
+	if closeErr := stream.Close(); err == nil {
+		err = closeErr
+	}
+	done()
+	return written, setStreamTrailers(w, written, err)
+}
+
+// setSavedFileHeader sets the SavedFileHeader for a stream saved to path.
+func setSavedFileHeader(w http.ResponseWriter, path string) {
+	w.Header().Set(SavedFileHeader, filepath.Base(path))
+}
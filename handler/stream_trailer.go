@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Trailers sent after a live stream body, for clients that cannot follow
+// progress over SSE. They are declared up front and only readable once the
+// whole body has been consumed.
+const (
+	StreamStatusTrailer = "X-Stream-Status" // "complete" or "error"
+	StreamBytesTrailer  = "X-Stream-Bytes"  // Bytes of media sent
+	StreamErrorTrailer  = "X-Stream-Error"  // Failure reason, only with status "error"
+)
+
+// copyWithTrailers streams src to w, then closes src and reports the outcome
+// in the stream trailers. Closing waits for yt-dlp, so a process that exits
+// with an error after a clean EOF still ends up as status "error".
+// It must be called before anything is written to w.
+func copyWithTrailers(w http.ResponseWriter, src io.ReadCloser) (int64, error) {
+	declareStreamTrailers(w)
+
+	written, err := safeCopy(w, src)
+	if closeErr := src.Close(); err == nil {
+		err = closeErr
+	}
+	return written, setStreamTrailers(w, written, err)
+}
+
+// declareStreamTrailers announces the stream trailers; it must be called
+// before anything is written to w.
+func declareStreamTrailers(w http.ResponseWriter) {
+	w.Header().Set("Trailer", StreamStatusTrailer+", "+StreamBytesTrailer+", "+StreamErrorTrailer)
+}
+
+// setStreamTrailers fills in the stream trailers for a stream that sent
+// written bytes and ended with err, and returns err.
+func setStreamTrailers(w http.ResponseWriter, written int64, err error) error {
+	w.Header().Set(StreamBytesTrailer, strconv.FormatInt(written, 10))
+	if err != nil {
+		w.Header().Set(StreamStatusTrailer, "error")
+		w.Header().Set(StreamErrorTrailer, err.Error())
+		return err
+	}
+	w.Header().Set(StreamStatusTrailer, "complete")
+	return nil
+}
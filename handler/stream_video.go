@@ -3,9 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"gostreampuller/service"
 )
@@ -24,26 +25,44 @@ func NewStreamVideoHandler(downloader *service.Downloader) *StreamVideoHandler {
 
 // StreamVideoRequest represents the request body for video streaming.
 type StreamVideoRequest struct {
-	URL        string `json:"url"`
-	Format     string `json:"format"`
-	Resolution string `json:"resolution"`
-	Codec      string `json:"codec"`
+	URL          string `json:"url"`
+	Format       string `json:"format"`
+	Resolution   string `json:"resolution"`
+	Codec        string `json:"codec"`
+	Progressive  bool   `json:"progressive"`  // Pick a single pre-muxed file, skipping the ffmpeg merge
+	StrictFormat bool   `json:"strictFormat"` // Fail instead of falling back when no progressive format matches
+	FormatSort   string `json:"formatSort"`   // yt-dlp --format-sort value, e.g. "res,fps,codec:av01"
+	Save         bool   `json:"save"`         // Also save the stream to the download directory
+	Preset       string `json:"preset"`       // Named quality preset, e.g. "hd"; explicit format, resolution and codec win
 }
 
 // Handle handles the video streaming request.
+// Requests carrying a Range header are served from a temporary file so
+// seeking works; all others get the live yt-dlp pipe.
+// With save set, the live stream is also written to the download directory
+// as it goes; a client disconnecting does not stop the save.
 //	@Summary		Stream a video
-//	@Description	Streams a video directly from the source URL.
+//	@Description	Streams a video directly from the source URL. Send a Range header to get a seekable 206 response instead of the live stream. Set save to also write the live stream to the download directory in the same pass; X-Saved-File names the file, which is completed even if the client disconnects, within STREAM_SAVE_TIMEOUT. Live sources and videos over MAX_DURATION cannot be saved.
 //	@Tags			stream
 //	@Accept			json
-//	@Produce		video/mp4
+//	@Produce		video/mp4,video/webm,video/x-matroska
 //	@Param			request	body		StreamVideoRequest	true	"Video stream request"
+//	@Param			Range	header		string				false	"Byte range, e.g. bytes=0-1023"
 //	@Success		200		{file}		file				"Successfully streamed video"
-//	@Failure		400		{object}	ErrorResponse		"Invalid request payload or missing URL"
+//	@Header			200		{string}	X-Video-Info		"Base64-encoded JSON metadata of the video"
+//	@Header			200		{string}	X-Stream-Status		"Trailer: complete or error, sent after the body"
+//	@Header			200		{string}	X-Stream-Bytes		"Trailer: bytes of media sent"
+//	@Header			200		{string}	X-Saved-File		"Name of the saved file, only with save"
+//	@Success		206		{file}		file				"Requested byte range of the video"
+//	@Failure		400		{object}	ErrorResponse		"Invalid request payload, missing URL, unknown preset or format sort field, or a live stream format other than mp4, webm or mkv"
+//	@Failure		403		{object}	ErrorResponse		"URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS"
+//	@Failure		422		{object}	ErrorResponse		"save requested for a live source or a video longer than MAX_DURATION"
 //	@Failure		500		{object}	ErrorResponse		"Internal server error during video streaming"
+//	@Failure		503		{object}	ErrorResponse		"Too many concurrent streams (MAX_CONCURRENT_STREAMS)"
 //	@Router			/stream/video [post]
 func (h *StreamVideoHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	var req StreamVideoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
 		slog.Error("Failed to decode request body", "error", err)
 		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
 		return
@@ -55,27 +74,191 @@ func (h *StreamVideoHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	preset, err := h.downloader.QualityPreset(req.Preset)
+	if err != nil {
+		slog.Error("Unknown quality preset", "error", err, "preset", req.Preset)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+	req.applyPreset(preset)
+
+	if err := service.ValidateFormatSort(req.FormatSort); err != nil {
+		slog.Error("Invalid format sort", "error", err, "formatSort", req.FormatSort)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
+		return
+	}
+
 	slog.Info("Attempting to stream video", "url", req.URL, "format", req.Format, "resolution", req.Resolution, "codec", req.Codec)
 
-	// Pass an empty string for progressID as this API endpoint doesn't have an SSE client
-	readCloser, err := h.downloader.StreamVideo(r.Context(), req.URL, req.Format, req.Resolution, req.Codec, "")
-	if err != nil {
-		slog.Error("Failed to stream video", "error", err, "url", req.URL)
-		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream video: %v", err)).ToJson(), http.StatusInternalServerError)
+	opts := service.VideoOptions{
+		Format:       req.Format,
+		Resolution:   req.Resolution,
+		Codec:        req.Codec,
+		Progressive:  req.Progressive,
+		StrictFormat: req.StrictFormat,
+		FormatSort:   req.FormatSort,
+	}
+
+	// The live stream is muxed on the fly, which only some containers allow.
+	// Ranged requests are recoded instead, but to the same formats
+	if err := service.ValidateStreamContainer(req.Format, req.Codec); err != nil {
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusBadRequest)
 		return
 	}
-	defer readCloser.Close()
 
-	// Set appropriate headers for video streaming
-	w.Header().Set("Content-Type", "video/mp4") // Assuming mp4 for now, can be dynamic
-	w.Header().Set("Transfer-Encoding", "chunked")
-	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("Range") != "" {
+		h.serveRange(w, r, req.URL, opts)
+		return
+	}
+
+	if req.Save {
+		h.streamAndSave(w, r, req.URL, opts)
+		return
+	}
 
-	slog.Info("Starting video stream", "url", req.URL)
-	if _, err := io.Copy(w, readCloser); err != nil {
+	// Pass an empty string for progressID as this API endpoint doesn't have an SSE client
+	started := false
+	written, err := h.downloader.StreamVideoTo(r.Context(), w, req.URL, opts, "", func(videoInfo *service.VideoInfo) error {
+		started = true
+		// Set appropriate headers for video streaming
+		w.Header().Set("Content-Type", service.StreamContentType(opts.Format))
+		w.Header().Set("Transfer-Encoding", "chunked")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Accept-Ranges", "bytes") // Ranged requests are served from a temp file
+		setVideoInfoHeader(w, videoInfo)
+		declareStreamTrailers(w)
+		slog.Info("Starting video stream", "url", req.URL)
+		return nil
+	})
+	if !started {
+		slog.Error("Failed to stream video", "error", err, "url", req.URL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream video: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+	if err := setStreamTrailers(w, written, err); err != nil {
 		slog.Error("Error while streaming video", "error", err, "url", req.URL)
 		// Note: Cannot send HTTP error after headers have been written and body started.
-		// The client might just see a broken stream.
+		// The X-Stream-Status trailer tells the client the stream is broken.
 	}
 	slog.Info("Video stream finished", "url", req.URL)
 }
+
+// streamAndSave serves the live stream while saving it to the download
+// directory. yt-dlp runs under the downloader's save context rather than the
+// request context, so the save can finish after the client disconnects.
+func (h *StreamVideoHandler) streamAndSave(w http.ResponseWriter, r *http.Request, videoURL string, opts service.VideoOptions) {
+	ctx, done := h.downloader.SaveContext(r.Context())
+	readCloser, videoInfo, err := h.downloader.StreamVideo(ctx, videoURL, opts, "")
+	if err != nil {
+		done()
+		slog.Error("Failed to stream video", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream video: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+	if err := h.downloader.CheckSavable(videoInfo); err != nil {
+		readCloser.Close()
+		done()
+		slog.Error("Refused to save video stream", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to save video stream: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+
+	ext := opts.Format
+	if ext == "" {
+		ext = "mp4"
+	}
+	stream, err := h.downloader.SaveStream(readCloser, service.MediaVideo, videoInfo.ID, ext)
+	if err != nil {
+		readCloser.Close()
+		done()
+		slog.Error("Failed to save video stream", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to save video stream: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", service.StreamContentType(opts.Format))
+	w.Header().Set("Cache-Control", "no-cache")
+	setVideoInfoHeader(w, videoInfo)
+	setSavedFileHeader(w, stream.Path())
+
+	slog.Info("Starting saved video stream", "url", videoURL, "filePath", stream.Path())
+	if _, err := copyAndSave(w, stream, done); err != nil {
+		slog.Error("Error while streaming saved video", "error", err, "url", videoURL)
+	}
+	slog.Info("Saved video stream finished", "url", videoURL)
+}
+
+// PrefetchRequest represents the request body for a stream info prefetch.
+type PrefetchRequest struct {
+	URL string `json:"url"`
+}
+
+// Prefetch warms the video info cache for a URL so the stream that follows
+// starts without waiting on yt-dlp. It returns before the info is fetched.
+//
+//	@Summary		Prefetch stream info
+//	@Description	Fetches and caches the video info for a URL in the background, so a following stream or web play request starts faster. Returns immediately.
+//	@Tags			stream
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		PrefetchRequest	true	"Prefetch request"
+//	@Success		202		{object}	SuccessResponse	"Prefetch started"
+//	@Failure		400		{object}	ErrorResponse	"Invalid request payload or missing URL"
+//	@Router			/stream/video/prefetch [post]
+func (h *StreamVideoHandler) Prefetch(w http.ResponseWriter, r *http.Request) {
+	var req PrefetchRequest
+	if err := decodeJSONBody(r, &req, h.downloader.StrictJSON()); err != nil {
+		slog.Error("Failed to decode request body", "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Invalid request payload: %v", err)).ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == "" {
+		slog.Error("Missing URL in prefetch request")
+		http.Error(w, NewErrorResponse("URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	h.downloader.PrefetchInfo(req.URL)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(NewSuccessResponse("Prefetch started"))
+}
+
+// serveRange downloads the video to a temporary file and serves the requested
+// byte range from it. The live pipe cannot seek, so this is the only way to
+// honour a Range header. The temporary file is removed once served.
+func (h *StreamVideoHandler) serveRange(w http.ResponseWriter, r *http.Request, videoURL string, opts service.VideoOptions) {
+	tempFilePath, err := h.downloader.DownloadVideoToTempFile(r.Context(), videoURL, opts, "")
+	if err != nil {
+		slog.Error("Failed to download video for ranged stream", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream video: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+	defer func() {
+		if err := os.Remove(tempFilePath); err != nil {
+			slog.Error("Failed to remove temporary video file", "filePath", tempFilePath, "error", err)
+		}
+	}()
+
+	file, err := os.Open(tempFilePath)
+	if err != nil {
+		slog.Error("Failed to open temporary video file", "filePath", tempFilePath, "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream video: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		slog.Error("Failed to stat temporary video file", "filePath", tempFilePath, "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to stream video: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	// From the file produced, which a failed recode leaves in its original format
+	w.Header().Set("Content-Type", mediaContentType(tempFilePath))
+	slog.Info("Serving ranged video stream", "url", videoURL, "range", r.Header.Get("Range"))
+	http.ServeContent(w, r, filepath.Base(tempFilePath), stat.ModTime(), file)
+}
@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/service"
+)
+
+func TestStreamVideoHandler_LiveStream(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v"}`))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	assert.Equal(t, fakeVideoContent, rec.Body.String())
+}
+
+func TestStreamVideoHandler_TooManyStreams(t *testing.T) {
+	cfg := newTestConfig(t, fakeYTDLPScript)
+	cfg.MaxConcurrentStreams = 1
+	downloader := service.NewDownloader(cfg, service.NewProgressManager(0))
+	h := NewStreamVideoHandler(downloader)
+
+	running, _, err := downloader.StreamVideo(t.Context(), "https://example.com/v", service.VideoOptions{}, "")
+	assert.NoError(t, err)
+	defer running.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v"}`))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "too many concurrent streams")
+}
+
+func TestStreamVideoHandler_RangeRequest(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v"}`))
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "bytes 2-5/10", rec.Header().Get("Content-Range"))
+	assert.Equal(t, "video/mp4", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "2345", rec.Body.String())
+
+	// The temporary file must be cleaned up after serving.
+	entries, err := os.ReadDir(cfg.DownloadDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestStreamVideoHandler_RangeRequestContentType(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v","format":"webm"}`))
+	req.Header.Set("Range", "bytes=0-1")
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "video/webm", rec.Header().Get("Content-Type"))
+}
+
+func TestStreamVideoHandler_RangeRequestInvalidFormat(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v","format":"../../x"}`))
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unsupported stream format")
+	entries, err := os.ReadDir(cfg.DownloadDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestStreamVideoHandler_VideoInfoHeader(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v"}`))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	encoded := rec.Header().Get(VideoInfoHeader)
+	assert.NotEmpty(t, encoded)
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	assert.NoError(t, err)
+	var info StreamVideoInfo
+	assert.NoError(t, json.Unmarshal(data, &info))
+	assert.Equal(t, StreamVideoInfo{
+		ID:       "abc123",
+		Title:    "Test Video",
+		Duration: 42,
+		Uploader: "Tester",
+	}, info)
+}
+
+func TestSetVideoInfoHeader_TruncatesTitle(t *testing.T) {
+	rec := httptest.NewRecorder()
+	setVideoInfoHeader(rec, &service.VideoInfo{ID: "x", Title: strings.Repeat("é", 1000)})
+
+	data, err := base64.StdEncoding.DecodeString(rec.Header().Get(VideoInfoHeader))
+	assert.NoError(t, err)
+	var info StreamVideoInfo
+	assert.NoError(t, json.Unmarshal(data, &info))
+	assert.Equal(t, maxHeaderTitleRunes, len([]rune(info.Title)))
+}
+
+func TestStreamVideoHandler_Prefetch(t *testing.T) {
+	// Every info dump appends a line to calls, so the background fetch is observable
+	calls := filepath.Join(t.TempDir(), "calls")
+	cfg := newTestConfig(t, "#!/bin/sh\necho dump >> '"+calls+"'\n"+strings.TrimPrefix(fakeYTDLPScript, "#!/bin/sh\n"))
+	cfg.InfoCacheTTL = time.Minute
+	h := NewStreamVideoHandler(service.NewDownloader(cfg, service.NewProgressManager(0)))
+
+	rec := httptest.NewRecorder()
+	h.Prefetch(rec, httptest.NewRequest(http.MethodPost, "/stream/video/prefetch", strings.NewReader(`{"url":"https://example.com/v"}`)))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(calls)
+		return strings.Count(string(data), "dump") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStreamVideoHandler_PrefetchMissingURL(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	rec := httptest.NewRecorder()
+	h.Prefetch(rec, httptest.NewRequest(http.MethodPost, "/stream/video/prefetch", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestStreamVideoHandler_Trailers(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		wantStatus string
+		wantBytes  string
+	}{
+		{
+			name:       "Complete",
+			script:     fakeYTDLPScript,
+			wantStatus: "complete",
+			wantBytes:  "10",
+		},
+		{
+			name:       "FailsMidStream",
+			script:     strings.Replace(fakeYTDLPScript, "\tprintf '"+fakeVideoContent+"'\n", "\tprintf '01234'; exit 1\n", 1),
+			wantStatus: "error",
+			wantBytes:  "5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloader, _ := newTestDownloader(t, tt.script)
+			server := httptest.NewServer(http.HandlerFunc(NewStreamVideoHandler(downloader).Handle))
+			defer server.Close()
+
+			resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"url":"https://example.com/v"}`))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			_, err = io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+
+			// Trailers are only filled in once the body has been read
+			assert.Equal(t, tt.wantStatus, resp.Trailer.Get(StreamStatusTrailer))
+			assert.Equal(t, tt.wantBytes, resp.Trailer.Get(StreamBytesTrailer))
+			if tt.wantStatus == "error" {
+				assert.NotEmpty(t, resp.Trailer.Get(StreamErrorTrailer))
+			}
+		})
+	}
+}
+
+func TestStreamVideoHandler_Save(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, fakeYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v","save":true}`))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, fakeVideoContent, rec.Body.String(), "the client must receive the stream")
+	assert.Equal(t, "complete", rec.Header().Get(StreamStatusTrailer))
+
+	savedFile := rec.Header().Get(SavedFileHeader)
+	assert.True(t, strings.HasSuffix(savedFile, "abc123.mp4"), "unexpected saved file %q", savedFile)
+	saved, err := os.ReadFile(filepath.Join(cfg.DownloadDir, savedFile))
+	assert.NoError(t, err)
+	assert.Equal(t, fakeVideoContent, string(saved), "the stream must be saved to disk")
+}
+
+// failingResponseWriter is a ResponseWriter whose client has gone away.
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (f failingResponseWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestCopyAndSave_ClientDisconnects(t *testing.T) {
+	downloader, _ := newTestDownloader(t, fakeYTDLPScript)
+	stream, err := downloader.SaveStream(io.NopCloser(strings.NewReader(fakeVideoContent)), service.MediaVideo, "abc123", "mp4")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = copyAndSave(failingResponseWriter{httptest.NewRecorder()}, stream, func() {})
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+	// The rest of the stream is saved in the background
+	assert.Eventually(t, func() bool {
+		saved, err := os.ReadFile(stream.Path())
+		return err == nil && string(saved) == fakeVideoContent
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// endlessYTDLPScript reports a video and streams it without end, like a
+// live source.
+const endlessYTDLPScript = `#!/bin/sh
+for a in "$@"; do
+	case "$a" in --dump-json) echo '{"id":"abc123","title":"Test Video","ext":"mp4","duration":42}'; exit 0 ;; esac
+done
+while true; do printf 'chunk'; sleep 0.01; done
+`
+
+func TestStreamVideoHandler_SaveStoppedAtShutdown(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, endlessYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	// The client goes away at once, leaving the save to run in the background
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v","save":true}`))
+	rec := failingResponseWriter{httptest.NewRecorder()}
+	h.Handle(rec, req)
+	savedFile := rec.Header().Get(SavedFileHeader)
+	assert.NotEmpty(t, savedFile)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, downloader.Shutdown(ctx))
+	assert.NoFileExists(t, filepath.Join(cfg.DownloadDir, savedFile), "the partial save must be removed")
+}
+
+func TestStreamVideoHandler_SaveTimeout(t *testing.T) {
+	downloader, cfg := newTestDownloader(t, endlessYTDLPScript)
+	cfg.StreamSaveTimeout = 100 * time.Millisecond
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v","save":true}`))
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.Handle(rec, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("save was not stopped by STREAM_SAVE_TIMEOUT")
+	}
+	assert.Equal(t, "error", rec.Header().Get(StreamStatusTrailer))
+}
+
+func TestStreamVideoHandler_SaveRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   string
+		maxDur time.Duration
+	}{
+		{name: "Live", info: `{"id":"abc123","title":"Live","ext":"mp4","is_live":true}`},
+		{name: "TooLong", info: `{"id":"abc123","title":"Long","ext":"mp4","duration":7200}`, maxDur: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := strings.Replace(endlessYTDLPScript, `{"id":"abc123","title":"Test Video","ext":"mp4","duration":42}`, tt.info, 1)
+			downloader, cfg := newTestDownloader(t, script)
+			cfg.MaxDuration = tt.maxDur
+			h := NewStreamVideoHandler(downloader)
+
+			req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v","save":true}`))
+			rec := httptest.NewRecorder()
+			h.Handle(rec, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			entries, err := os.ReadDir(cfg.DownloadDir)
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestStreamVideoHandler_Preset(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantArgs string
+	}{
+		{name: "Expanded", body: `{"url":"https://example.com/v","preset":"hd"}`, wantArgs: "bestvideo[height<=720][vcodec*=avc1]+bestaudio/best"},
+		{name: "ExplicitFieldsWin", body: `{"url":"https://example.com/v","preset":"hd","resolution":"1080"}`, wantArgs: "bestvideo[height<=1080][vcodec*=avc1]+bestaudio/best"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloader, _ := newTestDownloader(t, echoArgsYTDLPScript)
+			h := NewStreamVideoHandler(downloader)
+
+			req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.Handle(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "video/mp4", rec.Header().Get("Content-Type"))
+			assert.Contains(t, rec.Body.String(), tt.wantArgs)
+		})
+	}
+}
+
+func TestStreamVideoHandler_UnknownPreset(t *testing.T) {
+	downloader, _ := newTestDownloader(t, echoArgsYTDLPScript)
+	h := NewStreamVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodPost, "/stream/video", strings.NewReader(`{"url":"https://example.com/v","preset":"4k"}`))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `unknown preset \"4k\", valid presets: fhd, hd, mobile, sd`)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"gostreampuller/service"
+)
+
+// ListSubtitlesResponse represents the response body for a subtitle list.
+type ListSubtitlesResponse struct {
+	URL string `json:"url"`
+	service.SubtitleList
+}
+
+// ListSubtitles returns the subtitle languages available for a URL, so
+// clients can pick one before downloading.
+//
+//	@Summary		List available subtitle languages
+//	@Description	Returns the subtitle languages a video offers, split between manual subtitles and automatic captions, to choose a subtitleLang before downloading. Manual subtitles win over automatic captions of the same language. language is the video's own, which "auto" stands for.
+//	@Tags			download
+//	@Produce		json
+//	@Param			url	query		string					true	"Video URL"
+//	@Success		200	{object}	ListSubtitlesResponse	"Available subtitle languages"
+//	@Failure		400	{object}	ErrorResponse			"Missing URL"
+//	@Failure		403	{object}	ErrorResponse			"URL handled by a yt-dlp extractor outside ALLOWED_EXTRACTORS"
+//	@Failure		500	{object}	ErrorResponse			"Internal server error during video info retrieval"
+//	@Failure		504	{object}	ErrorResponse			"yt-dlp took longer than INFO_TIMEOUT"
+//	@Router			/download/subtitles/list [get]
+func (h *DownloadVideoHandler) ListSubtitles(w http.ResponseWriter, r *http.Request) {
+	videoURL := r.URL.Query().Get("url")
+	if videoURL == "" {
+		slog.Error("Missing URL in subtitle list request")
+		http.Error(w, NewErrorResponse("URL is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.downloader.ListSubtitles(r.Context(), videoURL)
+	var timeoutErr *service.InfoTimeoutError
+	if errors.As(err, &timeoutErr) {
+		slog.Error("Timed out listing subtitles", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(err.Error()).ToJson(), http.StatusGatewayTimeout)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to list subtitles", "error", err, "url", videoURL)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to get video info: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ListSubtitlesResponse{URL: videoURL, SubtitleList: *list})
+	slog.Info("Subtitle languages listed", "url", videoURL, "manual", len(list.Manual), "automatic", len(list.Automatic))
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadVideoHandler_ListSubtitles(t *testing.T) {
+	const info = `{"id":"abc123","title":"Test Video","language":"en",` +
+		`"subtitles":{"en":[{"ext":"vtt"}],"es":[{"ext":"vtt"},{"ext":"srt"}]},` +
+		`"automatic_captions":{"en":[{"ext":"vtt"}],"de":[{"ext":"vtt"}]}}`
+	downloader, _ := newTestDownloader(t, "#!/bin/sh\necho '"+info+"'\n")
+	h := NewDownloadVideoHandler(downloader)
+
+	req := httptest.NewRequest(http.MethodGet, "/download/subtitles/list?url=https://example.com/v", nil)
+	rec := httptest.NewRecorder()
+	h.ListSubtitles(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp ListSubtitlesResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "https://example.com/v", resp.URL)
+	assert.Equal(t, "en", resp.Language)
+	assert.Equal(t, []string{"en", "es"}, resp.Manual)
+	assert.Equal(t, []string{"de", "en"}, resp.Automatic)
+
+	rec = httptest.NewRecorder()
+	h.ListSubtitles(rec, httptest.NewRequest(http.MethodGet, "/download/subtitles/list", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
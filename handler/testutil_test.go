@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gostreampuller/config"
+	"gostreampuller/service"
+)
+
+// fakeVideoContent is what the fake yt-dlp writes for any download or stream.
+const fakeVideoContent = "0123456789"
+
+// fakeYTDLPScript imitates yt-dlp: it prints a fixed info JSON for
+// --dump-json and otherwise writes fakeVideoContent to the --output target
+// (stdout when the target is "-").
+const fakeYTDLPScript = `#!/bin/sh
+out=""
+prev=""
+for a in "$@"; do
+	case "$prev" in --output|-o) out="$a" ;; esac
+	case "$a" in --dump-json) echo '{"id":"abc123","title":"Test Video","ext":"mp4","duration":42,"uploader":"Tester"}'; exit 0 ;; esac
+	prev="$a"
+done
+if [ "$out" = "-" ]; then
+	printf '` + fakeVideoContent + `'
+elif [ -n "$out" ]; then
+	printf '` + fakeVideoContent + `' > "$out"
+fi
+`
+
+// writeScript writes an executable shell script into a temp dir and returns its path.
+func writeScript(t *testing.T, name, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake %s: %v", name, err)
+	}
+	return path
+}
+
+// newTestConfig returns a config whose yt-dlp is the given fake script.
+func newTestConfig(t *testing.T, ytdlpScript string) *config.Config {
+	t.Helper()
+	return &config.Config{
+		LocalMode:   true,
+		YTDLPPath:   writeScript(t, "yt-dlp", ytdlpScript),
+		FFMPEGPath:  "true",
+		DownloadDir: t.TempDir(),
+	}
+}
+
+// newTestDownloader returns a Downloader backed by the given fake yt-dlp script.
+func newTestDownloader(t *testing.T, ytdlpScript string) (*service.Downloader, *config.Config) {
+	t.Helper()
+	cfg := newTestConfig(t, ytdlpScript)
+	return service.NewDownloader(cfg, service.NewProgressManager(0)), cfg
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"gostreampuller/service"
+)
+
+const (
+	// VideoInfoHeader carries base64-encoded JSON metadata on stream responses.
+	VideoInfoHeader = "X-Video-Info"
+	// maxVideoInfoHeaderLen keeps the header well under common proxy limits (8KB).
+	maxVideoInfoHeaderLen = 4096
+	// maxHeaderTitleRunes bounds the title, the only field of unbounded length.
+	maxHeaderTitleRunes = 200
+)
+
+// StreamVideoInfo is the metadata subset sent in the X-Video-Info header.
+type StreamVideoInfo struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	OriginalURL string `json:"original_url,omitempty"`
+	Duration    int    `json:"duration"`
+	Uploader    string `json:"uploader,omitempty"`
+	UploadDate  string `json:"upload_date,omitempty"`
+	Thumbnail   string `json:"thumbnail,omitempty"`
+}
+
+// setVideoInfoHeader sets the X-Video-Info header from info. It must be called
+// before the body is written. The header is skipped if it would be too large.
+func setVideoInfoHeader(w http.ResponseWriter, info *service.VideoInfo) {
+	if info == nil {
+		return
+	}
+
+	title := []rune(info.Title)
+	if len(title) > maxHeaderTitleRunes {
+		title = title[:maxHeaderTitleRunes]
+	}
+	data, err := json.Marshal(StreamVideoInfo{
+		ID:          info.ID,
+		Title:       string(title),
+		OriginalURL: info.OriginalURL,
+		Duration:    info.Duration,
+		Uploader:    info.Uploader,
+		UploadDate:  info.UploadDate,
+		Thumbnail:   info.Thumbnail,
+	})
+	if err != nil {
+		slog.Warn("Failed to marshal video info header", "error", err)
+		return
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	if len(encoded) > maxVideoInfoHeaderLen {
+		slog.Warn("Video info header too large, skipping", "size", len(encoded), "videoID", info.ID)
+		return
+	}
+	w.Header().Set(VideoInfoHeader, encoded)
+}
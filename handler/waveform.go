@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"gostreampuller/service"
+)
+
+// defaultWaveformPoints is the waveform resolution when the client does not
+// ask for one; enough for a full-width scrubber.
+const defaultWaveformPoints = 800
+
+// WaveformResponse represents the waveform of a downloaded audio file.
+type WaveformResponse struct {
+	Filename string    `json:"filename"`
+	Peaks    []float64 `json:"peaks"` // Peak level of each slice of the file, from 0 to 1
+}
+
+// ServeWaveform serves the waveform of a downloaded audio file as JSON, for
+// audio UIs drawing a scrubber.
+//
+//	@Summary		Get a downloaded audio file's waveform
+//	@Description	Returns the waveform of an audio file in the download directory as peak levels between 0 and 1, one per slice of the file, for drawing a scrubber. The file is decoded with ffmpeg, taking one of the MAX_CONCURRENT_DOWNLOADS slots, and results are cached until the file changes.
+//	@Tags			download
+//	@Produce		json
+//	@Param			filename	path		string				true	"Filename of the downloaded audio"
+//	@Param			points		query		int					false	"Number of peaks to return (1 to 10000, default 800)"
+//	@Success		200			{object}	WaveformResponse	"Waveform peaks"
+//	@Failure		400			{object}	ErrorResponse		"Missing filename or invalid points"
+//	@Failure		404			{object}	ErrorResponse		"File not found"
+//	@Failure		500			{object}	ErrorResponse		"Internal server error"
+//	@Failure		503			{object}	ErrorResponse		"Too many concurrent downloads (MAX_CONCURRENT_DOWNLOADS)"
+//	@Router			/download/audio/{filename}/waveform [get]
+func (h *DownloadAudioHandler) ServeWaveform(w http.ResponseWriter, r *http.Request) {
+	filename := r.PathValue("filename")
+	if filename == "" {
+		slog.Error("Missing filename for serving waveform")
+		http.Error(w, NewErrorResponse("Filename is required").ToJson(), http.StatusBadRequest)
+		return
+	}
+
+	points := defaultWaveformPoints
+	if value := r.URL.Query().Get("points"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > service.MaxWaveformPoints {
+			http.Error(w, NewErrorResponse(fmt.Sprintf("points must be a number between 1 and %d", service.MaxWaveformPoints)).ToJson(), http.StatusBadRequest)
+			return
+		}
+		points = n
+	}
+
+	filePath := h.downloader.ResolveDownload(filename)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		slog.Warn("Downloaded audio file not found", "filePath", filePath)
+		http.Error(w, NewErrorResponse("File not found").ToJson(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.Error("Error checking file existence", "filePath", filePath, "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Error accessing file: %v", err)).ToJson(), http.StatusInternalServerError)
+		return
+	}
+
+	peaks, err := h.downloader.ComputeWaveform(r.Context(), filePath, points)
+	if err != nil {
+		slog.Error("Failed to compute waveform", "filePath", filePath, "error", err)
+		http.Error(w, NewErrorResponse(fmt.Sprintf("Failed to compute waveform: %v", err)).ToJson(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WaveformResponse{Filename: filename, Peaks: peaks})
+}
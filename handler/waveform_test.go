@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/service"
+)
+
+// fakeFFmpegRaw outputs the file passed with -i unchanged, standing in for
+// decoding it to raw PCM.
+const fakeFFmpegRaw = `#!/bin/sh
+prev=""
+for a in "$@"; do
+	case "$prev" in -i) cat "$a" ;; esac
+	prev="$a"
+done
+`
+
+func TestDownloadAudioHandler_ServeWaveform(t *testing.T) {
+	cfg := newTestConfig(t, fakeYTDLPScript)
+	cfg.FFMPEGPath = writeScript(t, "ffmpeg", fakeFFmpegRaw)
+	h := NewDownloadAudioHandler(service.NewDownloader(cfg, service.NewProgressManager(0)))
+
+	// Two 10ms blocks of 8kHz samples, the second one louder
+	var pcm []byte
+	for i := range 160 {
+		level := int16(8192)
+		if i >= 80 {
+			level = -16384
+		}
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(level))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(cfg.DownloadDir, "abc123.pcm"), pcm, 0644))
+
+	serve := func(filename, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/download/audio/"+filename+"/waveform"+query, nil)
+		req.SetPathValue("filename", filename)
+		rec := httptest.NewRecorder()
+		h.ServeWaveform(rec, req)
+		return rec
+	}
+
+	rec := serve("abc123.pcm", "?points=2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp WaveformResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, []float64{0.25, 0.5}, resp.Peaks)
+
+	assert.Equal(t, http.StatusBadRequest, serve("abc123.pcm", "?points=0").Code)
+	assert.Equal(t, http.StatusNotFound, serve("missing.mp3", "").Code)
+}
@@ -2,18 +2,22 @@ package handler
 
 import (
 	// Import the embed package
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
 
 	"gostreampuller/config" // Import config package
+	appMiddleware "gostreampuller/middleware"
 	"gostreampuller/service"
 	"gostreampuller/web"
 )
@@ -109,12 +113,18 @@ func (h *WebStreamHandler) ServeStreamPage(w http.ResponseWriter, r *http.Reques
 		VideoInfo     *service.VideoInfo
 		ProgressID    string
 		AppURL        string // Add AppURL to the data struct
+		MaxResolution int    // The anonymous cap, 0 when this user is not capped
+		PageURI       string // Where /web/login sends the user back to
 	}{
 		URL:           videoURL,
 		VideoInfoJSON: template.HTML(videoInfoJSONStr),
 		VideoInfo:     &videoInfo,
 		ProgressID:    progressID,
 		AppURL:        h.cfg.AppBaseURL, // Pass AppURL from config
+		PageURI:       r.URL.RequestURI(),
+	}
+	if !appMiddleware.IsAuthenticated(r.Context()) {
+		data.MaxResolution = h.downloader.Config().AnonymousMaxResolution
 	}
 	err := h.streamTemplate.Execute(w, data)
 	if err != nil {
@@ -123,41 +133,87 @@ func (h *WebStreamHandler) ServeStreamPage(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// Login sends an authenticated user back to the page given by the next
+// query parameter, or the main page. It sits behind Basic Auth, whose
+// challenge makes browsers ask for the AUTH_USERNAME/AUTH_PASSWORD
+// credentials; they then send them along with the other /web routes, which
+// lifts the ANONYMOUS_MAX_RESOLUTION cap.
+//
+//	@Summary		Log in to the web UI
+//	@Description	Asks the browser for Basic Auth credentials, then redirects to next, a path on this server, or to the main page. Authenticated users are not capped at ANONYMOUS_MAX_RESOLUTION.
+//	@Tags			web
+//	@Param			next	query		string	false	"Path to return to, e.g. /web?url=..."
+//	@Success		302		{string}	string	"Redirect to next or the main page"
+//	@Failure		401		{string}	string	"Missing or invalid credentials"
+//	@Router			/web/login [get]
+func (h *WebStreamHandler) Login(w http.ResponseWriter, r *http.Request) {
+	path, query := "/", url.Values(nil)
+	// Only paths on this server, never "//host" or absolute URLs
+	if next, err := url.Parse(r.URL.Query().Get("next")); err == nil && next.Scheme == "" && next.Host == "" && strings.HasPrefix(next.Path, "/") {
+		path, query = next.Path, next.Query()
+	}
+	redirect(w, r, h.cfg, path, query)
+}
+
+// LoadInfoResponse is the JSON variant of the /load-info response.
+type LoadInfoResponse struct {
+	ProgressID string             `json:"progressID"`
+	VideoInfo  *service.VideoInfo `json:"videoInfo"`
+}
+
 // HandleLoadInfo handles the initial URL submission, fetches video info, and redirects.
+// Clients sending "Accept: application/json" get a LoadInfoResponse instead
+// of the redirect, and may send the URL as a JSON body.
 //
 //	@Summary		Load video information and redirect to stream page
-//	@Description	Receives a video URL, fetches its metadata, and redirects the user to the main streaming/downloading page with the info pre-populated.
+//	@Description	Receives a video URL, fetches its metadata, and redirects the user to the main streaming/downloading page with the info pre-populated. With "Accept: application/json" it returns the info and progress ID as JSON instead; the URL may then also be sent as a JSON body.
 //	@Tags			web
-//	@Accept			x-www-form-urlencoded
-//	@Produce		html
+//	@Accept			x-www-form-urlencoded,json
+//	@Produce		html,json
 //	@Param			url	formData	string	true	"Video URL"
-//	@Success		302	{string}	string	"Redirect to /web with video info"
-//	@Failure		400	{string}	string	"Bad Request"
-//	@Failure		500	{string}	string	"Internal Server Error"
+//	@Success		200	{object}	LoadInfoResponse	"Video info, when JSON is accepted"
+//	@Success		302	{string}	string				"Redirect to /web with video info"
+//	@Failure		400	{string}	string				"Bad Request"
+//	@Failure		500	{string}	string				"Internal Server Error"
 //	@Router			/load-info [post]
 func (h *WebStreamHandler) HandleLoadInfo(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		slog.Error("Failed to parse form data", "error", err)
-		http.Redirect(w, r, h.cfg.AppBaseURL+"/?error="+url.QueryEscape("Bad Request: Could not parse form"), http.StatusFound)
-		return
+	wantsJSON := strings.Contains(r.Header.Get("Accept"), "application/json")
+	// fail reports an error the way the client expects it
+	fail := func(message string, status int) {
+		if wantsJSON {
+			http.Error(w, NewErrorResponse(message).ToJson(), status)
+			return
+		}
+		redirect(w, r, h.cfg, "/", url.Values{"error": {message}})
 	}
 
-	videoURL := r.FormValue("url")
+	videoURL, err := loadInfoURL(r)
+	if err != nil {
+		slog.Error("Failed to parse load info request", "error", err)
+		fail("Bad Request: Could not parse form", http.StatusBadRequest)
+		return
+	}
 	if videoURL == "" {
 		slog.Error("Missing URL in load info request")
-		http.Redirect(w, r, h.cfg.AppBaseURL+"/?error="+url.QueryEscape("URL is required"), http.StatusFound)
+		fail("URL is required", http.StatusBadRequest)
 		return
 	}
 
 	// Generate a unique progress ID for this operation
-	progressID := fmt.Sprintf("info-%d", time.Now().UnixNano())
+	progressID := service.NewProgressID("info")
 
 	slog.Info("Attempting to get video info for web interface", "url", videoURL, "progressID", progressID)
 	videoInfo, err := h.downloader.GetVideoInfo(r.Context(), videoURL, progressID)
 	if err != nil {
 		slog.Error("Failed to get video info for web interface", "error", err, "url", videoURL)
 		// Error event already sent by downloader.GetVideoInfo
-		http.Redirect(w, r, h.cfg.AppBaseURL+"/?error="+url.QueryEscape(fmt.Sprintf("Failed to get video information: %v", err)), http.StatusFound)
+		fail(fmt.Sprintf("Failed to get video information: %v", err), errorStatus(err))
+		return
+	}
+
+	if wantsJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(LoadInfoResponse{ProgressID: progressID, VideoInfo: videoInfo})
 		return
 	}
 
@@ -165,27 +221,45 @@ func (h *WebStreamHandler) HandleLoadInfo(w http.ResponseWriter, r *http.Request
 	videoInfoJSON, err := json.Marshal(videoInfo)
 	if err != nil {
 		slog.Error("Failed to marshal video info to JSON for redirect", "error", err)
-		http.Redirect(w, r, h.cfg.AppBaseURL+"/?error="+url.QueryEscape("Internal server error: Failed to process video info"), http.StatusFound)
+		fail("Internal server error: Failed to process video info", http.StatusInternalServerError)
 		return
 	}
 
-	// Construct the redirect URL with all necessary parameters
-	redirectURL := fmt.Sprintf("%s/web?url=%s&progressID=%s&videoInfo=%s",
-		h.cfg.AppBaseURL, // Use AppURL here
-		url.QueryEscape(videoURL),
-		url.QueryEscape(progressID),
-		url.QueryEscape(string(videoInfoJSON)),
-	)
-	http.Redirect(w, r, redirectURL, http.StatusFound)
+	// Redirect with all necessary parameters
+	redirect(w, r, h.cfg, "/web", url.Values{
+		"url":        {videoURL},
+		"progressID": {progressID},
+		"videoInfo":  {string(videoInfoJSON)},
+	})
+}
+
+// loadInfoURL reads the video URL from a JSON body or, for form posts, the "url" field.
+func loadInfoURL(r *http.Request) (string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req GetVideoInfoRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return "", err
+		}
+		return req.URL, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return "", err
+	}
+	return r.FormValue("url"), nil
 }
 
 // ServeProgress handles Server-Sent Events (SSE) for progress updates.
 //
+// Each event carries an incrementing SSE id. A client reconnecting with a
+// Last-Event-ID header first gets the recent events it missed.
+//
 //	@Summary		Get progress updates via SSE
-//	@Description	Establishes an SSE connection to stream real-time progress updates for download/stream operations.
+//	@Description	Establishes an SSE connection to stream real-time progress updates for download/stream operations. An operation that ends always ends its stream with its complete or error event.
 //	@Tags			web
 //	@Produce		text/event-stream
-//	@Param			progressID	query		string	true	"Unique ID for the operation to track"
+//	@Param			progressID		query		string	true	"Unique ID for the operation to track"
+//	@Param			Last-Event-ID	header		integer	false	"ID of the last event received, to replay the events missed since"
+//	@Param			connected		query		bool	false	"Start with a synthetic connected event (default SSE_CONNECTED_EVENT)"
 //	@Success		200			{string}	string	"Event stream of progress updates"
 //	@Failure		400			{string}	string	"Missing progressID"
 //	@Router			/web/progress [get]
@@ -208,18 +282,39 @@ func (h *WebStreamHandler) ServeProgress(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*") // Allow CORS for SSE
 
-	clientChan := h.progressManager.RegisterClient(progressID)
-	defer h.progressManager.UnregisterClient(progressID)
-
-	slog.Info("SSE client connected", "progressID", progressID)
-
-	// Send a "connected" event immediately
-	connectedEvent, _ := json.Marshal(service.ProgressEvent{
-		ID:      progressID,
-		Status:  "connected",
-		Message: "Connected to progress stream.",
-	})
-	fmt.Fprintf(w, "data: %s\n\n", connectedEvent)
+	var clientChan chan service.ProgressMessage
+	var replay []service.ProgressMessage
+	lastSeq, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	if err == nil {
+		clientChan, replay = h.progressManager.ResumeClient(progressID, lastSeq)
+	} else {
+		clientChan = h.progressManager.RegisterClient(progressID)
+	}
+	defer h.progressManager.UnregisterClient(progressID, clientChan)
+
+	slog.Info("SSE client connected", "progressID", progressID, "lastEventID", lastSeq, "replayed", len(replay))
+
+	// Send a "connected" event immediately, or a comment clients ignore
+	// when it is turned off, so the stream starts either way
+	if h.wantsConnectedEvent(r) {
+		connectedEvent, _ := json.Marshal(service.ProgressEvent{
+			ID:      progressID,
+			Status:  "connected",
+			Message: "Connected to progress stream.",
+		})
+		fmt.Fprintf(w, "data: %s\n\n", connectedEvent)
+	} else {
+		fmt.Fprint(w, ": connected\n\n")
+	}
+	for _, msg := range replay {
+		writeProgressMessage(w, msg)
+		lastSeq = msg.Seq
+		if msg.Terminal {
+			// The operation ended while the client was away
+			flusher.Flush()
+			return
+		}
+	}
 	flusher.Flush()
 
 	for {
@@ -227,31 +322,85 @@ func (h *WebStreamHandler) ServeProgress(w http.ResponseWriter, r *http.Request)
 		case <-r.Context().Done():
 			slog.Info("SSE client disconnected", "progressID", progressID, "reason", r.Context().Err())
 			return
-		case eventBytes := <-clientChan:
-			fmt.Fprintf(w, "data: %s\n\n", eventBytes)
+		case msg, ok := <-clientChan:
+			if !ok {
+				// Unregistered after a complete/error event, or replaced by a
+				// newer client. The terminal event may not have made it to
+				// the channel, e.g. when this client was too slow for it, so
+				// it is sent from the history: it is always the last event
+				// of an operation that ended.
+				if terminal, ended := h.progressManager.TerminalMessage(progressID); ended && terminal.Seq > lastSeq {
+					writeProgressMessage(w, terminal)
+					flusher.Flush()
+				}
+				return
+			}
+			if msg.Seq != 0 && msg.Seq <= lastSeq {
+				continue // Already sent in the replay
+			}
+			writeProgressMessage(w, msg)
 			flusher.Flush()
+			if msg.Terminal {
+				return
+			}
+			lastSeq = max(lastSeq, msg.Seq)
 		}
 	}
 }
 
+// wantsConnectedEvent reports whether a progress stream starts with a
+// "connected" event: as the connected query parameter of r says, or else
+// as SSE_CONNECTED_EVENT does.
+func (h *WebStreamHandler) wantsConnectedEvent(r *http.Request) bool {
+	if connected, err := strconv.ParseBool(r.URL.Query().Get("connected")); err == nil {
+		return connected
+	}
+	return h.downloader.Config().SSEConnectedEvent
+}
+
+// writeProgressMessage writes msg as an SSE event, with its sequence number
+// as the event id when it has one.
+func writeProgressMessage(w io.Writer, msg service.ProgressMessage) {
+	if msg.Seq != 0 {
+		fmt.Fprintf(w, "id: %d\n", msg.Seq)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", msg.Data)
+}
+
+// allowedResolution caps the resolution requested on r at
+// ANONYMOUS_MAX_RESOLUTION unless its user authenticated.
+func (h *WebStreamHandler) allowedResolution(r *http.Request, resolution string) string {
+	if appMiddleware.IsAuthenticated(r.Context()) {
+		return resolution
+	}
+	capped := service.CapResolution(resolution, h.downloader.Config().AnonymousMaxResolution)
+	if capped != resolution {
+		slog.Info("Capping resolution for anonymous user", "requested", resolution, "resolution", capped)
+	}
+	return capped
+}
+
 // PlayWebStream handles the actual video streaming for the web player.
 //
 //	@Summary		Play web stream
-//	@Description	Streams the video content directly to the browser based on query parameters.
+//	@Description	Streams the video content directly to the browser based on query parameters. The stream is muxed on the fly into the requested container, mp4 by default.
 //	@Tags			web
-//	@Produce		video/mp4
+//	@Produce		video/mp4,video/webm,video/x-matroska
 //	@Param			url			query		string	true	"Video URL"
-//	@Param			resolution	query		string	false	"Video Resolution (e.g., 720, 1080)"
+//	@Param			resolution	query		string	false	"Video Resolution (e.g., 720, 1080); capped at ANONYMOUS_MAX_RESOLUTION without Basic Auth credentials"
 //	@Param			codec		query		string	false	"Video Codec (e.g., avc1, vp9)"
+//	@Param			format		query		string	false	"Container to stream (mp4, webm or mkv; default mp4)"
 //	@Param			progressID	query		string	true	"Unique ID for progress tracking"
 //	@Success		200			{file}		file	"Successfully streamed video"
-//	@Failure		400			{string}	string	"Bad Request"
+//	@Failure		400			{string}	string	"Bad Request, or a format other than mp4, webm or mkv"
 //	@Failure		500			{string}	string	"Internal Server Error"
+//	@Failure		503			{string}	string	"Too many concurrent streams"
 //	@Router			/web/play [get]
 func (h *WebStreamHandler) PlayWebStream(w http.ResponseWriter, r *http.Request) {
 	videoURL := r.URL.Query().Get("url")
-	resolution := r.URL.Query().Get("resolution")
+	resolution := h.allowedResolution(r, r.URL.Query().Get("resolution"))
 	codec := r.URL.Query().Get("codec")
+	format := r.URL.Query().Get("format")
 	progressID := r.URL.Query().Get("progressID") // Get progress ID
 
 	if videoURL == "" {
@@ -259,25 +408,37 @@ func (h *WebStreamHandler) PlayWebStream(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "URL is required", http.StatusBadRequest)
 		return
 	}
+	if format == "" {
+		format = "mp4"
+	}
+	if err := service.ValidateStreamContainer(format, codec); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	slog.Info("Attempting to stream video for web player", "url", videoURL, "resolution", resolution, "codec", codec, "progressID", progressID)
-
-	// Use the downloader's StreamVideo method (direct piping)
-	readCloser, err := h.downloader.StreamVideo(r.Context(), videoURL, "mp4", resolution, codec, progressID)
-	if err != nil {
+	slog.Info("Attempting to stream video for web player", "url", videoURL, "resolution", resolution, "codec", codec, "format", format, "progressID", progressID)
+
+	// Use the downloader's StreamVideoTo method (direct piping)
+	started := false
+	_, err := h.downloader.StreamVideoTo(r.Context(), w, videoURL, service.VideoOptions{
+		Format:     format,
+		Resolution: resolution,
+		Codec:      codec,
+	}, progressID, func(*service.VideoInfo) error {
+		started = true
+		w.Header().Set("Content-Type", service.StreamContentType(format))
+		w.Header().Set("Transfer-Encoding", "chunked")
+		w.Header().Set("Cache-Control", "no-cache")
+		slog.Info("Starting web video stream", "url", videoURL)
+		return nil
+	})
+	if !started {
 		slog.Error("Failed to stream video for web player", "error", err, "url", videoURL)
 		h.progressManager.SendError(progressID, fmt.Sprintf("Failed to stream video: %v", err), err)
-		http.Error(w, fmt.Sprintf("Failed to stream video: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to stream video: %v", err), errorStatus(err))
 		return
 	}
-	defer readCloser.Close()
-
-	w.Header().Set("Content-Type", "video/mp4")
-	w.Header().Set("Transfer-Encoding", "chunked")
-	w.Header().Set("Cache-Control", "no-cache")
-
-	slog.Info("Starting web video stream", "url", videoURL)
-	if _, err := io.Copy(w, readCloser); err != nil {
+	if err != nil {
 		slog.Error("Error while streaming web video", "error", err, "url", videoURL)
 		// Note: Cannot send HTTP error after headers have been written and body started.
 		// The client might just see a broken stream.
@@ -295,16 +456,18 @@ func (h *WebStreamHandler) PlayWebStream(w http.ResponseWriter, r *http.Request)
 //	@Tags			web
 //	@Produce		video/mp4
 //	@Param			url			query		string	true	"Video URL"
-//	@Param			resolution	query		string	false	"Video Resolution (e.g., 720, 1080)"
+//	@Param			resolution	query		string	false	"Video Resolution (e.g., 720, 1080); capped at ANONYMOUS_MAX_RESOLUTION without Basic Auth credentials"
 //	@Param			codec		query		string	false	"Video Codec (e.g., avc1, vp9)"
 //	@Param			progressID	query		string	true	"Unique ID for progress tracking"
 //	@Success		200			{file}		file	"Successfully streamed video for download"
 //	@Failure		400			{string}	string	"Bad Request"
+//	@Failure		422			{string}	string	"Video is longer than MAX_DURATION"
 //	@Failure		500			{string}	string	"Internal Server Error"
+//	@Failure		503			{string}	string	"Too many concurrent downloads"
 //	@Router			/web/download/video [get]
 func (h *WebStreamHandler) DownloadVideoToBrowser(w http.ResponseWriter, r *http.Request) {
 	videoURL := r.URL.Query().Get("url")
-	resolution := r.URL.Query().Get("resolution")
+	resolution := h.allowedResolution(r, r.URL.Query().Get("resolution"))
 	codec := r.URL.Query().Get("codec")
 	progressID := r.URL.Query().Get("progressID") // Get progress ID
 
@@ -322,18 +485,24 @@ func (h *WebStreamHandler) DownloadVideoToBrowser(w http.ResponseWriter, r *http
 		slog.Warn("Could not get video info for filename suggestion, proceeding without it", "error", err)
 		videoInfo = &service.VideoInfo{Title: "video", Ext: "mp4"} // Fallback
 		// Error event already sent by downloader.GetVideoInfo
-		http.Error(w, fmt.Sprintf("Failed to get video information: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to get video information: %v", err), errorStatus(err))
 		return
 	}
 
 	// Download video to a temporary file
-	tempFilePath, err := h.downloader.DownloadVideoToTempFile(r.Context(), videoURL, "mp4", resolution, codec, progressID) // Pass progressID
+	tempFilePath, err := h.downloader.DownloadVideoToTempFile(r.Context(), videoURL, service.VideoOptions{
+		Format:     "mp4",
+		Resolution: resolution,
+		Codec:      codec,
+	}, progressID) // Pass progressID
 	if err != nil {
 		slog.Error("Failed to download video to temporary file", "error", err, "url", videoURL)
 		// Error event already sent by downloader.DownloadVideoToTempFile
-		http.Error(w, fmt.Sprintf("Failed to download video: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to download video: %v", err), errorStatus(err))
 		return
 	}
+	// http.ServeFile returns only once the whole response is written, so this
+	// deferred removal cannot pull the file out from under it.
 	defer func() {
 		if err := os.Remove(tempFilePath); err != nil {
 			slog.Error("Failed to remove temporary video file", "filePath", tempFilePath, "error", err)
@@ -341,13 +510,10 @@ func (h *WebStreamHandler) DownloadVideoToBrowser(w http.ResponseWriter, r *http
 	}()
 
 	// Set headers for download
-	filename := fmt.Sprintf("%s.%s", sanitizeFilename(videoInfo.Title), "mp4")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	w.Header().Set("Content-Type", "video/mp4")
-	// http.ServeFile will handle Content-Length and other headers
+	filename := setAttachmentHeaders(w, videoInfo.Title, tempFilePath)
 
 	slog.Info("Serving temporary video file for direct download", "filePath", tempFilePath, "filename", filename)
-	http.ServeFile(w, r, tempFilePath)
+	serveCompleteFile(w, r, tempFilePath)
 	h.progressManager.SendComplete(progressID, "Video download complete.", videoInfo) // Send complete event
 	slog.Info("Direct video download stream finished", "url", videoURL)
 }
@@ -363,9 +529,12 @@ func (h *WebStreamHandler) DownloadVideoToBrowser(w http.ResponseWriter, r *http
 //	@Param			codec			query		string	false	"Audio Codec (e.g., libmp3lame)"
 //	@Param			bitrate			query		string	false	"Audio Bitrate (e.g., 128k)"
 //	@Param			progressID		query		string	true	"Unique ID for progress tracking"
+//	@Param			icy				query		bool	false	"Send icy-name and icy-br headers for internet-radio clients"
 //	@Success		200				{file}		file	"Successfully streamed audio for download"
 //	@Failure		400				{string}	string	"Bad Request"
+//	@Failure		422				{string}	string	"Video is longer than MAX_DURATION"
 //	@Failure		500				{string}	string	"Internal Server Error"
+//	@Failure		503				{string}	string	"Too many concurrent downloads"
 //	@Router			/web/download/audio [get]
 func (h *WebStreamHandler) DownloadAudioToBrowser(w http.ResponseWriter, r *http.Request) {
 	audioURL := r.URL.Query().Get("url")
@@ -388,18 +557,25 @@ func (h *WebStreamHandler) DownloadAudioToBrowser(w http.ResponseWriter, r *http
 		slog.Warn("Could not get video info for filename suggestion, proceeding without it", "error", err)
 		videoInfo = &service.VideoInfo{Title: "audio", Ext: "mp3"} // Fallback
 		// Error event already sent by downloader.GetVideoInfo
-		http.Error(w, fmt.Sprintf("Failed to get video information: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to get video information: %v", err), errorStatus(err))
 		return
 	}
 
 	// Download audio to a temporary file
-	tempFilePath, err := h.downloader.DownloadAudioToTempFile(r.Context(), audioURL, outputFormat, codec, bitrate, progressID) // Pass progressID
+	opts := service.AudioOptions{
+		Format:  outputFormat,
+		Codec:   codec,
+		Bitrate: bitrate,
+	}
+	tempFilePath, err := h.downloader.DownloadAudioToTempFile(r.Context(), audioURL, opts, progressID) // Pass progressID
 	if err != nil {
 		slog.Error("Failed to download audio to temporary file", "error", err, "url", audioURL)
 		// Error event already sent by downloader.DownloadAudioToTempFile
-		http.Error(w, fmt.Sprintf("Failed to download audio: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to download audio: %v", err), errorStatus(err))
 		return
 	}
+	// http.ServeFile returns only once the whole response is written, so this
+	// deferred removal cannot pull the file out from under it.
 	defer func() {
 		if err := os.Remove(tempFilePath); err != nil {
 			slog.Error("Failed to remove temporary audio file", "filePath", tempFilePath, "error", err)
@@ -407,20 +583,74 @@ func (h *WebStreamHandler) DownloadAudioToBrowser(w http.ResponseWriter, r *http
 	}()
 
 	// Set headers for download
-	if outputFormat == "" {
-		outputFormat = "mp3" // Default for content-type
+	filename := setAttachmentHeaders(w, videoInfo.Title, tempFilePath)
+	if r.URL.Query().Get("icy") == "true" {
+		setICYHeaders(w, videoInfo, opts.EffectiveBitrate())
 	}
-	filename := fmt.Sprintf("%s.%s", sanitizeFilename(videoInfo.Title), outputFormat)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	w.Header().Set("Content-Type", fmt.Sprintf("audio/%s", outputFormat)) // e.g., audio/mp3
-	// http.ServeFile will handle Content-Length and other headers
 
 	slog.Info("Serving temporary audio file for direct download", "filePath", tempFilePath, "filename", filename)
-	http.ServeFile(w, r, tempFilePath)
+	serveCompleteFile(w, r, tempFilePath)
 	h.progressManager.SendComplete(progressID, "Audio download complete.", videoInfo) // Send complete event
 	slog.Info("Direct audio download stream finished", "url", audioURL)
 }
 
+// serveCompleteFile serves a finished download with its exact
+// Content-Length, so the browser shows accurate progress while receiving
+// it. http.ServeFile adjusts the length for range requests, but leaves it
+// out when a Content-Encoding is set, and a Transfer-Encoding would make the
+// response chunked: neither may be set on a complete file.
+func serveCompleteFile(w http.ResponseWriter, r *http.Request, path string) {
+	w.Header().Del("Content-Encoding")
+	w.Header().Del("Transfer-Encoding")
+	if info, err := os.Stat(path); err == nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	http.ServeFile(w, r, path)
+}
+
+// mediaTypes are the Content-Types of the files downloads produce, which the
+// mime package only knows when the system lists them.
+var mediaTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".opus": "audio/ogg",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+	".wav":  "audio/wav",
+}
+
+// setAttachmentHeaders sets the Content-Disposition and Content-Type of a
+// download of the file at path, saved as title. The extension comes from
+// the file actually produced rather than the format asked for, so it always
+// matches the content, e.g. when a recode fell back to the original webm.
+// Titles outside ASCII are sent RFC 2231 encoded. It returns the filename.
+func setAttachmentHeaders(w http.ResponseWriter, title, path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	filename := sanitizeFilename(title) + ext
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Type", mediaContentType(path))
+	return filename
+}
+
+// mediaContentType returns the Content-Type of the media file at path, from
+// its extension.
+func mediaContentType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if contentType := mediaTypes[ext]; contentType != "" {
+		return contentType
+	}
+	return cmp.Or(mime.TypeByExtension(ext), "application/octet-stream")
+}
+
 // sanitizeFilename removes characters that are not allowed in filenames.
 func sanitizeFilename(s string) string {
 	s = strings.ReplaceAll(s, "/", "_")
@@ -0,0 +1,454 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	appMiddleware "gostreampuller/middleware"
+	"gostreampuller/service"
+)
+
+func newTestWebStreamHandler(t *testing.T) *WebStreamHandler {
+	t.Helper()
+	cfg := newTestConfig(t, fakeYTDLPScript)
+	pm := service.NewProgressManager(0)
+	return NewWebStreamHandler(service.NewDownloader(cfg, pm), pm, cfg)
+}
+
+func TestWebStreamHandler_HandleLoadInfo_FormRedirects(t *testing.T) {
+	h := newTestWebStreamHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/load-info", strings.NewReader("url="+url.QueryEscape("https://example.com/v")))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.HandleLoadInfo(rec, req)
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/web", location.Path)
+	assert.Equal(t, "https://example.com/v", location.Query().Get("url"))
+	assert.Contains(t, location.Query().Get("videoInfo"), `"id":"abc123"`)
+}
+
+func TestWebStreamHandler_HandleLoadInfo_JSON(t *testing.T) {
+	h := newTestWebStreamHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/load-info", strings.NewReader(`{"url":"https://example.com/v"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	h.HandleLoadInfo(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var resp LoadInfoResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.True(t, strings.HasPrefix(resp.ProgressID, "info-"))
+	assert.Equal(t, "abc123", resp.VideoInfo.ID)
+}
+
+func TestWebStreamHandler_HandleLoadInfo_JSONMissingURL(t *testing.T) {
+	h := newTestWebStreamHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/load-info", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	h.HandleLoadInfo(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "URL is required")
+}
+
+func TestWebStreamHandler_DownloadAudioToBrowser_ICYHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantBitrate string
+	}{
+		{name: "DefaultBitrate", query: "", wantBitrate: "128"},
+		{name: "Lossless", query: "&outputFormat=flac", wantBitrate: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestWebStreamHandler(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/web/download/audio?icy=true&url="+url.QueryEscape("https://example.com/v")+tt.query, nil)
+			rec := httptest.NewRecorder()
+			h.DownloadAudioToBrowser(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "Test Video", rec.Header().Get(ICYNameHeader))
+			assert.Equal(t, tt.wantBitrate, rec.Header().Get(ICYBitrateHeader))
+		})
+	}
+}
+
+func TestWebStreamHandler_ServeProgress_ResumesFromLastEventID(t *testing.T) {
+	h := newTestWebStreamHandler(t)
+	pm := h.progressManager
+
+	// A first connection receives events 1 and 2, then drops; the operation
+	// goes on and completes while the client is away
+	pm.UnregisterClient("p1", pm.RegisterClient("p1"))
+	for _, status := range []string{"fetching_info", "downloading", "encoding"} {
+		pm.SendEvent(service.ProgressEvent{ID: "p1", Status: status})
+	}
+	pm.SendComplete("p1", "done", nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/web/progress?progressID=p1", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	rec := httptest.NewRecorder()
+	h.ServeProgress(rec, req)
+
+	body := rec.Body.String()
+	assert.NotContains(t, body, "id: 1\n")
+	assert.NotContains(t, body, "id: 2\n")
+	assert.NotContains(t, body, `"status":"downloading"`)
+	assert.Contains(t, body, "id: 3\ndata: ")
+	assert.Contains(t, body, `"status":"encoding"`)
+	assert.Contains(t, body, "id: 4\ndata: ")
+	assert.Contains(t, body, `"status":"complete"`)
+}
+
+// blockingRecorder is a ResponseRecorder whose writes wait until release is
+// closed, to hold up an SSE handler; started is closed by the first write.
+type blockingRecorder struct {
+	*httptest.ResponseRecorder
+	started, release chan struct{}
+	once             sync.Once
+}
+
+func (w *blockingRecorder) Write(b []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestWebStreamHandler_ServeProgress_TerminalEventAlwaysLast(t *testing.T) {
+	h := newTestWebStreamHandler(t)
+	pm := h.progressManager
+
+	rec := &blockingRecorder{ResponseRecorder: httptest.NewRecorder(), started: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeProgress(rec, httptest.NewRequest(http.MethodGet, "/web/progress?progressID=p1", nil))
+	}()
+
+	// While the client is stuck writing, the final event times out waiting
+	// for it and the client is unregistered, so the event never reaches
+	// its channel
+	<-rec.started
+	pm.SendEvent(service.ProgressEvent{ID: "p1", Status: "downloading"})
+	pm.SendError("p1", "Download failed", errors.New("boom"))
+	close(rec.release)
+	<-done
+
+	events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+	last := events[len(events)-1]
+	assert.Contains(t, last, `"status":"error"`)
+	assert.Contains(t, last, `"error":"boom"`)
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), `"status":"error"`))
+}
+
+func TestWebStreamHandler_ServeProgress_ReconnectKeepsNewClient(t *testing.T) {
+	h := newTestWebStreamHandler(t)
+	pm := h.progressManager
+
+	first := &blockingRecorder{ResponseRecorder: httptest.NewRecorder(), started: make(chan struct{}), release: make(chan struct{})}
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		h.ServeProgress(first, httptest.NewRequest(http.MethodGet, "/web/progress?progressID=p1", nil))
+	}()
+	<-first.started
+
+	// The user reconnects while the first handler is still running
+	second := &blockingRecorder{ResponseRecorder: httptest.NewRecorder(), started: make(chan struct{}), release: make(chan struct{})}
+	close(second.release)
+	secondDone := make(chan struct{})
+	go func() {
+		defer close(secondDone)
+		req := httptest.NewRequest(http.MethodGet, "/web/progress?progressID=p1", nil)
+		req.Header.Set("Last-Event-ID", "0")
+		h.ServeProgress(second, req)
+	}()
+	<-second.started
+
+	// The first handler ends once replaced, and must not unregister the
+	// second client as it leaves
+	close(first.release)
+	<-firstDone
+	select {
+	case <-secondDone:
+		t.Fatal("the second client was cut off when the first one left")
+	case <-time.After(50 * time.Millisecond):
+	}
+	pm.SendComplete("p1", "done", nil)
+	<-secondDone
+
+	assert.NotContains(t, first.Body.String(), `"status":"complete"`)
+	assert.Contains(t, second.Body.String(), `"status":"complete"`)
+}
+
+func TestWebStreamHandler_ServeProgress_ConnectedEvent(t *testing.T) {
+	tests := []struct {
+		name          string
+		configEnabled bool
+		query         string
+		want          bool
+	}{
+		{name: "Config", configEnabled: true, want: true},
+		{name: "ConfigOff", configEnabled: false, want: false},
+		{name: "ParamOff", configEnabled: true, query: "&connected=false", want: false},
+		{name: "ParamOn", configEnabled: false, query: "&connected=true", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestWebStreamHandler(t)
+			h.downloader.Config().SSEConnectedEvent = tt.configEnabled
+
+			// The operation is over, so the stream ends after the replay
+			h.progressManager.UnregisterClient("p1", h.progressManager.RegisterClient("p1"))
+			h.progressManager.SendComplete("p1", "done", nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/web/progress?progressID=p1"+tt.query, nil)
+			req.Header.Set("Last-Event-ID", "0")
+			rec := httptest.NewRecorder()
+			h.ServeProgress(rec, req)
+
+			body := rec.Body.String()
+			assert.Equal(t, tt.want, strings.Contains(body, `"status":"connected"`))
+			assert.Equal(t, !tt.want, strings.HasPrefix(body, ": connected\n\n"))
+			assert.Contains(t, body, `"status":"complete"`)
+		})
+	}
+}
+
+// echoArgsYTDLPScript streams its own arguments, so tests can check how the
+// live stream was asked for.
+const echoArgsYTDLPScript = `#!/bin/sh
+for a in "$@"; do
+	case "$a" in --dump-json) echo '{"id":"abc123","title":"Test Video","ext":"mp4","duration":42}'; exit 0 ;; esac
+done
+echo "$@"
+`
+
+func TestWebStreamHandler_PlayWebStream_Format(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		wantContentType string
+		wantArgs        string
+	}{
+		{name: "DefaultMP4", query: "", wantContentType: "video/mp4"},
+		{name: "WebM", query: "&format=webm", wantContentType: "video/webm", wantArgs: "--merge-output-format webm"},
+		{name: "MKV", query: "&format=mkv", wantContentType: "video/x-matroska", wantArgs: "--merge-output-format mkv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, echoArgsYTDLPScript)
+			pm := service.NewProgressManager(0)
+			h := NewWebStreamHandler(service.NewDownloader(cfg, pm), pm, cfg)
+
+			req := httptest.NewRequest(http.MethodGet, "/web/play?url="+url.QueryEscape("https://example.com/v")+tt.query, nil)
+			rec := httptest.NewRecorder()
+			h.PlayWebStream(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			if tt.wantArgs != "" {
+				assert.Contains(t, rec.Body.String(), tt.wantArgs)
+			} else {
+				assert.NotContains(t, rec.Body.String(), "--merge-output-format")
+			}
+		})
+	}
+}
+
+func TestWebStreamHandler_PlayWebStream_InvalidFormat(t *testing.T) {
+	h := newTestWebStreamHandler(t)
+
+	for _, query := range []string{"&format=avi", "&format=webm&codec=avc1"} {
+		req := httptest.NewRequest(http.MethodGet, "/web/play?url="+url.QueryEscape("https://example.com/v")+query, nil)
+		rec := httptest.NewRecorder()
+		h.PlayWebStream(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestWebStreamHandler_PlayWebStream_AnonymousResolutionCap(t *testing.T) {
+	cfg := newTestConfig(t, echoArgsYTDLPScript)
+	cfg.LocalMode = false
+	cfg.AuthUsername = "admin"
+	cfg.AuthPassword = "secret"
+	cfg.AnonymousMaxResolution = 480
+	pm := service.NewProgressManager(0)
+	h := appMiddleware.OptionalAuthMiddleware(cfg)(http.HandlerFunc(NewWebStreamHandler(service.NewDownloader(cfg, pm), pm, cfg).PlayWebStream))
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantArgs string
+	}{
+		{name: "Anonymous", wantArgs: "bestvideo[height<=480]"},
+		{name: "WrongPassword", username: "admin", password: "guess", wantArgs: "bestvideo[height<=480]"},
+		{name: "Authenticated", username: "admin", password: "secret", wantArgs: "bestvideo[height<=1080]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/web/play?resolution=1080&url="+url.QueryEscape("https://example.com/v"), nil)
+			if tt.username != "" {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantArgs)
+		})
+	}
+}
+
+func TestWebStreamHandler_ServeStreamPage_LoginLink(t *testing.T) {
+	cfg := newTestConfig(t, fakeYTDLPScript)
+	cfg.LocalMode = false
+	cfg.AuthUsername = "admin"
+	cfg.AuthPassword = "secret"
+	cfg.AnonymousMaxResolution = 480
+	pm := service.NewProgressManager(0)
+	h := appMiddleware.OptionalAuthMiddleware(cfg)(http.HandlerFunc(NewWebStreamHandler(service.NewDownloader(cfg, pm), pm, cfg).ServeStreamPage))
+
+	target := "/web?progressID=p1&url=" + url.QueryEscape("https://example.com/v") + "&videoInfo=" + url.QueryEscape(`{"id":"v1","title":"Test Video"}`)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "above 480p need an account")
+	assert.Contains(t, rec.Body.String(), `href="/web/login?next=%2fweb%3fprogressID%3dp1%26url%3d`)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.SetBasicAuth("admin", "secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "/web/login")
+}
+
+func TestWebStreamHandler_Login(t *testing.T) {
+	tests := []struct {
+		name string
+		next string
+		want string
+	}{
+		{name: "NoNext", want: "/"},
+		{name: "Page", next: "/web?url=x&progressID=p1", want: "/web?progressID=p1&url=x"},
+		{name: "OtherHost", next: "//evil.example/web", want: "/"},
+		{name: "AbsoluteURL", next: "https://evil.example/web", want: "/"},
+		{name: "RelativePath", next: "web", want: "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestWebStreamHandler(t)
+
+			rec := httptest.NewRecorder()
+			h.Login(rec, httptest.NewRequest(http.MethodGet, "/web/login?next="+url.QueryEscape(tt.next), nil))
+
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.want, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestWebStreamHandler_DownloadToBrowser_ContentLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		handle func(*WebStreamHandler) http.HandlerFunc
+	}{
+		{name: "Video", path: "/web/download/video", handle: func(h *WebStreamHandler) http.HandlerFunc { return h.DownloadVideoToBrowser }},
+		{name: "Audio", path: "/web/download/audio", handle: func(h *WebStreamHandler) http.HandlerFunc { return h.DownloadAudioToBrowser }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestWebStreamHandler(t)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path+"?url="+url.QueryEscape("https://example.com/v"), nil)
+			rec := httptest.NewRecorder()
+			tt.handle(h)(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, strconv.Itoa(len(fakeVideoContent)), rec.Header().Get("Content-Length"))
+			assert.Empty(t, rec.Header().Get("Transfer-Encoding"))
+			assert.Equal(t, fakeVideoContent, rec.Body.String())
+		})
+	}
+}
+
+func TestWebStreamHandler_DownloadToBrowser_FilenameMatchesFile(t *testing.T) {
+	// The recode to mp4 fails, leaving the original webm to be served
+	const recodeFailsScript = `#!/bin/sh
+out=""
+prev=""
+for a in "$@"; do
+	case "$prev" in --output|-o) out="$a" ;; esac
+	case "$a" in --dump-json) echo '{"id":"abc123","title":"Test Video","ext":"webm"}'; exit 0 ;; esac
+	prev="$a"
+done
+printf 'webm-data' > "${out%.*}.webm"
+echo "ERROR: Postprocessing: Conversion failed!" >&2
+exit 1
+`
+	cfg := newTestConfig(t, recodeFailsScript)
+	pm := service.NewProgressManager(0)
+	h := NewWebStreamHandler(service.NewDownloader(cfg, pm), pm, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/web/download/video?url="+url.QueryEscape("https://example.com/v"), nil)
+	rec := httptest.NewRecorder()
+	h.DownloadVideoToBrowser(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `attachment; filename=Test_Video.webm`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "video/webm", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "webm-data", rec.Body.String())
+
+	// Audio without an outputFormat is named after the default format
+	rec = httptest.NewRecorder()
+	newTestWebStreamHandler(t).DownloadAudioToBrowser(rec, httptest.NewRequest(http.MethodGet, "/web/download/audio?url="+url.QueryEscape("https://example.com/v"), nil))
+	assert.Equal(t, `attachment; filename=Test_Video.mp3`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "audio/mpeg", rec.Header().Get("Content-Type"))
+}
+
+func TestSetAttachmentHeaders(t *testing.T) {
+	tests := []struct {
+		name, title, path string
+		wantDisposition   string
+		wantType          string
+	}{
+		{"Plain", "Test Video", "/tmp/x.MP4", `attachment; filename=Test_Video.mp4`, "video/mp4"},
+		{"Quoted", `Say "hi"; bye`, "/tmp/x.m4a", `attachment; filename="Say_hi_;_bye.m4a"`, "audio/mp4"},
+		{"Unicode", "Café été", "/tmp/x.opus", `attachment; filename*=utf-8''Caf%C3%A9_%C3%A9t%C3%A9.opus`, "audio/ogg"},
+		{"UnknownExtension", "Test", "/tmp/x.zzz", `attachment; filename=Test.zzz`, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			setAttachmentHeaders(rec, tt.title, tt.path)
+			assert.Equal(t, tt.wantDisposition, rec.Header().Get("Content-Disposition"))
+			assert.Equal(t, tt.wantType, rec.Header().Get("Content-Type"))
+		})
+	}
+}
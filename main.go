@@ -13,6 +13,8 @@ import (
 	"gostreampuller/config"
 	_ "gostreampuller/docs" // This line is necessary for Swagger to find the docs
 	"gostreampuller/router"
+	"gostreampuller/service"
+	"gostreampuller/telemetry"
 )
 
 // @title			GoStreamPuller API
@@ -23,6 +25,8 @@ import (
 // @contact.email	support@example.com
 // @BasePath		/
 // @schemes		http
+//
+// @securityDefinitions.basic	BasicAuth
 func main() {
 	// Set up structured logging
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
@@ -34,20 +38,47 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Set up tracing before anything records spans
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Telemetry setup error", "error", err)
+		os.Exit(1)
+	}
+
+	// Remove partial downloads left by a crash, without delaying startup
+	if cfg.PartialCleanupAge > 0 {
+		go func() {
+			for _, dir := range cfg.MediaDirs() {
+				removed, err := service.CleanupPartials(dir, cfg.PartialCleanupAge)
+				if err != nil {
+					slog.Error("Partial download cleanup failed", "error", err, "dir", dir)
+					continue
+				}
+				slog.Info(fmt.Sprintf("Removed %d stale partial download(s)", removed), "dir", dir)
+			}
+		}()
+	}
+
 	// Setup router
 	r := router.New(cfg)
 
 	// Configure server
-	srv := &http.Server{
-		Addr:              ":" + cfg.Port,
-		Handler:           r.Handler(),
-		ReadHeaderTimeout: 10 * time.Second, // Fix for G112: Potential Slowloris Attack
-	}
+	srv := newServer(cfg, r.Handler())
 
 	// Graceful shutdown handling
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
+	// Reload the settings that can change without a restart on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		current := cfg
+		for range hup {
+			current = reloadConfig(current, r)
+		}
+	}()
+
 	// Start server
 	go func() {
 		slog.Info(fmt.Sprintf("Server starting on port %s...", cfg.Port))
@@ -75,5 +106,43 @@ func main() {
 		slog.Error("Server shutdown failed", "error", err)
 		os.Exit(1)
 	}
+	if err := r.Shutdown(ctx); err != nil {
+		slog.Error("Background stream saves did not stop in time", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
 	slog.Info("Server stopped")
 }
+
+// reloadConfig reads the configuration again and applies its reloadable
+// settings to the running server, logging what changed. On error the
+// current configuration stays in effect and is returned.
+func reloadConfig(current *config.Config, r *router.Router) *config.Config {
+	next, err := current.Reload()
+	if err != nil {
+		slog.Error("Configuration reload failed, keeping the current configuration", "error", err)
+		return current
+	}
+	before, after := current.Redacted(), next.Redacted()
+	for name, value := range after {
+		if before[name] != value {
+			slog.Info("Configuration changed", "setting", name, "from", before[name], "to", value)
+		}
+	}
+	r.Reload(next)
+	slog.Info("Configuration reloaded")
+	return next
+}
+
+// newServer builds the HTTP server with the configured timeouts.
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second, // Fix for G112: Potential Slowloris Attack
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout, // 0 by default so streams are not cut off
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
 	"gostreampuller/config"
 )
@@ -40,3 +42,30 @@ func TestConfigWithCredentials(t *testing.T) {
 		t.Errorf("Expected password 'testpass', got '%s'", cfg.AuthPassword)
 	}
 }
+
+func TestNewServerTimeouts(t *testing.T) {
+	cfg := &config.Config{
+		Port:         "9090",
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 0,
+		IdleTimeout:  90 * time.Second,
+	}
+
+	srv := newServer(cfg, http.NotFoundHandler())
+
+	if srv.Addr != ":9090" {
+		t.Errorf("Expected addr ':9090', got '%s'", srv.Addr)
+	}
+	if srv.ReadHeaderTimeout != 10*time.Second {
+		t.Errorf("Expected ReadHeaderTimeout 10s, got %s", srv.ReadHeaderTimeout)
+	}
+	if srv.ReadTimeout != 15*time.Second {
+		t.Errorf("Expected ReadTimeout 15s, got %s", srv.ReadTimeout)
+	}
+	if srv.WriteTimeout != 0 {
+		t.Errorf("Expected WriteTimeout 0 so streams are not cut off, got %s", srv.WriteTimeout)
+	}
+	if srv.IdleTimeout != 90*time.Second {
+		t.Errorf("Expected IdleTimeout 90s, got %s", srv.IdleTimeout)
+	}
+}
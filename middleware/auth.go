@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+
+	"gostreampuller/config"
+)
+
+// authenticatedKey marks requests whose user authenticated in the context.
+type authenticatedKey struct{}
+
+// validCredentials reports whether r carries the configured
+// AUTH_USERNAME/AUTH_PASSWORD credentials.
+func validCredentials(cfg *config.Config, r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(cfg.AuthUsername)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(cfg.AuthPassword)) == 1
+}
+
+// BasicAuthMiddleware requires the configured AUTH_USERNAME/AUTH_PASSWORD
+// credentials. It lets every request through in local mode.
+func BasicAuthMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.LocalMode {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !validCredentials(cfg, r) {
+				slog.Warn("Rejected unauthenticated request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", `Basic realm="gostreampuller"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// OptionalAuthMiddleware lets every request through, recording whether it
+// carried the configured credentials so handlers can serve anonymous users
+// less; see IsAuthenticated. Every request counts as authenticated in local
+// mode.
+func OptionalAuthMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.LocalMode || validCredentials(cfg, r) {
+				r = r.WithContext(context.WithValue(r.Context(), authenticatedKey{}, true))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsAuthenticated reports whether OptionalAuthMiddleware found valid
+// credentials on the request of ctx.
+func IsAuthenticated(ctx context.Context) bool {
+	authenticated, _ := ctx.Value(authenticatedKey{}).(bool)
+	return authenticated
+}
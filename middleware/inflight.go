@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// InFlightLimiter caps how many requests are handled at once, protecting
+// the server as a whole from overload on top of the stream and download
+// limits. Requests beyond the max get 503 right away. Requests to the exempt
+// paths, such as health checks and long-lived SSE connections, are neither
+// counted nor refused. The max can be changed while the server runs; 0 or
+// less means no limit.
+type InFlightLimiter struct {
+	mu      sync.Mutex
+	running int
+	max     int
+	exempt  map[string]bool
+}
+
+// NewInFlightLimiter returns a limiter handling max requests at once.
+func NewInFlightLimiter(max int, exempt ...string) *InFlightLimiter {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+	return &InFlightLimiter{max: max, exempt: exemptPaths}
+}
+
+// SetMax changes how many requests are handled at once. Requests already
+// in flight are not interrupted.
+func (l *InFlightLimiter) SetMax(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = max
+}
+
+// Load returns how many requests are in flight, exempt ones aside, and the
+// max (0 for no limit).
+func (l *InFlightLimiter) Load() (running, max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.running, l.max
+}
+
+// acquire counts a request in, unless max requests are already in flight.
+// It returns the max in effect, for logging.
+func (l *InFlightLimiter) acquire() (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.running >= l.max {
+		return false, l.max
+	}
+	l.running++
+	return true, l.max
+}
+
+func (l *InFlightLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+}
+
+// Middleware applies the limiter to the requests of next.
+func (l *InFlightLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.exempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, max := l.acquire()
+		if !ok {
+			slog.Warn("Rejected request, too many in flight", "path", r.URL.Path, "max", max)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many requests in flight, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		defer l.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlightLimitMiddleware caps how many requests are handled at once with
+// a fixed max; see InFlightLimiter.
+func InFlightLimitMiddleware(max int, exempt ...string) func(http.Handler) http.Handler {
+	return NewInFlightLimiter(max, exempt...).Middleware
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInFlightLimitMiddleware(t *testing.T) {
+	var started sync.WaitGroup
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-release
+	})
+	h := InFlightLimitMiddleware(2, "/health")(blocking)
+
+	// Saturate the limiter with two requests held in the handler
+	var done sync.WaitGroup
+	for range 2 {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/download/list", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}()
+	}
+	started.Wait()
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/download/list", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	}
+
+	// Exempt paths still get through
+	started.Add(1)
+	exempt := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		exempt <- rec.Code
+	}()
+	started.Wait()
+
+	// Finished requests free their slots
+	close(release)
+	done.Wait()
+	assert.Equal(t, http.StatusOK, <-exempt)
+	started.Add(1)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/download/list", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestInFlightLimiter_SetMax(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	limiter := NewInFlightLimiter(0)
+	h := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	// No limit at first; a request is held in the handler
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/download/list", nil))
+		done <- rec.Code
+	}()
+	<-started
+
+	// Lowering the max to the running request refuses the next one
+	limiter.SetMax(1)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/download/list", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	running, max := limiter.Load()
+	assert.Equal(t, 1, running, "refused requests are not counted")
+	assert.Equal(t, 1, max)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	running, _ = limiter.Load()
+	assert.Equal(t, 0, running)
+}
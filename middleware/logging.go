@@ -68,6 +68,11 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // Flush implements the http.Flusher interface.
 // It checks if the underlying ResponseWriter is an http.Flusher and calls its Flush method.
 func (r *responseRecorder) Flush() {
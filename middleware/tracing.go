@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware starts a span for each HTTP request, continuing the
+// caller's trace when the request carries one. The request context holds
+// the span, so the download stages traced by the service become its
+// children. Spans are named after the route pattern, not the path, to keep
+// filenames out of span names.
+func TracingMiddleware() func(http.Handler) http.Handler {
+	tracer := otel.Tracer("gostreampuller/http")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("url.path", r.URL.Path),
+				))
+			defer span.End()
+
+			recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			if route := chi.RouteContext(r.Context()); route != nil && route.RoutePattern() != "" {
+				span.SetName(r.Method + " " + route.RoutePattern())
+				span.SetAttributes(attribute.String("http.route", route.RoutePattern()))
+			}
+			span.SetAttributes(
+				attribute.Int("http.response.status_code", recorder.statusCode),
+				attribute.Int("http.response.body.size", recorder.size),
+			)
+			if recorder.statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.statusCode))
+			}
+		})
+	}
+}
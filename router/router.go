@@ -1,9 +1,13 @@
 package router
 
 import (
+	"context"
+	"expvar"
 	"log/slog"
 	"net/http"
 	"net/http/pprof" // Import pprof package
+	"strings"
+	"sync/atomic"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -16,55 +20,146 @@ import (
 	"gostreampuller/service"
 )
 
-// Router holds the HTTP multiplexer and configuration.
+// Router holds the HTTP multiplexer and the components a configuration
+// reload updates.
 type Router struct {
 	Mux *chi.Mux
-	cfg *config.Config
+
+	// What Reload can change in the running server
+	downloader *service.Downloader
+	inFlight   *appMiddleware.InFlightLimiter
+	features   features
+}
+
+// features holds the feature flags in effect. Every route is registered, and
+// those of a disabled feature answer 404, so that flags can change on reload.
+type features struct {
+	download, stream, web atomic.Bool
+}
+
+// set switches the flags to those of cfg.
+func (f *features) set(cfg *config.Config) {
+	f.download.Store(cfg.EnableDownload)
+	f.stream.Store(cfg.EnableStream)
+	f.web.Store(cfg.EnableWeb)
+}
+
+// enabled reports whether the feature serving path is enabled. Paths outside
+// the features, like health checks, are always enabled.
+func (f *features) enabled(path string) bool {
+	switch {
+	case strings.HasPrefix(path, "/download/"):
+		return f.download.Load()
+	case strings.HasPrefix(path, "/stream/"):
+		return f.stream.Load()
+	case path == "/" || path == "/load-info" || path == "/web" || strings.HasPrefix(path, "/web/"):
+		return f.web.Load()
+	}
+	return true
+}
+
+// gate answers 404 for the routes of disabled features, whatever the method.
+func (f *features) gate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.enabled(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 // New creates a new Router instance and initializes routes.
 func New(cfg *config.Config) *Router {
 	r := chi.NewRouter()
+	rt := &Router{Mux: r}
+	rt.features.set(cfg)
 
 	// Add common middleware
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
+	if cfg.OTelEnabled {
+		r.Use(appMiddleware.TracingMiddleware()) // Span per request, parent of the download stage spans
+	}
 	r.Use(appMiddleware.LoggingMiddleware(cfg)) // Use our custom logging middleware
 	r.Use(middleware.Recoverer)                 // Recover from panics and return 500 error
+	// Probes, status checks and SSE connections are cheap and must not be
+	// refused; the readiness probe caches its result to stay cheap. The
+	// limiter is always in place, as a reload may set a max.
+	rt.inFlight = appMiddleware.NewInFlightLimiter(cfg.MaxInflightRequests, "/health", "/ready", "/status", "/web/progress")
+	r.Use(rt.inFlight.Middleware)
+	r.Use(rt.features.gate)
 
 	// Create services
-	progressManager := service.NewProgressManager()           // Instantiate ProgressManager
-	downloader := service.NewDownloader(cfg, progressManager) // Pass ProgressManager to Downloader
+	progressManager := service.NewProgressManager(cfg.ProgressBufferSize) // Instantiate ProgressManager
+	downloader := service.NewDownloader(cfg, progressManager)             // Pass ProgressManager to Downloader
+	rt.downloader = downloader
 
 	// Create handlers
-	healthHandler := handler.NewHealthHandler()
+	healthHandler := handler.NewHealthHandler(cfg)
 	downloadVideoHandler := handler.NewDownloadVideoHandler(downloader)
 	downloadAudioHandler := handler.NewDownloadAudioHandler(downloader)
 	streamVideoHandler := handler.NewStreamVideoHandler(downloader)
 	streamAudioHandler := handler.NewStreamAudioHandler(downloader)
 	webStreamHandler := handler.NewWebStreamHandler(downloader, progressManager, cfg) // Pass ProgressManager to web handler
+	adminHandler := handler.NewAdminHandler(downloader, cfg)
+	scheduleHandler := handler.NewScheduleHandler(downloader, service.NewScheduler(downloader))
+	statusHandler := handler.NewStatusHandler(downloader, rt.inFlight)
 
-	// Public routes
-	r.Get("/health", healthHandler.Handle)
+	// Public routes. chi answers other methods with 405 and an Allow header.
+	r.Get("/health", healthHandler.Handle)  // Liveness: never spawns processes
+	r.Head("/health", healthHandler.Handle) // For probes that only check the status
+	r.Get("/ready", healthHandler.Ready)    // Readiness: checks tools and disk
+	r.Head("/ready", healthHandler.Ready)
+	r.Get("/status", statusHandler.Handle) // Backpressure: whether the concurrency limits are reached
 
 	// Download routes
+	if !cfg.EnableDownload {
+		slog.Info("Download routes disabled (ENABLE_DOWNLOAD=false)")
+	}
 	r.Group(func(downloadRouter chi.Router) {
 		// Add any specific middleware for download routes here if needed
 		downloadRouter.Post("/download/video", downloadVideoHandler.Handle)
 		downloadRouter.Get("/download/video/{filename}", downloadVideoHandler.ServeDownloadedVideo)
+		downloadRouter.Get("/download/video/{filename}/chapters.vtt", downloadVideoHandler.ServeChapters)
+		downloadRouter.Get("/download/video/{filename}/storyboard.vtt", downloadVideoHandler.ServeStoryboard)
+		downloadRouter.Get("/download/video/{filename}/{sprite}", downloadVideoHandler.ServeStoryboardSprite)
 		downloadRouter.Post("/download/video/info", downloadVideoHandler.GetVideoInfo)
+		downloadRouter.Post("/download/video/info/batch", downloadVideoHandler.GetVideoInfoBatch)
+		downloadRouter.Get("/download/video/info/raw", downloadVideoHandler.GetRawVideoInfo)
+		downloadRouter.Get("/download/supported", downloadVideoHandler.CheckSupported)
+		downloadRouter.Get("/download/playlist/info", downloadVideoHandler.GetPlaylistInfo)
+		downloadRouter.Get("/download/subtitles/list", downloadVideoHandler.ListSubtitles)
+		downloadRouter.Post("/download/archive", downloadVideoHandler.Archive)
 		downloadRouter.Post("/download/audio", downloadAudioHandler.Handle)
 		downloadRouter.Get("/download/audio/{filename}", downloadAudioHandler.ServeDownloadedAudio)
+		downloadRouter.Get("/download/audio/{filename}/waveform", downloadAudioHandler.ServeWaveform)
 		downloadRouter.Delete("/download/delete/{filename}", downloadVideoHandler.DeleteDownloadedFile) // Re-use for any file deletion
 		downloadRouter.Get("/download/list", downloadVideoHandler.ListDownloadedFiles)                  // Re-use for any file listing
+		downloadRouter.Post("/download/schedule", scheduleHandler.Schedule)
+		downloadRouter.Get("/download/schedule/{id}", scheduleHandler.GetJob)
+		downloadRouter.Delete("/download/schedule/{id}", scheduleHandler.CancelJob)
 	})
 
 	// Stream routes
+	if !cfg.EnableStream {
+		slog.Info("Stream routes disabled (ENABLE_STREAM=false)")
+	}
 	r.Group(func(streamRouter chi.Router) {
 		streamRouter.Post("/stream/video", streamVideoHandler.Handle)
+		streamRouter.Post("/stream/video/prefetch", streamVideoHandler.Prefetch)
+		streamRouter.Post("/stream/video/multipart", streamVideoHandler.StreamWithSubtitles)
 		streamRouter.Post("/stream/audio", streamAudioHandler.Handle)
 	})
 
+	// Admin routes, always behind Basic Auth (except in local mode)
+	r.Group(func(adminRouter chi.Router) {
+		adminRouter.Use(appMiddleware.BasicAuthMiddleware(cfg))
+		adminRouter.Post("/admin/cache/prune", adminHandler.PruneCache)
+		adminRouter.Delete("/admin/cache", adminHandler.ClearCache)
+		adminRouter.Get("/admin/config", adminHandler.Config)
+	})
+
 	// Pprof endpoints (if debug mode is enabled)
 	if cfg.DebugMode {
 		slog.Warn("Debug mode enabled: Registering pprof endpoints")
@@ -74,6 +169,7 @@ func New(cfg *config.Config) *Router {
 			pprofRouter.Get("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
 			pprofRouter.Get("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
 			pprofRouter.Get("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+			pprofRouter.Get("/debug/vars", expvar.Handler().ServeHTTP) // Includes ytdlp_exit_codes
 		})
 	}
 
@@ -82,7 +178,11 @@ func New(cfg *config.Config) *Router {
 	slog.Info("Swagger UI available at /swagger/index.html")
 
 	// Web UI routes
+	if !cfg.EnableWeb {
+		slog.Info("Web UI routes disabled (ENABLE_WEB=false)")
+	}
 	r.Group(func(webRouter chi.Router) {
+		webRouter.Use(appMiddleware.OptionalAuthMiddleware(cfg))                      // Anonymous users get capped resolutions
 		webRouter.Get("/", webStreamHandler.ServeMainPage)                            // New entry point
 		webRouter.Post("/load-info", webStreamHandler.HandleLoadInfo)                 // Handles initial URL submission
 		webRouter.Get("/web", webStreamHandler.ServeStreamPage)                       // Main streaming/downloading page
@@ -90,12 +190,28 @@ func New(cfg *config.Config) *Router {
 		webRouter.Get("/web/download/video", webStreamHandler.DownloadVideoToBrowser) // Uses downloader.DownloadVideoToTempFile
 		webRouter.Get("/web/download/audio", webStreamHandler.DownloadAudioToBrowser) // Uses downloader.DownloadAudioToTempFile
 		webRouter.Get("/web/progress", webStreamHandler.ServeProgress)                // New SSE endpoint
+
+		// The Basic Auth challenge makes browsers ask for credentials, which
+		// they then send to the other /web routes too
+		webRouter.With(appMiddleware.BasicAuthMiddleware(cfg)).Get("/web/login", webStreamHandler.Login)
 	})
 
-	return &Router{
-		Mux: r,
-		cfg: cfg,
-	}
+	return rt
+}
+
+// Reload applies a reloaded configuration to the running server: the
+// downloader's settings and limits, the in-flight request limit and the
+// feature flags.
+func (r *Router) Reload(cfg *config.Config) {
+	r.downloader.UpdateConfig(cfg)
+	r.inFlight.SetMax(cfg.MaxInflightRequests)
+	r.features.set(cfg)
+}
+
+// Shutdown stops the work that outlives requests, such as stream saves whose
+// client went away, once the server no longer accepts them.
+func (r *Router) Shutdown(ctx context.Context) error {
+	return r.downloader.Shutdown(ctx)
 }
 
 // Handler returns the http.Handler with middleware applied.
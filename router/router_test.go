@@ -0,0 +1,146 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/config"
+)
+
+// newTestConfig returns a config with every feature enabled.
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	return &config.Config{
+		LocalMode:      true,
+		YTDLPPath:      "false",
+		FFMPEGPath:     "false",
+		DownloadDir:    t.TempDir(),
+		EnableDownload: true,
+		EnableStream:   true,
+		EnableWeb:      true,
+	}
+}
+
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	return New(newTestConfig(t)).Handler()
+}
+
+func TestRouter_HeadHealth(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		method    string
+		path      string
+		wantAllow []string
+	}{
+		{method: http.MethodGet, path: "/download/video", wantAllow: []string{"POST"}},
+		{method: http.MethodPut, path: "/stream/audio", wantAllow: []string{"POST"}},
+		{method: http.MethodPost, path: "/health", wantAllow: []string{"GET", "HEAD"}},
+		{method: http.MethodPost, path: "/download/list", wantAllow: []string{"GET"}},
+		{method: http.MethodGet, path: "/admin/cache", wantAllow: []string{"DELETE"}},
+	}
+
+	h := newTestRouter(t)
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader("")))
+
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+			assert.ElementsMatch(t, tt.wantAllow, rec.Header().Values("Allow"))
+		})
+	}
+}
+
+func TestRouter_FeatureFlags(t *testing.T) {
+	// One route per feature, probed with a method it does not accept so the
+	// handler never runs: a registered route answers 405, a missing one 404.
+	probes := map[string]struct{ method, path string }{
+		"download": {http.MethodPut, "/download/video"},
+		"stream":   {http.MethodPut, "/stream/video"},
+		"web":      {http.MethodPut, "/web"},
+	}
+	tests := []struct {
+		name     string
+		disable  func(*config.Config)
+		disabled string
+	}{
+		{name: "DownloadDisabled", disable: func(c *config.Config) { c.EnableDownload = false }, disabled: "download"},
+		{name: "StreamDisabled", disable: func(c *config.Config) { c.EnableStream = false }, disabled: "stream"},
+		{name: "WebDisabled", disable: func(c *config.Config) { c.EnableWeb = false }, disabled: "web"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			tt.disable(cfg)
+			h := New(cfg).Handler()
+
+			for feature, probe := range probes {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(probe.method, probe.path, nil))
+				if feature == tt.disabled {
+					assert.Equal(t, http.StatusNotFound, rec.Code, "%s routes should be absent", feature)
+				} else {
+					assert.Equal(t, http.StatusMethodNotAllowed, rec.Code, "%s routes should be registered", feature)
+				}
+			}
+
+			// Health checks are never disabled
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+		})
+	}
+}
+
+func TestRouter_Reload(t *testing.T) {
+	cfg := newTestConfig(t)
+	rt := New(cfg)
+	h := rt.Handler()
+	probe := func(method, path string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusMethodNotAllowed, probe(http.MethodPut, "/stream/video"))
+
+	reloaded := *cfg
+	reloaded.EnableStream = false
+	rt.Reload(&reloaded)
+	assert.Equal(t, http.StatusNotFound, probe(http.MethodPut, "/stream/video"))
+	assert.Equal(t, http.StatusMethodNotAllowed, probe(http.MethodPut, "/download/video"))
+
+	reloaded.EnableStream = true
+	rt.Reload(&reloaded)
+	assert.Equal(t, http.StatusMethodNotAllowed, probe(http.MethodPut, "/stream/video"))
+}
+
+func TestRouter_WebLogin(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.LocalMode = false
+	cfg.AuthUsername = "admin"
+	cfg.AuthPassword = "secret"
+	h := New(cfg).Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/web/login", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Basic realm="gostreampuller"`, rec.Header().Get("WWW-Authenticate"))
+
+	req := httptest.NewRequest(http.MethodGet, "/web/login?next=/web", nil)
+	req.SetBasicAuth("admin", "secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/web", rec.Header().Get("Location"))
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Chapter is a named section of a video, in seconds from the start.
+type Chapter struct {
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	Title     string  `json:"title"`
+}
+
+// ErrNoChapters is returned by Chapters when a file has no chapter markers.
+var ErrNoChapters = errors.New("no chapters found")
+
+// Chapters returns the chapters of a downloaded file. They are read from the
+// yt-dlp .info.json sidecar next to the file when there is one, and otherwise
+// from the chapter markers embedded in the file, via ffmpeg.
+func (d *Downloader) Chapters(ctx context.Context, filePath string) ([]Chapter, error) {
+	sidecar := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".info.json"
+	if data, err := os.ReadFile(sidecar); err == nil {
+		var info struct {
+			Chapters []Chapter `json:"chapters"`
+		}
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, fmt.Errorf("failed to parse chapters from %s: %w", sidecar, err)
+		}
+		if len(info.Chapters) == 0 {
+			return nil, ErrNoChapters
+		}
+		return info.Chapters, nil
+	}
+
+	args := []string{"-v", "error", "-i", filePath, "-f", "ffmetadata", "-"}
+	cmd := exec.CommandContext(ctx, d.config().FFMPEGPath, args...)
+	slog.Debug(fmt.Sprintf("Executing ffmpeg for chapters: %s %s", d.config().FFMPEGPath, strings.Join(args, " ")))
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg chapter read failed: %w, stderr: %s", err, stderr.String())
+	}
+
+	chapters, err := parseFFMetadataChapters(&stdout)
+	if err != nil {
+		return nil, err
+	}
+	if len(chapters) == 0 {
+		return nil, ErrNoChapters
+	}
+	return chapters, nil
+}
+
+// parseFFMetadataChapters reads the [CHAPTER] sections of an ffmetadata
+// dump. START and END are expressed in TIMEBASE units (1/1000 by default).
+func parseFFMetadataChapters(r io.Reader) ([]Chapter, error) {
+	var chapters []Chapter
+	var current *Chapter
+	var num, den, start, end int64
+
+	flush := func() {
+		if current != nil {
+			current.StartTime = float64(start*num) / float64(den)
+			current.EndTime = float64(end*num) / float64(den)
+			chapters = append(chapters, *current)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "[") {
+			flush()
+			current = nil
+			if line == "[CHAPTER]" {
+				current = &Chapter{}
+				num, den, start, end = 1, 1000, 0, 0
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		var err error
+		switch key {
+		case "TIMEBASE":
+			n, d, found := strings.Cut(value, "/")
+			if !found {
+				return nil, fmt.Errorf("invalid chapter timebase '%s'", value)
+			}
+			if num, err = strconv.ParseInt(n, 10, 64); err == nil {
+				den, err = strconv.ParseInt(d, 10, 64)
+			}
+			if err == nil && den == 0 {
+				err = errors.New("zero denominator")
+			}
+		case "START":
+			start, err = strconv.ParseInt(value, 10, 64)
+		case "END":
+			end, err = strconv.ParseInt(value, 10, 64)
+		case "title":
+			current.Title = unescapeFFMetadata(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid chapter %s '%s': %w", strings.ToLower(key), value, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chapter metadata: %w", err)
+	}
+	flush()
+	return chapters, nil
+}
+
+// unescapeFFMetadata undoes ffmetadata's backslash escaping of '=', ';',
+// '#', '\\' and newlines.
+func unescapeFFMetadata(value string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// WriteWebVTTChapters writes chapters as a WebVTT chapters track, one cue per
+// chapter titled with the chapter name (or "Chapter N" when it has none).
+func WriteWebVTTChapters(w io.Writer, chapters []Chapter) error {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i, chapter := range chapters {
+		title := strings.TrimSpace(chapter.Title)
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		// A blank line or "-->" would end or corrupt the cue
+		title = strings.ReplaceAll(strings.ReplaceAll(title, "\n", " "), "-->", "->")
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n%s\n", i+1, vttTimestamp(chapter.StartTime), vttTimestamp(chapter.EndTime), title)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// vttTimestamp formats seconds as a WebVTT HH:MM:SS.mmm timestamp.
+func vttTimestamp(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/config"
+)
+
+func TestParseFFMetadataChapters(t *testing.T) {
+	metadata := `;FFMETADATA1
+title=Some video
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=61500
+title=Intro\=Start
+[CHAPTER]
+TIMEBASE=1/10
+START=615
+END=36000
+title=Main
+[STREAM]
+title=ignored
+`
+	chapters, err := parseFFMetadataChapters(strings.NewReader(metadata))
+	assert.NoError(t, err)
+	assert.Equal(t, []Chapter{
+		{StartTime: 0, EndTime: 61.5, Title: "Intro=Start"},
+		{StartTime: 61.5, EndTime: 3600, Title: "Main"},
+	}, chapters)
+
+	_, err = parseFFMetadataChapters(strings.NewReader("[CHAPTER]\nTIMEBASE=1/0\n"))
+	assert.Error(t, err)
+}
+
+func TestWriteWebVTTChapters(t *testing.T) {
+	var b strings.Builder
+	err := WriteWebVTTChapters(&b, []Chapter{
+		{StartTime: 0, EndTime: 61.5, Title: "Intro"},
+		{StartTime: 61.5, EndTime: 3723.004, Title: ""},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "WEBVTT\n"+
+		"\n1\n00:00:00.000 --> 00:01:01.500\nIntro\n"+
+		"\n2\n00:01:01.500 --> 01:02:03.004\nChapter 2\n", b.String())
+}
+
+func TestChapters_Sidecar(t *testing.T) {
+	dir := t.TempDir()
+	d := NewDownloader(&config.Config{FFMPEGPath: "false", DownloadDir: dir}, NewProgressManager(0))
+
+	videoPath := filepath.Join(dir, "1-abc.mp4")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "1-abc.info.json"),
+		[]byte(`{"id":"abc","chapters":[{"start_time":0,"end_time":12.5,"title":"One"}]}`), 0644))
+
+	chapters, err := d.Chapters(context.Background(), videoPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []Chapter{{StartTime: 0, EndTime: 12.5, Title: "One"}}, chapters)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "2-def.info.json"), []byte(`{"id":"def"}`), 0644))
+	_, err = d.Chapters(context.Background(), filepath.Join(dir, "2-def.mp4"))
+	assert.True(t, errors.Is(err, ErrNoChapters))
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// ChecksumMismatchError is returned when a downloaded file does not have the
+// SHA-256 the client expected. The file is deleted.
+type ChecksumMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("downloaded file has SHA-256 %s, expected %s", e.Actual, e.Expected)
+}
+
+// ValidateSHA256 checks an expected SHA-256: 64 hexadecimal digits, in
+// either case. An empty checksum asks for no verification and is always
+// valid.
+func ValidateSHA256(sum string) error {
+	if sum == "" {
+		return nil
+	}
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return fmt.Errorf("invalid SHA-256 %q, expected %d hexadecimal digits", sum, sha256.Size*2)
+	}
+	return nil
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 of the file at path. The file
+// is read in chunks, never loaded whole.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyDownload returns the SHA-256 of the downloaded file at path and, when
+// expected is set, checks it matches. A file that does not is deleted, so
+// corrupt or unexpected media is never kept, and a *ChecksumMismatchError
+// returned.
+func VerifyDownload(path, expected string) (string, error) {
+	sum, err := FileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	if expected != "" && !strings.EqualFold(sum, expected) {
+		slog.Warn("Downloaded file does not match the expected checksum, deleting it", "filePath", path, "expected", expected, "actual", sum)
+		if err := os.Remove(path); err != nil {
+			slog.Error("Failed to delete mismatching download", "filePath", path, "error", err)
+		}
+		return "", &ChecksumMismatchError{Expected: strings.ToLower(expected), Actual: sum}
+	}
+	return sum, nil
+}
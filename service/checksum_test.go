@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// helloSHA256 is the SHA-256 of "hello\n".
+const helloSHA256 = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+func TestValidateSHA256(t *testing.T) {
+	assert.NoError(t, ValidateSHA256(""))
+	assert.NoError(t, ValidateSHA256(helloSHA256))
+	assert.NoError(t, ValidateSHA256(strings.ToUpper(helloSHA256)))
+	assert.Error(t, ValidateSHA256("abc"))
+	assert.Error(t, ValidateSHA256(strings.Repeat("z", 64)))
+}
+
+func TestVerifyDownload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	assert.NoError(t, os.WriteFile(path, []byte("hello\n"), 0644))
+
+	sum, err := VerifyDownload(path, "")
+	assert.NoError(t, err)
+	assert.Equal(t, helloSHA256, sum)
+
+	sum, err = VerifyDownload(path, strings.ToUpper(helloSHA256))
+	assert.NoError(t, err)
+	assert.Equal(t, helloSHA256, sum)
+	assert.FileExists(t, path)
+
+	_, err = VerifyDownload(path, strings.Repeat("0", 64))
+	var mismatch *ChecksumMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, helloSHA256, mismatch.Actual)
+	assert.NoFileExists(t, path, "a mismatching download should be deleted")
+}
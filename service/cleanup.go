@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// partialSuffixes are the extensions of files yt-dlp and this service leave
+// behind when a download is interrupted.
+var partialSuffixes = []string{".part", ".ytdl", ".tmp"}
+
+// isPartialFile reports whether name is an unfinished download: a .part,
+// .ytdl or .tmp file, a fragment ("x.part-Frag12") or a merge in progress
+// ("x.temp.mp4").
+func isPartialFile(name string) bool {
+	for _, suffix := range partialSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return strings.Contains(name, ".part-Frag") || strings.Contains(name, ".temp.")
+}
+
+// CleanupPartials removes the partial download files under dir, including
+// subfolders, that were last modified more than olderThan ago. Recent ones
+// are kept since they may belong to a download still running. It returns how
+// many files were removed; files that cannot be removed are logged and
+// skipped.
+func CleanupPartials(dir string, olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			slog.Warn("Skipping unreadable path during partial cleanup", "path", path, "error", err)
+			return nil
+		}
+		if entry.IsDir() || !isPartialFile(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to remove partial file", "path", path, "error", err)
+			return nil
+		}
+		slog.Debug("Removed partial file", "path", path)
+		removed++
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanupPartials(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+
+	// write creates a file under dir, last modified at modTime
+	write := func(name string, modTime time.Time) string {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+		return path
+	}
+
+	stale := []string{
+		write("abc123.mp4.part", old),
+		write("abc123.mp4.ytdl", old),
+		write("upload.tmp", old),
+		write("abc123.f137.mp4.part-Frag12", old),
+		write("abc123.temp.mp4", old),
+		write("2026/01/02/def456.webm.part", old), // ORGANIZE_BY_DATE subfolder
+	}
+	kept := []string{
+		write("abc123.mp4", old),
+		write("song.mp3", old),
+		write("abc123.info.json", old),
+		write("running.mp4.part", time.Now()), // Too recent, may still be downloading
+	}
+
+	removed, err := CleanupPartials(dir, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, len(stale), removed)
+	for _, path := range stale {
+		assert.NoFileExists(t, path)
+	}
+	for _, path := range kept {
+		assert.FileExists(t, path)
+	}
+}
+
+func TestCleanupPartials_MissingDir(t *testing.T) {
+	_, err := CleanupPartials(filepath.Join(t.TempDir(), "missing"), time.Hour)
+	assert.Error(t, err)
+}
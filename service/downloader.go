@@ -4,37 +4,167 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"gostreampuller/config"
 )
 
+// prefetchTimeout bounds a background info prefetch, which has no request
+// context to inherit a deadline from.
+const prefetchTimeout = 2 * time.Minute
+
 // Downloader provides functionality to download and stream videos/audio.
 type Downloader struct {
-	cfg             *config.Config
-	progressManager *ProgressManager // Added ProgressManager
+	cfg             atomic.Pointer[config.Config] // Swapped by UpdateConfig
+	progressManager *ProgressManager              // Added ProgressManager
+	extraArgs       []string                      // Operator-controlled yt-dlp args from config
+	infoCache       *infoCache                    // yt-dlp info per URL, shared by info and stream lookups
+	now             func() time.Time              // Clock for dated download folders, replaceable in tests
+	streamLimit     *limiter                      // MAX_CONCURRENT_STREAMS
+	downloadLimit   *limiter                      // MAX_CONCURRENT_DOWNLOADS
+	waveforms       *waveformCache                // Computed waveforms of downloaded files
+	storyboardLocks *renderLocks                  // Serializes the renders of each storyboard sprite
+	lifetime        context.Context               // Cancelled by Shutdown, ending background saves
+	stop            context.CancelFunc            // Cancels lifetime
+	saves           sync.WaitGroup                // Saves under SaveContext still running
 }
 
 // NewDownloader creates a new Downloader instance.
 func NewDownloader(cfg *config.Config, pm *ProgressManager) *Downloader {
-	return &Downloader{
-		cfg:             cfg,
+	extraArgs, err := cfg.YTDLPExtraArgs()
+	if err != nil {
+		// config.New already validates this, so only hand-built configs end up here
+		slog.Error("Ignoring invalid extra yt-dlp args", "error", err)
+	}
+	d := &Downloader{
 		progressManager: pm,
+		extraArgs:       extraArgs,
+		infoCache:       newInfoCache(cfg.InfoCacheTTL),
+		now:             time.Now,
+		streamLimit:     newLimiter(cfg.MaxConcurrentStreams, cfg.ConcurrencyWait, ErrTooManyStreams),
+		downloadLimit:   newLimiter(cfg.MaxConcurrentDownloads, cfg.ConcurrencyWait, ErrTooManyDownloads),
+		waveforms:       newWaveformCache(),
+		storyboardLocks: newRenderLocks(),
 	}
+	d.lifetime, d.stop = context.WithCancel(context.Background())
+	d.cfg.Store(cfg)
+	pm.SetCallbackAllowlist(cfg.CallbackAllowlist())
+	return d
+}
+
+// config returns the configuration in effect, which UpdateConfig may
+// replace at any time: read it once per use rather than keeping it.
+func (d *Downloader) config() *config.Config {
+	return d.cfg.Load()
+}
+
+// Config returns the configuration in effect.
+func (d *Downloader) Config() *config.Config {
+	return d.config()
+}
+
+// UpdateConfig switches the downloader to cfg, typically reloaded on
+// SIGHUP, resizes the stream and download limits and swaps the progress
+// callback allowlist. Operations already running keep their slot under the
+// limits. Settings the downloader only reads at construction (the info cache
+// TTL and extra yt-dlp args) need a restart.
+func (d *Downloader) UpdateConfig(cfg *config.Config) {
+	d.cfg.Store(cfg)
+	d.streamLimit.setLimits(cfg.MaxConcurrentStreams, cfg.ConcurrencyWait)
+	d.downloadLimit.setLimits(cfg.MaxConcurrentDownloads, cfg.ConcurrencyWait)
+	d.progressManager.SetCallbackAllowlist(cfg.CallbackAllowlist())
+}
+
+// ytdlpArgs builds the final yt-dlp argument list: the ffmpeg log level, the
+// given args, then the operator's extra args, then "--" and the target URL.
+// The "--" separator ensures a client-supplied URL is never parsed as a
+// yt-dlp option.
+func (d *Downloader) ytdlpArgs(url string, args ...string) []string {
+	full := make([]string, 0, len(args)+len(d.extraArgs)+4)
+	if d.config().FFmpegLogLevel != "" {
+		full = append(full, "--postprocessor-args", d.ffmpegArgs())
+	}
+	full = append(full, args...)
+	if d.config().CookiesFile != "" {
+		full = append(full, "--cookies", d.config().CookiesFile)
+	}
+	full = append(full, d.extraArgs...)
+	return append(full, "--", url)
+}
+
+// fileDownloadArgs is ytdlpArgs for downloads written to disk: it adds the
+// configured external downloader, which cannot be used when piping to stdout.
+func (d *Downloader) fileDownloadArgs(url string, args ...string) []string {
+	if d.config().ExternalDownloader != "" {
+		args = append(args,
+			"--downloader", d.config().ExternalDownloader,
+			"--downloader-args", d.config().ExternalDownloader+":"+d.config().ExternalDownloaderArgs,
+		)
+	}
+	return d.ytdlpArgs(url, args...)
+}
+
+// ffmpegArgs returns a "ffmpeg:..." value for --postprocessor-args or
+// --downloader-args: args followed by the configured FFMPEG_LOG_LEVEL. A later
+// value for the same key replaces an earlier one, so callers passing their
+// own ffmpeg args must build them here to keep the log level.
+func (d *Downloader) ffmpegArgs(args ...string) string {
+	if d.config().FFmpegLogLevel != "" {
+		args = append(args, "-loglevel", d.config().FFmpegLogLevel)
+	}
+	return "ffmpeg:" + strings.Join(args, " ")
+}
+
+// streamCommand starts a streaming yt-dlp run and returns its stdout. Its
+// stderr is kept in a bounded buffer and reported by Close if the run fails;
+// in debug mode it is also copied to the process stderr.
+func (d *Downloader) streamCommand(ctx context.Context, op string, args []string) (*commandReadCloser, error) {
+	cmd := exec.CommandContext(ctx, d.config().YTDLPPath, args...)
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for %s: %s %s", op, d.config().YTDLPPath, strings.Join(args, " ")))
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe for yt-dlp: %w", err)
+	}
+	stderr := newTailBuffer(maxStderrTail)
+	cmd.Stderr = stderr
+	if d.config().DebugMode {
+		cmd.Stderr = io.MultiWriter(stderr, os.Stderr)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start yt-dlp command for %s: %w", op, err)
+	}
+	return &commandReadCloser{
+		ReadCloser: stdoutPipe,
+		cmd:        cmd,
+		op:         op,
+		stderr:     stderr,
+	}, nil
 }
 
 // GetDownloadDir returns the configured download directory.
 func (d *Downloader) GetDownloadDir() string {
-	return d.cfg.DownloadDir
+	return d.config().DownloadDir
+}
+
+// StrictJSON reports whether API request bodies must not carry unknown fields.
+func (d *Downloader) StrictJSON() bool {
+	return d.config().StrictJSON
 }
 
 // VideoInfo represents a subset of yt-dlp's info.json output.
@@ -47,11 +177,20 @@ type VideoInfo struct {
 	Uploader    string `json:"uploader"`
 	UploadDate  string `json:"upload_date"` // YYYYMMDD
 	Thumbnail   string `json:"thumbnail"`   // URL to thumbnail
+	// Thumbnails lists every thumbnail size available, so clients can pick one
+	Thumbnails []Thumbnail `json:"thumbnails,omitempty"`
+	// IsLive is set for a live broadcast, which has no end to wait for
+	IsLive bool `json:"is_live,omitempty"`
+	// Extractor is the yt-dlp extractor that handled the URL, e.g.
+	// "youtube", and ExtractorKey its class name, e.g. "Youtube"
+	Extractor    string `json:"extractor,omitempty"`
+	ExtractorKey string `json:"extractor_key,omitempty"`
 	// Add fields for direct stream URL and file size
 	DirectStreamURL string  `json:"url"` // The actual direct URL of the stream
 	FileSize        int64   `json:"filesize"`
 	FormatID        string  `json:"format_id"`
 	FormatNote      string  `json:"format_note"`
+	Protocol        string  `json:"protocol"` // e.g. "https", "m3u8_native", "http_dash_segments"
 	VCodec          string  `json:"vcodec"`
 	ACodec          string  `json:"acodec"`
 	FPS             float64 `json:"fps"`
@@ -59,6 +198,25 @@ type VideoInfo struct {
 	Height          int     `json:"height"`
 	// Formats is a slice of available formats, used by GetStreamInfo
 	Formats []VideoInfo `json:"formats"`
+	// Subtitle availability, used to resolve the subtitle language chain
+	Language          string        `json:"language,omitempty"`
+	Subtitles         SubtitleLangs `json:"subtitles,omitempty"`
+	AutomaticCaptions SubtitleLangs `json:"automatic_captions,omitempty"`
+	// SubtitleLang is the subtitle language actually obtained for a download
+	SubtitleLang string `json:"subtitleLang,omitempty"`
+	// AudioLanguages are the audio tracks muxed into an all-languages download
+	AudioLanguages []string `json:"audioLanguages,omitempty"`
+	// SelectedFormat is the format yt-dlp picked for a video download
+	SelectedFormat *SelectedFormat `json:"selectedFormat,omitempty"`
+}
+
+// Thumbnail is one of the thumbnail images yt-dlp reports for a video.
+// Width and Height are 0 when the extractor does not know them.
+type Thumbnail struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
 }
 
 // GetVideoInfo fetches video metadata without downloading the file.
@@ -71,30 +229,10 @@ func (d *Downloader) GetVideoInfo(ctx context.Context, url string, progressID st
 		Percentage: 0,
 	})
 
-	infoArgs := []string{
-		"--dump-json",
-		"--no-playlist",
-		"--restrict-filenames",
-		url,
-	}
-	cmd := exec.CommandContext(ctx, d.cfg.YTDLPPath, infoArgs...)
-	slog.Debug(fmt.Sprintf("Executing yt-dlp for video info: %s %s", d.cfg.YTDLPPath, strings.Join(infoArgs, " ")))
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+	videoInfo, err := d.dumpInfo(ctx, url, "info dump")
 	if err != nil {
-		slog.Error(fmt.Sprintf("yt-dlp info dump failed: %v\nStdout: %s\nStderr: %s", err, stdout.String(), stderr.String()))
 		d.progressManager.SendError(progressID, "Failed to fetch video information", err)
-		return nil, fmt.Errorf("yt-dlp info dump failed: %w, stderr: %s", err, stderr.String())
-	}
-
-	var videoInfo VideoInfo
-	if err := json.Unmarshal(stdout.Bytes(), &videoInfo); err != nil {
-		d.progressManager.SendError(progressID, "Failed to parse video information", err)
-		return nil, fmt.Errorf("failed to parse yt-dlp info json: %w", err)
+		return nil, err
 	}
 
 	d.progressManager.SendEvent(ProgressEvent{
@@ -102,15 +240,183 @@ func (d *Downloader) GetVideoInfo(ctx context.Context, url string, progressID st
 		Status:     "info_fetched",
 		Message:    "Video information fetched successfully.",
 		Percentage: 10,
-		VideoInfo:  &videoInfo,
+		VideoInfo:  videoInfo,
 	})
+	return videoInfo, nil
+}
+
+// dumpInfo runs yt-dlp --dump-json for url, or returns the cached result of
+// an earlier run. op names the lookup in errors and logs.
+func (d *Downloader) dumpInfo(ctx context.Context, url string, op string) (*VideoInfo, error) {
+	if info, ok := d.infoCache.get(url); ok {
+		slog.Debug("Using cached video info", "url", url)
+		// Checked again, as ALLOWED_EXTRACTORS may have been reloaded
+		if err := d.checkExtractor(info.Extractor, info.ExtractorKey); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+
+	raw, err := d.runInfoDump(ctx, url, op)
+	if err != nil {
+		return nil, err
+	}
+
+	var videoInfo VideoInfo
+	if err := json.Unmarshal(raw, &videoInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp info json: %w", err)
+	}
+	d.infoCache.set(url, &videoInfo)
+	if err := d.checkExtractor(videoInfo.Extractor, videoInfo.ExtractorKey); err != nil {
+		return nil, err
+	}
 	return &videoInfo, nil
 }
 
+// GetRawVideoInfo returns yt-dlp's --dump-json output for url unchanged, for
+// clients that need fields VideoInfo leaves out. It bypasses the info cache,
+// which only holds the parsed subset, and checks the output is valid JSON.
+func (d *Downloader) GetRawVideoInfo(ctx context.Context, url string) ([]byte, error) {
+	raw, err := d.runInfoDump(ctx, url, "raw info dump")
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimSpace(raw)
+	if !json.Valid(raw) {
+		return nil, fmt.Errorf("yt-dlp info output for %s is not valid JSON", url)
+	}
+	var extractor struct {
+		Extractor    string `json:"extractor"`
+		ExtractorKey string `json:"extractor_key"`
+	}
+	json.Unmarshal(raw, &extractor) // Anything but an object has no extractor to allow
+	if err := d.checkExtractor(extractor.Extractor, extractor.ExtractorKey); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// runInfoDump runs yt-dlp --dump-json for url within the configured
+// InfoTimeout and returns its stdout.
+func (d *Downloader) runInfoDump(ctx context.Context, url string, op string) ([]byte, error) {
+	return d.runInfo(ctx, url, op, "--dump-json")
+}
+
+// runInfo runs yt-dlp with the info-printing args for url within the
+// configured InfoTimeout and returns its stdout.
+func (d *Downloader) runInfo(ctx context.Context, url string, op string, printArgs ...string) (_ []byte, err error) {
+	ctx, span := startSpan(ctx, "fetch info", url, attribute.String("ytdlp.op", op))
+	defer func() { endSpan(span, "", err) }()
+
+	infoCtx := ctx
+	if d.config().InfoTimeout > 0 {
+		var cancel context.CancelFunc
+		infoCtx, cancel = context.WithTimeout(ctx, d.config().InfoTimeout)
+		defer cancel()
+	}
+
+	infoArgs := d.ytdlpArgs(url, slices.Concat(printArgs, []string{
+		"--no-playlist",
+		"--restrict-filenames",
+	})...)
+	cmd := exec.CommandContext(infoCtx, d.config().YTDLPPath, infoArgs...)
+	cmd.WaitDelay = time.Second // Don't wait on children of a killed yt-dlp still holding the pipes
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for %s: %s %s", op, d.config().YTDLPPath, strings.Join(infoArgs, " ")))
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Only our own deadline counts; a cancelled or expired parent context is the caller's
+		if errors.Is(infoCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			slog.Warn("yt-dlp info fetch timed out", "url", url, "timeout", d.config().InfoTimeout)
+			return nil, &InfoTimeoutError{URL: url, Timeout: d.config().InfoTimeout}
+		}
+		return nil, newYTDLPError(op, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// PrefetchInfo fetches and caches the info for url in the background, so a
+// following stream or info request for it starts without waiting on yt-dlp.
+// It does nothing when the info cache is disabled.
+func (d *Downloader) PrefetchInfo(url string) {
+	if !d.infoCache.enabled() {
+		slog.Warn("Info cache is disabled, ignoring prefetch", "url", url)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+		defer cancel()
+		if _, err := d.dumpInfo(ctx, url, "prefetch info dump"); err != nil {
+			slog.Warn("Prefetching video info failed", "url", url, "error", err)
+			return
+		}
+		slog.Debug("Prefetched video info", "url", url)
+	}()
+}
+
+// PruneInfoCache drops expired video info from the cache and returns how
+// many entries were removed and how many remain.
+func (d *Downloader) PruneInfoCache() (removed, remaining int) {
+	return d.infoCache.prune()
+}
+
+// ClearInfoCache drops all cached video info, forcing fresh extractions,
+// and returns how many entries were removed.
+func (d *Downloader) ClearInfoCache() int {
+	return d.infoCache.clear()
+}
+
+// CheckSupported reports whether yt-dlp can handle url and, if so, which
+// extractor it would use. It simulates the extraction without resolving
+// download URLs, so it is much cheaper than GetVideoInfo. An unsupported URL
+// is not an error; only failing to run yt-dlp at all is. A URL whose
+// extractor is outside ALLOWED_EXTRACTORS is not supported, and its
+// ExtractorNotAllowedError is returned along with the extractor.
+func (d *Downloader) CheckSupported(ctx context.Context, url string) (bool, string, error) {
+	checkArgs := d.ytdlpArgs(url,
+		"--simulate",
+		"--quiet",
+		"--no-warnings",
+		"--no-playlist",
+		"--print", "%(extractor)s\t%(extractor_key)s",
+	)
+	cmd := exec.CommandContext(ctx, d.config().YTDLPPath, checkArgs...)
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for support check: %s %s", d.config().YTDLPPath, strings.Join(checkArgs, " ")))
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			slog.Debug("URL not supported by yt-dlp", "url", url, "stderr", stderr.String())
+			return false, "", nil
+		}
+		return false, "", fmt.Errorf("yt-dlp support check failed: %w", err)
+	}
+
+	line := strings.TrimSpace(stdout.String())
+	// --print emits one line per entry; the first one is enough here
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	extractor, extractorKey, _ := strings.Cut(line, "\t")
+	if err := d.checkExtractor(extractor, extractorKey); err != nil {
+		return false, extractor, err
+	}
+	return true, extractor, nil
+}
+
 // GetStreamInfo fetches detailed stream information, including direct URLs.
 // It tries to find a suitable video stream based on resolution and codec.
+// A non-empty protocol, e.g. "https", only considers formats downloaded over
+// it, for callers that cannot handle HLS or DASH manifests.
 // This method is still useful for getting detailed format information, even if not directly proxying.
-func (d *Downloader) GetStreamInfo(ctx context.Context, url string, resolution string, codec string, progressID string) (*VideoInfo, error) {
+func (d *Downloader) GetStreamInfo(ctx context.Context, url string, resolution string, codec string, protocol string, progressID string) (*VideoInfo, error) {
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
 		Status:     "fetching_stream_info",
@@ -118,30 +424,10 @@ func (d *Downloader) GetStreamInfo(ctx context.Context, url string, resolution s
 		Percentage: 0,
 	})
 
-	infoArgs := []string{
-		"--dump-json",
-		"--no-playlist",
-		"--restrict-filenames",
-		url,
-	}
-	cmd := exec.CommandContext(ctx, d.cfg.YTDLPPath, infoArgs...)
-	slog.Debug(fmt.Sprintf("Executing yt-dlp for stream info: %s %s", d.cfg.YTDLPPath, strings.Join(infoArgs, " ")))
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+	fullInfo, err := d.dumpInfo(ctx, url, "stream info dump")
 	if err != nil {
-		slog.Error(fmt.Sprintf("yt-dlp stream info dump failed: %v\nStdout: %s\nStderr: %s", err, stdout.String(), stderr.String()))
 		d.progressManager.SendError(progressID, "Failed to fetch stream information", err)
-		return nil, fmt.Errorf("yt-dlp stream info dump failed: %w, stderr: %s", err, stderr.String())
-	}
-
-	var fullInfo VideoInfo // Use VideoInfo directly as it now contains Formats
-	if err := json.Unmarshal(stdout.Bytes(), &fullInfo); err != nil {
-		d.progressManager.SendError(progressID, "Failed to parse stream information", err)
-		return nil, fmt.Errorf("failed to parse yt-dlp full info json: %w", err)
+		return nil, err
 	}
 
 	// Default resolution if not provided
@@ -161,7 +447,7 @@ func (d *Downloader) GetStreamInfo(ctx context.Context, url string, resolution s
 	for i := range fullInfo.Formats {
 		f := &fullInfo.Formats[i]
 		// Prioritize formats with direct URLs and video streams
-		if f.DirectStreamURL != "" && f.VCodec != "none" {
+		if f.DirectStreamURL != "" && f.VCodec != "none" && matchesProtocol(f, protocol) {
 			// Try to match resolution and codec
 			if f.Height == targetHeight && strings.Contains(f.VCodec, codec) {
 				bestFormat = f
@@ -183,7 +469,7 @@ func (d *Downloader) GetStreamInfo(ctx context.Context, url string, resolution s
 		// Fallback: if no specific video format found, try to find the best overall video stream
 		for i := range fullInfo.Formats {
 			f := &fullInfo.Formats[i]
-			if f.DirectStreamURL != "" && f.VCodec != "none" {
+			if f.DirectStreamURL != "" && f.VCodec != "none" && matchesProtocol(f, protocol) {
 				if bestFormat == nil || f.FileSize > bestFormat.FileSize { // Simple heuristic: largest file size
 					bestFormat = f
 				}
@@ -192,8 +478,12 @@ func (d *Downloader) GetStreamInfo(ctx context.Context, url string, resolution s
 	}
 
 	if bestFormat == nil {
-		d.progressManager.SendError(progressID, "No suitable direct stream URL found", nil)
-		return nil, fmt.Errorf("no suitable direct stream URL found for video: %s", url)
+		err := fmt.Errorf("no suitable direct stream URL found for video: %s", url)
+		if protocol != "" {
+			err = fmt.Errorf("no suitable direct stream URL with protocol %q found for video: %s", protocol, url)
+		}
+		d.progressManager.SendError(progressID, "No suitable direct stream URL found", err)
+		return nil, err
 	}
 
 	// Populate top-level video info from fullInfo
@@ -216,9 +506,44 @@ func (d *Downloader) GetStreamInfo(ctx context.Context, url string, resolution s
 	return bestFormat, nil
 }
 
+// matchesProtocol reports whether format f is downloaded over protocol. An
+// empty protocol matches every format.
+func matchesProtocol(f *VideoInfo, protocol string) bool {
+	return protocol == "" || strings.EqualFold(f.Protocol, protocol)
+}
+
 // DownloadVideoToFile downloads a video from the given URL to a file.
-// It returns the path to the downloaded file and its metadata.
-func (d *Downloader) DownloadVideoToFile(ctx context.Context, url string, format string, resolution string, codec string, progressID string) (string, *VideoInfo, error) {
+// It returns the path to the downloaded file, its metadata and the warnings
+// yt-dlp printed along the way.
+func (d *Downloader) DownloadVideoToFile(ctx context.Context, url string, opts VideoOptions, progressID string) (filePath string, _ *VideoInfo, _ []string, err error) {
+	ctx, span := startSpan(ctx, "download video", url,
+		attribute.String("media.format", opts.withDefaults().Format),
+		attribute.String("media.resolution", opts.withDefaults().Resolution))
+	defer func() { endSpan(span, filePath, err) }()
+
+	if err := ValidateFormatSort(opts.FormatSort); err != nil {
+		return "", nil, nil, err
+	}
+	if err := ValidateOnExists(opts.OnExists); err != nil {
+		return "", nil, nil, err
+	}
+	if opts.AllAudioLanguages {
+		if err := ValidateMultiAudio(opts.Format, opts.Progressive); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	if err := d.ValidateWatermark(opts.Watermark, opts.WatermarkPosition); err != nil {
+		return "", nil, nil, err
+	}
+	if err := ValidateTargetSize(opts.TargetSizeMB); err != nil {
+		return "", nil, nil, err
+	}
+	release, err := d.downloadLimit.acquire(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	defer release()
+
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
 		Status:     "fetching_info",
@@ -228,7 +553,24 @@ func (d *Downloader) DownloadVideoToFile(ctx context.Context, url string, format
 
 	videoInfo, err := d.GetVideoInfo(ctx, url, progressID) // Pass progressID
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get video info: %w", err)
+		return "", nil, nil, fmt.Errorf("failed to get video info: %w", err)
+	}
+	if err := d.checkDuration(videoInfo, progressID); err != nil {
+		return "", nil, nil, err
+	}
+
+	opts, qualityWarning, err := resolveQuality(opts, videoInfo.Formats)
+	if err != nil {
+		d.progressManager.SendError(progressID, "Requested quality not available", err)
+		return "", nil, nil, err
+	}
+	if qualityWarning != "" {
+		slog.Warn("Requested quality not available, using the closest", "videoID", videoInfo.ID, "warning", qualityWarning)
+		d.progressManager.SendEvent(ProgressEvent{
+			ID:      progressID,
+			Status:  "warning",
+			Message: qualityWarning,
+		})
 	}
 
 	d.progressManager.SendEvent(ProgressEvent{
@@ -238,58 +580,106 @@ func (d *Downloader) DownloadVideoToFile(ctx context.Context, url string, format
 		Percentage: 25,
 	})
 
-	if format == "" {
-		format = "mp4"
+	opts = opts.withDefaults()
+	if err := d.checkProgressive(opts, videoInfo, progressID); err != nil {
+		return "", nil, nil, err
 	}
-	if resolution == "" {
-		resolution = "720"
-	}
-	if codec == "" {
-		codec = "avc1"
+	if opts.AllAudioLanguages {
+		opts.audioLanguages = audioLanguages(videoInfo.Formats)
+		videoInfo.AudioLanguages = opts.audioLanguages
 	}
 
-	// Generate a unique filename using timestamp and original extension
-	uniqueFilename := fmt.Sprintf("%d-%s.%s", time.Now().UnixNano(), videoInfo.ID, format)
-	finalFilePath := filepath.Join(d.cfg.DownloadDir, uniqueFilename)
+	// Pick the filename, unique by default, deterministic with other strategies
+	finalFilePath, err := d.outputPath(MediaVideo, videoInfo.ID, opts.Format, opts.OnExists)
+	if err != nil {
+		d.progressManager.SendError(progressID, "Cannot write video file", err)
+		return "", nil, nil, err
+	}
 
 	// Step 2: Download the video to the specific filename
-	downloadArgs := []string{
-		"--format", fmt.Sprintf("bestvideo[height<=%s][vcodec*=%s]+bestaudio/best", resolution, codec),
-		"--output", finalFilePath,
-		"--no-progress",          // We'll handle progress via stderr parsing if needed, or just stages
-		"--no-playlist",          // Assume single video download
-		"--recode-video", format, // Instruct yt-dlp to convert to the desired format
-		url,
+	downloadStdout, downloadStderr, warnings, ytdlpErr := d.runVideoDownload(ctx, "video download", opts, finalFilePath, progressID, func(formatArgs []string) []string {
+		return d.fileDownloadArgs(url, append(formatArgs,
+			"--output", finalFilePath,
+			"--no-progress",               // We'll handle progress via stderr parsing if needed, or just stages
+			"--no-playlist",               // Assume single video download
+			"--recode-video", opts.Format, // Instruct yt-dlp to convert to the desired format
+			"--print-json", // Report the format actually selected on stdout
+		)...)
+	})
+	if qualityWarning != "" {
+		warnings = append([]string{qualityWarning}, warnings...)
 	}
-
-	downloadCmd := exec.CommandContext(ctx, d.cfg.YTDLPPath, downloadArgs...) // Use CommandContext
-	slog.Debug(fmt.Sprintf("Executing yt-dlp for video download: %s %s", d.cfg.YTDLPPath, strings.Join(downloadArgs, " ")))
-
-	var downloadStdout, downloadStderr bytes.Buffer
-	downloadCmd.Stdout = &downloadStdout
-	downloadCmd.Stderr = &downloadStderr
-
-	err = downloadCmd.Run()
-	if err != nil {
-		slog.Error(fmt.Sprintf("yt-dlp video download failed: %v\nStdout: %s\nStderr: %s", err, downloadStdout.String(), downloadStderr.String()))
-		d.progressManager.SendError(progressID, "Video download failed", err)
-		return "", nil, fmt.Errorf("yt-dlp video download failed: %w, stderr: %s", err, downloadStderr.String())
+	if ytdlpErr != nil && d.retryRecode(ctx, downloadStderr, finalFilePath, progressID) {
+		ytdlpErr = nil
+	}
+	if ytdlpErr != nil {
+		source, warning := d.recodeFallback(opts, downloadStderr, finalFilePath, progressID)
+		if source == "" {
+			d.progressManager.SendError(progressID, "Video download failed", ytdlpErr.Err)
+			return "", nil, nil, ytdlpErr
+		}
+		warnings = append(warnings, warning)
+		finalFilePath = source
 	}
 
 	// Verify the file exists
 	if _, err := os.Stat(finalFilePath); err != nil {
 		d.progressManager.SendError(progressID, "Downloaded file not found", err)
-		return "", nil, fmt.Errorf("downloaded video file not found at %s: %w", finalFilePath, err)
+		return "", nil, nil, fmt.Errorf("downloaded video file not found at %s: %w", finalFilePath, err)
+	}
+
+	if selected, err := parseSelectedFormat(downloadStdout); err != nil {
+		slog.Warn("Could not determine the selected format", "error", err, "filePath", finalFilePath)
+	} else {
+		videoInfo.SelectedFormat = selected
+	}
+
+	if opts.BurnSubtitles {
+		if err := d.burnSubtitles(ctx, url, finalFilePath, videoInfo, opts.SubtitleLang, progressID); err != nil {
+			d.progressManager.SendError(progressID, "Subtitle burn-in failed", err)
+			return "", nil, nil, err
+		}
+	}
+
+	if opts.Watermark != "" {
+		if err := d.applyWatermark(ctx, finalFilePath, opts.Watermark, opts.WatermarkPosition, progressID); err != nil {
+			d.progressManager.SendError(progressID, "Watermark overlay failed", err)
+			return "", nil, nil, err
+		}
 	}
 
-	d.progressManager.SendComplete(progressID, "Video downloaded successfully", videoInfo)
+	// Last, so no other encode changes the size
+	if opts.TargetSizeMB > 0 {
+		if err := d.encodeToTargetSize(ctx, finalFilePath, opts.TargetSizeMB, progressID); err != nil {
+			d.progressManager.SendError(progressID, "Target size encode failed", err)
+			return "", nil, nil, err
+		}
+	}
+
+	d.progressManager.SendComplete(progressID, "Video downloaded successfully", videoInfo, warnings...)
 	slog.Info(fmt.Sprintf("Video downloaded to: %s", finalFilePath))
-	return finalFilePath, videoInfo, nil
+	return finalFilePath, videoInfo, warnings, nil
 }
 
 // DownloadAudioToFile downloads audio from the given URL to a file.
-// It returns the path to the downloaded file and its metadata.
-func (d *Downloader) DownloadAudioToFile(ctx context.Context, url string, outputFormat string, codec string, bitrate string, progressID string) (string, *VideoInfo, error) {
+// It returns the path to the downloaded file, its metadata and the warnings
+// yt-dlp printed along the way.
+func (d *Downloader) DownloadAudioToFile(ctx context.Context, url string, opts AudioOptions, progressID string) (filePath string, _ *VideoInfo, _ []string, err error) {
+	ctx, span := startSpan(ctx, "download audio", url, attribute.String("media.format", opts.withDefaults().Format))
+	defer func() { endSpan(span, filePath, err) }()
+
+	if err := opts.Validate(); err != nil {
+		return "", nil, nil, err
+	}
+	if err := ValidateOnExists(opts.OnExists); err != nil {
+		return "", nil, nil, err
+	}
+	release, err := d.downloadLimit.acquire(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	defer release()
+
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
 		Status:     "fetching_info",
@@ -299,7 +689,10 @@ func (d *Downloader) DownloadAudioToFile(ctx context.Context, url string, output
 
 	videoInfo, err := d.GetVideoInfo(ctx, url, progressID) // Pass progressID
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get audio info: %w", err)
+		return "", nil, nil, fmt.Errorf("failed to get audio info: %w", err)
+	}
+	if err := d.checkDuration(videoInfo, progressID); err != nil {
+		return "", nil, nil, err
 	}
 
 	d.progressManager.SendEvent(ProgressEvent{
@@ -309,59 +702,114 @@ func (d *Downloader) DownloadAudioToFile(ctx context.Context, url string, output
 		Percentage: 25,
 	})
 
-	if outputFormat == "" {
-		outputFormat = "mp3"
-	}
-	if codec == "" {
-		codec = "libmp3lame"
-	}
-	if bitrate == "" {
-		bitrate = "128k"
-	}
+	opts = opts.withDefaults()
 
-	// Generate a unique filename using timestamp and desired output format
-	uniqueFilename := fmt.Sprintf("%d-%s.%s", time.Now().UnixNano(), videoInfo.ID, outputFormat)
-	finalFilePath := filepath.Join(d.cfg.DownloadDir, uniqueFilename)
+	// Pick the filename, unique by default, deterministic with other strategies
+	finalFilePath, err := d.outputPath(MediaAudio, videoInfo.ID, opts.Format, opts.OnExists)
+	if err != nil {
+		d.progressManager.SendError(progressID, "Cannot write audio file", err)
+		return "", nil, nil, err
+	}
 
 	// Step 2: Download the audio to the specific filename
-	downloadArgs := []string{
-		"--extract-audio",
-		"--audio-format", outputFormat,
-		"--audio-quality", bitrate, // Corresponds to bitrate for audio quality
-		"--postprocessor-args", fmt.Sprintf("ffmpeg:-acodec %s", codec), // Specify audio codec for ffmpeg
+	downloadArgs := d.fileDownloadArgs(url, append(opts.audioArgs(),
 		"--output", finalFilePath,
 		"--no-progress",
 		"--no-playlist",
-		url,
-	}
+	)...)
 
-	downloadCmd := exec.CommandContext(ctx, d.cfg.YTDLPPath, downloadArgs...) // Use CommandContext
-	slog.Debug(fmt.Sprintf("Executing yt-dlp for audio download: %s %s", d.cfg.YTDLPPath, strings.Join(downloadArgs, " ")))
+	downloadCmd := exec.CommandContext(ctx, d.config().YTDLPPath, downloadArgs...) // Use CommandContext
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for audio download: %s %s", d.config().YTDLPPath, strings.Join(downloadArgs, " ")))
 
 	var downloadStdout, downloadStderr bytes.Buffer
 	downloadCmd.Stdout = &downloadStdout
 	downloadCmd.Stderr = &downloadStderr
 
-	err = downloadCmd.Run()
-	if err != nil {
-		slog.Error(fmt.Sprintf("yt-dlp audio fetch failed: %v\nStdout: %s\nStderr: %s", err, downloadStdout.String(), downloadStderr.String()))
-		d.progressManager.SendError(progressID, "Audio download failed", err)
-		return "", nil, fmt.Errorf("yt-dlp audio fetch failed: %w, stderr: %s", err, downloadStderr.String())
+	warnings, ytdlpErr := checkYTDLPRun(ctx, "audio fetch", downloadCmd.Run(), downloadStderr.String(), finalFilePath)
+	if ytdlpErr != nil {
+		d.progressManager.SendError(progressID, "Audio download failed", ytdlpErr.Err)
+		return "", nil, nil, ytdlpErr
 	}
 
 	// Verify the file exists
 	if _, err := os.Stat(finalFilePath); err != nil {
 		d.progressManager.SendError(progressID, "Downloaded file not found", err)
-		return "", nil, fmt.Errorf("downloaded audio file not found at %s: %w", finalFilePath, err)
+		return "", nil, nil, fmt.Errorf("downloaded audio file not found at %s: %w", finalFilePath, err)
 	}
 
-	d.progressManager.SendComplete(progressID, "Audio downloaded successfully", videoInfo)
+	d.progressManager.SendComplete(progressID, "Audio downloaded successfully", videoInfo, warnings...)
 	slog.Info(fmt.Sprintf("Audio downloaded to: %s", finalFilePath))
-	return finalFilePath, videoInfo, nil
+	return finalFilePath, videoInfo, warnings, nil
+}
+
+// ArchiveInfo writes the video's .info.json and thumbnail to the download
+// directory without downloading the media itself, for cataloging. It returns
+// the path of both sidecar files; thumbnailPath is empty if the video has none.
+func (d *Downloader) ArchiveInfo(ctx context.Context, url string) (infoPath string, thumbnailPath string, videoInfo *VideoInfo, err error) {
+	videoInfo, err = d.GetVideoInfo(ctx, url, "")
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to get video info for archive: %w", err)
+	}
+
+	dir, err := d.outputDir("")
+	if err != nil {
+		return "", "", nil, err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), videoInfo.ID))
+	archiveArgs := d.ytdlpArgs(url,
+		"--skip-download",
+		"--write-info-json",
+		"--write-thumbnail",
+		"--output", base+".%(ext)s",
+		"--no-playlist",
+	)
+	cmd := exec.CommandContext(ctx, d.config().YTDLPPath, archiveArgs...)
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for info archive: %s %s", d.config().YTDLPPath, strings.Join(archiveArgs, " ")))
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", nil, newYTDLPError("info archive", err, stderr.String())
+	}
+
+	infoPath = base + ".info.json"
+	if _, err := os.Stat(infoPath); err != nil {
+		return "", "", nil, fmt.Errorf("archived info file not found at %s: %w", infoPath, err)
+	}
+
+	// The thumbnail keeps the source's extension (jpg, webp, ...)
+	matches, _ := filepath.Glob(base + ".*")
+	for _, match := range matches {
+		if match != infoPath {
+			thumbnailPath = match
+			break
+		}
+	}
+	slog.Info("Archived video info", "infoPath", infoPath, "thumbnailPath", thumbnailPath)
+	return infoPath, thumbnailPath, videoInfo, nil
 }
 
 // StreamVideo streams video from the given URL by piping yt-dlp output.
-func (d *Downloader) StreamVideo(ctx context.Context, url string, format string, resolution string, codec string, progressID string) (io.ReadCloser, error) {
+// It also returns the video metadata fetched before the stream starts. The
+// stream counts against MAX_CONCURRENT_STREAMS until it is closed, and stops
+// after MAX_DURATION.
+func (d *Downloader) StreamVideo(ctx context.Context, url string, opts VideoOptions, progressID string) (_ io.ReadCloser, _ *VideoInfo, err error) {
+	if err := ValidateFormatSort(opts.FormatSort); err != nil {
+		return nil, nil, err
+	}
+	if err := ValidateStreamContainer(opts.Format, opts.Codec); err != nil {
+		return nil, nil, err
+	}
+	release, err := d.streamLimit.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
 		Status:     "fetching_info",
@@ -372,7 +820,7 @@ func (d *Downloader) StreamVideo(ctx context.Context, url string, format string,
 	// Get video info to send with the initial event
 	videoInfo, err := d.GetVideoInfo(ctx, url, progressID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get video info for streaming: %w", err)
+		return nil, nil, fmt.Errorf("failed to get video info for streaming: %w", err)
 	}
 
 	d.progressManager.SendEvent(ProgressEvent{
@@ -383,51 +831,50 @@ func (d *Downloader) StreamVideo(ctx context.Context, url string, format string,
 		VideoInfo:  videoInfo, // Send video info with the streaming event
 	})
 
-	if format == "" {
-		format = "mp4"
-	}
-	if resolution == "" {
-		resolution = "720" // Default to 720p for streaming if not specified
-	}
-	if codec == "" {
-		codec = "avc1"
+	opts = opts.withDefaults()
+	if err := d.checkProgressive(opts, videoInfo, progressID); err != nil {
+		return nil, nil, err
 	}
 
 	// Use --downloader ffmpeg to let yt-dlp handle the piping and conversion internally.
 	// This is more reliable than external piping.
 	// Format string: bestvideo[height<=RES]+bestaudio/best --recode-video FORMAT
 	// This tells yt-dlp to select the best video/audio and then recode it to the desired format.
-	ytDLPArgs := []string{
+	ytDLPArgs := d.ytdlpArgs(url, append(append(opts.formatArgs(), opts.streamContainerArgs()...),
 		"--downloader", "ffmpeg",
-		"--format", fmt.Sprintf("bestvideo[height<=%s][vcodec*=%s]+bestaudio/best", resolution, codec),
+		"--downloader-args", d.streamDownloaderArgs(videoInfo, progressID),
 		"-o", "-", // Output to stdout
-		url,
-	}
-	cmd := exec.CommandContext(ctx, d.cfg.YTDLPPath, ytDLPArgs...)
-	slog.Debug(fmt.Sprintf("Executing yt-dlp for video stream: %s %s", d.cfg.YTDLPPath, strings.Join(ytDLPArgs, " ")))
-
-	stdoutPipe, err := cmd.StdoutPipe()
+	)...)
+	stream, err := d.streamCommand(ctx, "video stream", ytDLPArgs)
 	if err != nil {
-		d.progressManager.SendError(progressID, "Failed to create stream pipe", err)
-		return nil, fmt.Errorf("failed to create stdout pipe for yt-dlp: %w", err)
-	}
-	cmd.Stderr = os.Stderr // Direct yt-dlp errors to stderr for debugging
-
-	if err := cmd.Start(); err != nil {
 		d.progressManager.SendError(progressID, "Failed to start stream command", err)
-		return nil, fmt.Errorf("failed to start yt-dlp command for video stream: %w", err)
+		return nil, nil, err
 	}
+	stream.release = release
 
 	// No "complete" event for streaming, as it's a continuous process.
 	// The client will close the connection when done.
-	return &commandReadCloser{
-		ReadCloser: stdoutPipe,
-		cmd:        cmd,
-	}, nil
+	return stream, videoInfo, nil
 }
 
 // StreamAudio streams audio from the given URL by piping yt-dlp output.
-func (d *Downloader) StreamAudio(ctx context.Context, url string, outputFormat string, codec string, bitrate string, progressID string) (io.ReadCloser, error) {
+// It also returns the video metadata fetched before the stream starts. The
+// stream counts against MAX_CONCURRENT_STREAMS until it is closed, and stops
+// after MAX_DURATION.
+func (d *Downloader) StreamAudio(ctx context.Context, url string, outputFormat string, codec string, bitrate string, progressID string) (_ io.ReadCloser, _ *VideoInfo, err error) {
+	if err := validateAudioCodec(outputFormat, codec); err != nil {
+		return nil, nil, err
+	}
+	release, err := d.streamLimit.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
 		Status:     "fetching_info",
@@ -438,7 +885,7 @@ func (d *Downloader) StreamAudio(ctx context.Context, url string, outputFormat s
 	// Get video info to send with the initial event
 	videoInfo, err := d.GetVideoInfo(ctx, url, progressID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get audio info for streaming: %w", err)
+		return nil, nil, fmt.Errorf("failed to get audio info for streaming: %w", err)
 	}
 
 	d.progressManager.SendEvent(ProgressEvent{
@@ -449,52 +896,50 @@ func (d *Downloader) StreamAudio(ctx context.Context, url string, outputFormat s
 		VideoInfo:  videoInfo, // Send video info with the streaming event
 	})
 
-	if outputFormat == "" {
-		outputFormat = "mp3"
-	}
-	if codec == "" {
-		codec = "libmp3lame"
-	}
-	if bitrate == "" {
-		bitrate = "128k"
+	opts := AudioOptions{Format: outputFormat, Codec: codec, Bitrate: bitrate}.withDefaults()
+	audioArgs := []string{"--extract-audio", "--audio-format", opts.Format}
+	if opts.Bitrate != "" {
+		audioArgs = append(audioArgs, "--audio-quality", opts.Bitrate) // Corresponds to bitrate for audio quality
 	}
 
 	// Use --downloader ffmpeg to let yt-dlp handle the piping and conversion internally.
-	ytDLPArgs := []string{
-		"--extract-audio",
-		"--audio-format", outputFormat,
-		"--audio-quality", bitrate, // Corresponds to bitrate for audio quality
-		"--postprocessor-args", fmt.Sprintf("ffmpeg:-acodec %s", codec), // Specify audio codec for ffmpeg
+	ytDLPArgs := d.ytdlpArgs(url, append(audioArgs,
+		"--postprocessor-args", d.ffmpegArgs("-acodec", opts.Codec), // Specify audio codec for ffmpeg
 		"--downloader", "ffmpeg",
+		"--downloader-args", d.streamDownloaderArgs(videoInfo, progressID),
 		"-o", "-", // Output to stdout
-		url,
-	}
-	cmd := exec.CommandContext(ctx, d.cfg.YTDLPPath, ytDLPArgs...)
-	slog.Debug(fmt.Sprintf("Executing yt-dlp for audio stream: %s %s", d.cfg.YTDLPPath, strings.Join(ytDLPArgs, " ")))
-
-	stdoutPipe, err := cmd.StdoutPipe()
+	)...)
+	stream, err := d.streamCommand(ctx, "audio stream", ytDLPArgs)
 	if err != nil {
-		d.progressManager.SendError(progressID, "Failed to create stream pipe", err)
-		return nil, fmt.Errorf("failed to create stdout pipe for yt-dlp: %w", err)
-	}
-	cmd.Stderr = os.Stderr // Direct yt-dlp errors to stderr for debugging
-
-	if err := cmd.Start(); err != nil {
 		d.progressManager.SendError(progressID, "Failed to start stream command", err)
-		return nil, fmt.Errorf("failed to start yt-dlp command for audio stream: %w", err)
+		return nil, nil, err
 	}
+	stream.release = release
 
 	// No "complete" event for streaming, as it's a continuous process.
 	// The client will close the connection when done.
-	return &commandReadCloser{
-		ReadCloser: stdoutPipe,
-		cmd:        cmd,
-	}, nil
+	return stream, videoInfo, nil
 }
 
 // DownloadVideoToTempFile downloads a video to a temporary file on the server.
-// Returns the path to the temporary file and any error.
-func (d *Downloader) DownloadVideoToTempFile(ctx context.Context, url string, format string, resolution string, codec string, progressID string) (string, error) {
+// Returns the path to the temporary file and any error. When the video cannot
+// be recoded to opts.Format, the original download is kept, so the file's
+// extension is the one to trust.
+func (d *Downloader) DownloadVideoToTempFile(ctx context.Context, url string, opts VideoOptions, progressID string) (filePath string, err error) {
+	ctx, span := startSpan(ctx, "download video", url,
+		attribute.String("media.format", opts.withDefaults().Format),
+		attribute.String("media.resolution", opts.withDefaults().Resolution))
+	defer func() { endSpan(span, filePath, err) }()
+
+	if err := ValidateFormatSort(opts.FormatSort); err != nil {
+		return "", err
+	}
+	release, err := d.downloadLimit.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
 		Status:     "fetching_info",
@@ -507,6 +952,9 @@ func (d *Downloader) DownloadVideoToTempFile(ctx context.Context, url string, fo
 	if err != nil {
 		return "", fmt.Errorf("failed to get video info for download: %w", err)
 	}
+	if err := d.checkDuration(videoInfo, progressID); err != nil {
+		return "", err
+	}
 
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
@@ -516,40 +964,34 @@ func (d *Downloader) DownloadVideoToTempFile(ctx context.Context, url string, fo
 		VideoInfo:  videoInfo, // Send video info with the downloading event
 	})
 
-	if format == "" {
-		format = "mp4"
-	}
-	if resolution == "" {
-		resolution = "720"
-	}
-	if codec == "" {
-		codec = "avc1"
-	}
+	opts = opts.withDefaults()
 
 	// Generate a unique filename in the configured download directory
-	uniqueFilename := fmt.Sprintf("video-download-%d.mp4", time.Now().UnixNano())
-	finalFilePath := filepath.Join(d.cfg.DownloadDir, uniqueFilename)
-
-	downloadArgs := []string{
-		"--format", fmt.Sprintf("bestvideo[height<=%s][vcodec*=%s]+bestaudio/best", resolution, codec),
-		"--output", finalFilePath,
-		"--no-progress",
-		"--no-playlist",
-		"--recode-video", format,
-		url,
+	uniqueFilename := fmt.Sprintf("video-download-%d.%s", time.Now().UnixNano(), opts.Format)
+	finalFilePath := filepath.Join(d.mediaDir(MediaVideo), uniqueFilename)
+
+	_, downloadStderr, _, ytdlpErr := d.runVideoDownload(ctx, "temp video download", opts, finalFilePath, progressID, func(formatArgs []string) []string {
+		return d.fileDownloadArgs(url, append(formatArgs,
+			"--output", finalFilePath,
+			"--no-progress",
+			"--no-playlist",
+			"--recode-video", opts.Format,
+		)...)
+	})
+	if ytdlpErr != nil && !d.retryRecode(ctx, downloadStderr, finalFilePath, progressID) {
+		source, _ := d.recodeFallback(opts, downloadStderr, finalFilePath, progressID)
+		if source == "" {
+			d.progressManager.SendError(progressID, "Video download to server failed", ytdlpErr.Err)
+			return "", ytdlpErr
+		}
+		finalFilePath = source
 	}
 
-	downloadCmd := exec.CommandContext(ctx, d.cfg.YTDLPPath, downloadArgs...)
-	slog.Debug(fmt.Sprintf("Executing yt-dlp for temp video download: %s %s", d.cfg.YTDLPPath, strings.Join(downloadArgs, " ")))
-
-	var downloadStderr bytes.Buffer
-	downloadCmd.Stderr = &downloadStderr
-
-	err = downloadCmd.Run()
-	if err != nil {
-		slog.Error(fmt.Sprintf("yt-dlp temp video download failed: %v\nStderr: %s", err, downloadStderr.String()))
-		d.progressManager.SendError(progressID, "Video download to server failed", err)
-		return "", fmt.Errorf("yt-dlp temp video download failed: %w, stderr: %s", err, downloadStderr.String())
+	if opts.BurnSubtitles {
+		if err := d.burnSubtitles(ctx, url, finalFilePath, videoInfo, opts.SubtitleLang, progressID); err != nil {
+			d.progressManager.SendError(progressID, "Subtitle burn-in failed", err)
+			return "", err
+		}
 	}
 
 	d.progressManager.SendEvent(ProgressEvent{
@@ -565,7 +1007,19 @@ func (d *Downloader) DownloadVideoToTempFile(ctx context.Context, url string, fo
 
 // DownloadAudioToTempFile downloads audio to a temporary file on the server.
 // Returns the path to the temporary file and any error.
-func (d *Downloader) DownloadAudioToTempFile(ctx context.Context, url string, outputFormat string, codec string, bitrate string, progressID string) (string, error) {
+func (d *Downloader) DownloadAudioToTempFile(ctx context.Context, url string, opts AudioOptions, progressID string) (filePath string, err error) {
+	ctx, span := startSpan(ctx, "download audio", url, attribute.String("media.format", opts.withDefaults().Format))
+	defer func() { endSpan(span, filePath, err) }()
+
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	release, err := d.downloadLimit.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
 		Status:     "fetching_info",
@@ -578,6 +1032,9 @@ func (d *Downloader) DownloadAudioToTempFile(ctx context.Context, url string, ou
 	if err != nil {
 		return "", fmt.Errorf("failed to get audio info for download: %w", err)
 	}
+	if err := d.checkDuration(videoInfo, progressID); err != nil {
+		return "", err
+	}
 
 	d.progressManager.SendEvent(ProgressEvent{
 		ID:         progressID,
@@ -587,42 +1044,27 @@ func (d *Downloader) DownloadAudioToTempFile(ctx context.Context, url string, ou
 		VideoInfo:  videoInfo, // Send video info with the downloading event
 	})
 
-	if outputFormat == "" {
-		outputFormat = "mp3"
-	}
-	if codec == "" {
-		codec = "libmp3lame"
-	}
-	if bitrate == "" {
-		bitrate = "128k"
-	}
+	opts = opts.withDefaults()
 
 	// Generate a unique filename in the configured download directory
-	uniqueFilename := fmt.Sprintf("audio-download-%d.%s", time.Now().UnixNano(), outputFormat)
-	finalFilePath := filepath.Join(d.cfg.DownloadDir, uniqueFilename)
-
-	downloadArgs := []string{
-		"--extract-audio",
-		"--audio-format", outputFormat,
-		"--audio-quality", bitrate,
-		"--postprocessor-args", fmt.Sprintf("ffmpeg:-acodec %s", codec),
+	uniqueFilename := fmt.Sprintf("audio-download-%d.%s", time.Now().UnixNano(), opts.Format)
+	finalFilePath := filepath.Join(d.mediaDir(MediaAudio), uniqueFilename)
+
+	downloadArgs := d.fileDownloadArgs(url, append(opts.audioArgs(),
 		"--output", finalFilePath,
 		"--no-progress",
 		"--no-playlist",
-		url,
-	}
+	)...)
 
-	downloadCmd := exec.CommandContext(ctx, d.cfg.YTDLPPath, downloadArgs...)
-	slog.Debug(fmt.Sprintf("Executing yt-dlp for temp audio download: %s %s", d.cfg.YTDLPPath, strings.Join(downloadArgs, " ")))
+	downloadCmd := exec.CommandContext(ctx, d.config().YTDLPPath, downloadArgs...)
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for temp audio download: %s %s", d.config().YTDLPPath, strings.Join(downloadArgs, " ")))
 
 	var downloadStderr bytes.Buffer
 	downloadCmd.Stderr = &downloadStderr
 
-	err = downloadCmd.Run()
-	if err != nil {
-		slog.Error(fmt.Sprintf("yt-dlp temp audio download failed: %v\nStderr: %s", err, downloadStderr.String()))
-		d.progressManager.SendError(progressID, "Audio download to server failed", err)
-		return "", fmt.Errorf("yt-dlp temp audio download failed: %w, stderr: %s", err, downloadStderr.String())
+	if _, ytdlpErr := checkYTDLPRun(ctx, "temp audio download", downloadCmd.Run(), downloadStderr.String(), finalFilePath); ytdlpErr != nil {
+		d.progressManager.SendError(progressID, "Audio download to server failed", ytdlpErr.Err)
+		return "", ytdlpErr
 	}
 
 	d.progressManager.SendEvent(ProgressEvent{
@@ -636,11 +1078,179 @@ func (d *Downloader) DownloadAudioToTempFile(ctx context.Context, url string, ou
 	return finalFilePath, nil
 }
 
+// checkProgressive warns when a progressive download was requested but the
+// video has no matching pre-muxed format. With StrictFormat it fails instead,
+// since yt-dlp would only error out later with a less helpful message. A
+// FormatID takes over from Progressive, so is not checked.
+func (d *Downloader) checkProgressive(opts VideoOptions, videoInfo *VideoInfo, progressID string) error {
+	if !opts.Progressive || opts.FormatID != "" || opts.hasProgressiveFormat(videoInfo.Formats) {
+		return nil
+	}
+	if opts.StrictFormat {
+		err := fmt.Errorf("no progressive %s format at or below %sp for %s", opts.Format, opts.Resolution, videoInfo.ID)
+		d.progressManager.SendError(progressID, "No progressive format available", err)
+		return err
+	}
+	slog.Warn("No progressive format at requested resolution, falling back", "videoID", videoInfo.ID, "format", opts.Format, "resolution", opts.Resolution)
+	d.progressManager.SendEvent(ProgressEvent{
+		ID:      progressID,
+		Status:  "warning",
+		Message: fmt.Sprintf("No pre-merged %s at %sp, using the best pre-merged format available.", opts.Format, opts.Resolution),
+	})
+	return nil
+}
+
+// recodeFailed reports whether yt-dlp's stderr shows it failed while
+// post-processing, i.e. after the media itself was downloaded.
+func recodeFailed(stderr string) bool {
+	return strings.Contains(stderr, "Postprocessing:") || strings.Contains(stderr, "Conversion failed")
+}
+
+// findDownloadedSource returns the media file yt-dlp left next to outputPath
+// (same base name, any extension), ignoring partial downloads. It returns an
+// empty string if there is none.
+func findDownloadedSource(outputPath string) string {
+	dir := filepath.Dir(outputPath)
+	prefix := strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath)) + "."
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == ".part" || ext == ".ytdl" || strings.Contains(name, ".part-Frag") {
+			continue
+		}
+		return filepath.Join(dir, name)
+	}
+	return ""
+}
+
+// fetchSubtitles downloads the first subtitle language in langChain that the
+// video has, converted to SRT, next to base. It returns the subtitle file's
+// path, which the caller must remove, and the language picked.
+func (d *Downloader) fetchSubtitles(ctx context.Context, url, base string, videoInfo *VideoInfo, langChain, progressID string) (string, string, error) {
+	lang, auto, ok := pickSubtitleLang(videoInfo, parseSubtitleChain(langChain))
+	if !ok {
+		return "", "", fmt.Errorf("no subtitles available for %s in any of: %s", url, langChain)
+	}
+
+	d.progressManager.SendEvent(ProgressEvent{
+		ID:         progressID,
+		Status:     "fetching_subtitles",
+		Message:    fmt.Sprintf("Downloading %s subtitles...", lang),
+		Percentage: 60,
+	})
+
+	writeFlag := "--write-subs"
+	if auto {
+		writeFlag = "--write-auto-subs"
+	}
+
+	subArgs := d.ytdlpArgs(url,
+		"--skip-download",
+		writeFlag,
+		"--sub-langs", lang,
+		"--convert-subs", "srt",
+		"--output", base+".%(ext)s",
+		"--no-playlist",
+	)
+	subCmd := exec.CommandContext(ctx, d.config().YTDLPPath, subArgs...)
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for subtitles: %s %s", d.config().YTDLPPath, strings.Join(subArgs, " ")))
+
+	var subStderr bytes.Buffer
+	subCmd.Stderr = &subStderr
+	if err := subCmd.Run(); err != nil {
+		return "", "", newYTDLPError("subtitle download", err, subStderr.String())
+	}
+
+	subPath := fmt.Sprintf("%s.%s.srt", base, lang)
+	if _, err := os.Stat(subPath); err != nil {
+		return "", "", fmt.Errorf("'%s' subtitles were not written for %s: %w", lang, url, err)
+	}
+	return subPath, lang, nil
+}
+
+// FetchSubtitles downloads the first subtitle language in langChain that the
+// video at url has (English when langChain is empty) and returns the language
+// and the SRT contents. The file only lives in the download directory while
+// it is read.
+func (d *Downloader) FetchSubtitles(ctx context.Context, url, langChain string) (string, []byte, error) {
+	if langChain == "" {
+		langChain = "en"
+	}
+
+	videoInfo, err := d.dumpInfo(ctx, url, "subtitle info dump")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get video info for subtitles: %w", err)
+	}
+
+	base := filepath.Join(d.config().DownloadDir, fmt.Sprintf("subtitles-%d", time.Now().UnixNano()))
+	subPath, lang, err := d.fetchSubtitles(ctx, url, base, videoInfo, langChain, "")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.Remove(subPath)
+
+	data, err := os.ReadFile(subPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read '%s' subtitles for %s: %w", lang, url, err)
+	}
+	return lang, data, nil
+}
+
+// burnSubtitles fetches subtitles for url and re-encodes videoPath with them
+// drawn onto the picture. The file at videoPath is replaced. langChain is a
+// comma-separated preference list; the first language the video actually has
+// is used and recorded in videoInfo.SubtitleLang.
+func (d *Downloader) burnSubtitles(ctx context.Context, url, videoPath string, videoInfo *VideoInfo, langChain, progressID string) (err error) {
+	ctx, span := startSpan(ctx, "encode subtitles", url, attribute.String("subtitles.languages", langChain))
+	defer func() { endSpan(span, videoPath, err) }()
+
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	subPath, lang, err := d.fetchSubtitles(ctx, url, base, videoInfo, langChain, progressID)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(subPath)
+	videoInfo.SubtitleLang = lang
+
+	d.progressManager.SendEvent(ProgressEvent{
+		ID:         progressID,
+		Status:     "encoding",
+		Message:    "Burning subtitles into video...",
+		Percentage: 75,
+	})
+
+	burnedPath := base + ".burned" + filepath.Ext(videoPath)
+	ffmpegArgs := burnSubtitlesArgs(videoPath, subPath, burnedPath)
+	ffmpegCmd := exec.CommandContext(ctx, d.config().FFMPEGPath, ffmpegArgs...)
+	slog.Debug(fmt.Sprintf("Executing ffmpeg for subtitle burn-in: %s %s", d.config().FFMPEGPath, strings.Join(ffmpegArgs, " ")))
+
+	var ffmpegStderr bytes.Buffer
+	ffmpegCmd.Stderr = &ffmpegStderr
+	if err := ffmpegCmd.Run(); err != nil {
+		os.Remove(burnedPath)
+		return fmt.Errorf("ffmpeg subtitle burn-in failed: %w, stderr: %s", err, ffmpegStderr.String())
+	}
+
+	if err := os.Rename(burnedPath, videoPath); err != nil {
+		return fmt.Errorf("failed to replace video with subtitled version: %w", err)
+	}
+	return nil
+}
+
 // commandReadCloser wraps an io.ReadCloser and an exec.Cmd,
 // ensuring the command is waited upon when the reader is closed.
 type commandReadCloser struct {
 	io.ReadCloser
-	cmd *exec.Cmd
+	cmd     *exec.Cmd
+	op      string      // What the command does, for errors
+	stderr  *tailBuffer // End of the command's stderr, for errors
+	release func()      // Gives back the concurrency slot, if any
 	// Add a mutex to protect access to cmd.Wait() if Close() could be called concurrently
 	// or if cmd.Wait() could be called multiple times.
 	// For this use case, it's typically called once.
@@ -656,12 +1266,18 @@ func (crc *commandReadCloser) Close() error {
 	// Wait for the command to exit, ensuring it's only called once
 	crc.waitOnce.Do(func() {
 		crc.waitErr = crc.cmd.Wait()
+		if crc.release != nil {
+			crc.release()
+		}
 	})
 
 	if pipeCloseErr != nil {
 		return fmt.Errorf("error closing pipe: %w; command wait error: %v", pipeCloseErr, crc.waitErr)
 	}
 	if crc.waitErr != nil {
+		if crc.stderr != nil {
+			return fmt.Errorf("command exited with error: %w", newYTDLPError(crc.op, crc.waitErr, crc.stderr.String()))
+		}
 		return fmt.Errorf("command exited with error: %w", crc.waitErr)
 	}
 	return nil
@@ -1,5 +1,19 @@
 package service
 
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/config"
+)
+
 // Import context package
 
 // // createTestConfig creates a config.Config for testing.
@@ -580,7 +594,7 @@ package service
 // 	ctx, cancel := context.WithCancel(context.Background())
 // 	defer cancel()
 
-// 	streamInfo, err := downloader.GetStreamInfo(ctx, url, resolution, codec, "") // Pass empty progressID
+// 	streamInfo, err := downloader.GetStreamInfo(ctx, url, resolution, codec, "", "") // Pass empty protocol and progressID
 // 	assert.NoError(t, err, "GetStreamInfo should not fail on success")
 // 	assert.NotNil(t, streamInfo, "StreamInfo should not be nil")
 // 	assert.NotEmpty(t, streamInfo.DirectStreamURL, "DirectStreamURL should not be empty")
@@ -603,7 +617,7 @@ package service
 // 	ctx, cancel := context.WithCancel(context.Background())
 // 	defer cancel()
 
-// 	streamInfo, err := downloader.GetStreamInfo(ctx, nonExistentURL, resolution, codec, "") // Pass empty progressID
+// 	streamInfo, err := downloader.GetStreamInfo(ctx, nonExistentURL, resolution, codec, "", "") // Pass empty protocol and progressID
 // 	assert.Error(t, err, "Expected error for non-existent URL")
 // 	assert.Nil(t, streamInfo, "StreamInfo should be nil on failure")
 // 	assert.Contains(t, err.Error(), "yt-dlp stream info dump failed")
@@ -644,3 +658,274 @@ package service
 // 	assert.Error(t, err, "Expected error when command fails")
 // 	assert.Contains(t, err.Error(), "command exited with error", "Expected command exit error message")
 // }
+
+func TestYTDLPArgs_AppendsExtraArgs(t *testing.T) {
+	cfg := &config.Config{ExtraYTDLPArgs: `--proxy "socks5://127.0.0.1:1080" --force-ipv4`}
+	d := NewDownloader(cfg, NewProgressManager(0))
+
+	args := d.ytdlpArgs("https://example.com/watch?v=1", "--dump-json")
+	assert.Equal(t, []string{
+		"--dump-json",
+		"--proxy", "socks5://127.0.0.1:1080",
+		"--force-ipv4",
+		"--", "https://example.com/watch?v=1",
+	}, args)
+}
+
+func TestYTDLPArgs_ClientCannotInjectArgs(t *testing.T) {
+	d := NewDownloader(&config.Config{}, NewProgressManager(0))
+
+	// A malicious "URL" must stay a single positional argument after "--".
+	url := "--exec rm -rf / https://example.com"
+	args := d.ytdlpArgs(url, "--dump-json")
+	assert.Equal(t, []string{"--dump-json", "--", url}, args)
+}
+
+func TestYTDLPArgs_FFmpegLogLevel(t *testing.T) {
+	d := NewDownloader(&config.Config{FFmpegLogLevel: "error"}, NewProgressManager(0))
+
+	args := d.ytdlpArgs("https://example.com/watch?v=1", "-x")
+	assert.Equal(t, []string{
+		"--postprocessor-args", "ffmpeg:-loglevel error",
+		"-x",
+		"--", "https://example.com/watch?v=1",
+	}, args)
+
+	// Callers with their own ffmpeg args keep the log level
+	assert.Equal(t, "ffmpeg:-acodec opus -loglevel error", d.ffmpegArgs("-acodec", "opus"))
+}
+
+func TestYTDLPArgs_CookiesFile(t *testing.T) {
+	d := NewDownloader(&config.Config{CookiesFile: "/etc/gostreampuller/cookies.txt"}, NewProgressManager(0))
+
+	args := d.ytdlpArgs("https://example.com/watch?v=1", "--dump-json")
+	assert.Equal(t, []string{
+		"--dump-json",
+		"--cookies", "/etc/gostreampuller/cookies.txt",
+		"--", "https://example.com/watch?v=1",
+	}, args)
+}
+
+func TestFileDownloadArgs_ExternalDownloader(t *testing.T) {
+	cfg := &config.Config{ExternalDownloader: "aria2c", ExternalDownloaderArgs: "-x16 -s16"}
+	d := NewDownloader(cfg, NewProgressManager(0))
+
+	args := d.fileDownloadArgs("https://example.com/v", "--output", "/data/v.mp4")
+	assert.Equal(t, []string{
+		"--output", "/data/v.mp4",
+		"--downloader", "aria2c",
+		"--downloader-args", "aria2c:-x16 -s16",
+		"--", "https://example.com/v",
+	}, args)
+
+	// Without an external downloader, file downloads use yt-dlp's own
+	d = NewDownloader(&config.Config{}, NewProgressManager(0))
+	args = d.fileDownloadArgs("https://example.com/v", "--output", "/data/v.mp4")
+	assert.Equal(t, []string{"--output", "/data/v.mp4", "--", "https://example.com/v"}, args)
+}
+
+func TestVideoInfo_UnmarshalThumbnails(t *testing.T) {
+	data := `{
+		"id": "abc123",
+		"thumbnail": "https://i.example.com/abc123/maxres.jpg",
+		"thumbnails": [
+			{"url": "https://i.example.com/abc123/default.jpg", "preference": -7, "id": "0", "width": 120, "height": 90, "resolution": "120x90"},
+			{"url": "https://i.example.com/abc123/maxres.webp", "preference": -1, "id": "1"},
+			{"url": "https://i.example.com/abc123/maxres.jpg", "id": "2", "width": 1280, "height": 720}
+		]
+	}`
+
+	var info VideoInfo
+	assert.NoError(t, json.Unmarshal([]byte(data), &info))
+	assert.Equal(t, []Thumbnail{
+		{ID: "0", URL: "https://i.example.com/abc123/default.jpg", Width: 120, Height: 90},
+		{ID: "1", URL: "https://i.example.com/abc123/maxres.webp"},
+		{ID: "2", URL: "https://i.example.com/abc123/maxres.jpg", Width: 1280, Height: 720},
+	}, info.Thumbnails)
+	assert.Equal(t, "https://i.example.com/abc123/maxres.jpg", info.Thumbnail)
+}
+
+func TestBurnSubtitlesArgs(t *testing.T) {
+	args := burnSubtitlesArgs("/data/1-abc.mp4", "/data/1-abc.en.srt", "/data/1-abc.burned.mp4")
+	assert.Equal(t, []string{
+		"-y",
+		"-i", "/data/1-abc.mp4",
+		"-vf", "subtitles=/data/1-abc.en.srt",
+		"-c:a", "copy",
+		"/data/1-abc.burned.mp4",
+	}, args)
+}
+
+func TestBurnSubtitlesArgs_EscapesFilterPath(t *testing.T) {
+	args := burnSubtitlesArgs("in.mp4", `C:\subs\it's.srt`, "out.mp4")
+	assert.Contains(t, args, `subtitles=C\:\\subs\\it\'s.srt`)
+}
+
+// fakeInfoPrelude makes a fake yt-dlp answer --dump-json with a minimal info
+// JSON and capture the --output target in $out for the rest of the script.
+const fakeInfoPrelude = `#!/bin/sh
+out=""
+prev=""
+for a in "$@"; do
+	case "$prev" in --output|-o) out="$a" ;; esac
+	case "$a" in --dump-json) echo '{"id":"abc123","title":"Test Video","duration":42}'; exit 0 ;; esac
+	prev="$a"
+done
+`
+
+// newFakeDownloader returns a Downloader whose yt-dlp is the given script.
+func newFakeDownloader(t *testing.T, script string) *Downloader {
+	t.Helper()
+	ytdlpPath := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(ytdlpPath, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake yt-dlp: %v", err)
+	}
+	cfg := &config.Config{
+		YTDLPPath:   ytdlpPath,
+		FFMPEGPath:  "true",
+		DownloadDir: t.TempDir(),
+	}
+	return NewDownloader(cfg, NewProgressManager(0))
+}
+
+// withFakeFFmpeg makes d run the given script as ffmpeg.
+func withFakeFFmpeg(t *testing.T, d *Downloader, script string) {
+	t.Helper()
+	ffmpegPath := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(ffmpegPath, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake ffmpeg: %v", err)
+	}
+	d.config().FFMPEGPath = ffmpegPath
+}
+
+// recodeFailsScript downloads a webm next to the requested output, then fails
+// the way yt-dlp does when the recode post-processor errors out.
+const recodeFailsScript = fakeInfoPrelude + `
+printf 'webm-data' > "${out%.*}.webm"
+echo "ERROR: Postprocessing: Conversion failed!" >&2
+exit 1
+`
+
+func TestDownloadVideoToFile_RecodeFailureKeepsOriginal(t *testing.T) {
+	d := newFakeDownloader(t, recodeFailsScript)
+
+	filePath, videoInfo, warnings, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", videoInfo.ID)
+	assert.Equal(t, ".webm", filepath.Ext(filePath))
+	assert.Equal(t, []string{"Could not convert to mp4, keeping the original webm file."}, warnings)
+
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "webm-data", string(data))
+}
+
+func TestDownloadVideoToFile_RecodeFailureStrict(t *testing.T) {
+	d := newFakeDownloader(t, recodeFailsScript)
+
+	_, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{StrictFormat: true}, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Conversion failed")
+}
+
+func TestDownloadVideoToFile_DownloadFailureIsNotRecovered(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude+`
+echo "ERROR: unable to download video data: HTTP Error 403" >&2
+exit 1
+`)
+
+	_, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP Error 403")
+}
+
+// multiAudioScript reports a video with English and French audio tracks and
+// writes the arguments of the download call, one per line, to the output file.
+const multiAudioScript = `#!/bin/sh
+out=""
+prev=""
+for a in "$@"; do
+	case "$prev" in --output|-o) out="$a" ;; esac
+	case "$a" in --dump-json) echo '{"id":"abc123","title":"Test Video","formats":[
+		{"format_id":"v1","vcodec":"avc1","acodec":"none","height":720},
+		{"format_id":"a-fr","vcodec":"none","acodec":"opus","language":"fr"},
+		{"format_id":"a-en-low","vcodec":"none","acodec":"mp4a","language":"en"},
+		{"format_id":"a-en","vcodec":"none","acodec":"opus","language":"en"}]}'; exit 0 ;; esac
+	prev="$a"
+done
+printf '%s\n' "$@" > "$out"
+`
+
+func TestDownloadVideoToFile_AllAudioLanguages(t *testing.T) {
+	d := newFakeDownloader(t, multiAudioScript)
+
+	filePath, videoInfo, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{AllAudioLanguages: true}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, ".mkv", filepath.Ext(filePath))
+	assert.Equal(t, []string{"en", "fr"}, videoInfo.AudioLanguages)
+
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Contains(t, args, "--audio-multistreams")
+	assert.Contains(t, args, "bestvideo[height<=720][vcodec*=avc1]+bestaudio[language=en]+bestaudio[language=fr]/bestvideo[height<=720][vcodec*=avc1]+bestaudio/best")
+	assert.Contains(t, strings.Join(args, " "), "--merge-output-format mkv")
+}
+
+func TestDownloadVideoToFile_AllAudioLanguagesInvalidFormat(t *testing.T) {
+	d := newFakeDownloader(t, multiAudioScript)
+
+	_, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{AllAudioLanguages: true, Format: "flv"}, "")
+	assert.ErrorContains(t, err, "cannot hold multiple audio tracks")
+}
+
+// streamFailsScript writes some stream data, then fails with an error on stderr.
+const streamFailsScript = fakeInfoPrelude + `
+printf 'partial'
+echo "ERROR: [youtube] abc123: Video unavailable" >&2
+exit 1
+`
+
+func TestStreamVideo_CapturesStderr(t *testing.T) {
+	d := newFakeDownloader(t, streamFailsScript)
+
+	stream, _, err := d.StreamVideo(context.Background(), "https://example.com/watch?v=abc123", VideoOptions{}, "")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(stream)
+	assert.Equal(t, "partial", string(data))
+
+	err = stream.Close()
+	var ytdlpErr *YTDLPError
+	if assert.ErrorAs(t, err, &ytdlpErr) {
+		assert.Equal(t, "video stream", ytdlpErr.Op)
+		assert.Contains(t, err.Error(), "Video unavailable")
+	}
+}
+
+func TestTailBuffer_KeepsEnd(t *testing.T) {
+	buf := newTailBuffer(8)
+	buf.Write([]byte("0123456789"))
+	buf.Write([]byte("ab"))
+	assert.Equal(t, "456789ab", buf.String())
+}
+
+func TestGetStreamInfo_Protocol(t *testing.T) {
+	d := newFakeDownloader(t, `#!/bin/sh
+echo '{"id":"abc123","title":"Test Video","formats":[
+	{"format_id":"hls-720","vcodec":"avc1","height":720,"protocol":"m3u8_native","url":"https://example.com/720.m3u8"},
+	{"format_id":"480","vcodec":"avc1","height":480,"protocol":"https","url":"https://example.com/480.mp4"}]}'
+`)
+
+	info, err := d.GetStreamInfo(context.Background(), "https://example.com/v", "720", "", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "hls-720", info.FormatID)
+	assert.Equal(t, "m3u8_native", info.Protocol)
+
+	info, err = d.GetStreamInfo(context.Background(), "https://example.com/v", "720", "", "https", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "480", info.FormatID)
+	assert.Equal(t, "https://example.com/480.mp4", info.DirectStreamURL)
+
+	_, err = d.GetStreamInfo(context.Background(), "https://example.com/v", "720", "", "http_dash_segments", "")
+	assert.ErrorContains(t, err, `protocol "http_dash_segments"`)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// DurationLimitError is returned for a download of a video longer than
+// MAX_DURATION.
+type DurationLimitError struct {
+	Duration time.Duration // The video's duration
+	Max      time.Duration // The configured limit
+}
+
+func (e *DurationLimitError) Error() string {
+	return fmt.Sprintf("video is %s long, downloads are limited to %s", e.Duration, e.Max)
+}
+
+// videoDuration returns the duration yt-dlp reported for videoInfo, 0 when
+// unknown (e.g. live streams).
+func videoDuration(videoInfo *VideoInfo) time.Duration {
+	return time.Duration(videoInfo.Duration) * time.Second
+}
+
+// checkDuration rejects downloading a video longer than MAX_DURATION, so
+// extremely long videos cannot fill the disk or tie up the server. Videos
+// of unknown duration are let through.
+func (d *Downloader) checkDuration(videoInfo *VideoInfo, progressID string) error {
+	duration := videoDuration(videoInfo)
+	if d.config().MaxDuration <= 0 || duration <= d.config().MaxDuration {
+		return nil
+	}
+	err := &DurationLimitError{Duration: duration, Max: d.config().MaxDuration}
+	d.progressManager.SendError(progressID, "Video is too long", err)
+	return err
+}
+
+// streamDownloaderArgs returns the --downloader-args of a stream of
+// videoInfo. Streams are not rejected for exceeding MAX_DURATION: ffmpeg
+// stops them at the limit instead, and the client is warned.
+func (d *Downloader) streamDownloaderArgs(videoInfo *VideoInfo, progressID string) string {
+	duration := videoDuration(videoInfo)
+	if d.config().MaxDuration <= 0 || duration <= d.config().MaxDuration {
+		return d.ffmpegArgs()
+	}
+	slog.Warn("Video longer than MAX_DURATION, cutting the stream short", "videoID", videoInfo.ID, "duration", duration, "max", d.config().MaxDuration)
+	d.progressManager.SendEvent(ProgressEvent{
+		ID:      progressID,
+		Status:  "warning",
+		Message: fmt.Sprintf("Video is %s long, only the first %s is streamed.", duration, d.config().MaxDuration),
+	})
+	return d.ffmpegArgs("-t", strconv.FormatFloat(d.config().MaxDuration.Seconds(), 'f', -1, 64))
+}
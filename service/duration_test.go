@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownload_MaxDuration(t *testing.T) {
+	// fakeInfoPrelude reports a 42s video
+	d := newFakeDownloader(t, mediaScript)
+	ctx := context.Background()
+
+	d.config().MaxDuration = 10 * time.Second
+	_, _, _, err := d.DownloadVideoToFile(ctx, "https://example.com/v", VideoOptions{}, "")
+	var durationErr *DurationLimitError
+	if assert.ErrorAs(t, err, &durationErr) {
+		assert.Equal(t, 42*time.Second, durationErr.Duration)
+		assert.Equal(t, 10*time.Second, durationErr.Max)
+	}
+	assert.EqualError(t, err, "video is 42s long, downloads are limited to 10s")
+	_, err = d.DownloadAudioToTempFile(ctx, "https://example.com/v", AudioOptions{}, "")
+	assert.ErrorAs(t, err, &durationErr)
+
+	d.config().MaxDuration = time.Minute
+	_, _, _, err = d.DownloadVideoToFile(ctx, "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+}
+
+func TestStream_MaxDurationCutsShort(t *testing.T) {
+	// Print the arguments of the stream call as the stream itself
+	d := newFakeDownloader(t, fakeInfoPrelude+`printf '%s\n' "$@"
+`)
+	d.config().MaxDuration = 10 * time.Second
+
+	stream, _, err := d.StreamVideo(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	args, _ := io.ReadAll(stream)
+	assert.NoError(t, stream.Close())
+	assert.Contains(t, strings.Split(string(args), "\n"), "ffmpeg:-t 10")
+
+	d.config().MaxDuration = time.Minute
+	stream, _, err = d.StreamAudio(context.Background(), "https://example.com/v", "", "", "", "")
+	assert.NoError(t, err)
+	args, _ = io.ReadAll(stream)
+	assert.NoError(t, stream.Close())
+	assert.NotContains(t, string(args), "-t ")
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ExtractorNotAllowedError is returned for a URL handled by a yt-dlp
+// extractor missing from ALLOWED_EXTRACTORS.
+type ExtractorNotAllowedError struct {
+	Extractor string // The extractor yt-dlp reported, empty when unknown
+}
+
+func (e *ExtractorNotAllowedError) Error() string {
+	if e.Extractor == "" {
+		return "the extractor of this URL is unknown, and only allowed extractors are accepted"
+	}
+	return fmt.Sprintf("videos from extractor %q are not allowed on this server", e.Extractor)
+}
+
+// checkExtractor rejects info from an extractor outside ALLOWED_EXTRACTORS.
+// Either the extractor name (e.g. "youtube:tab") or its key (e.g.
+// "YoutubeTab") may be listed, in any case. Checking what yt-dlp actually
+// used is more robust than matching URL domains, which redirects and
+// shorteners get around.
+func (d *Downloader) checkExtractor(extractor, extractorKey string) error {
+	allowed := d.config().ExtractorAllowlist()
+	if len(allowed) == 0 {
+		return nil
+	}
+	// Empty names never match, as the allowlist has none
+	if slices.Contains(allowed, strings.ToLower(extractor)) || slices.Contains(allowed, strings.ToLower(extractorKey)) {
+		return nil
+	}
+	return &ExtractorNotAllowedError{Extractor: cmp.Or(extractor, extractorKey)}
+}
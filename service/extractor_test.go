@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// vimeoInfoScript reports its videos as coming from yt-dlp's Vimeo
+// extractor, and fails any download.
+const vimeoInfoScript = `#!/bin/sh
+for a in "$@"; do
+	case "$a" in --dump-json) echo '{"id":"v1","title":"Vimeo Video","extractor":"vimeo","extractor_key":"Vimeo"}'; exit 0 ;; esac
+done
+echo "ERROR: download should not start" >&2
+exit 1
+`
+
+func TestCheckExtractor(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed string
+		wantErr bool
+	}{
+		{"NoAllowlist", "", false},
+		{"ByName", "youtube, vimeo", false},
+		{"ByKeyAnyCase", "VIMEO", false},
+		{"NotListed", "youtube,youtube:tab", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDownloader(t, vimeoInfoScript)
+			d.config().AllowedExtractors = tt.allowed
+
+			info, err := d.GetVideoInfo(context.Background(), "https://vimeo.com/1", "")
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				assert.Equal(t, "vimeo", info.Extractor)
+				assert.Equal(t, "Vimeo", info.ExtractorKey)
+				return
+			}
+			var extractorErr *ExtractorNotAllowedError
+			assert.ErrorAs(t, err, &extractorErr)
+			assert.Equal(t, "vimeo", extractorErr.Extractor)
+			assert.Nil(t, info)
+		})
+	}
+}
+
+func TestCheckExtractor_RejectsBeforeDownload(t *testing.T) {
+	d := newFakeDownloader(t, vimeoInfoScript)
+	d.config().AllowedExtractors = "youtube"
+
+	_, _, _, err := d.DownloadVideoToFile(context.Background(), "https://vimeo.com/1", VideoOptions{}, "")
+	var extractorErr *ExtractorNotAllowedError
+	assert.ErrorAs(t, err, &extractorErr)
+	entries, _ := os.ReadDir(d.config().DownloadDir)
+	assert.Empty(t, entries)
+
+	_, err = d.GetRawVideoInfo(context.Background(), "https://vimeo.com/1")
+	assert.ErrorAs(t, err, &extractorErr)
+}
+
+func TestCheckExtractor_CachedInfoRechecked(t *testing.T) {
+	d := newFakeDownloader(t, vimeoInfoScript)
+	d.infoCache = newInfoCache(time.Minute)
+	_, err := d.GetVideoInfo(context.Background(), "https://vimeo.com/1", "")
+	assert.NoError(t, err)
+
+	// The allowlist applies to info cached before it was set
+	d.config().AllowedExtractors = "youtube"
+	_, cached := d.infoCache.get("https://vimeo.com/1")
+	assert.True(t, cached)
+	_, err = d.GetVideoInfo(context.Background(), "https://vimeo.com/1", "")
+	var extractorErr *ExtractorNotAllowedError
+	assert.ErrorAs(t, err, &extractorErr)
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Strategies for naming a downloaded file when the name is already taken.
+const (
+	// OnExistsUnique prefixes every file with a timestamp, so names never collide.
+	OnExistsUnique = "unique"
+	// OnExistsOverwrite names the file after the video ID and replaces any existing file.
+	OnExistsOverwrite = "overwrite"
+	// OnExistsRename names the file after the video ID, adding " (1)", " (2)", ... when taken.
+	OnExistsRename = "rename"
+	// OnExistsError names the file after the video ID and fails when it is taken.
+	OnExistsError = "error"
+)
+
+// maxRenameAttempts bounds the search for a free " (n)" suffix.
+const maxRenameAttempts = 1000
+
+// ErrFileExists is returned when the target file exists and the strategy is OnExistsError.
+var ErrFileExists = errors.New("file already exists")
+
+// ValidateOnExists checks that onExists names a known strategy. An empty
+// value is valid and stands for the configured default.
+func ValidateOnExists(onExists string) error {
+	switch onExists {
+	case "", OnExistsUnique, OnExistsOverwrite, OnExistsRename, OnExistsError:
+		return nil
+	}
+	return fmt.Errorf("unknown onExists strategy %q, expected one of: %s, %s, %s, %s",
+		onExists, OnExistsUnique, OnExistsOverwrite, OnExistsRename, OnExistsError)
+}
+
+// prepareOutputPath picks the path a download of the video id is written to
+// in dir, applying the onExists strategy. With OnExistsOverwrite it removes
+// the existing file, since yt-dlp skips downloads whose output already exists.
+func prepareOutputPath(dir, id, ext, onExists string) (string, error) {
+	if onExists == "" || onExists == OnExistsUnique {
+		return filepath.Join(dir, fmt.Sprintf("%d-%s.%s", time.Now().UnixNano(), id, ext)), nil
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s.%s", id, ext))
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return path, nil
+	}
+
+	switch onExists {
+	case OnExistsOverwrite:
+		if err := os.Remove(path); err != nil {
+			return "", fmt.Errorf("failed to remove existing file %s: %w", path, err)
+		}
+		return path, nil
+	case OnExistsRename:
+		for n := 1; n <= maxRenameAttempts; n++ {
+			candidate := filepath.Join(dir, fmt.Sprintf("%s (%d).%s", id, n, ext))
+			if _, err := os.Stat(candidate); errors.Is(err, os.ErrNotExist) {
+				return candidate, nil
+			}
+		}
+		return "", fmt.Errorf("no free filename for %s after %d attempts", path, maxRenameAttempts)
+	case OnExistsError:
+		return "", fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(path))
+	}
+	return "", ValidateOnExists(onExists)
+}
+
+// dateDirLayout is the YYYY/MM/DD subfolder layout used with ORGANIZE_BY_DATE.
+const dateDirLayout = "2006/01/02"
+
+// Kinds of media downloads, which VIDEO_DIR and AUDIO_DIR can keep apart.
+const (
+	MediaVideo = "video"
+	MediaAudio = "audio"
+)
+
+// mediaDir returns the directory downloads of the kind are written to:
+// VIDEO_DIR or AUDIO_DIR, or the download directory when that is unset.
+// Other files (subtitles, info archives) go to the download directory.
+func (d *Downloader) mediaDir(kind string) string {
+	switch kind {
+	case MediaVideo:
+		return cmp.Or(d.config().VideoDir, d.config().DownloadDir)
+	case MediaAudio:
+		return cmp.Or(d.config().AudioDir, d.config().DownloadDir)
+	}
+	return d.config().DownloadDir
+}
+
+// outputDir returns the directory new downloads of the kind are written to:
+// its mediaDir, or today's YYYY/MM/DD subfolder of it when ORGANIZE_BY_DATE
+// is on, created as needed.
+func (d *Downloader) outputDir(kind string) (string, error) {
+	if !d.config().OrganizeByDate {
+		return d.mediaDir(kind), nil
+	}
+	dir := filepath.Join(d.mediaDir(kind), filepath.FromSlash(d.now().Format(dateDirLayout)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create dated download directory %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// outputPath picks the path a download of the kind of the video id is
+// written to, in outputDir. Name collisions are only checked within that
+// directory, so with ORGANIZE_BY_DATE the same name can exist once per day.
+func (d *Downloader) outputPath(kind, id, ext, onExists string) (string, error) {
+	dir, err := d.outputDir(kind)
+	if err != nil {
+		return "", err
+	}
+	return prepareOutputPath(dir, id, ext, d.onExists(onExists))
+}
+
+// ResolveDownload returns the path of the downloaded file called filename,
+// looked up in the download, video and audio directories in turn. Files at
+// the top of a directory win; otherwise, with ORGANIZE_BY_DATE, the most
+// recent dated subfolder holding it is used. When the file exists nowhere,
+// the top-level path in the download directory is returned so callers
+// report it as not found.
+func (d *Downloader) ResolveDownload(filename string) string {
+	dirs := d.config().MediaDirs()
+	path := filepath.Join(d.config().DownloadDir, filename)
+	for _, dir := range dirs {
+		if candidate := filepath.Join(dir, filename); fileExists(candidate) {
+			return candidate
+		}
+	}
+	if !d.config().OrganizeByDate {
+		return path
+	}
+	// Only plain names are looked up, so a pattern can't match other files
+	if filepath.Base(filename) != filename || strings.ContainsAny(filename, `*?[\`) {
+		return path
+	}
+	for _, dir := range dirs {
+		// Glob sorts its matches, so the newest date comes last
+		matches, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*", filename))
+		if len(matches) > 0 {
+			return matches[len(matches)-1]
+		}
+	}
+	return path
+}
+
+// fileExists reports whether path can be stat'ed.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// DownloadedFiles returns the paths of the files in the directory downloads
+// of the kind are written to, or in every download directory for an empty
+// kind, including those in dated subfolders when ORGANIZE_BY_DATE is on.
+// Storyboard sprites generated for the videos are left out.
+func (d *Downloader) DownloadedFiles(kind string) ([]string, error) {
+	dirs := d.config().MediaDirs()
+	if kind != "" {
+		dirs = []string{d.mediaDir(kind)}
+	}
+	var paths []string
+	for _, dir := range dirs {
+		files, err := filesIn(dir, d.config().OrganizeByDate)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !IsStoryboardSprite(file) {
+				paths = append(paths, file)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// filesIn returns the paths of the files in dir, and in its YYYY/MM/DD
+// subfolders when dated is set.
+func filesIn(dir string, dated bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if dated {
+		matches, err := filepath.Glob(filepath.Join(dir, "*", "*", "*", "*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// onExists returns the strategy to use for a request, falling back to the
+// configured default.
+func (d *Downloader) onExists(requested string) string {
+	if requested != "" {
+		return requested
+	}
+	return d.config().OnExists
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareOutputPath(t *testing.T) {
+	// newDir returns a download dir already holding abc123.mp4
+	newDir := func(t *testing.T) string {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "abc123.mp4"), []byte("old"), 0644))
+		return dir
+	}
+
+	t.Run("Unique", func(t *testing.T) {
+		dir := newDir(t)
+		path, err := prepareOutputPath(dir, "abc123", "mp4", OnExistsUnique)
+		assert.NoError(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^\d+-abc123\.mp4$`), filepath.Base(path))
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		dir := newDir(t)
+		path, err := prepareOutputPath(dir, "abc123", "mp4", OnExistsOverwrite)
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "abc123.mp4"), path)
+		assert.NoFileExists(t, path, "the old file must be removed so yt-dlp does not skip the download")
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		dir := newDir(t)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "abc123 (1).mp4"), []byte("old"), 0644))
+
+		path, err := prepareOutputPath(dir, "abc123", "mp4", OnExistsRename)
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "abc123 (2).mp4"), path)
+		assert.FileExists(t, filepath.Join(dir, "abc123.mp4"))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		dir := newDir(t)
+		_, err := prepareOutputPath(dir, "abc123", "mp4", OnExistsError)
+		assert.True(t, errors.Is(err, ErrFileExists))
+		assert.FileExists(t, filepath.Join(dir, "abc123.mp4"))
+	})
+
+	t.Run("NoCollision", func(t *testing.T) {
+		for _, onExists := range []string{OnExistsOverwrite, OnExistsRename, OnExistsError} {
+			path, err := prepareOutputPath(newDir(t), "def456", "mp4", onExists)
+			assert.NoError(t, err)
+			assert.Equal(t, "def456.mp4", filepath.Base(path))
+		}
+	})
+}
+
+func TestValidateOnExists(t *testing.T) {
+	for _, onExists := range []string{"", OnExistsUnique, OnExistsOverwrite, OnExistsRename, OnExistsError} {
+		assert.NoError(t, ValidateOnExists(onExists))
+	}
+	assert.Error(t, ValidateOnExists("skip"))
+}
+
+func TestOrganizeByDate(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude+`printf 'data' > "$out"
+`)
+	d.config().OrganizeByDate = true
+	d.config().OnExists = OnExistsOverwrite
+	d.now = func() time.Time { return time.Date(2026, time.March, 7, 23, 59, 0, 0, time.UTC) }
+
+	filePath, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(d.config().DownloadDir, "2026", "03", "07", "abc123.mp4"), filePath)
+	assert.FileExists(t, filePath)
+
+	// A day later the same video goes to a new folder and is resolved there
+	d.now = func() time.Time { return time.Date(2026, time.March, 8, 0, 1, 0, 0, time.UTC) }
+	newerPath, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(d.config().DownloadDir, "2026", "03", "08", "abc123.mp4"), newerPath)
+	assert.Equal(t, newerPath, d.ResolveDownload("abc123.mp4"))
+
+	files, err := d.DownloadedFiles("")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{filePath, newerPath}, files)
+}
+
+func TestResolveDownload(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude)
+	d.config().OrganizeByDate = true
+	dir := d.config().DownloadDir
+
+	dated := filepath.Join(dir, "2026", "01", "02")
+	assert.NoError(t, os.MkdirAll(dated, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dated, "a.mp4"), nil, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.mp4"), nil, 0644))
+
+	assert.Equal(t, filepath.Join(dated, "a.mp4"), d.ResolveDownload("a.mp4"))
+	assert.Equal(t, filepath.Join(dir, "b.mp4"), d.ResolveDownload("b.mp4"))
+	// Missing files and patterns resolve to the top level, where they don't exist
+	assert.Equal(t, filepath.Join(dir, "missing.mp4"), d.ResolveDownload("missing.mp4"))
+	assert.Equal(t, filepath.Join(dir, "*.mp4"), d.ResolveDownload("*.mp4"))
+
+	d.config().OrganizeByDate = false
+	assert.Equal(t, filepath.Join(dir, "a.mp4"), d.ResolveDownload("a.mp4"))
+}
+
+func TestMediaDirs(t *testing.T) {
+	d := newFakeDownloader(t, mediaScript)
+	videoDir, audioDir := t.TempDir(), t.TempDir()
+	d.config().VideoDir = videoDir
+	d.config().AudioDir = audioDir
+	ctx := context.Background()
+
+	videoPath, _, _, err := d.DownloadVideoToFile(ctx, "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, videoDir, filepath.Dir(videoPath))
+	audioPath, _, _, err := d.DownloadAudioToFile(ctx, "https://example.com/v", AudioOptions{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, audioDir, filepath.Dir(audioPath))
+
+	// Both are found by name, and listed by type
+	assert.Equal(t, videoPath, d.ResolveDownload(filepath.Base(videoPath)))
+	assert.Equal(t, audioPath, d.ResolveDownload(filepath.Base(audioPath)))
+	files, err := d.DownloadedFiles(MediaVideo)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{videoPath}, files)
+	files, err = d.DownloadedFiles(MediaAudio)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{audioPath}, files)
+	files, err = d.DownloadedFiles("")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{videoPath, audioPath}, files)
+}
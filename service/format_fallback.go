@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+// formatFailures are the yt-dlp messages of downloads that failed over the
+// format picked rather than the video, which another selector may avoid,
+// e.g. YouTube formats listed but not served to yt-dlp.
+var formatFailures = []string{
+	"Requested format is not available",
+	"HTTP Error 403",
+	"Did not get any data blocks",
+	"fragment 1 not found",
+}
+
+// formatFailure reports whether stderr shows a download that failed over
+// its format.
+func formatFailure(stderr string) bool {
+	for _, message := range formatFailures {
+		if strings.Contains(stderr, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSelectors returns the format selectors a video download tries, in
+// order: the options' own, then the FORMAT_FALLBACKS. StrictQuality asks
+// for the requested quality or nothing, and a FormatID for that exact
+// format, so neither gets fallbacks.
+func (d *Downloader) formatSelectors(opts VideoOptions) []string {
+	selectors := []string{opts.formatSelector()}
+	if opts.StrictQuality || opts.FormatID != "" {
+		return selectors
+	}
+	return append(selectors, d.config().FormatFallbackChain()...)
+}
+
+// runVideoDownload runs the yt-dlp video download of the arguments buildArgs
+// makes from format arguments, writing to outputPath. When the run fails
+// over its format, it is run again with each FORMAT_FALLBACKS selector in
+// turn until one succeeds; the fallback that did is reported as a warning.
+// It returns the output of the last run, with its warnings or error as
+// checkYTDLPRun judged them.
+func (d *Downloader) runVideoDownload(ctx context.Context, op string, opts VideoOptions, outputPath, progressID string, buildArgs func(formatArgs []string) []string) (stdout []byte, stderr string, warnings []string, ytdlpErr *YTDLPError) {
+	selectors := d.formatSelectors(opts)
+	for i, selector := range selectors {
+		if i > 0 {
+			slog.Warn("Video download failed over its format, trying the next selector", "selector", selector, "attempt", i+1, "attempts", len(selectors))
+			d.progressManager.SendEvent(ProgressEvent{
+				ID:         progressID,
+				Status:     "format_fallback",
+				Message:    fmt.Sprintf("Format unavailable, retrying with format %q...", selector),
+				Percentage: 25,
+			})
+		}
+
+		downloadArgs := buildArgs(opts.formatArgsFor(selector))
+		downloadCmd := exec.CommandContext(ctx, d.config().YTDLPPath, downloadArgs...)
+		slog.Debug(fmt.Sprintf("Executing yt-dlp for %s: %s %s", op, d.config().YTDLPPath, strings.Join(downloadArgs, " ")))
+
+		var downloadStdout, downloadStderr bytes.Buffer
+		downloadCmd.Stdout = &downloadStdout
+		downloadCmd.Stderr = &downloadStderr
+		runErr := downloadCmd.Run()
+		stdout, stderr = downloadStdout.Bytes(), downloadStderr.String()
+
+		warnings, ytdlpErr = checkYTDLPRun(ctx, op, runErr, stderr, outputPath)
+		if ytdlpErr == nil {
+			if i > 0 {
+				warning := fmt.Sprintf("requested format unavailable, downloaded with fallback format %q", selector)
+				slog.Warn("Video downloaded with a fallback format", "selector", selector)
+				d.progressManager.SendEvent(ProgressEvent{
+					ID:      progressID,
+					Status:  "warning",
+					Message: warning,
+				})
+				warnings = append(warnings, warning)
+			}
+			return stdout, stderr, warnings, nil
+		}
+		if ctx.Err() != nil || !formatFailure(stderr) {
+			break
+		}
+	}
+	return stdout, stderr, nil, ytdlpErr
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// formatFallbackScript fails over its format unless given one of the
+// $WORKING_FORMAT selector, which it writes to the output file, and logs
+// every selector it is given to $TRIED.
+const formatFallbackScript = fakeInfoPrelude + `
+format=""
+prev=""
+for a in "$@"; do
+	case "$prev" in --format) format="$a" ;; esac
+	prev="$a"
+done
+echo "$format" >> "$TRIED"
+if [ "$format" != "$WORKING_FORMAT" ]; then
+	echo "ERROR: [youtube] abc123: $FAILURE" >&2
+	exit 1
+fi
+printf '%s' "$format" > "$out"
+`
+
+func newFormatFallbackDownloader(t *testing.T, workingFormat, failure string) (*Downloader, string) {
+	t.Helper()
+	tried := t.TempDir() + "/tried"
+	t.Setenv("TRIED", tried)
+	t.Setenv("WORKING_FORMAT", workingFormat)
+	t.Setenv("FAILURE", failure)
+	d := newFakeDownloader(t, formatFallbackScript)
+	d.config().FormatFallbacks = "best; worst"
+	return d, tried
+}
+
+func triedFormats(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestDownloadVideoToFile_FormatFallback(t *testing.T) {
+	d, tried := newFormatFallbackDownloader(t, "worst", "Requested format is not available. Use --list-formats for a list of available formats")
+
+	// Buffered, so no event is dropped
+	d.progressManager = NewProgressManager(32)
+	events := make(chan []ProgressEvent)
+	ch := d.progressManager.RegisterClient("p1")
+	go func() { events <- drain(ch, 0) }()
+
+	filePath, _, warnings, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "p1")
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "worst", string(data))
+	assert.Equal(t, []string{"bestvideo[height<=720][vcodec*=avc1]+bestaudio/best", "best", "worst"}, triedFormats(t, tried))
+	assert.Equal(t, []string{`requested format unavailable, downloaded with fallback format "worst"`}, warnings)
+
+	var fallbacks []string
+	for _, event := range <-events {
+		if event.Status == "format_fallback" {
+			fallbacks = append(fallbacks, event.Message)
+		}
+	}
+	assert.Equal(t, []string{`Format unavailable, retrying with format "best"...`, `Format unavailable, retrying with format "worst"...`}, fallbacks)
+}
+
+func TestDownloadVideoToFile_FormatFallbackOnlyForFormatFailures(t *testing.T) {
+	// The video itself is the problem: no other format helps
+	d, tried := newFormatFallbackDownloader(t, "best", "Private video. Sign in if you've been granted access to this video")
+	_, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.ErrorContains(t, err, "Private video")
+	assert.Len(t, triedFormats(t, tried), 1)
+
+	// Strict quality asks for the requested format or nothing
+	d, tried = newFormatFallbackDownloader(t, "best", "Requested format is not available")
+	_, _, _, err = d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{StrictQuality: true}, "")
+	assert.ErrorContains(t, err, "Requested format is not available")
+	assert.Len(t, triedFormats(t, tried), 1)
+
+	// Every selector failing reports the last failure
+	d, tried = newFormatFallbackDownloader(t, "none", "HTTP Error 403: Forbidden")
+	_, _, _, err = d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.ErrorContains(t, err, "HTTP Error 403")
+	assert.Equal(t, []string{"bestvideo[height<=720][vcodec*=avc1]+bestaudio/best", "best", "worst"}, triedFormats(t, tried))
+}
+
+func TestDownloadVideoToFile_FormatID(t *testing.T) {
+	d, tried := newFormatFallbackDownloader(t, "137+140", "Requested format is not available")
+	filePath, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{FormatID: "137+140", Resolution: "1080"}, "")
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "137+140", string(data))
+	assert.Equal(t, []string{"137+140"}, triedFormats(t, tried))
+
+	// An explicit format gets no fallbacks
+	d, tried = newFormatFallbackDownloader(t, "best", "Requested format is not available")
+	_, _, _, err = d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{FormatID: "999"}, "")
+	assert.ErrorContains(t, err, "Requested format is not available")
+	assert.Equal(t, []string{"999"}, triedFormats(t, tried))
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// InfoResult is the outcome of one URL of a batch info lookup: its info, or
+// the error that prevented fetching it.
+type InfoResult struct {
+	URL       string
+	VideoInfo *VideoInfo
+	Err       error
+}
+
+// GetVideoInfoBatch fetches the info of several videos, running up to
+// INFO_BATCH_CONCURRENCY yt-dlp lookups at once. Cached info is reused as
+// for single lookups. A failing URL does not stop the others: its error is
+// reported in its result. Results are in the order of urls.
+func (d *Downloader) GetVideoInfoBatch(ctx context.Context, urls []string) []InfoResult {
+	results := make([]InfoResult, len(urls))
+	sem := make(chan struct{}, max(d.config().InfoBatchConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		results[i].URL = url
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			results[i].VideoInfo, results[i].Err = d.dumpInfo(ctx, url, "info dump")
+		}()
+	}
+	wg.Wait()
+	return results
+}
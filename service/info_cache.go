@@ -0,0 +1,117 @@
+package service
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// infoCacheSize is how many URLs the info cache holds. Each entry is a full
+// VideoInfo with all its formats, and any client can add one, so the cache
+// must not grow with the number of distinct URLs asked for.
+const infoCacheSize = 256
+
+// infoCache keeps yt-dlp --dump-json results per URL for a while, so that
+// looking a video up and then streaming it only runs the extraction once.
+// A zero or negative TTL disables the cache.
+type infoCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]infoCacheEntry
+}
+
+type infoCacheEntry struct {
+	info    VideoInfo
+	expires time.Time
+}
+
+func newInfoCache(ttl time.Duration) *infoCache {
+	return &infoCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]infoCacheEntry),
+	}
+}
+
+// enabled reports whether entries are kept at all.
+func (c *infoCache) enabled() bool {
+	return c.ttl > 0
+}
+
+// get returns a copy of the cached info for url, if present and not expired.
+// Callers are free to modify the returned info.
+func (c *infoCache) get(url string) (*VideoInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[url]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, url)
+		return nil, false
+	}
+	info := entry.info
+	info.Formats = slices.Clone(entry.info.Formats) // GetStreamInfo hands out pointers into Formats
+	return &info, true
+}
+
+// set stores a copy of info for url. Expired entries are dropped on the way,
+// and when the cache is full the entry closest to expiry, i.e. the oldest,
+// makes room.
+func (c *infoCache) set(url string, info *VideoInfo) {
+	if !c.enabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pruneLocked()
+	if _, ok := c.entries[url]; !ok && len(c.entries) >= infoCacheSize {
+		oldest := ""
+		for u, entry := range c.entries {
+			if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+				oldest = u
+			}
+		}
+		delete(c.entries, oldest)
+	}
+
+	entry := infoCacheEntry{info: *info, expires: c.now().Add(c.ttl)}
+	entry.info.Formats = slices.Clone(info.Formats)
+	c.entries[url] = entry
+}
+
+// prune removes expired entries and returns how many were removed and how
+// many remain.
+func (c *infoCache) prune() (removed, remaining int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pruneLocked(), len(c.entries)
+}
+
+// pruneLocked removes expired entries and returns how many it removed.
+// c.mu must be held.
+func (c *infoCache) pruneLocked() (removed int) {
+	now := c.now()
+	for url, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, url)
+			removed++
+		}
+	}
+	return removed
+}
+
+// clear removes every entry and returns how many there were.
+func (c *infoCache) clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := len(c.entries)
+	clear(c.entries)
+	return removed
+}
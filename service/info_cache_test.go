@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoCache_Expiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newInfoCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.set("u", &VideoInfo{ID: "abc123", Formats: []VideoInfo{{FormatID: "18"}}})
+
+	info, ok := c.get("u")
+	assert.True(t, ok)
+	assert.Equal(t, "abc123", info.ID)
+
+	// The cached entry must not change when a caller modifies its copy
+	info.Title = "changed"
+	info.Formats[0].Title = "changed"
+	info, _ = c.get("u")
+	assert.Empty(t, info.Title)
+	assert.Empty(t, info.Formats[0].Title)
+
+	now = now.Add(time.Minute)
+	_, ok = c.get("u")
+	assert.False(t, ok)
+}
+
+func TestInfoCache_Bounded(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newInfoCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	// Expired entries are swept when others are stored
+	c.set("expired", &VideoInfo{ID: "old"})
+	now = now.Add(time.Minute)
+	c.set("fresh", &VideoInfo{ID: "new"})
+	assert.Len(t, c.entries, 1)
+
+	// A full cache evicts its oldest entry
+	for i := range infoCacheSize + 10 {
+		now = now.Add(time.Millisecond)
+		c.set(fmt.Sprintf("u%d", i), &VideoInfo{ID: "abc123"})
+	}
+	assert.Len(t, c.entries, infoCacheSize)
+	_, ok := c.get("fresh")
+	assert.False(t, ok)
+	_, ok = c.get("u9")
+	assert.False(t, ok)
+	_, ok = c.get("u10")
+	assert.True(t, ok)
+
+	// Refreshing a cached URL evicts nothing
+	c.set("u10", &VideoInfo{ID: "abc123"})
+	_, ok = c.get("u11")
+	assert.True(t, ok)
+}
+
+func TestInfoCache_Disabled(t *testing.T) {
+	c := newInfoCache(0)
+	c.set("u", &VideoInfo{ID: "abc123"})
+	_, ok := c.get("u")
+	assert.False(t, ok)
+}
+
+func TestPrefetchInfo_PopulatesCache(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	d := newFakeDownloader(t, "#!/bin/sh\necho dump >> '"+calls+"'\n"+strings.TrimPrefix(fakeInfoPrelude, "#!/bin/sh\n"))
+	d.infoCache = newInfoCache(time.Minute)
+
+	d.PrefetchInfo("https://example.com/v")
+	assert.Eventually(t, func() bool {
+		_, ok := d.infoCache.get("https://example.com/v")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The following lookup is served from the cache without running yt-dlp again
+	info, err := d.GetVideoInfo(context.Background(), "https://example.com/v", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", info.ID)
+	data, _ := os.ReadFile(calls)
+	assert.Equal(t, 1, strings.Count(string(data), "dump"))
+}
+
+func TestInfoCache_PruneAndClear(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newInfoCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.set("old", &VideoInfo{ID: "old"})
+	now = now.Add(30 * time.Second)
+	c.set("new", &VideoInfo{ID: "new"})
+	now = now.Add(45 * time.Second) // "old" has expired, "new" has not
+
+	removed, remaining := c.prune()
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, 1, remaining)
+	_, ok := c.get("new")
+	assert.True(t, ok)
+
+	assert.Equal(t, 1, c.clear())
+	_, ok = c.get("new")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.clear())
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// lightInfoFields are the fields a light info lookup asks yt-dlp for: what a
+// list or preview shows, nothing that needs the formats resolved.
+var lightInfoFields = []string{
+	"id", "title", "original_url", "duration", "uploader", "upload_date", "thumbnail", "extractor", "extractor_key",
+}
+
+// lightInfoArgs prints only lightInfoFields, as JSON. --flat-playlist keeps
+// playlist entries from being resolved, and --ignore-no-formats-error lets
+// the lookup succeed when the formats cannot be, as they are not needed.
+var lightInfoArgs = []string{
+	"--skip-download",
+	"--flat-playlist",
+	"--ignore-no-formats-error",
+	"--print", "%(.{" + strings.Join(lightInfoFields, ",") + "})j",
+}
+
+// GetVideoInfoLight fetches only the title, duration, thumbnail and other
+// lightInfoFields of url, which is much faster than GetVideoInfo for sites
+// like YouTube where resolving every format is what takes time. Formats,
+// thumbnail sizes and subtitles are left empty, so downloads keep using
+// GetVideoInfo. Full info already cached is returned as is; light results
+// are never cached, so they cannot stand in for full info.
+func (d *Downloader) GetVideoInfoLight(ctx context.Context, url string) (*VideoInfo, error) {
+	if info, ok := d.infoCache.get(url); ok {
+		slog.Debug("Using cached video info for light lookup", "url", url)
+		if err := d.checkExtractor(info.Extractor, info.ExtractorKey); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+
+	raw, err := d.runInfo(ctx, url, "light info dump", lightInfoArgs...)
+	if err != nil {
+		return nil, err
+	}
+	var videoInfo VideoInfo
+	if err := json.Unmarshal(raw, &videoInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp light info json: %w", err)
+	}
+	if err := d.checkExtractor(videoInfo.Extractor, videoInfo.ExtractorKey); err != nil {
+		return nil, err
+	}
+	return &videoInfo, nil
+}
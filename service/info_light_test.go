@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lightInfoScript logs its args to $ARGS_LOG, then prints the requested
+// fields for --print, or the full info for --dump-json.
+const lightInfoScript = `#!/bin/sh
+echo "$@" >> "$ARGS_LOG"
+for a in "$@"; do
+	case "$a" in
+	--print) echo '{"id":"abc123","title":"Test Video","duration":42,"thumbnail":"https://example.com/t.jpg"}'; exit 0 ;;
+	--dump-json) echo '{"id":"abc123","title":"Test Video","duration":42,"formats":[{"format_id":"18"}]}'; exit 0 ;;
+	esac
+done
+exit 1
+`
+
+func TestGetVideoInfoLight(t *testing.T) {
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+	t.Setenv("ARGS_LOG", argsLog)
+	d := newFakeDownloader(t, lightInfoScript)
+
+	info, err := d.GetVideoInfoLight(context.Background(), "https://example.com/v")
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Video", info.Title)
+	assert.Equal(t, 42, info.Duration)
+	assert.Equal(t, "https://example.com/t.jpg", info.Thumbnail)
+	assert.Empty(t, info.Formats)
+
+	logged, err := os.ReadFile(argsLog)
+	assert.NoError(t, err)
+	args := string(logged)
+	for _, flag := range []string{"--skip-download", "--flat-playlist", "--ignore-no-formats-error", "--print %(.{id,title,"} {
+		assert.Contains(t, args, flag)
+	}
+	assert.NotContains(t, args, "--dump-json")
+}
+
+func TestGetVideoInfoLight_FullFetchUnchanged(t *testing.T) {
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+	t.Setenv("ARGS_LOG", argsLog)
+	d := newFakeDownloader(t, lightInfoScript)
+
+	info, err := d.GetVideoInfo(context.Background(), "https://example.com/v", "")
+	assert.NoError(t, err)
+	assert.Len(t, info.Formats, 1)
+
+	logged, err := os.ReadFile(argsLog)
+	assert.NoError(t, err)
+	assert.Contains(t, string(logged), "--dump-json")
+	assert.False(t, strings.Contains(string(logged), "--print") || strings.Contains(string(logged), "--skip-download"))
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooManyStreams is returned when MAX_CONCURRENT_STREAMS streams are
+	// already running.
+	ErrTooManyStreams = errors.New("too many concurrent streams, try again later")
+	// ErrTooManyDownloads is returned when MAX_CONCURRENT_DOWNLOADS file
+	// downloads are already running.
+	ErrTooManyDownloads = errors.New("too many concurrent downloads, try again later")
+)
+
+// limiter caps how many operations of one kind run at once. Streams and
+// file downloads each have their own, since long-lived streams would
+// otherwise starve downloads and the other way round. A nil limiter, or one
+// with a max of 0 or less, does not limit anything.
+type limiter struct {
+	mu      sync.Mutex
+	running int
+	max     int
+	wait    time.Duration // How long acquire waits for a slot, 0 for not at all
+	freed   chan struct{} // Closed, and replaced, whenever a slot may have freed up
+	err     error         // Returned when all slots are taken
+}
+
+// newLimiter returns a limiter allowing max operations at once, 0 or less
+// meaning no limit, whose acquire waits up to wait for a slot.
+func newLimiter(max int, wait time.Duration, err error) *limiter {
+	return &limiter{max: max, wait: wait, freed: make(chan struct{}), err: err}
+}
+
+// setLimits changes how many operations may run at once and how long new
+// ones wait for a slot. Operations already running keep their slot, so
+// lowering the max only refuses new ones until enough of them finish.
+func (l *limiter) setLimits(max int, wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = max
+	l.wait = wait
+	l.notifyLocked() // A higher max frees slots for waiting operations
+}
+
+// notifyLocked wakes the operations waiting for a slot. l.mu must be held.
+func (l *limiter) notifyLocked() {
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// waitForSlotKey marks a context whose operations wait for a slot as long as
+// it takes; see waitForSlot.
+type waitForSlotKey struct{}
+
+// waitForSlot returns a copy of ctx under which acquire waits for a slot
+// until one frees up or ctx is done, instead of up to the limiter's wait.
+// It suits background work that has no client to retry it.
+func waitForSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, waitForSlotKey{}, true)
+}
+
+// acquire takes a slot and returns the function giving it back, which may
+// be called more than once. When every slot is taken, it waits up to the
+// limiter's wait for one to free up, then fails with the limiter's error;
+// it fails with ctx's error if ctx is done first. Under waitForSlot it
+// waits without a time limit.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	unbounded, _ := ctx.Value(waitForSlotKey{}).(bool)
+	var timeout <-chan time.Time
+	for {
+		l.mu.Lock()
+		if l.max <= 0 || l.running < l.max {
+			l.running++
+			l.mu.Unlock()
+			return l.releaseFunc(), nil
+		}
+		wait, freed := l.wait, l.freed
+		l.mu.Unlock()
+
+		if unbounded {
+			select {
+			case <-freed:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if wait <= 0 {
+			return nil, l.err
+		}
+		if timeout == nil {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-freed:
+		case <-timeout:
+			return nil, l.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// releaseFunc returns the function giving back a slot taken by acquire.
+func (l *limiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.running--
+			l.notifyLocked()
+			l.mu.Unlock()
+		})
+	}
+}
+
+// load returns how many operations are running and the max allowed; a nil
+// limiter has no max.
+func (l *limiter) load() (running, max int) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.running, l.max
+}
+
+// StreamLoad returns how many streams are running, and
+// MAX_CONCURRENT_STREAMS (0 for no limit).
+func (d *Downloader) StreamLoad() (running, max int) {
+	return d.streamLimit.load()
+}
+
+// DownloadLoad returns how many file downloads are running, and
+// MAX_CONCURRENT_DOWNLOADS (0 for no limit).
+func (d *Downloader) DownloadLoad() (running, max int) {
+	return d.downloadLimit.load()
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gostreampuller/config"
+)
+
+// mediaScript writes "media" to stdout for streams and to the output file
+// for downloads.
+const mediaScript = fakeInfoPrelude + `
+if [ "$out" = "-" ]; then printf 'media'; else printf 'media' > "$out"; fi
+`
+
+func TestLimiter(t *testing.T) {
+	var unlimited *limiter
+	release, err := unlimited.acquire(context.Background())
+	assert.NoError(t, err)
+	release()
+
+	l := newLimiter(1, 0, ErrTooManyStreams)
+	release, err = l.acquire(context.Background())
+	assert.NoError(t, err)
+	_, err = l.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+
+	release()
+	release() // Giving a slot back twice must not free another one
+	_, err = l.acquire(context.Background())
+	assert.NoError(t, err)
+	_, err = l.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+}
+
+func TestLimiter_SetMax(t *testing.T) {
+	l := newLimiter(0, 0, ErrTooManyStreams)
+	first, err := l.acquire(context.Background())
+	assert.NoError(t, err)
+	second, err := l.acquire(context.Background())
+	assert.NoError(t, err)
+
+	// Lowering the max keeps running operations, but refuses new ones until
+	// enough of them finish
+	l.setLimits(1, 0)
+	_, err = l.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+	first()
+	_, err = l.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+	second()
+	_, err = l.acquire(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	l := newLimiter(1, time.Second, ErrTooManyDownloads)
+	release, err := l.acquire(context.Background())
+	assert.NoError(t, err)
+
+	// A waiting operation gets the slot once it frees up
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	start := time.Now()
+	release, err = l.acquire(context.Background())
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// ... gives up once the wait is over
+	l.setLimits(1, 20*time.Millisecond)
+	_, err = l.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyDownloads)
+
+	// ... or when its context is done
+	l.setLimits(1, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Raising the max lets waiting operations in
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.setLimits(2, time.Minute)
+	}()
+	_, err = l.acquire(context.Background())
+	assert.NoError(t, err)
+	release()
+}
+
+func TestLimiter_WaitForSlot(t *testing.T) {
+	l := newLimiter(1, 0, ErrTooManyDownloads)
+	release, err := l.acquire(context.Background())
+	assert.NoError(t, err)
+
+	// Without a wait, only operations under waitForSlot hold on for the slot
+	_, err = l.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrTooManyDownloads)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	release, err = l.acquire(waitForSlot(context.Background()))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(waitForSlot(context.Background()), 20*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	release()
+}
+
+func TestConcurrencyLimits_Independent(t *testing.T) {
+	d := newFakeDownloader(t, mediaScript)
+	d.streamLimit = newLimiter(1, 0, ErrTooManyStreams)
+	d.downloadLimit = newLimiter(1, 0, ErrTooManyDownloads)
+	ctx := context.Background()
+
+	// A running stream blocks other streams, but not downloads
+	stream, _, err := d.StreamVideo(ctx, "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	_, _, err = d.StreamAudio(ctx, "https://example.com/v", "", "", "", "")
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+	_, _, _, err = d.DownloadVideoToFile(ctx, "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+
+	// Closing the stream frees its slot
+	io.Copy(io.Discard, stream)
+	assert.NoError(t, stream.Close())
+
+	// A running download blocks other downloads, but not streams
+	release, err := d.downloadLimit.acquire(context.Background())
+	assert.NoError(t, err)
+	defer release()
+	_, _, _, err = d.DownloadAudioToFile(ctx, "https://example.com/v", AudioOptions{}, "")
+	assert.ErrorIs(t, err, ErrTooManyDownloads)
+	_, err = d.DownloadVideoToTempFile(ctx, "https://example.com/v", VideoOptions{}, "")
+	assert.ErrorIs(t, err, ErrTooManyDownloads)
+	stream, _, err = d.StreamAudio(ctx, "https://example.com/v", "", "", "", "")
+	assert.NoError(t, err)
+	stream.Close()
+}
+
+func TestUpdateConfig_ReloadsLimits(t *testing.T) {
+	d := newFakeDownloader(t, mediaScript)
+	ctx := context.Background()
+	reloaded := func(change func(*config.Config)) {
+		cfg := *d.Config()
+		change(&cfg)
+		d.UpdateConfig(&cfg)
+	}
+
+	reloaded(func(c *config.Config) { c.MaxConcurrentStreams = 1 })
+	stream, _, err := d.StreamVideo(ctx, "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	defer stream.Close()
+	_, _, err = d.StreamVideo(ctx, "https://example.com/v", VideoOptions{}, "")
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+
+	// Raising the limit lets a second stream in next to the running one
+	reloaded(func(c *config.Config) { c.MaxConcurrentStreams = 2 })
+	second, _, err := d.StreamVideo(ctx, "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	second.Close()
+
+	// Settings read per request follow the reload too
+	reloaded(func(c *config.Config) { c.MaxDuration = 10 * time.Second })
+	_, _, _, err = d.DownloadVideoToFile(ctx, "https://example.com/v", VideoOptions{}, "")
+	var limitErr *DurationLimitError
+	assert.ErrorAs(t, err, &limitErr)
+}
@@ -0,0 +1,554 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VideoOptions holds the per-request settings for video downloads and streams.
+type VideoOptions struct {
+	Format     string // Output container, e.g. "mp4"
+	Resolution string // Maximum height, e.g. "720"
+	Codec      string // Preferred video codec, e.g. "avc1"
+	// BurnSubtitles draws the SubtitleLang subtitles onto the picture with
+	// ffmpeg. Only applies to file downloads, not live streams.
+	BurnSubtitles bool
+	// SubtitleLang is a comma-separated language preference chain, e.g.
+	// "en, en-US, auto". "auto" stands for the video's own language.
+	SubtitleLang string
+	// StrictFormat fails the download when recoding to Format fails, instead
+	// of keeping the originally downloaded file.
+	StrictFormat bool
+	// StrictQuality fails the download when the video has no format with the
+	// requested Resolution and Codec, instead of using the closest one.
+	StrictQuality bool
+	// Progressive picks a single pre-muxed audio+video file instead of
+	// merging separate streams, so ffmpeg never has to merge anything.
+	Progressive bool
+	// FormatSort is passed to yt-dlp's --format-sort, e.g. "res,fps,codec:av01",
+	// to rank the formats the selector matches. See ValidateFormatSort.
+	FormatSort string
+	// FormatID is a literal yt-dlp format selector, e.g. "137+140" from the
+	// format listing, passed as --format instead of the one built from
+	// Resolution, Codec and Progressive. See ValidateFormatID.
+	FormatID string
+	// OnExists overrides the configured filename collision strategy for file
+	// downloads; see OnExistsUnique and friends.
+	OnExists string
+	// AllAudioLanguages keeps the best audio track of every language the
+	// video offers, muxed into one file (mkv unless Format says otherwise).
+	// Only applies to file downloads. See ValidateMultiAudio.
+	AllAudioLanguages bool
+	// Watermark overlays an image from ASSETS_DIR on the picture, in the
+	// WatermarkPosition corner (bottom-right by default). Only applies to
+	// file downloads. See Downloader.ValidateWatermark.
+	Watermark         string
+	WatermarkPosition string
+	// TargetSizeMB re-encodes the video in two passes at the bitrate making
+	// it about that many MB, e.g. to fit an upload limit. Only applies to
+	// file downloads. Zero keeps the downloaded size.
+	TargetSizeMB int
+
+	// audioLanguages are the languages selected for AllAudioLanguages,
+	// filled in from the video's formats once they are known.
+	audioLanguages []string
+}
+
+// withDefaults returns a copy of the options with empty fields filled in.
+func (o VideoOptions) withDefaults() VideoOptions {
+	if o.Format == "" {
+		o.Format = "mp4"
+		if o.AllAudioLanguages {
+			o.Format = "mkv"
+		}
+	}
+	if o.Resolution == "" {
+		o.Resolution = "720"
+	}
+	if o.Codec == "" {
+		o.Codec = "avc1"
+		if o.Format == "webm" {
+			o.Codec = "vp9" // webm cannot hold H.264
+		}
+	}
+	if o.SubtitleLang == "" {
+		o.SubtitleLang = "en"
+	}
+	return o
+}
+
+// formatSortFields are the sort keys yt-dlp's --format-sort understands.
+var formatSortFields = map[string]bool{
+	"hasvid": true, "hasaud": true, "ie_pref": true, "lang": true, "quality": true,
+	"source": true, "proto": true, "vcodec": true, "acodec": true, "codec": true,
+	"vext": true, "aext": true, "ext": true, "filesize": true, "fs_approx": true,
+	"size": true, "height": true, "width": true, "res": true, "fps": true,
+	"hdr": true, "channels": true, "tbr": true, "vbr": true, "abr": true,
+	"br": true, "asr": true, "id": true,
+}
+
+// ValidateFormatSort checks that every field of a comma-separated
+// --format-sort value is a known yt-dlp sort key. Fields may carry a leading
+// "+" (reverse) and a ":value" or "~value" suffix. An empty value is valid.
+func ValidateFormatSort(sort string) error {
+	if sort == "" {
+		return nil
+	}
+	for _, field := range strings.Split(sort, ",") {
+		key := strings.TrimPrefix(strings.TrimSpace(field), "+")
+		if i := strings.IndexAny(key, ":~"); i >= 0 {
+			key = key[:i]
+		}
+		if !formatSortFields[key] {
+			return fmt.Errorf("unknown format sort field %q", strings.TrimSpace(field))
+		}
+	}
+	return nil
+}
+
+// formatIDPattern is what a FormatID may be made of: format IDs, the
+// "+", "/" and "," operators and simple [key=value] filters. Shell
+// metacharacters, whitespace and comparisons are ruled out.
+var formatIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:+/,=\[\]-]+$`)
+
+// ValidateFormatID checks that a literal format selector is safe to hand to
+// yt-dlp. An empty selector is valid.
+func ValidateFormatID(formatID string) error {
+	if formatID == "" {
+		return nil
+	}
+	if len(formatID) > 200 || !formatIDPattern.MatchString(formatID) {
+		return fmt.Errorf("invalid format ID %q, expected yt-dlp format IDs joined by +, / or ,", formatID)
+	}
+	if strings.HasPrefix(formatID, "-") {
+		return fmt.Errorf("invalid format ID %q, it cannot start with -", formatID)
+	}
+	return nil
+}
+
+// formatArgs builds the yt-dlp format selection arguments for the options.
+func (o VideoOptions) formatArgs() []string {
+	return o.formatArgsFor(o.formatSelector())
+}
+
+// formatArgsFor is formatArgs with another format selector, for the
+// FORMAT_FALLBACKS.
+func (o VideoOptions) formatArgsFor(selector string) []string {
+	args := []string{"--format", selector}
+	if o.FormatSort != "" {
+		args = append(args, "--format-sort", o.FormatSort)
+	}
+	if o.AllAudioLanguages {
+		args = append(args, "--audio-multistreams", "--merge-output-format", o.Format)
+	}
+	return args
+}
+
+// formatSelector builds the yt-dlp --format value for the options.
+func (o VideoOptions) formatSelector() string {
+	if o.FormatID != "" {
+		return o.FormatID
+	}
+	if o.Progressive {
+		selector := fmt.Sprintf("best[ext=%s][height<=%s]", o.Format, o.Resolution)
+		if !o.StrictFormat {
+			// Still progressive, just not necessarily in the requested container/height
+			selector += fmt.Sprintf("/best[height<=%s]/best", o.Resolution)
+		}
+		return selector
+	}
+	video := fmt.Sprintf("bestvideo[height<=%s][vcodec*=%s]", o.Resolution, o.Codec)
+	if o.Format == "webm" && !o.AllAudioLanguages {
+		// webm only holds Opus or Vorbis audio, so prefer it to need no re-encoding
+		return video + "+bestaudio[acodec=opus]/" + video + "+bestaudio/best"
+	}
+	if o.AllAudioLanguages && len(o.audioLanguages) > 0 {
+		selector := video
+		for _, lang := range o.audioLanguages {
+			selector += fmt.Sprintf("+bestaudio[language=%s]", lang)
+		}
+		return selector + "/" + video + "+bestaudio/best"
+	}
+	return video + "+bestaudio/best"
+}
+
+// streamContainers maps the containers a live stream can be muxed into to
+// their Content-Type.
+var streamContainers = map[string]string{
+	"mp4":  "video/mp4",
+	"webm": "video/webm",
+	"mkv":  "video/x-matroska",
+}
+
+// webmCodecs are the video codecs a webm container can hold.
+var webmCodecs = []string{"vp8", "vp9", "av01"}
+
+// ValidateStreamContainer checks that a live stream can be muxed into
+// format with the codec. An empty format stands for mp4 and an empty codec
+// for the container's default.
+func ValidateStreamContainer(format, codec string) error {
+	if format == "" {
+		return nil
+	}
+	if _, ok := streamContainers[format]; !ok {
+		return fmt.Errorf("unsupported stream format %q, expected mp4, webm or mkv", format)
+	}
+	if format == "webm" && codec != "" && !slices.ContainsFunc(webmCodecs, func(c string) bool { return strings.HasPrefix(codec, c) }) {
+		return fmt.Errorf("codec %q cannot be streamed as webm, use vp8, vp9 or av01", codec)
+	}
+	return nil
+}
+
+// StreamContentType returns the Content-Type of a live stream muxed into
+// format, mp4 when it is empty.
+func StreamContentType(format string) string {
+	if contentType, ok := streamContainers[format]; ok {
+		return contentType
+	}
+	return streamContainers["mp4"]
+}
+
+// streamContainerArgs returns the yt-dlp arguments muxing a live stream
+// into o.Format on the fly. mp4 is what yt-dlp produces by default.
+func (o VideoOptions) streamContainerArgs() []string {
+	if o.Format == "" || o.Format == "mp4" {
+		return nil
+	}
+	return []string{"--merge-output-format", o.Format}
+}
+
+// multiAudioContainers are the output containers that can hold more than
+// one audio track.
+var multiAudioContainers = map[string]bool{"mkv": true, "mp4": true, "mov": true}
+
+// ValidateMultiAudio checks that a download asking for all audio languages
+// can hold them: progressive formats carry a single track, and so do most
+// containers. An empty format is valid, it defaults to mkv.
+func ValidateMultiAudio(format string, progressive bool) error {
+	if progressive {
+		return errors.New("all audio languages cannot be combined with a progressive format")
+	}
+	if format != "" && !multiAudioContainers[format] {
+		return fmt.Errorf("format %q cannot hold multiple audio tracks, use mkv, mp4 or mov", format)
+	}
+	return nil
+}
+
+// audioLanguages returns the distinct languages of the audio-only formats,
+// sorted. Formats whose language the extractor does not know are skipped.
+func audioLanguages(formats []VideoInfo) []string {
+	var langs []string
+	for _, f := range formats {
+		if f.VCodec != "none" || f.ACodec == "none" || f.ACodec == "" || f.Language == "" {
+			continue
+		}
+		if !slices.Contains(langs, f.Language) {
+			langs = append(langs, f.Language)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// hasProgressiveFormat reports whether formats contains a pre-muxed file in
+// the requested container at or below the requested height.
+func (o VideoOptions) hasProgressiveFormat(formats []VideoInfo) bool {
+	maxHeight, err := strconv.Atoi(o.Resolution)
+	if err != nil {
+		return false
+	}
+	for _, f := range formats {
+		if f.VCodec == "none" || f.ACodec == "none" || f.VCodec == "" || f.ACodec == "" {
+			continue
+		}
+		if f.Ext == o.Format && f.Height <= maxHeight {
+			return true
+		}
+	}
+	return false
+}
+
+// podcastLoudnorm normalizes loudness to -16 LUFS, the usual target for
+// spoken-word podcasts.
+const podcastLoudnorm = "loudnorm=I=-16:TP=-1.5:LRA=11"
+
+// AudioOptions holds the per-request settings for audio downloads.
+type AudioOptions struct {
+	Format   string // Output container, e.g. "mp3"
+	Codec    string // ffmpeg audio encoder, e.g. "libmp3lame"
+	Bitrate  string // Target bitrate, e.g. "128k"
+	Channels int    // Output channel count; 0 keeps the source layout
+	// Normalize applies EBU R128 loudness normalization.
+	Normalize bool
+	// EmbedMetadata writes tags (ID3 for mp3), chapters and the thumbnail
+	// as cover art into the file.
+	EmbedMetadata bool
+	// Podcast fills unset fields with podcast-friendly values: 64k mono mp3,
+	// normalized, with metadata. Explicit Format, Codec, Bitrate and Channels
+	// still win.
+	Podcast bool
+	// OnExists overrides the configured filename collision strategy for file
+	// downloads; see OnExistsUnique and friends.
+	OnExists string
+	// TrimSilence strips leading and trailing silence. Audio quieter than
+	// SilenceThreshold (e.g. "-50dB") for at least SilenceDuration seconds
+	// (e.g. "0.5") counts as silence.
+	TrimSilence      bool
+	SilenceThreshold string
+	SilenceDuration  string
+	// VolumeDB raises (positive) or lowers (negative) the volume by a fixed
+	// gain, for when full loudness normalization is not wanted. 0 leaves it.
+	VolumeDB float64
+	// SampleFormat exports uncompressed PCM in a wav file with that sample
+	// format, e.g. "s24le" for 24-bit, for audio-processing clients. See
+	// sampleFormats. It picks the pcm_ encoder itself, so Format must be
+	// empty or wav and Codec empty or that encoder.
+	SampleFormat string
+}
+
+// sampleFormats are the PCM sample formats a wav export can be written in:
+// signed 16-bit, signed 24-bit and 32-bit float, all little-endian.
+var sampleFormats = []string{"s16le", "s24le", "f32le"}
+
+// Allowed range for AudioOptions.VolumeDB. Beyond it audio is either
+// inaudible or hopelessly clipped.
+const (
+	minVolumeDB = -30
+	maxVolumeDB = 30
+)
+
+var (
+	silenceThresholdPattern = regexp.MustCompile(`^-?\d+(\.\d+)?(dB)?$`)
+	silenceDurationPattern  = regexp.MustCompile(`^\d+(\.\d+)?$`)
+)
+
+// Validate checks the fields that end up inside the ffmpeg filter chain, so
+// a client cannot smuggle extra filters or ffmpeg options through them.
+func (o AudioOptions) Validate() error {
+	if o.SilenceThreshold != "" && !silenceThresholdPattern.MatchString(o.SilenceThreshold) {
+		return fmt.Errorf("invalid silence threshold %q, expected a level such as -50dB", o.SilenceThreshold)
+	}
+	if o.SilenceDuration != "" && !silenceDurationPattern.MatchString(o.SilenceDuration) {
+		return fmt.Errorf("invalid silence duration %q, expected seconds such as 0.5", o.SilenceDuration)
+	}
+	if o.VolumeDB < minVolumeDB || o.VolumeDB > maxVolumeDB {
+		return fmt.Errorf("invalid volume %gdB, expected between %ddB and %ddB", o.VolumeDB, minVolumeDB, maxVolumeDB)
+	}
+	if err := o.validateSampleFormat(); err != nil {
+		return err
+	}
+	return validateAudioCodec(o.Format, o.Codec)
+}
+
+// validateSampleFormat checks that SampleFormat is a known PCM sample format
+// and that the format and codec asked for, if any, are its wav and pcm_
+// encoder. An empty SampleFormat is valid.
+func (o AudioOptions) validateSampleFormat() error {
+	if o.SampleFormat == "" {
+		return nil
+	}
+	if !slices.Contains(sampleFormats, o.SampleFormat) {
+		return fmt.Errorf("invalid sample format %q, expected one of: %s", o.SampleFormat, strings.Join(sampleFormats, ", "))
+	}
+	if o.Format != "" && o.Format != "wav" {
+		return fmt.Errorf("sample format %q only applies to wav exports, not %q", o.SampleFormat, o.Format)
+	}
+	if o.Codec != "" && o.Codec != "pcm_"+o.SampleFormat {
+		return fmt.Errorf("codec %q does not match sample format %q, leave it empty or use pcm_%s", o.Codec, o.SampleFormat, o.SampleFormat)
+	}
+	return nil
+}
+
+// audioFormatCodecs lists the ffmpeg encoders each audio format can hold.
+// Any other pairing makes yt-dlp's post-processor fail with an unhelpful
+// ffmpeg error, so it is rejected up front.
+var audioFormatCodecs = map[string][]string{
+	"mp3":    {"libmp3lame", "libshine"},
+	"aac":    {"aac", "libfdk_aac"},
+	"m4a":    {"aac", "libfdk_aac", "alac"},
+	"opus":   {"libopus", "opus"},
+	"vorbis": {"libvorbis", "vorbis"},
+	"flac":   {"flac"},
+	"alac":   {"alac"},
+	"wav":    {"pcm_s16le", "pcm_s24le", "pcm_s32le", "pcm_f32le", "pcm_u8"},
+}
+
+// validateAudioCodec checks that codec can be written into the audio format.
+// An empty codec picks the format's default and is always valid; formats
+// missing from audioFormatCodecs are left for yt-dlp to judge.
+func validateAudioCodec(format, codec string) error {
+	if codec == "" {
+		return nil
+	}
+	if format == "" {
+		format = "mp3"
+	}
+	codecs, ok := audioFormatCodecs[format]
+	if !ok || slices.Contains(codecs, codec) {
+		return nil
+	}
+	return fmt.Errorf("codec %q cannot be used with format %q, valid codecs: %s", codec, format, strings.Join(codecs, ", "))
+}
+
+// audioFormatDefault is the encoder and bitrate an audio format gets when the
+// request leaves them unset.
+type audioFormatDefault struct {
+	codec   string
+	bitrate string // Empty for lossless formats
+}
+
+// audioFormatDefaults maps yt-dlp's --audio-format values to their defaults.
+// Formats missing from the table get the mp3 defaults.
+var audioFormatDefaults = map[string]audioFormatDefault{
+	"mp3":    {codec: "libmp3lame", bitrate: "128k"},
+	"aac":    {codec: "aac", bitrate: "128k"},
+	"m4a":    {codec: "aac", bitrate: "128k"},
+	"opus":   {codec: "libopus", bitrate: "96k"}, // Opus is transparent at lower bitrates
+	"vorbis": {codec: "libvorbis", bitrate: "160k"},
+	"flac":   {codec: "flac"},
+	"alac":   {codec: "alac"},
+	"wav":    {codec: "pcm_s16le"},
+}
+
+// withDefaults returns a copy of the options with empty fields filled in,
+// applying the podcast preset first when requested.
+func (o AudioOptions) withDefaults() AudioOptions {
+	if o.Podcast {
+		if o.Bitrate == "" {
+			o.Bitrate = "64k"
+		}
+		if o.Channels == 0 {
+			o.Channels = 1
+		}
+		o.Normalize = true
+		o.EmbedMetadata = true
+	}
+	if o.SampleFormat != "" {
+		o.Format = "wav"
+		o.Codec = "pcm_" + o.SampleFormat
+	}
+	if o.Format == "" {
+		o.Format = "mp3"
+	}
+	defaults, ok := audioFormatDefaults[o.Format]
+	if !ok {
+		defaults = audioFormatDefaults["mp3"]
+	}
+	if o.Codec == "" {
+		o.Codec = defaults.codec
+	}
+	if defaults.bitrate == "" {
+		o.Bitrate = "" // Lossless, a bitrate means nothing
+	} else if o.Bitrate == "" {
+		o.Bitrate = defaults.bitrate
+	}
+	if o.SilenceThreshold == "" {
+		o.SilenceThreshold = "-50dB"
+	}
+	if o.SilenceDuration == "" {
+		o.SilenceDuration = "0.5"
+	}
+	return o
+}
+
+// EffectiveBitrate returns the bitrate the audio is encoded at once
+// defaults apply, e.g. "128k", or "" for lossless formats.
+func (o AudioOptions) EffectiveBitrate() string {
+	return o.withDefaults().Bitrate
+}
+
+// audioFilters returns the ffmpeg audio filters for the options, in the
+// order they must run: silence is trimmed before the gain is applied and
+// loudness is measured.
+func (o AudioOptions) audioFilters() []string {
+	var filters []string
+	if o.TrimSilence {
+		// silenceremove only trims reliably from the start, so the trailing
+		// silence is removed by trimming the start of the reversed audio.
+		trim := fmt.Sprintf("silenceremove=start_periods=1:start_duration=%s:start_threshold=%s", o.SilenceDuration, o.SilenceThreshold)
+		filters = append(filters, trim, "areverse", trim, "areverse")
+	}
+	if o.VolumeDB != 0 {
+		filters = append(filters, "volume="+strconv.FormatFloat(o.VolumeDB, 'f', -1, 64)+"dB")
+	}
+	if o.Normalize {
+		filters = append(filters, podcastLoudnorm)
+	}
+	return filters
+}
+
+// audioArgs builds the yt-dlp arguments that extract and encode the audio.
+// The encoder arguments are scoped to the ExtractAudio post-processor so
+// they do not leak into the metadata step, which only copies streams.
+func (o AudioOptions) audioArgs() []string {
+	encoderArgs := "-acodec " + o.Codec
+	if o.Channels > 0 {
+		encoderArgs += " -ac " + strconv.Itoa(o.Channels)
+	}
+	// ffmpeg only honours the last -af, so all filters go in a single chain
+	if filters := o.audioFilters(); len(filters) > 0 {
+		encoderArgs += " -af " + strings.Join(filters, ",")
+	}
+
+	args := []string{
+		"--extract-audio",
+		"--audio-format", o.Format,
+	}
+	if o.Bitrate != "" {
+		args = append(args, "--audio-quality", o.Bitrate) // Corresponds to bitrate for audio quality
+	}
+	args = append(args, "--postprocessor-args", "ExtractAudio:"+encoderArgs)
+	if o.EmbedMetadata {
+		args = append(args,
+			"--embed-metadata",
+			"--embed-chapters",
+			"--embed-thumbnail",
+		)
+	}
+	return args
+}
+
+// burnSubtitlesArgs builds the ffmpeg arguments that re-encode input with the
+// subtitles file drawn onto the picture, copying the audio stream as-is.
+func burnSubtitlesArgs(input, subtitles, output string) []string {
+	return []string{
+		"-y",
+		"-i", input,
+		"-vf", "subtitles=" + escapeFilterPath(subtitles),
+		"-c:a", "copy",
+		output,
+	}
+}
+
+// escapeFilterPath escapes the characters ffmpeg treats specially inside a
+// filtergraph so a file path can be used as a filter option value.
+func escapeFilterPath(path string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `\'`,
+		`:`, `\:`,
+		`,`, `\,`,
+		`;`, `\;`,
+		`[`, `\[`,
+		`]`, `\]`,
+	).Replace(path)
+}
+
+// CapResolution returns the resolution to request when videos may be at
+// most max pixels high: resolution itself when it is a height within max,
+// or else max. An empty resolution, meaning the best available, is capped
+// too. A max of 0 or less leaves resolution unchanged.
+func CapResolution(resolution string, max int) string {
+	if max <= 0 {
+		return resolution
+	}
+	if height, err := strconv.Atoi(resolution); err == nil && height > 0 && height <= max {
+		return resolution
+	}
+	return strconv.Itoa(max)
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVideoOptions_FormatSelector(t *testing.T) {
+	tests := []struct {
+		name string
+		opts VideoOptions
+		want string
+	}{
+		{
+			name: "Merged",
+			opts: VideoOptions{},
+			want: "bestvideo[height<=720][vcodec*=avc1]+bestaudio/best",
+		},
+		{
+			name: "Progressive",
+			opts: VideoOptions{Progressive: true, Resolution: "480"},
+			want: "best[ext=mp4][height<=480]/best[height<=480]/best",
+		},
+		{
+			name: "ProgressiveStrict",
+			opts: VideoOptions{Progressive: true, StrictFormat: true, Format: "webm"},
+			want: "best[ext=webm][height<=720]",
+		},
+		{
+			name: "FormatID",
+			opts: VideoOptions{FormatID: "137+140", Progressive: true, Resolution: "480"},
+			want: "137+140",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.opts.withDefaults().formatSelector())
+		})
+	}
+}
+
+func TestVideoOptions_HasProgressiveFormat(t *testing.T) {
+	formats := []VideoInfo{
+		{Ext: "mp4", Height: 1080, VCodec: "avc1", ACodec: "none"}, // video only
+		{Ext: "m4a", VCodec: "none", ACodec: "mp4a"},               // audio only
+		{Ext: "mp4", Height: 360, VCodec: "avc1", ACodec: "mp4a"},  // progressive
+		{Ext: "webm", Height: 720, VCodec: "vp9", ACodec: "opus"},  // progressive, other container
+	}
+
+	assert.True(t, VideoOptions{Resolution: "720", Format: "mp4"}.hasProgressiveFormat(formats))
+	assert.True(t, VideoOptions{Resolution: "720", Format: "webm"}.hasProgressiveFormat(formats))
+	assert.False(t, VideoOptions{Resolution: "240", Format: "mp4"}.hasProgressiveFormat(formats))
+	assert.False(t, VideoOptions{Resolution: "1080", Format: "mkv"}.hasProgressiveFormat(formats))
+}
+
+func TestVideoOptions_FormatArgs(t *testing.T) {
+	opts := VideoOptions{FormatSort: "res,fps,codec:av01"}.withDefaults()
+	assert.Equal(t, []string{
+		"--format", "bestvideo[height<=720][vcodec*=avc1]+bestaudio/best",
+		"--format-sort", "res,fps,codec:av01",
+	}, opts.formatArgs())
+
+	assert.Equal(t, []string{"--format", "bestvideo[height<=720][vcodec*=avc1]+bestaudio/best"},
+		VideoOptions{}.withDefaults().formatArgs())
+}
+
+func TestValidateFormatSort(t *testing.T) {
+	for _, sort := range []string{"", "res", "res,fps,codec:av01", "+size, br", "filesize~50M", "res:720,ext:mp4:m4a"} {
+		assert.NoError(t, ValidateFormatSort(sort), sort)
+	}
+
+	err := ValidateFormatSort("res,quality,bogus:1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"bogus:1"`)
+	assert.Error(t, ValidateFormatSort("res,,fps"))
+	assert.Error(t, ValidateFormatSort("--exec"))
+}
+
+func TestValidateFormatID(t *testing.T) {
+	for _, id := range []string{"", "22", "137+140", "137+140/22/best", "hls-1080p", "bestvideo[ext=mp4]+bestaudio"} {
+		assert.NoError(t, ValidateFormatID(id), id)
+	}
+	for _, id := range []string{"22; rm -rf /", "137 140", "$(id)", "`id`", "best|worst", "22\n", "--exec=id", "best[height<=720]"} {
+		assert.Error(t, ValidateFormatID(id), id)
+	}
+}
+
+func TestAudioOptions_AudioArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts AudioOptions
+		want []string
+	}{
+		{
+			name: "Defaults",
+			opts: AudioOptions{},
+			want: []string{
+				"--extract-audio",
+				"--audio-format", "mp3",
+				"--audio-quality", "128k",
+				"--postprocessor-args", "ExtractAudio:-acodec libmp3lame",
+			},
+		},
+		{
+			name: "Podcast",
+			opts: AudioOptions{Podcast: true},
+			want: []string{
+				"--extract-audio",
+				"--audio-format", "mp3",
+				"--audio-quality", "64k",
+				"--postprocessor-args", "ExtractAudio:-acodec libmp3lame -ac 1 -af loudnorm=I=-16:TP=-1.5:LRA=11",
+				"--embed-metadata",
+				"--embed-chapters",
+				"--embed-thumbnail",
+			},
+		},
+		{
+			name: "PodcastOverrides",
+			opts: AudioOptions{Podcast: true, Bitrate: "96k", Channels: 2},
+			want: []string{
+				"--extract-audio",
+				"--audio-format", "mp3",
+				"--audio-quality", "96k",
+				"--postprocessor-args", "ExtractAudio:-acodec libmp3lame -ac 2 -af loudnorm=I=-16:TP=-1.5:LRA=11",
+				"--embed-metadata",
+				"--embed-chapters",
+				"--embed-thumbnail",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.opts.withDefaults().audioArgs())
+		})
+	}
+}
+
+func TestAudioOptions_TrimSilence(t *testing.T) {
+	trim := "silenceremove=start_periods=1:start_duration=0.5:start_threshold=-50dB"
+
+	opts := AudioOptions{TrimSilence: true}.withDefaults()
+	assert.Equal(t, "ExtractAudio:-acodec libmp3lame -af "+trim+",areverse,"+trim+",areverse", opts.audioArgs()[6])
+
+	// Composes with the codec, channel and loudness args in a single -af chain
+	opts = AudioOptions{Podcast: true, TrimSilence: true, SilenceThreshold: "-40dB", SilenceDuration: "1"}.withDefaults()
+	trim = "silenceremove=start_periods=1:start_duration=1:start_threshold=-40dB"
+	assert.Equal(t, "ExtractAudio:-acodec libmp3lame -ac 1 -af "+trim+",areverse,"+trim+",areverse,loudnorm=I=-16:TP=-1.5:LRA=11", opts.audioArgs()[6])
+}
+
+func TestAudioOptions_Validate(t *testing.T) {
+	assert.NoError(t, AudioOptions{}.Validate())
+	assert.NoError(t, AudioOptions{SilenceThreshold: "-50dB", SilenceDuration: "0.5"}.Validate())
+	assert.NoError(t, AudioOptions{SilenceThreshold: "0.001"}.Validate())
+
+	assert.Error(t, AudioOptions{SilenceThreshold: "-50dB,volume=10"}.Validate())
+	assert.Error(t, AudioOptions{SilenceThreshold: "-50dB -f null"}.Validate())
+	assert.Error(t, AudioOptions{SilenceDuration: "1:stop_periods=-1"}.Validate())
+
+	assert.NoError(t, AudioOptions{VolumeDB: -30}.Validate())
+	assert.NoError(t, AudioOptions{VolumeDB: 30}.Validate())
+	assert.Error(t, AudioOptions{VolumeDB: -30.5}.Validate())
+	assert.Error(t, AudioOptions{VolumeDB: 31}.Validate())
+}
+
+func TestAudioOptions_ValidateCodec(t *testing.T) {
+	tests := []struct {
+		format, codec string
+		valid         bool
+	}{
+		{"", "", true},
+		{"", "libmp3lame", true},
+		{"", "aac", false}, // An empty format means mp3
+		{"mp3", "libmp3lame", true},
+		{"m4a", "aac", true},
+		{"m4a", "alac", true},
+		{"m4a", "libmp3lame", false},
+		{"opus", "libopus", true},
+		{"opus", "libvorbis", false},
+		{"flac", "", true},
+		{"flac", "aac", false},
+		{"wav", "pcm_s24le", true},
+		{"wav", "libmp3lame", false},
+		{"best", "libmp3lame", true}, // Unknown formats are left to yt-dlp
+	}
+	for _, tt := range tests {
+		err := AudioOptions{Format: tt.format, Codec: tt.codec}.Validate()
+		if tt.valid {
+			assert.NoError(t, err, "format %q, codec %q", tt.format, tt.codec)
+		} else {
+			assert.Error(t, err, "format %q, codec %q", tt.format, tt.codec)
+		}
+	}
+
+	err := AudioOptions{Format: "m4a", Codec: "libmp3lame"}.Validate()
+	assert.EqualError(t, err, `codec "libmp3lame" cannot be used with format "m4a", valid codecs: aac, libfdk_aac, alac`)
+}
+
+func TestAudioOptions_Volume(t *testing.T) {
+	opts := AudioOptions{VolumeDB: 6}.withDefaults()
+	assert.Equal(t, "ExtractAudio:-acodec libmp3lame -af volume=6dB", opts.audioArgs()[6])
+
+	opts = AudioOptions{VolumeDB: -3.5}.withDefaults()
+	assert.Equal(t, "ExtractAudio:-acodec libmp3lame -af volume=-3.5dB", opts.audioArgs()[6])
+
+	// Gain goes after silence trimming and before loudness normalization
+	opts = AudioOptions{TrimSilence: true, Normalize: true, VolumeDB: 2}.withDefaults()
+	trim := "silenceremove=start_periods=1:start_duration=0.5:start_threshold=-50dB"
+	assert.Equal(t, "ExtractAudio:-acodec libmp3lame -af "+trim+",areverse,"+trim+",areverse,volume=2dB,loudnorm=I=-16:TP=-1.5:LRA=11", opts.audioArgs()[6])
+}
+
+func TestValidateMultiAudio(t *testing.T) {
+	assert.NoError(t, ValidateMultiAudio("", false))
+	assert.NoError(t, ValidateMultiAudio("mkv", false))
+	assert.NoError(t, ValidateMultiAudio("mp4", false))
+	assert.Error(t, ValidateMultiAudio("webm", false))
+	assert.Error(t, ValidateMultiAudio("mkv", true))
+}
+
+func TestVideoOptions_AllAudioLanguagesWithoutLanguages(t *testing.T) {
+	// Extractors that don't report languages fall back to a single track
+	opts := VideoOptions{AllAudioLanguages: true}.withDefaults()
+	assert.Equal(t, "mkv", opts.Format)
+	assert.Equal(t, "bestvideo[height<=720][vcodec*=avc1]+bestaudio/best", opts.formatSelector())
+}
+
+func TestAudioOptions_FormatDefaults(t *testing.T) {
+	tests := []struct {
+		format      string
+		wantCodec   string
+		wantBitrate string
+	}{
+		{format: "", wantCodec: "libmp3lame", wantBitrate: "128k"},
+		{format: "mp3", wantCodec: "libmp3lame", wantBitrate: "128k"},
+		{format: "m4a", wantCodec: "aac", wantBitrate: "128k"},
+		{format: "opus", wantCodec: "libopus", wantBitrate: "96k"},
+		{format: "vorbis", wantCodec: "libvorbis", wantBitrate: "160k"},
+		{format: "flac", wantCodec: "flac", wantBitrate: ""},
+		{format: "wav", wantCodec: "pcm_s16le", wantBitrate: ""},
+		{format: "unknown", wantCodec: "libmp3lame", wantBitrate: "128k"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			opts := AudioOptions{Format: tt.format}.withDefaults()
+			assert.Equal(t, tt.wantCodec, opts.Codec)
+			assert.Equal(t, tt.wantBitrate, opts.Bitrate)
+		})
+	}
+}
+
+func TestAudioOptions_LosslessOmitsBitrate(t *testing.T) {
+	opts := AudioOptions{Format: "flac", Bitrate: "320k"}.withDefaults()
+	assert.Equal(t, []string{
+		"--extract-audio",
+		"--audio-format", "flac",
+		"--postprocessor-args", "ExtractAudio:-acodec flac",
+	}, opts.audioArgs())
+
+	// Explicit values still win for lossy formats
+	opts = AudioOptions{Format: "opus", Codec: "libvorbis", Bitrate: "64k"}.withDefaults()
+	assert.Equal(t, "libvorbis", opts.Codec)
+	assert.Equal(t, "64k", opts.Bitrate)
+}
+
+func TestAudioOptions_SampleFormat(t *testing.T) {
+	for _, sampleFormat := range []string{"s16le", "s24le", "f32le"} {
+		t.Run(sampleFormat, func(t *testing.T) {
+			opts := AudioOptions{SampleFormat: sampleFormat, Bitrate: "320k"}
+			assert.NoError(t, opts.Validate())
+			assert.Equal(t, []string{
+				"--extract-audio",
+				"--audio-format", "wav",
+				"--postprocessor-args", "ExtractAudio:-acodec pcm_" + sampleFormat,
+			}, opts.withDefaults().audioArgs())
+		})
+	}
+
+	assert.NoError(t, AudioOptions{SampleFormat: "s24le", Format: "wav", Codec: "pcm_s24le"}.Validate())
+	assert.ErrorContains(t, AudioOptions{SampleFormat: "s32be"}.Validate(), "invalid sample format")
+	assert.ErrorContains(t, AudioOptions{SampleFormat: "s16le", Format: "flac"}.Validate(), "only applies to wav")
+	assert.ErrorContains(t, AudioOptions{SampleFormat: "s16le", Codec: "pcm_f32le"}.Validate(), "does not match")
+}
+
+func TestCapResolution(t *testing.T) {
+	tests := []struct {
+		resolution string
+		max        int
+		want       string
+	}{
+		{resolution: "1080", max: 480, want: "480"},
+		{resolution: "360", max: 480, want: "360"},
+		{resolution: "480", max: 480, want: "480"},
+		{resolution: "", max: 480, want: "480"},
+		{resolution: "best", max: 480, want: "480"},
+		{resolution: "1080", max: 0, want: "1080"},
+		{resolution: "", max: 0, want: ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CapResolution(tt.resolution, tt.max), "%q capped at %d", tt.resolution, tt.max)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlaylistInfo is the flat listing of a playlist: its own metadata and one
+// lightweight entry per video, without any per-video extraction.
+type PlaylistInfo struct {
+	ID       string          `json:"id"`
+	Title    string          `json:"title"`
+	Uploader string          `json:"uploader,omitempty"`
+	Entries  []PlaylistEntry `json:"entries"`
+	// Extractor and ExtractorKey name the yt-dlp extractor of the playlist
+	Extractor    string `json:"extractor,omitempty"`
+	ExtractorKey string `json:"extractor_key,omitempty"`
+	// Truncated is set when the playlist has more entries than were returned
+	Truncated bool `json:"truncated"`
+}
+
+// PlaylistEntry is a video in a flat playlist listing. Duration is in
+// seconds and 0 when the site does not list it.
+type PlaylistEntry struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration,omitempty"`
+	URL      string  `json:"url"`
+}
+
+// GetPlaylistInfo lists the entries of the playlist at url with
+// --flat-playlist, which only reads the playlist page(s) and is much faster
+// than fetching each video's info. At most PLAYLIST_MAX_ENTRIES entries are
+// returned. A playlist from an extractor outside ALLOWED_EXTRACTORS is
+// rejected with an ExtractorNotAllowedError.
+func (d *Downloader) GetPlaylistInfo(ctx context.Context, url string) (*PlaylistInfo, error) {
+	maxEntries := d.config().PlaylistMaxEntries
+	infoCtx := ctx
+	if d.config().InfoTimeout > 0 {
+		var cancel context.CancelFunc
+		infoCtx, cancel = context.WithTimeout(ctx, d.config().InfoTimeout)
+		defer cancel()
+	}
+
+	// One extra entry tells whether the listing was cut short
+	playlistArgs := d.ytdlpArgs(url,
+		"--flat-playlist",
+		"--dump-single-json",
+		"--playlist-end", strconv.Itoa(maxEntries+1),
+	)
+	cmd := exec.CommandContext(infoCtx, d.config().YTDLPPath, playlistArgs...)
+	cmd.WaitDelay = time.Second
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for playlist info: %s %s", d.config().YTDLPPath, strings.Join(playlistArgs, " ")))
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(infoCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			slog.Warn("yt-dlp playlist info fetch timed out", "url", url, "timeout", d.config().InfoTimeout)
+			return nil, &InfoTimeoutError{URL: url, Timeout: d.config().InfoTimeout}
+		}
+		return nil, newYTDLPError("playlist info dump", err, stderr.String())
+	}
+
+	var playlist PlaylistInfo
+	if err := json.Unmarshal(stdout.Bytes(), &playlist); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp playlist json: %w", err)
+	}
+	if err := d.checkExtractor(playlist.Extractor, playlist.ExtractorKey); err != nil {
+		return nil, err
+	}
+	if len(playlist.Entries) > maxEntries {
+		playlist.Entries = playlist.Entries[:maxEntries]
+		playlist.Truncated = true
+	}
+	if playlist.Entries == nil {
+		// A single video URL has no entries; keep the JSON an empty list
+		playlist.Entries = []PlaylistEntry{}
+	}
+	return &playlist, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flatPlaylistScript answers like yt-dlp --flat-playlist --dump-single-json
+// for a three-video playlist, ignoring --playlist-end.
+const flatPlaylistScript = `#!/bin/sh
+echo '{"_type":"playlist","id":"PL1","title":"My Playlist","uploader":"Tester","extractor":"youtube:tab","extractor_key":"YoutubeTab","entries":[
+	{"_type":"url","id":"a1","title":"First","duration":61.5,"url":"https://example.com/watch?v=a1"},
+	{"_type":"url","id":"b2","title":"Second","duration":null,"url":"https://example.com/watch?v=b2"},
+	{"_type":"url","id":"c3","title":"Third","duration":30,"url":"https://example.com/watch?v=c3"}]}'
+`
+
+func TestGetPlaylistInfo(t *testing.T) {
+	d := newFakeDownloader(t, flatPlaylistScript)
+	d.config().PlaylistMaxEntries = 10
+
+	playlist, err := d.GetPlaylistInfo(context.Background(), "https://example.com/playlist?list=PL1")
+	assert.NoError(t, err)
+	assert.Equal(t, "PL1", playlist.ID)
+	assert.Equal(t, "My Playlist", playlist.Title)
+	assert.Equal(t, "Tester", playlist.Uploader)
+	assert.Equal(t, "youtube:tab", playlist.Extractor)
+	assert.False(t, playlist.Truncated)
+	assert.Equal(t, []PlaylistEntry{
+		{ID: "a1", Title: "First", Duration: 61.5, URL: "https://example.com/watch?v=a1"},
+		{ID: "b2", Title: "Second", URL: "https://example.com/watch?v=b2"},
+		{ID: "c3", Title: "Third", Duration: 30, URL: "https://example.com/watch?v=c3"},
+	}, playlist.Entries)
+}
+
+func TestGetPlaylistInfo_Capped(t *testing.T) {
+	d := newFakeDownloader(t, flatPlaylistScript)
+	d.config().PlaylistMaxEntries = 2
+
+	playlist, err := d.GetPlaylistInfo(context.Background(), "https://example.com/playlist?list=PL1")
+	assert.NoError(t, err)
+	assert.True(t, playlist.Truncated)
+	assert.Len(t, playlist.Entries, 2)
+	assert.Equal(t, "b2", playlist.Entries[1].ID)
+}
+
+func TestGetPlaylistInfo_ExtractorNotAllowed(t *testing.T) {
+	d := newFakeDownloader(t, flatPlaylistScript)
+	d.config().PlaylistMaxEntries = 10
+
+	d.config().AllowedExtractors = "YoutubeTab"
+	_, err := d.GetPlaylistInfo(context.Background(), "https://example.com/playlist?list=PL1")
+	assert.NoError(t, err)
+
+	d.config().AllowedExtractors = "youtube"
+	playlist, err := d.GetPlaylistInfo(context.Background(), "https://example.com/playlist?list=PL1")
+	var extractorErr *ExtractorNotAllowedError
+	assert.ErrorAs(t, err, &extractorErr)
+	assert.Equal(t, "youtube:tab", extractorErr.Extractor)
+	assert.Nil(t, playlist)
+}
+
+func TestGetPlaylistInfo_PassesPlaylistEnd(t *testing.T) {
+	d := newFakeDownloader(t, `#!/bin/sh
+prev=""
+for a in "$@"; do
+	case "$prev" in --playlist-end) echo "{\"id\":\"PL1\",\"title\":\"end $a\"}" ;; esac
+	prev="$a"
+done
+`)
+	d.config().PlaylistMaxEntries = 5
+
+	playlist, err := d.GetPlaylistInfo(context.Background(), "https://example.com/playlist?list=PL1")
+	assert.NoError(t, err)
+	assert.Equal(t, "end 6", playlist.Title)
+	assert.Empty(t, playlist.Entries)
+	assert.NotNil(t, playlist.Entries)
+}
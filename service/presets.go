@@ -0,0 +1,34 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"gostreampuller/config"
+)
+
+// UnknownPresetError is returned for a quality preset name that is not
+// configured. It lists the valid names.
+type UnknownPresetError struct {
+	Name  string
+	Valid []string
+}
+
+func (e *UnknownPresetError) Error() string {
+	return fmt.Sprintf("unknown preset %q, valid presets: %s", e.Name, strings.Join(e.Valid, ", "))
+}
+
+// QualityPreset returns the quality preset called name, or an
+// UnknownPresetError. An empty name is no preset: it returns an empty one.
+func (d *Downloader) QualityPreset(name string) (config.QualityPreset, error) {
+	if name == "" {
+		return config.QualityPreset{}, nil
+	}
+	// QUALITY_PRESETS was checked at startup
+	presets, _ := d.config().QualityPresets()
+	preset, ok := presets[name]
+	if !ok {
+		return config.QualityPreset{}, &UnknownPresetError{Name: name, Valid: d.config().QualityPresetNames()}
+	}
+	return preset, nil
+}
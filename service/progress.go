@@ -3,9 +3,16 @@ package service
 import (
 	"encoding/json"
 	"log/slog"
+	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// terminalEventTimeout is how long SendEvent waits for a slow client to make
+// room for a "complete" or "error" event before giving up on it.
+const terminalEventTimeout = 2 * time.Second
+
 // ProgressEvent represents a single update in the download/stream process.
 type ProgressEvent struct {
 	ID        string    `json:"id"`        // Unique ID for this operation
@@ -14,72 +21,206 @@ type ProgressEvent struct {
 	Percentage float64   `json:"percentage"` // 0.0 to 100.0, if applicable
 	VideoInfo *VideoInfo `json:"videoInfo,omitempty"` // Optional: full video info
 	Error     string    `json:"error,omitempty"`     // Error message if status is "error"
+	Warnings  []string  `json:"warnings,omitempty"`  // Non-fatal yt-dlp warnings, on "complete"
 }
 
 // ProgressManager manages and broadcasts progress updates to subscribed clients.
 type ProgressManager struct {
-	clients map[string]chan []byte // Map of progressID to a channel of JSON-encoded events
-	mu      sync.RWMutex
+	clients    map[string]*progressClient // Map of progressID to the client receiving its events
+	mu         sync.RWMutex
+	bufferSize int // Events buffered per client before non-terminal events are dropped
+
+	// Recent events replayed to reconnecting clients, see ResumeClient
+	history   map[string]*progressHistory
+	historyMu sync.Mutex
+
+	// Progress callbacks, see RegisterCallback
+	callbacks         map[string]*progressCallback
+	callbackMu        sync.Mutex
+	callbackInterval  time.Duration
+	callbackClient    *http.Client
+	callbackAllowlist atomic.Pointer[[]string] // Hosts exempt from the public address check, see SetCallbackAllowlist
 }
 
-// NewProgressManager creates and returns a new ProgressManager.
-func NewProgressManager() *ProgressManager {
-	return &ProgressManager{
-		clients: make(map[string]chan []byte),
+// NewProgressManager creates and returns a new ProgressManager whose client
+// channels buffer up to bufferSize events.
+func NewProgressManager(bufferSize int) *ProgressManager {
+	pm := &ProgressManager{
+		clients:          make(map[string]*progressClient),
+		bufferSize:       bufferSize,
+		history:          make(map[string]*progressHistory),
+		callbacks:        make(map[string]*progressCallback),
+		callbackInterval: progressCallbackInterval,
 	}
+	pm.callbackClient = pm.newCallbackClient()
+	return pm
 }
 
 // RegisterClient registers a new client for a given progressID.
 // It returns a channel where events for this progressID will be sent.
-func (pm *ProgressManager) RegisterClient(progressID string) chan []byte {
+func (pm *ProgressManager) RegisterClient(progressID string) chan ProgressMessage {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.trackHistory(progressID, 0)
+	return pm.registerClientLocked(progressID)
+}
+
+// ResumeClient registers a client reconnecting after it received the event
+// numbered lastSeq. Along with the channel, it returns the recent events
+// numbered after lastSeq, oldest first, which the client missed. The channel
+// may repeat some of them, so clients should skip events they already have.
+func (pm *ProgressManager) ResumeClient(progressID string, lastSeq uint64) (chan ProgressMessage, []ProgressMessage) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	if _, ok := pm.clients[progressID]; ok {
+	replay := pm.trackHistory(progressID, lastSeq)
+	return pm.registerClientLocked(progressID), replay
+}
+
+// registerClientLocked creates the channel of progressID, replacing any
+// existing one. pm.mu must be held.
+func (pm *ProgressManager) registerClientLocked(progressID string) chan ProgressMessage {
+
+	if old, ok := pm.clients[progressID]; ok {
 		// If a client is already registered for this ID, close the old channel
 		// and create a new one. This handles cases where a user refreshes the page.
-		close(pm.clients[progressID])
+		old.close()
 	}
 
-	clientChan := make(chan []byte)
-	pm.clients[progressID] = clientChan
+	client := &progressClient{
+		ch:   make(chan ProgressMessage, pm.bufferSize),
+		done: make(chan struct{}),
+	}
+	pm.clients[progressID] = client
 	slog.Debug("Registered new progress client", "progressID", progressID)
-	return clientChan
+	return client.ch
 }
 
-// UnregisterClient unregisters a client for a given progressID.
-func (pm *ProgressManager) UnregisterClient(progressID string) {
+// UnregisterClient unregisters the client of progressID whose channel is
+// clientChan. It does nothing once that client was replaced by a newer one,
+// e.g. after the user reconnected, so a departing client never cuts off its
+// successor.
+func (pm *ProgressManager) UnregisterClient(progressID string, clientChan chan ProgressMessage) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	if clientChan, ok := pm.clients[progressID]; ok {
-		close(clientChan)
+	if current, ok := pm.clients[progressID]; ok && current.ch == clientChan {
+		pm.unregisterClientLocked(progressID)
+	}
+}
+
+// unregisterClientLocked closes and removes whichever client is registered
+// for progressID. pm.mu must be held.
+func (pm *ProgressManager) unregisterClientLocked(progressID string) {
+	if client, ok := pm.clients[progressID]; ok {
+		client.close()
 		delete(pm.clients, progressID)
 		slog.Debug("Unregistered progress client", "progressID", progressID)
 	}
 }
 
-// SendEvent sends a progress event to the specified client.
+// endClient unregisters whichever client is registered for progressID, once
+// its operation has ended.
+func (pm *ProgressManager) endClient(progressID string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.unregisterClientLocked(progressID)
+}
+
+// SendEvent sends a progress event to the specified client. Intermediate
+// events are dropped if the client's buffer is full, but terminal events
+// ("complete" and "error") wait up to terminalEventTimeout for room, so the
+// client does not miss the end of the operation. Events are also kept for
+// replay to a reconnecting client, and go to the operation's progress
+// callback, if one is registered.
 func (pm *ProgressManager) SendEvent(event ProgressEvent) {
+	pm.notifyCallback(event)
+
+	jsonEvent, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to marshal progress event", "error", err, "event", event)
+		return
+	}
+	msg := pm.record(event.ID, jsonEvent, isTerminalStatus(event.Status))
+
+	// The send happens without the manager lock, so a slow client only
+	// holds up the events of its own operation
 	pm.mu.RLock()
-	defer pm.mu.RUnlock()
+	client, ok := pm.clients[event.ID]
+	pm.mu.RUnlock()
 
-	if clientChan, ok := pm.clients[event.ID]; ok {
-		jsonEvent, err := json.Marshal(event)
-		if err != nil {
-			slog.Error("Failed to marshal progress event", "error", err, "event", event)
-			return
+	if !ok {
+		slog.Debug("No client registered for progress ID", "progressID", event.ID)
+		return
+	}
+	if !client.send(msg) {
+		if msg.Terminal {
+			slog.Warn("Final progress event not sent, client too slow or gone", "progressID", event.ID, "status", event.Status)
+		} else {
+			slog.Warn("Dropped progress event, client channel full", "progressID", event.ID, "status", event.Status)
 		}
+	}
+}
+
+// progressClient is the channel of a registered progress client. Its mutex
+// keeps the channel from being closed while an event is sent to it, and
+// done, closed first, cuts short a send waiting for room, so closing never
+// waits on a slow client.
+type progressClient struct {
+	ch        chan ProgressMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	closed    bool
+}
+
+// send delivers msg to the client. A full buffer drops intermediate events
+// at once, while terminal events wait up to terminalEventTimeout for room,
+// unless the client is closed meanwhile. It reports whether msg was sent.
+func (c *progressClient) send(msg ProgressMessage) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	if !msg.Terminal {
 		select {
-		case clientChan <- jsonEvent:
-			// Event sent successfully
+		case c.ch <- msg:
+			return true
 		default:
-			slog.Warn("Failed to send progress event, client channel full or closed", "progressID", event.ID)
-			// Optionally, unregister client if channel is consistently full/closed
+			return false
 		}
-	} else {
-		slog.Debug("No client registered for progress ID", "progressID", event.ID)
 	}
+	timer := time.NewTimer(terminalEventTimeout)
+	defer timer.Stop()
+	select {
+	case c.ch <- msg:
+		return true
+	case <-c.done:
+		return false
+	case <-timer.C:
+		return false
+	}
+}
+
+// close closes the client's channel once any send in progress has ended.
+func (c *progressClient) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.ch)
+	}
+}
+
+// isTerminalStatus reports whether status ends an operation's event stream.
+func isTerminalStatus(status string) bool {
+	return status == "complete" || status == "error"
 }
 
 // SendError sends an error event to the specified client and unregisters it.
@@ -91,18 +232,19 @@ func (pm *ProgressManager) SendError(progressID, message string, err error) {
 		Error:   err.Error(),
 	}
 	pm.SendEvent(event)
-	pm.UnregisterClient(progressID) // Unregister on error
+	pm.endClient(progressID) // Unregister on error
 }
 
 // SendComplete sends a complete event to the specified client and unregisters it.
-func (pm *ProgressManager) SendComplete(progressID, message string, videoInfo *VideoInfo) {
+func (pm *ProgressManager) SendComplete(progressID, message string, videoInfo *VideoInfo, warnings ...string) {
 	event := ProgressEvent{
 		ID:        progressID,
 		Status:    "complete",
 		Message:   message,
 		Percentage: 100.0,
 		VideoInfo: videoInfo,
+		Warnings:  warnings,
 	}
 	pm.SendEvent(event)
-	pm.UnregisterClient(progressID) // Unregister on completion
+	pm.endClient(progressID) // Unregister on completion
 }
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// progressCallbackInterval is the least time between two intermediate
+	// events POSTed to a progress callback; the ones in between are dropped.
+	// Terminal events are never held back.
+	progressCallbackInterval = time.Second
+	// progressCallbackTimeout bounds each callback POST.
+	progressCallbackTimeout = 10 * time.Second
+	// progressCallbackQueueSize is how many events wait for a slow callback.
+	progressCallbackQueueSize = 8
+)
+
+// progressCallback delivers the events of one operation to a client URL.
+type progressCallback struct {
+	url      string
+	events   chan ProgressEvent
+	lastSent time.Time // Guarded by ProgressManager.callbackMu
+}
+
+// errCallbackNotPublic is returned when a progress callback would reach an
+// address that is not public.
+var errCallbackNotPublic = errors.New("progress callback address is not public")
+
+// SetCallbackAllowlist sets the hosts, lowercased, that progress callbacks may
+// target even though they are not public, e.g. from CALLBACK_ALLOWED_HOSTS.
+func (pm *ProgressManager) SetCallbackAllowlist(hosts []string) {
+	pm.callbackAllowlist.Store(&hosts)
+}
+
+// callbackHostAllowed reports whether host, a host name or IP address, is on
+// the callback allowlist.
+func (pm *ProgressManager) callbackHostAllowed(host string) bool {
+	allowed := pm.callbackAllowlist.Load()
+	return allowed != nil && slices.Contains(*allowed, strings.ToLower(host))
+}
+
+// ValidateCallbackURL checks that a progress callback URL is an absolute
+// http or https URL, whose host is public unless it is on the allowlist.
+func (pm *ProgressManager) ValidateCallbackURL(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("invalid progress callback URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid progress callback URL %q: expected an http or https URL", callbackURL)
+	}
+	if !pm.callbackHostAllowed(u.Hostname()) && !IsPublicURL(callbackURL) {
+		return fmt.Errorf("invalid progress callback URL %q: host must be a public address", callbackURL)
+	}
+	return nil
+}
+
+// newCallbackClient returns the client progress callbacks are POSTed with.
+// Unless the host is on the allowlist, it only connects to public addresses,
+// checked on the address actually dialed so that a name resolving to an
+// internal one after ValidateCallbackURL (DNS rebinding) is refused too.
+// Redirects are not followed, since they could point anywhere.
+func (pm *ProgressManager) newCallbackClient() *http.Client {
+	dialer := &net.Dialer{Timeout: progressCallbackTimeout}
+	publicDialer := &net.Dialer{Timeout: progressCallbackTimeout, Control: dialPublicOnly}
+	return &http.Client{
+		Timeout: progressCallbackTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if host, _, err := net.SplitHostPort(addr); err == nil && pm.callbackHostAllowed(host) {
+					return dialer.DialContext(ctx, network, addr)
+				}
+				return publicDialer.DialContext(ctx, network, addr)
+			},
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: progressCallbackTimeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialPublicOnly is a net.Dialer Control refusing connections to addresses
+// that are not public. It runs after DNS resolution, on the resolved IP.
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", errCallbackNotPublic, ip)
+	}
+	return nil
+}
+
+// RegisterCallback POSTs the events sent for progressID to callbackURL as
+// JSON, until a terminal event ends the operation. Intermediate events are
+// throttled to one per progressCallbackInterval; "complete" and "error" are
+// always delivered. Delivery is asynchronous and never slows the operation.
+func (pm *ProgressManager) RegisterCallback(progressID, callbackURL string) {
+	cb := &progressCallback{
+		url:    callbackURL,
+		events: make(chan ProgressEvent, progressCallbackQueueSize),
+	}
+
+	pm.callbackMu.Lock()
+	if old, ok := pm.callbacks[progressID]; ok {
+		close(old.events)
+	}
+	pm.callbacks[progressID] = cb
+	pm.callbackMu.Unlock()
+
+	go pm.deliverCallback(progressID, cb)
+	slog.Debug("Registered progress callback", "progressID", progressID, "url", callbackURL)
+}
+
+// notifyCallback queues event for the operation's callback, if it has one.
+func (pm *ProgressManager) notifyCallback(event ProgressEvent) {
+	pm.callbackMu.Lock()
+	defer pm.callbackMu.Unlock()
+
+	cb, ok := pm.callbacks[event.ID]
+	if !ok {
+		return
+	}
+
+	if isTerminalStatus(event.Status) {
+		// Make room by dropping the oldest queued intermediate event rather
+		// than blocking or losing the final one
+		for queued := false; !queued; {
+			select {
+			case cb.events <- event:
+				queued = true
+			default:
+				select {
+				case <-cb.events:
+				default:
+				}
+			}
+		}
+		close(cb.events)
+		delete(pm.callbacks, event.ID)
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(cb.lastSent) < pm.callbackInterval {
+		slog.Debug("Throttled progress callback event", "progressID", event.ID, "status", event.Status)
+		return
+	}
+	select {
+	case cb.events <- event:
+		cb.lastSent = now
+	default:
+		slog.Warn("Dropped progress callback event, callback too slow", "progressID", event.ID, "status", event.Status)
+	}
+}
+
+// deliverCallback POSTs queued events to the callback URL in order until the
+// queue is closed. Failed deliveries are logged and not retried.
+func (pm *ProgressManager) deliverCallback(progressID string, cb *progressCallback) {
+	for event := range cb.events {
+		body, err := json.Marshal(event)
+		if err != nil {
+			slog.Error("Failed to marshal progress callback event", "error", err, "progressID", progressID)
+			continue
+		}
+		resp, err := pm.callbackClient.Post(cb.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			slog.Warn("Progress callback failed", "error", err, "progressID", progressID, "url", cb.url)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			slog.Warn("Progress callback rejected event", "status", resp.StatusCode, "progressID", progressID, "url", cb.url)
+		}
+	}
+	slog.Debug("Progress callback finished", "progressID", progressID)
+}
+
+// UnregisterCallback stops sending events for progressID to its callback.
+// Events already queued are still delivered.
+func (pm *ProgressManager) UnregisterCallback(progressID string) {
+	pm.callbackMu.Lock()
+	defer pm.callbackMu.Unlock()
+
+	if cb, ok := pm.callbacks[progressID]; ok {
+		close(cb.events)
+		delete(pm.callbacks, progressID)
+	}
+}
+
+// ValidateCallbackURL checks a progress callback URL; see
+// ProgressManager.ValidateCallbackURL.
+func (d *Downloader) ValidateCallbackURL(callbackURL string) error {
+	return d.progressManager.ValidateCallbackURL(callbackURL)
+}
+
+// RegisterProgressCallback starts a progress ID for a new operation whose
+// events are POSTed to callbackURL; see ProgressManager.RegisterCallback.
+// Call release once the operation returns, in case it ended without a
+// terminal event.
+func (d *Downloader) RegisterProgressCallback(callbackURL string) (progressID string, release func()) {
+	progressID = NewProgressID("callback")
+	d.progressManager.RegisterCallback(progressID, callbackURL)
+	return progressID, func() { d.progressManager.UnregisterCallback(progressID) }
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// callbackRecorder is a progress callback endpoint that records every event
+// POSTed to it.
+type callbackRecorder struct {
+	mu     sync.Mutex
+	events []ProgressEvent
+}
+
+func (c *callbackRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var event ProgressEvent
+	json.NewDecoder(r.Body).Decode(&event)
+	c.mu.Lock()
+	c.events = append(c.events, event)
+	c.mu.Unlock()
+}
+
+func (c *callbackRecorder) statuses() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var statuses []string
+	for _, event := range c.events {
+		statuses = append(statuses, event.Status)
+	}
+	return statuses
+}
+
+func TestProgressCallback_ThrottlesIntermediateEvents(t *testing.T) {
+	recorder := &callbackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	pm := NewProgressManager(0)
+	pm.SetCallbackAllowlist([]string{"127.0.0.1"}) // httptest servers listen on loopback
+	pm.callbackInterval = time.Hour                // Only the first intermediate event gets through
+	pm.RegisterCallback("op", server.URL)
+
+	pm.SendEvent(ProgressEvent{ID: "op", Status: "fetching_info"})
+	pm.SendEvent(ProgressEvent{ID: "op", Status: "downloading", Percentage: 25})
+	pm.SendEvent(ProgressEvent{ID: "op", Status: "encoding", Percentage: 75})
+	pm.SendComplete("op", "done", nil)
+
+	assert.Eventually(t, func() bool { return len(recorder.statuses()) == 2 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"fetching_info", "complete"}, recorder.statuses())
+
+	// The callback is gone once the operation has ended
+	pm.SendEvent(ProgressEvent{ID: "op", Status: "downloading"})
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, recorder.statuses(), 2)
+}
+
+func TestProgressCallback_SendsAfterInterval(t *testing.T) {
+	recorder := &callbackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	pm := NewProgressManager(0)
+	pm.SetCallbackAllowlist([]string{"127.0.0.1"}) // httptest servers listen on loopback
+	pm.callbackInterval = 20 * time.Millisecond
+	pm.RegisterCallback("op", server.URL)
+
+	pm.SendEvent(ProgressEvent{ID: "op", Status: "fetching_info"})
+	pm.SendEvent(ProgressEvent{ID: "op", Status: "info_fetched"}) // Throttled
+	time.Sleep(30 * time.Millisecond)
+	pm.SendEvent(ProgressEvent{ID: "op", Status: "downloading"})
+	pm.SendError("op", "failed", errors.New("boom"))
+
+	assert.Eventually(t, func() bool { return len(recorder.statuses()) == 3 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"fetching_info", "downloading", "error"}, recorder.statuses())
+}
+
+func TestProgressCallback_TerminalEventWhenQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	recorder := &callbackRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // Hold every delivery so the queue fills up
+		recorder.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	pm := NewProgressManager(0)
+	pm.SetCallbackAllowlist([]string{"127.0.0.1"}) // httptest servers listen on loopback
+	pm.callbackInterval = 0
+	pm.RegisterCallback("op", server.URL)
+
+	for i := 0; i < 2*progressCallbackQueueSize; i++ {
+		pm.SendEvent(ProgressEvent{ID: "op", Status: "downloading"})
+	}
+	done := make(chan struct{})
+	go func() {
+		pm.SendComplete("op", "done", nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SendComplete blocked on a slow callback")
+	}
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		statuses := recorder.statuses()
+		return len(statuses) > 0 && statuses[len(statuses)-1] == "complete"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	fakeLookup(t, map[string][]string{
+		"example.com":    {"93.184.216.34"},
+		"hooks.internal": {"10.0.0.7"},
+	})
+	pm := NewProgressManager(0)
+
+	assert.NoError(t, pm.ValidateCallbackURL("https://example.com/hook"))
+	assert.Error(t, pm.ValidateCallbackURL("http://127.0.0.1:8080/progress"))
+	assert.Error(t, pm.ValidateCallbackURL("http://10.0.0.1:8080/progress"))
+	assert.Error(t, pm.ValidateCallbackURL("http://169.254.169.254/latest/meta-data"))
+	assert.Error(t, pm.ValidateCallbackURL("http://hooks.internal/progress"))
+	assert.Error(t, pm.ValidateCallbackURL("ftp://example.com/hook"))
+	assert.Error(t, pm.ValidateCallbackURL("/relative"))
+	assert.Error(t, pm.ValidateCallbackURL("://bad"))
+
+	// The allowlist lets operators reach internal receivers
+	pm.SetCallbackAllowlist([]string{"hooks.internal", "10.0.0.1"})
+	assert.NoError(t, pm.ValidateCallbackURL("http://Hooks.Internal/progress"))
+	assert.NoError(t, pm.ValidateCallbackURL("http://10.0.0.1:8080/progress"))
+	assert.Error(t, pm.ValidateCallbackURL("http://10.0.0.2:8080/progress"))
+}
+
+func TestProgressCallback_RefusesInternalAddresses(t *testing.T) {
+	recorder := &callbackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+	pm := NewProgressManager(0)
+
+	// Checked on the dialed address, so names resolving to one are refused too
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	for _, target := range []string{
+		server.URL,
+		"http://localhost:" + port,
+		"http://10.0.0.1:8080",
+		"http://169.254.169.254",
+	} {
+		_, err := pm.callbackClient.Post(target, "application/json", strings.NewReader("{}"))
+		assert.ErrorIs(t, err, errCallbackNotPublic, target)
+	}
+	assert.Empty(t, recorder.statuses())
+
+	pm.SetCallbackAllowlist([]string{"127.0.0.1"})
+	resp, err := pm.callbackClient.Post(server.URL, "application/json", strings.NewReader("{}"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Len(t, recorder.statuses(), 1)
+}
+
+func TestProgressCallback_DoesNotFollowRedirects(t *testing.T) {
+	internal := &callbackRecorder{}
+	internalServer := httptest.NewServer(internal)
+	defer internalServer.Close()
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internalServer.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirector.Close()
+
+	_, port, _ := net.SplitHostPort(redirector.Listener.Addr().String())
+	pm := NewProgressManager(0)
+	pm.SetCallbackAllowlist([]string{"localhost"}) // The redirector, but not 127.0.0.1
+	resp, err := pm.callbackClient.Post("http://localhost:"+port, "application/json", strings.NewReader("{}"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	assert.Empty(t, internal.statuses())
+}
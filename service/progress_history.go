@@ -0,0 +1,97 @@
+package service
+
+import (
+	"time"
+)
+
+const (
+	// progressHistorySize is how many recent events of an operation are kept
+	// to be replayed to a reconnecting SSE client.
+	progressHistorySize = 64
+	// progressHistoryTTL is how long the history of an operation is kept
+	// after its last event.
+	progressHistoryTTL = 10 * time.Minute
+)
+
+// ProgressMessage is a JSON-encoded progress event with its sequence number
+// within the operation. Sequence numbers start at 1 and are sent as the SSE
+// event id, so a reconnecting client can ask for the events it missed.
+type ProgressMessage struct {
+	Seq      uint64
+	Data     []byte
+	Terminal bool // The event was "complete" or "error"
+}
+
+// progressHistory holds the latest events of one operation.
+type progressHistory struct {
+	lastSeq  uint64
+	messages []ProgressMessage // At most progressHistorySize, oldest first
+	updated  time.Time
+}
+
+// record numbers data as the next event of progressID and keeps it for
+// replay. Events of operations no SSE client ever registered for are not
+// kept and get sequence number 0.
+func (pm *ProgressManager) record(progressID string, data []byte, terminal bool) ProgressMessage {
+	pm.historyMu.Lock()
+	defer pm.historyMu.Unlock()
+
+	msg := ProgressMessage{Data: data, Terminal: terminal}
+	h, ok := pm.history[progressID]
+	if !ok {
+		return msg
+	}
+	h.lastSeq++
+	msg.Seq = h.lastSeq
+	h.messages = append(h.messages, msg)
+	if over := len(h.messages) - progressHistorySize; over > 0 {
+		h.messages = append(h.messages[:0], h.messages[over:]...)
+	}
+	h.updated = time.Now()
+	return msg
+}
+
+// trackHistory starts keeping the events of progressID, if not already, and
+// returns the kept events numbered after lastSeq. Histories idle for longer
+// than progressHistoryTTL are dropped.
+func (pm *ProgressManager) trackHistory(progressID string, lastSeq uint64) []ProgressMessage {
+	pm.historyMu.Lock()
+	defer pm.historyMu.Unlock()
+
+	now := time.Now()
+	for id, h := range pm.history {
+		if now.Sub(h.updated) > progressHistoryTTL {
+			delete(pm.history, id)
+		}
+	}
+
+	h, ok := pm.history[progressID]
+	if !ok {
+		pm.history[progressID] = &progressHistory{updated: now}
+		return nil
+	}
+	h.updated = now
+
+	var replay []ProgressMessage
+	for _, msg := range h.messages {
+		if msg.Seq > lastSeq {
+			replay = append(replay, msg)
+		}
+	}
+	return replay
+}
+
+// TerminalMessage returns the "complete" or "error" event that ended the
+// operation progressID, if it has ended and its history is still kept, so a
+// client whose channel closed before receiving it can still be sent it.
+func (pm *ProgressManager) TerminalMessage(progressID string) (ProgressMessage, bool) {
+	pm.historyMu.Lock()
+	defer pm.historyMu.Unlock()
+
+	h, ok := pm.history[progressID]
+	if !ok || len(h.messages) == 0 {
+		return ProgressMessage{}, false
+	}
+	last := h.messages[len(h.messages)-1]
+	return last, last.Terminal
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// progressIDSeq numbers the progress IDs minted by this process.
+var progressIDSeq atomic.Uint64
+
+// NewProgressID returns a progress ID for a new operation, e.g.
+// "info-1718000000000000000-42". The timestamp keeps IDs apart across
+// restarts and the sequence number between operations started in the same
+// nanosecond, whose events would otherwise go to each other's clients.
+func NewProgressID(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), progressIDSeq.Add(1))
+}
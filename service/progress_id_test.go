@@ -0,0 +1,34 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProgressID_Unique(t *testing.T) {
+	const workers, perWorker = 16, 1000
+	ids := make(chan string, workers*perWorker)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				ids <- NewProgressID("info")
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool, workers*perWorker)
+	for id := range ids {
+		assert.True(t, strings.HasPrefix(id, "info-"), id)
+		assert.False(t, seen[id], "duplicate progress ID %s", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, workers*perWorker)
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drain reads every event from ch until it is closed, pausing before each
+// read to simulate a slow client.
+func drain(ch chan ProgressMessage, delay time.Duration) []ProgressEvent {
+	var events []ProgressEvent
+	for msg := range ch {
+		time.Sleep(delay)
+		var event ProgressEvent
+		json.Unmarshal(msg.Data, &event)
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestProgressManager_SlowClientGetsComplete(t *testing.T) {
+	pm := NewProgressManager(2)
+	ch := pm.RegisterClient("p1")
+
+	done := make(chan []ProgressEvent)
+	go func() { done <- drain(ch, 20*time.Millisecond) }()
+
+	// Far more events than the buffer holds; intermediate ones may be dropped
+	for i := range 20 {
+		pm.SendEvent(ProgressEvent{ID: "p1", Status: "downloading", Percentage: float64(i)})
+	}
+	pm.SendComplete("p1", "done", nil)
+
+	events := <-done
+	assert.NotEmpty(t, events)
+	assert.Equal(t, "complete", events[len(events)-1].Status)
+}
+
+func TestProgressManager_SlowClientGetsError(t *testing.T) {
+	pm := NewProgressManager(0)
+	ch := pm.RegisterClient("p1")
+
+	done := make(chan []ProgressEvent)
+	go func() { done <- drain(ch, 50*time.Millisecond) }()
+
+	pm.SendError("p1", "failed", errors.New("boom"))
+
+	events := <-done
+	assert.Len(t, events, 1)
+	assert.Equal(t, "error", events[0].Status)
+	assert.Equal(t, "boom", events[0].Error)
+}
+
+func TestProgressManager_DropsIntermediateEventsWhenFull(t *testing.T) {
+	pm := NewProgressManager(1)
+	ch := pm.RegisterClient("p1")
+
+	// Nobody reads: the first event fills the buffer, the second is dropped
+	// without blocking the sender
+	start := time.Now()
+	pm.SendEvent(ProgressEvent{ID: "p1", Status: "downloading", Percentage: 10})
+	pm.SendEvent(ProgressEvent{ID: "p1", Status: "downloading", Percentage: 20})
+	assert.Less(t, time.Since(start), terminalEventTimeout)
+	assert.Len(t, ch, 1)
+}
+
+func TestProgressManager_SlowClientDoesNotBlockOthers(t *testing.T) {
+	pm := NewProgressManager(0)
+	slow := pm.RegisterClient("p1")
+
+	// Nobody reads p1, so its final event waits for room
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		pm.SendComplete("p1", "done", nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Meanwhile, other clients connect and get their events
+	start := time.Now()
+	ch := pm.RegisterClient("p2")
+	go pm.SendError("p2", "failed", errors.New("boom"))
+	<-ch
+	assert.Less(t, time.Since(start), terminalEventTimeout/2)
+
+	// The slow client leaving cuts the pending send short
+	start = time.Now()
+	pm.UnregisterClient("p1", slow)
+	<-sent
+	assert.Less(t, time.Since(start), terminalEventTimeout/2)
+	_, open := <-slow
+	assert.False(t, open)
+}
+
+func TestProgressManager_ResumeReplaysMissedEvents(t *testing.T) {
+	pm := NewProgressManager(8)
+	first := pm.RegisterClient("p1")
+	for i := range 3 {
+		pm.SendEvent(ProgressEvent{ID: "p1", Status: "downloading", Percentage: float64(i)})
+	}
+	// The client drops after receiving event 1; event 4 is sent while it is away
+	pm.UnregisterClient("p1", first)
+	pm.SendEvent(ProgressEvent{ID: "p1", Status: "downloading", Percentage: 3})
+
+	ch, replay := pm.ResumeClient("p1", 1)
+	var seqs []uint64
+	for _, msg := range replay {
+		seqs = append(seqs, msg.Seq)
+	}
+	assert.Equal(t, []uint64{2, 3, 4}, seqs)
+
+	pm.SendComplete("p1", "done", nil)
+	msg := <-ch
+	assert.Equal(t, uint64(5), msg.Seq)
+	assert.True(t, msg.Terminal)
+}
+
+func TestProgressManager_UnregisterReplacedClient(t *testing.T) {
+	pm := NewProgressManager(1)
+	first := pm.RegisterClient("p1")
+	second, _ := pm.ResumeClient("p1", 0)
+
+	// The first client leaving after it was replaced keeps the second one
+	pm.UnregisterClient("p1", first)
+	pm.SendEvent(ProgressEvent{ID: "p1", Status: "downloading"})
+	assert.Len(t, second, 1)
+
+	pm.UnregisterClient("p1", second)
+	_, open := <-second
+	assert.True(t, open, "the buffered event is still read")
+	_, open = <-second
+	assert.False(t, open)
+}
+
+func TestProgressManager_HistoryIsBounded(t *testing.T) {
+	pm := NewProgressManager(0)
+	pm.RegisterClient("p1")
+	for i := range progressHistorySize + 10 {
+		pm.SendEvent(ProgressEvent{ID: "p1", Status: "downloading", Percentage: float64(i)})
+	}
+
+	_, replay := pm.ResumeClient("p1", 0)
+	assert.Len(t, replay, progressHistorySize)
+	assert.Equal(t, uint64(11), replay[0].Seq)
+}
+
+// TestProgressManager_ConcurrentUnregister races sends, including the
+// terminal ones that unregister the client, against clients connecting and
+// disconnecting. It must not panic on a closed channel; run it with -race.
+func TestProgressManager_ConcurrentUnregister(t *testing.T) {
+	pm := NewProgressManager(0)
+	ids := []string{"p1", "p2", "p3"}
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		// Clients (re)connecting and draining until their channel is closed
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last chan ProgressMessage
+			for i := range 200 {
+				var ch chan ProgressMessage
+				if i%2 == 0 {
+					ch = pm.RegisterClient(id)
+				} else {
+					ch, _ = pm.ResumeClient(id, uint64(i))
+				}
+				go func() {
+					for range ch {
+					}
+				}()
+				if i%3 == 0 {
+					pm.UnregisterClient(id, ch)
+				}
+				last = ch
+			}
+			pm.UnregisterClient(id, last)
+		}()
+
+		// An operation sending events, then ending
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				switch i % 10 {
+				case 8:
+					pm.SendComplete(id, "done", nil)
+				case 9:
+					pm.SendError(id, "failed", errors.New("boom"))
+				default:
+					pm.SendEvent(ProgressEvent{ID: id, Status: "downloading", Percentage: float64(i)})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
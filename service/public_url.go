@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// publicLookupTimeout bounds how long IsPublicURL waits for DNS.
+const publicLookupTimeout = 5 * time.Second
+
+// nonPublicPrefixes are the ranges IsPublicIP refuses on top of the loopback,
+// link-local, multicast, unspecified and private ones the netip predicates
+// cover: "this network", carrier-grade NAT, IETF protocol assignments,
+// benchmarking, reserved and broadcast, and the NAT64 prefix that maps onto
+// IPv4, private ranges included.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// lookupNetIP resolves host names for IsPublicURL; tests swap it out to stay
+// off the network.
+var lookupNetIP = net.DefaultResolver.LookupNetIP
+
+// IsPublicIP reports whether ip is a public unicast address, i.e. not
+// loopback, private, link-local, multicast, unspecified or otherwise reserved.
+// IPv4-mapped IPv6 addresses are judged by the IPv4 address they map.
+func IsPublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPublicURL reports whether rawURL is an http or https URL whose host is
+// public: an IP address IsPublicIP accepts, or a name all of whose addresses
+// it accepts. Names that do not resolve are not public. The answer only holds
+// at the time of the lookup, so code that then connects to the URL must
+// check the address it dials too, or DNS rebinding gets around it.
+func IsPublicURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := u.Hostname()
+	if host == "" {
+		return false
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return IsPublicIP(ip)
+	}
+	if strings.EqualFold(strings.TrimSuffix(host, "."), "localhost") {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publicLookupTimeout)
+	defer cancel()
+	ips, err := lookupNetIP(ctx, "ip", host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return false
+		}
+	}
+	return true
+}
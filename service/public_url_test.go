@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLookup makes IsPublicURL resolve names from addrs for the rest of the
+// test.
+func fakeLookup(t *testing.T, addrs map[string][]string) {
+	t.Helper()
+	orig := lookupNetIP
+	lookupNetIP = func(_ context.Context, _, host string) ([]netip.Addr, error) {
+		var ips []netip.Addr
+		for _, a := range addrs[host] {
+			ips = append(ips, netip.MustParseAddr(a))
+		}
+		if len(ips) == 0 {
+			return nil, errors.New("no such host")
+		}
+		return ips, nil
+	}
+	t.Cleanup(func() { lookupNetIP = orig })
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::":    true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"255.255.255.255":      false,
+		"224.0.0.1":            false,
+		"::1":                  false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+		"64:ff9b::a00:1":       false,
+	}
+	for addr, want := range tests {
+		assert.Equal(t, want, IsPublicIP(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestIsPublicURL(t *testing.T) {
+	fakeLookup(t, map[string][]string{
+		"example.com":   {"93.184.216.34"},
+		"internal.test": {"10.0.0.5"},
+		"mixed.test":    {"93.184.216.34", "192.168.0.10"},
+	})
+
+	tests := map[string]bool{
+		"https://example.com/hook":           true,
+		"http://93.184.216.34:8080/progress": true,
+		"http://127.0.0.1:8080/progress":     false,
+		"http://10.0.0.1/progress":           false,
+		"http://169.254.169.254/latest/meta": false,
+		"http://[::1]/progress":              false,
+		"http://localhost/progress":          false,
+		"http://LOCALHOST./progress":         false,
+		"http://internal.test/progress":      false,
+		"http://mixed.test/progress":         false,
+		"http://unresolvable.test/progress":  false,
+		"ftp://example.com/progress":         false,
+		"file:///etc/passwd":                 false,
+		"https:///no-host":                   false,
+		"://bad":                             false,
+	}
+	for rawURL, want := range tests {
+		assert.Equal(t, want, IsPublicURL(rawURL), rawURL)
+	}
+}
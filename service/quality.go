@@ -0,0 +1,153 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// QualityUnavailableError is returned when a video has no format with the
+// requested resolution or codec and the request asked not to fall back. It
+// lists what the video does offer.
+type QualityUnavailableError struct {
+	Resolution  string   // Requested height, empty when not requested
+	Codec       string   // Requested codec, empty when not requested
+	Resolutions []int    // Available heights, highest first
+	Codecs      []string // Available video codecs, e.g. "avc1", "vp9"
+}
+
+func (e *QualityUnavailableError) Error() string {
+	heights := make([]string, len(e.Resolutions))
+	for i, h := range e.Resolutions {
+		heights[i] = fmt.Sprintf("%dp", h)
+	}
+	return fmt.Sprintf("requested quality %s is not available, available resolutions: %s, available codecs: %s",
+		describeQuality(e.Resolution, e.Codec), strings.Join(heights, ", "), strings.Join(e.Codecs, ", "))
+}
+
+// describeQuality formats a resolution and codec pair, e.g. "1080p avc1".
+func describeQuality(resolution, codec string) string {
+	var parts []string
+	if resolution != "" {
+		parts = append(parts, resolution+"p")
+	}
+	if codec != "" {
+		parts = append(parts, codec)
+	}
+	return strings.Join(parts, " ")
+}
+
+// shortCodec strips the profile from a yt-dlp vcodec, e.g. "avc1.64001F"
+// becomes "avc1".
+func shortCodec(vcodec string) string {
+	codec, _, _ := strings.Cut(vcodec, ".")
+	return codec
+}
+
+// resolveQuality checks the resolution and codec the client asked for
+// against the video's formats, so a download is not silently worse than
+// requested. When they are not available it returns a QualityUnavailableError
+// with StrictQuality, or else switches the options to the closest available
+// quality and returns a warning saying so. Options left empty, progressive
+// downloads (see checkProgressive), explicit FormatIDs and videos without
+// format details are not checked.
+func resolveQuality(opts VideoOptions, formats []VideoInfo) (VideoOptions, string, error) {
+	if opts.Progressive || opts.FormatID != "" || (opts.Resolution == "" && opts.Codec == "") {
+		return opts, "", nil
+	}
+	target, err := strconv.Atoi(opts.Resolution)
+	if opts.Resolution != "" && err != nil {
+		return opts, "", nil // Not a height; left for yt-dlp to judge
+	}
+
+	var video []VideoInfo
+	var heights []int
+	var codecs []string
+	for _, f := range formats {
+		if f.VCodec == "" || f.VCodec == "none" || f.Height == 0 {
+			continue
+		}
+		video = append(video, f)
+		if !slices.Contains(heights, f.Height) {
+			heights = append(heights, f.Height)
+		}
+		if codec := shortCodec(f.VCodec); !slices.Contains(codecs, codec) {
+			codecs = append(codecs, codec)
+		}
+	}
+	if len(video) == 0 {
+		return opts, "", nil
+	}
+	slices.Sort(heights)
+	slices.Reverse(heights)
+	slices.Sort(codecs)
+
+	// The formats the codec part of the request allows, if any
+	candidates := video
+	if opts.Codec != "" {
+		candidates = nil
+		for _, f := range video {
+			if strings.Contains(f.VCodec, opts.Codec) {
+				candidates = append(candidates, f)
+			}
+		}
+	}
+	if len(candidates) > 0 && (opts.Resolution == "" || hasHeight(candidates, target)) {
+		return opts, "", nil
+	}
+
+	if opts.StrictQuality {
+		return opts, "", &QualityUnavailableError{
+			Resolution:  opts.Resolution,
+			Codec:       opts.Codec,
+			Resolutions: heights,
+			Codecs:      codecs,
+		}
+	}
+
+	if len(candidates) == 0 {
+		candidates = video // Keep the resolution, give up on the codec
+	}
+	closest := closestHeight(candidates, target)
+	requested := describeQuality(opts.Resolution, opts.Codec)
+	if opts.Resolution != "" {
+		opts.Resolution = strconv.Itoa(closest.Height)
+	}
+	if opts.Codec != "" {
+		opts.Codec = shortCodec(closest.VCodec)
+	}
+	warning := fmt.Sprintf("Requested %s is not available, using %s instead.", requested, describeQuality(opts.Resolution, opts.Codec))
+	return opts, warning, nil
+}
+
+// hasHeight reports whether one of formats is exactly height pixels high.
+func hasHeight(formats []VideoInfo, height int) bool {
+	for _, f := range formats {
+		if f.Height == height {
+			return true
+		}
+	}
+	return false
+}
+
+// closestHeight returns the format closest to target height: the highest
+// one below it, or the lowest one above it when none is below. A zero target
+// picks the highest format.
+func closestHeight(formats []VideoInfo, target int) VideoInfo {
+	var below, above *VideoInfo
+	for i := range formats {
+		f := &formats[i]
+		if target == 0 || f.Height <= target {
+			if below == nil || f.Height > below.Height {
+				below = f
+			}
+		} else if above == nil || f.Height < above.Height {
+			above = f
+		}
+	}
+	if below != nil {
+		return *below
+	}
+	return *above
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var qualityTestFormats = []VideoInfo{
+	{FormatID: "a", VCodec: "none", ACodec: "opus"},
+	{FormatID: "v360", VCodec: "avc1.4D401E", Height: 360},
+	{FormatID: "v720", VCodec: "avc1.64001F", Height: 720},
+	{FormatID: "v720-vp9", VCodec: "vp9", Height: 720},
+	{FormatID: "v1440-vp9", VCodec: "vp9", Height: 1440},
+}
+
+func TestResolveQuality(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           VideoOptions
+		wantResolution string
+		wantCodec      string
+		wantWarning    string
+	}{
+		{"not requested", VideoOptions{}, "", "", ""},
+		{"available", VideoOptions{Resolution: "720", Codec: "avc1"}, "720", "avc1", ""},
+		{"codec only", VideoOptions{Codec: "vp9"}, "", "vp9", ""},
+		{"resolution falls back below", VideoOptions{Resolution: "1080", Codec: "avc1"}, "720", "avc1",
+			"Requested 1080p avc1 is not available, using 720p avc1 instead."},
+		{"resolution falls back above", VideoOptions{Resolution: "240"}, "360", "",
+			"Requested 240p is not available, using 360p instead."},
+		{"codec falls back", VideoOptions{Resolution: "1440", Codec: "av01"}, "1440", "vp9",
+			"Requested 1440p av01 is not available, using 1440p vp9 instead."},
+		{"progressive is not checked", VideoOptions{Resolution: "1080", Progressive: true}, "1080", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, warning, err := resolveQuality(tt.opts, qualityTestFormats)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantResolution, opts.Resolution)
+			assert.Equal(t, tt.wantCodec, opts.Codec)
+			assert.Equal(t, tt.wantWarning, warning)
+		})
+	}
+}
+
+func TestResolveQuality_Strict(t *testing.T) {
+	_, _, err := resolveQuality(VideoOptions{Resolution: "1080", Codec: "avc1", StrictQuality: true}, qualityTestFormats)
+
+	var qualityErr *QualityUnavailableError
+	if assert.ErrorAs(t, err, &qualityErr) {
+		assert.Equal(t, []int{1440, 720, 360}, qualityErr.Resolutions)
+		assert.Equal(t, []string{"avc1", "vp9"}, qualityErr.Codecs)
+	}
+	assert.EqualError(t, err, "requested quality 1080p avc1 is not available, available resolutions: 1440p, 720p, 360p, available codecs: avc1, vp9")
+}
+
+func TestResolveQuality_NoFormatDetails(t *testing.T) {
+	opts := VideoOptions{Resolution: "1080", StrictQuality: true}
+	got, warning, err := resolveQuality(opts, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, warning)
+	assert.Equal(t, opts, got)
+}
+
+func TestDownloadVideoToFile_UnavailableResolution(t *testing.T) {
+	// multiAudioScript only offers a 720p avc1 video format
+	d := newFakeDownloader(t, multiAudioScript)
+
+	filePath, _, warnings, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{Resolution: "1080"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Requested 1080p is not available, using 720p instead."}, warnings)
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Split(string(data), "\n"), "bestvideo[height<=720][vcodec*=avc1]+bestaudio/best")
+
+	_, _, _, err = d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{Resolution: "1080", StrictQuality: true}, "")
+	var qualityErr *QualityUnavailableError
+	assert.ErrorAs(t, err, &qualityErr)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// recodeRetryDelay is the pause before each recode retry, giving whatever
+// ran out (disk space, memory) a chance to free up. Tests shorten it.
+var recodeRetryDelay = 2 * time.Second
+
+// transientRecodeErrors are the ffmpeg and OS messages of recode failures
+// that may not happen again, unlike those caused by the media itself.
+var transientRecodeErrors = []string{
+	"No space left on device",
+	"Disk quota exceeded",
+	"Cannot allocate memory",
+	"Resource temporarily unavailable",
+	"Too many open files",
+	"Input/output error",
+}
+
+// transientFailure reports whether output shows a failure for a reason
+// worth retrying.
+func transientFailure(output string) bool {
+	for _, message := range transientRecodeErrors {
+		if strings.Contains(output, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryRecode recovers a download whose recode yt-dlp gave up on for a
+// transient reason: it converts the media yt-dlp left next to target into
+// target's format with ffmpeg, up to RECODE_RETRIES times, so the download
+// itself is not repeated. It reports whether target was produced; on
+// failure the downloaded media is left for the caller's own fallback.
+func (d *Downloader) retryRecode(ctx context.Context, stderr, target, progressID string) bool {
+	retries := d.config().RecodeRetries
+	if retries <= 0 || !recodeFailed(stderr) || !transientFailure(stderr) {
+		return false
+	}
+	source := findDownloadedSource(target)
+	if source == "" || source == target {
+		return false
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(target), ".")
+	for attempt := 1; attempt <= retries; attempt++ {
+		slog.Warn("Recoding failed, retrying", "format", format, "source", source, "attempt", attempt, "retries", retries)
+		d.progressManager.SendEvent(ProgressEvent{
+			ID:         progressID,
+			Status:     "retrying_encode",
+			Message:    fmt.Sprintf("Converting to %s failed, retrying (attempt %d of %d)...", format, attempt, retries),
+			Percentage: 80,
+		})
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(recodeRetryDelay):
+		}
+
+		err := d.recode(ctx, source, target)
+		if err == nil {
+			os.Remove(source)
+			return true
+		}
+		slog.Warn("Recode retry failed", "source", source, "attempt", attempt, "error", err)
+		if !transientFailure(err.Error()) {
+			return false
+		}
+	}
+	return false
+}
+
+// recodeFallback returns the media yt-dlp downloaded next to target before
+// failing to recode it, to be kept instead, along with the warning telling
+// the client so. It returns an empty path when the download itself failed,
+// or with opts.StrictFormat.
+func (d *Downloader) recodeFallback(opts VideoOptions, stderr, target, progressID string) (source, warning string) {
+	if opts.StrictFormat || !recodeFailed(stderr) {
+		return "", ""
+	}
+	source = findDownloadedSource(target)
+	if source == "" {
+		return "", ""
+	}
+
+	slog.Warn("Recoding failed, keeping original download", "format", opts.Format, "filePath", source)
+	warning = fmt.Sprintf("Could not convert to %s, keeping the original %s file.", opts.Format, strings.TrimPrefix(filepath.Ext(source), "."))
+	d.progressManager.SendEvent(ProgressEvent{
+		ID:         progressID,
+		Status:     "warning",
+		Message:    warning,
+		Percentage: 90,
+	})
+	return source, warning
+}
+
+// recode converts source into target, the format following from target's
+// extension, as yt-dlp's --recode-video does. It writes to a temporary file
+// first, so a failed run never leaves a partial target behind.
+func (d *Downloader) recode(ctx context.Context, source, target string) (err error) {
+	ctx, span := startSpan(ctx, "encode recode", "", attribute.String("media.format", strings.TrimPrefix(filepath.Ext(target), ".")))
+	defer func() { endSpan(span, target, err) }()
+
+	tmpPath := strings.TrimSuffix(target, filepath.Ext(target)) + ".recode" + filepath.Ext(target)
+	args := []string{"-y"}
+	if d.config().FFmpegLogLevel != "" {
+		args = append(args, "-loglevel", d.config().FFmpegLogLevel)
+	}
+	args = append(args, "-i", source, tmpPath)
+	cmd := exec.CommandContext(ctx, d.config().FFMPEGPath, args...)
+	slog.Debug(fmt.Sprintf("Executing ffmpeg for recode: %s %s", d.config().FFMPEGPath, strings.Join(args, " ")))
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg recode failed: %w, stderr: %s", err, stderr.String())
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save recoded file: %w", err)
+	}
+	return nil
+}
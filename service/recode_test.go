@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recodeNoSpaceScript downloads a webm next to the requested output, then
+// fails the way yt-dlp does when the disk fills up during the recode.
+const recodeNoSpaceScript = fakeInfoPrelude + `
+printf 'webm-data' > "${out%.*}.webm"
+echo "ERROR: Postprocessing: Conversion failed! [Errno 28] No space left on device" >&2
+exit 1
+`
+
+// flakyFFmpegScript fails with a full disk for its first failures runs, then
+// writes mp4-data to its output, the last argument. Each run is counted
+// in the runs file next to it.
+func flakyFFmpegScript(t *testing.T, failures int) (path, runsFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	runsFile = filepath.Join(dir, "runs")
+	script := `#!/bin/sh
+echo run >> "` + runsFile + `"
+if [ "$(wc -l < "` + runsFile + `")" -le ` + strconv.Itoa(failures) + ` ]; then
+	echo "Error writing trailer: No space left on device" >&2
+	exit 1
+fi
+for last; do :; done
+printf 'mp4-data' > "$last"
+`
+	path = filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake ffmpeg: %v", err)
+	}
+	return path, runsFile
+}
+
+// countRuns returns how many times the fake ffmpeg ran.
+func countRuns(t *testing.T, runsFile string) int {
+	t.Helper()
+	data, err := os.ReadFile(runsFile)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	assert.NoError(t, err)
+	return strings.Count(string(data), "run\n")
+}
+
+func noRecodeRetryDelay(t *testing.T) {
+	t.Helper()
+	delay := recodeRetryDelay
+	recodeRetryDelay = 0
+	t.Cleanup(func() { recodeRetryDelay = delay })
+}
+
+func TestDownloadVideoToFile_RecodeRetriedAfterTransientFailure(t *testing.T) {
+	noRecodeRetryDelay(t)
+	d := newFakeDownloader(t, recodeNoSpaceScript)
+	ffmpegPath, runsFile := flakyFFmpegScript(t, 1)
+	d.config().FFMPEGPath = ffmpegPath
+	d.config().RecodeRetries = 2
+
+	// Buffered, so no event is dropped
+	d.progressManager = NewProgressManager(32)
+	events := make(chan []ProgressEvent)
+	ch := d.progressManager.RegisterClient("p1")
+	go func() { events <- drain(ch, 0) }()
+
+	filePath, _, warnings, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "p1")
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, ".mp4", filepath.Ext(filePath))
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "mp4-data", string(data))
+	assert.Equal(t, 2, countRuns(t, runsFile))
+
+	// Only the recoded file is left
+	entries, _ := os.ReadDir(filepath.Dir(filePath))
+	assert.Len(t, entries, 1)
+
+	var retries int
+	for _, event := range <-events {
+		if event.Status == "retrying_encode" {
+			retries++
+		}
+	}
+	assert.Equal(t, 2, retries)
+}
+
+func TestDownloadVideoToFile_RecodeRetriesExhausted(t *testing.T) {
+	noRecodeRetryDelay(t)
+	d := newFakeDownloader(t, recodeNoSpaceScript)
+	ffmpegPath, runsFile := flakyFFmpegScript(t, 9)
+	d.config().FFMPEGPath = ffmpegPath
+	d.config().RecodeRetries = 2
+
+	// Falls back to keeping the original, as without retries
+	filePath, _, warnings, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, ".webm", filepath.Ext(filePath))
+	assert.Equal(t, []string{"Could not convert to mp4, keeping the original webm file."}, warnings)
+	assert.Equal(t, 2, countRuns(t, runsFile))
+}
+
+func TestDownloadVideoToFile_RecodeNotRetried(t *testing.T) {
+	noRecodeRetryDelay(t)
+	for name, tc := range map[string]struct {
+		script  string
+		retries int
+	}{
+		"NotTransient": {recodeFailsScript, 2},
+		"Disabled":     {recodeNoSpaceScript, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := newFakeDownloader(t, tc.script)
+			ffmpegPath, runsFile := flakyFFmpegScript(t, 0)
+			d.config().FFMPEGPath = ffmpegPath
+			d.config().RecodeRetries = tc.retries
+
+			filePath, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+			assert.NoError(t, err)
+			assert.Equal(t, ".webm", filepath.Ext(filePath))
+			assert.Zero(t, countRuns(t, runsFile))
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// ErrLiveSave is returned when asked to save a live broadcast, which has no
+// end and would grow the file for as long as it runs.
+var ErrLiveSave = errors.New("live streams cannot be saved")
+
+// SavingStream wraps a media stream so that everything read from it is also
+// written to a file in the download directory, saving a second download of
+// the same media. The file is only kept when the stream was read to the end
+// and the source closed cleanly; otherwise Close removes the partial file.
+type SavingStream struct {
+	src  io.ReadCloser
+	tee  io.Reader
+	file *os.File
+	path string
+	eof  bool
+}
+
+// SaveStream starts saving src, a MediaVideo or MediaAudio stream, to a new
+// file named after the video id, with the usual naming rules of file
+// downloads of the kind (ON_EXISTS, ORGANIZE_BY_DATE, VIDEO_DIR/AUDIO_DIR).
+// The returned stream takes ownership of src.
+func (d *Downloader) SaveStream(src io.ReadCloser, kind, id, ext string) (*SavingStream, error) {
+	path, err := d.outputPath(kind, id, ext, "")
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	return &SavingStream{
+		src:  src,
+		tee:  io.TeeReader(src, file),
+		file: file,
+		path: path,
+	}, nil
+}
+
+// CheckSavable reports whether a stream of videoInfo may be saved to disk.
+// Saves are file downloads, so MAX_DURATION applies to them in full rather
+// than cutting them short as it does plain streams, and live broadcasts are
+// refused with ErrLiveSave.
+func (d *Downloader) CheckSavable(videoInfo *VideoInfo) error {
+	if videoInfo.IsLive {
+		return ErrLiveSave
+	}
+	return d.checkDuration(videoInfo, "")
+}
+
+// SaveContext returns the context to run a saved stream under. It keeps the
+// values of ctx but not its cancellation, so the save can finish after the
+// client goes away, and is cancelled instead after STREAM_SAVE_TIMEOUT or by
+// Shutdown. Call cancel once the save is done.
+func (d *Downloader) SaveContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	var cancel context.CancelFunc
+	if timeout := d.config().StreamSaveTimeout; timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+	}
+	stop := context.AfterFunc(d.lifetime, cancel)
+
+	d.saves.Add(1)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			stop()
+			cancel()
+			d.saves.Done()
+		})
+	}
+}
+
+// Shutdown cancels the saves still running under SaveContext, typically
+// those whose client went away, and waits for them to clean up their
+// partial files, or for ctx to be done.
+func (d *Downloader) Shutdown(ctx context.Context) error {
+	d.stop()
+	done := make(chan struct{})
+	go func() {
+		d.saves.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Path returns the path the stream is saved to.
+func (s *SavingStream) Path() string {
+	return s.path
+}
+
+// Read reads from the source stream, writing what it read to the file. A
+// failed file write is returned as a read error.
+func (s *SavingStream) Read(p []byte) (int, error) {
+	n, err := s.tee.Read(p)
+	if err == io.EOF {
+		s.eof = true
+	}
+	return n, err
+}
+
+// Finish saves the rest of the stream without a reader on the other end,
+// then closes it. It is used to complete the save after the client went away.
+func (s *SavingStream) Finish() error {
+	_, err := io.Copy(io.Discard, s)
+	if closeErr := s.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Close closes the source stream and the file. When the stream was not read
+// to the end or the source failed, the partial file is removed and an error
+// is returned.
+func (s *SavingStream) Close() error {
+	err := errors.Join(s.src.Close(), s.file.Close())
+	if err == nil && !s.eof {
+		err = errors.New("stream closed before the end")
+	}
+	if err != nil {
+		if removeErr := os.Remove(s.path); removeErr != nil {
+			slog.Error("Failed to remove partially saved stream", "filePath", s.path, "error", removeErr)
+		}
+		return fmt.Errorf("stream not saved: %w", err)
+	}
+	slog.Info("Saved stream to file", "filePath", s.path)
+	return nil
+}
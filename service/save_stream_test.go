@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStream is a stream source whose Close returns closeErr.
+type fakeStream struct {
+	io.Reader
+	closeErr error
+}
+
+func (f *fakeStream) Close() error { return f.closeErr }
+
+func TestSavingStream(t *testing.T) {
+	t.Run("ReadToEnd", func(t *testing.T) {
+		d := newFakeDownloader(t, "")
+		stream, err := d.SaveStream(&fakeStream{Reader: strings.NewReader("0123456789")}, MediaVideo, "abc123", "mp4")
+		if !assert.NoError(t, err) {
+			return
+		}
+		data, err := io.ReadAll(stream)
+		assert.NoError(t, err)
+		assert.Equal(t, "0123456789", string(data))
+		assert.NoError(t, stream.Close())
+
+		saved, err := os.ReadFile(stream.Path())
+		assert.NoError(t, err)
+		assert.Equal(t, "0123456789", string(saved))
+	})
+
+	t.Run("ClosedEarly", func(t *testing.T) {
+		d := newFakeDownloader(t, "")
+		stream, err := d.SaveStream(&fakeStream{Reader: strings.NewReader("0123456789")}, MediaVideo, "abc123", "mp4")
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = stream.Read(make([]byte, 4))
+		assert.NoError(t, err)
+		assert.Error(t, stream.Close())
+		assert.NoFileExists(t, stream.Path(), "a partial file must not be kept")
+	})
+
+	t.Run("SourceFails", func(t *testing.T) {
+		d := newFakeDownloader(t, "")
+		src := &fakeStream{Reader: strings.NewReader("01234"), closeErr: errors.New("yt-dlp exited with status 1")}
+		stream, err := d.SaveStream(src, MediaVideo, "abc123", "mp4")
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = io.ReadAll(stream)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, stream.Close(), "exited with status 1")
+		assert.NoFileExists(t, stream.Path())
+	})
+
+	t.Run("FinishWithoutReader", func(t *testing.T) {
+		d := newFakeDownloader(t, "")
+		stream, err := d.SaveStream(&fakeStream{Reader: strings.NewReader("0123456789")}, MediaVideo, "abc123", "mp4")
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = stream.Read(make([]byte, 4))
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Finish())
+
+		saved, err := os.ReadFile(stream.Path())
+		assert.NoError(t, err)
+		assert.Equal(t, "0123456789", string(saved))
+	})
+}
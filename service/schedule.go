@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Statuses of a scheduled download.
+const (
+	JobScheduled = "scheduled" // Waiting for its start time
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+const (
+	// scheduledJobRetention is how long a finished or cancelled job can
+	// still be looked up before it is forgotten.
+	scheduledJobRetention = time.Hour
+	// MaxPendingJobs bounds the jobs waiting for their start time or
+	// running, which are all kept in memory.
+	MaxPendingJobs = 100
+	// MaxScheduleAhead is how far in the future a job can be scheduled.
+	MaxScheduleAhead = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrJobNotFound is returned for an unknown, or already forgotten, job.
+	ErrJobNotFound = errors.New("scheduled job not found")
+	// ErrJobStarted is returned when cancelling a job that is no longer
+	// waiting for its start time.
+	ErrJobStarted = errors.New("scheduled job already started")
+	// ErrTooManyJobs is returned when MaxPendingJobs jobs are already
+	// pending.
+	ErrTooManyJobs = errors.New("too many scheduled downloads pending, try again later")
+	// ErrStartTooLate is returned for a start time more than
+	// MaxScheduleAhead away.
+	ErrStartTooLate = fmt.Errorf("startAt must be at most %d days ahead", MaxScheduleAhead/(24*time.Hour))
+)
+
+// ScheduledDownload is the video download a scheduled job runs.
+type ScheduledDownload struct {
+	URL     string
+	Options VideoOptions
+	// SeparateAudio downloads the video and audio as two files, as
+	// DownloadSeparateStreams does
+	SeparateAudio bool
+	// ProgressCallbackURL receives the job's progress events once it runs
+	ProgressCallbackURL string
+	// ExpectedSHA256 fails the job, deleting the file, unless the file has
+	// this SHA-256; see VerifyDownload
+	ExpectedSHA256 string
+}
+
+// ScheduledJob is the state of a scheduled download, as reported to clients.
+type ScheduledJob struct {
+	ID            string    `json:"id"`
+	URL           string    `json:"url"`
+	StartAt       time.Time `json:"startAt"`
+	Status        string    `json:"status"` // scheduled, running, done, failed or cancelled
+	FilePath      string    `json:"filePath,omitempty"`
+	AudioFilePath string    `json:"audioFilePath,omitempty"`
+	SHA256        string    `json:"sha256,omitempty"` // Checksum of FilePath
+	Error         string    `json:"error,omitempty"`
+}
+
+// scheduledJob is a job along with what it needs to run.
+type scheduledJob struct {
+	ScheduledJob
+	download ScheduledDownload
+	timer    *time.Timer
+}
+
+// Scheduler runs video downloads at a later time, e.g. off-peak. Jobs are
+// kept in memory: those still waiting are lost on restart. A job that
+// starts while MAX_CONCURRENT_DOWNLOADS downloads are running waits for one
+// of them to finish.
+type Scheduler struct {
+	downloader *Downloader
+
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+}
+
+// NewScheduler creates a Scheduler running its jobs with downloader.
+func NewScheduler(downloader *Downloader) *Scheduler {
+	return &Scheduler{
+		downloader: downloader,
+		jobs:       make(map[string]*scheduledJob),
+	}
+}
+
+// Schedule queues download to start at startAt, or right away if that time
+// has passed, and returns the new job. It fails with ErrStartTooLate past
+// MaxScheduleAhead, and with ErrTooManyJobs once MaxPendingJobs are pending.
+func (s *Scheduler) Schedule(download ScheduledDownload, startAt time.Time) (ScheduledJob, error) {
+	if time.Until(startAt) > MaxScheduleAhead {
+		return ScheduledJob{}, ErrStartTooLate
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := 0
+	for _, job := range s.jobs {
+		if job.Status == JobScheduled || job.Status == JobRunning {
+			pending++
+		}
+	}
+	if pending >= MaxPendingJobs {
+		return ScheduledJob{}, ErrTooManyJobs
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return ScheduledJob{}, err
+	}
+	job := &scheduledJob{
+		ScheduledJob: ScheduledJob{
+			ID:      id,
+			URL:     download.URL,
+			StartAt: startAt,
+			Status:  JobScheduled,
+		},
+		download: download,
+	}
+	s.jobs[job.ID] = job
+	job.timer = time.AfterFunc(time.Until(startAt), func() { s.run(job) })
+
+	slog.Info("Download scheduled", "jobID", job.ID, "url", download.URL, "startAt", startAt)
+	return job.ScheduledJob, nil
+}
+
+// newJobID returns a random job ID, e.g. "job-3f9c...", which clients
+// cannot guess to look up or cancel each other's jobs.
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return "job-" + hex.EncodeToString(b), nil
+}
+
+// Job returns the state of the job with the given ID.
+func (s *Scheduler) Job(id string) (ScheduledJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return ScheduledJob{}, ErrJobNotFound
+	}
+	return job.ScheduledJob, nil
+}
+
+// Cancel stops a job that has not started yet, and returns its state.
+func (s *Scheduler) Cancel(id string) (ScheduledJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return ScheduledJob{}, ErrJobNotFound
+	}
+	// The status check covers a timer that fired but whose run has yet to
+	// take the lock: run skips jobs that are no longer scheduled.
+	if job.Status != JobScheduled {
+		return job.ScheduledJob, ErrJobStarted
+	}
+	job.timer.Stop()
+	job.Status = JobCancelled
+	s.forgetLater(job.ID)
+
+	slog.Info("Scheduled download cancelled", "jobID", job.ID)
+	return job.ScheduledJob, nil
+}
+
+// run downloads a due job and records the outcome.
+func (s *Scheduler) run(job *scheduledJob) {
+	s.mu.Lock()
+	if job.Status != JobScheduled {
+		s.mu.Unlock()
+		return
+	}
+	job.Status = JobRunning
+	s.mu.Unlock()
+
+	slog.Info("Starting scheduled download", "jobID", job.ID, "url", job.download.URL)
+	progressID := ""
+	if job.download.ProgressCallbackURL != "" {
+		var release func()
+		progressID, release = s.downloader.RegisterProgressCallback(job.download.ProgressCallbackURL)
+		defer release()
+	}
+
+	// The request that scheduled the job is long gone. Waiting for a slot
+	// beats failing a job nobody is there to retry
+	ctx := waitForSlot(context.Background())
+	var filePath, audioFilePath string
+	var err error
+	if job.download.SeparateAudio {
+		filePath, audioFilePath, _, _, err = s.downloader.DownloadSeparateStreams(ctx, job.download.URL, job.download.Options, progressID)
+	} else {
+		filePath, _, _, err = s.downloader.DownloadVideoToFile(ctx, job.download.URL, job.download.Options, progressID)
+	}
+	var sum string
+	if err == nil {
+		sum, err = VerifyDownload(filePath, job.download.ExpectedSHA256)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		slog.Error("Scheduled download failed", "jobID", job.ID, "url", job.download.URL, "error", err)
+		job.Status = JobFailed
+		job.Error = err.Error()
+	} else {
+		slog.Info("Scheduled download finished", "jobID", job.ID, "filePath", filePath)
+		job.Status = JobDone
+		job.FilePath = filePath
+		job.AudioFilePath = audioFilePath
+		job.SHA256 = sum
+	}
+	s.forgetLater(job.ID)
+}
+
+// forgetLater drops a finished job once clients had time to look it up.
+// The caller holds s.mu.
+func (s *Scheduler) forgetLater(id string) {
+	time.AfterFunc(scheduledJobRetention, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.jobs, id)
+	})
+}
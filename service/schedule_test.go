@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_RunsDueJob(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude+`printf 'video' > "$out"
+`)
+	s := NewScheduler(d)
+
+	job, err := s.Schedule(ScheduledDownload{URL: "https://example.com/v"}, time.Now().Add(50*time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, JobScheduled, job.Status)
+	assert.NotEmpty(t, job.ID)
+
+	assert.Eventually(t, func() bool {
+		job, _ = s.Job(job.ID)
+		return job.Status == JobDone
+	}, 5*time.Second, 10*time.Millisecond)
+	data, err := os.ReadFile(job.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+	assert.Empty(t, job.Error)
+}
+
+func TestScheduler_FailedJob(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude+`echo "ERROR: unable to download video data: HTTP Error 403" >&2
+exit 1
+`)
+	s := NewScheduler(d)
+
+	job, err := s.Schedule(ScheduledDownload{URL: "https://example.com/v"}, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		job, _ = s.Job(job.ID)
+		return job.Status == JobFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, job.Error, "HTTP Error 403")
+	assert.Empty(t, job.FilePath)
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude+`printf 'video' > "$out"
+`)
+	s := NewScheduler(d)
+
+	job, err := s.Schedule(ScheduledDownload{URL: "https://example.com/v"}, time.Now().Add(100*time.Millisecond))
+	assert.NoError(t, err)
+	job, err = s.Cancel(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, JobCancelled, job.Status)
+
+	// The job never runs, and cannot be cancelled twice
+	time.Sleep(200 * time.Millisecond)
+	job, _ = s.Job(job.ID)
+	assert.Equal(t, JobCancelled, job.Status)
+	entries, _ := os.ReadDir(d.config().DownloadDir)
+	assert.Empty(t, entries)
+	_, err = s.Cancel(job.ID)
+	assert.ErrorIs(t, err, ErrJobStarted)
+
+	_, err = s.Cancel("job-unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = s.Job("job-unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestScheduler_Limits(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude+`printf 'video' > "$out"
+`)
+	s := NewScheduler(d)
+
+	_, err := s.Schedule(ScheduledDownload{URL: "https://example.com/v"}, time.Now().Add(MaxScheduleAhead+time.Hour))
+	assert.ErrorIs(t, err, ErrStartTooLate)
+
+	ids := make(map[string]bool)
+	for range MaxPendingJobs {
+		job, err := s.Schedule(ScheduledDownload{URL: "https://example.com/v"}, time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Regexp(t, `^job-[0-9a-f]{32}$`, job.ID)
+		assert.False(t, ids[job.ID], "duplicate job ID %s", job.ID)
+		ids[job.ID] = true
+	}
+	_, err = s.Schedule(ScheduledDownload{URL: "https://example.com/v"}, time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrTooManyJobs)
+
+	// Cancelled jobs no longer count
+	for id := range ids {
+		_, err = s.Cancel(id)
+		assert.NoError(t, err)
+		break
+	}
+	_, err = s.Schedule(ScheduledDownload{URL: "https://example.com/v"}, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+}
+
+func TestScheduler_WaitsForDownloadSlot(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude+`printf 'video' > "$out"
+`)
+	d.downloadLimit.setLimits(1, 0)
+	release, err := d.downloadLimit.acquire(context.Background())
+	assert.NoError(t, err)
+	s := NewScheduler(d)
+
+	job, err := s.Schedule(ScheduledDownload{URL: "https://example.com/v"}, time.Now())
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	job, _ = s.Job(job.ID)
+	assert.Equal(t, JobRunning, job.Status)
+
+	release()
+	assert.Eventually(t, func() bool {
+		job, _ = s.Job(job.ID)
+		return job.Status == JobDone
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// SelectedFormat describes the format yt-dlp actually picked for a download,
+// which can differ from the request since selectors fall back (e.g. to a
+// lower resolution or another codec).
+type SelectedFormat struct {
+	FormatID string  `json:"formatId"`
+	Ext      string  `json:"ext"`
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	FPS      float64 `json:"fps,omitempty"`
+	VCodec   string  `json:"vcodec,omitempty"`
+	ACodec   string  `json:"acodec,omitempty"`
+	FileSize int64   `json:"filesize,omitempty"` // Bytes, exact or estimated by yt-dlp
+}
+
+// printedFormat holds the format fields of the info JSON yt-dlp prints with
+// --print-json.
+type printedFormat struct {
+	FormatID       string          `json:"format_id"`
+	Ext            string          `json:"ext"`
+	Width          int             `json:"width"`
+	Height         int             `json:"height"`
+	FPS            float64         `json:"fps"`
+	VCodec         string          `json:"vcodec"`
+	ACodec         string          `json:"acodec"`
+	FileSize       int64           `json:"filesize"`
+	FileSizeApprox float64         `json:"filesize_approx"`
+	Requested      []printedFormat `json:"requested_formats"`
+}
+
+// size returns the exact file size when known, else yt-dlp's estimate.
+func (f printedFormat) size() int64 {
+	if f.FileSize > 0 {
+		return f.FileSize
+	}
+	return int64(f.FileSizeApprox)
+}
+
+// parseSelectedFormat reads the format yt-dlp picked from the --print-json
+// output of a download. The JSON is the last line starting with "{"; other
+// stdout lines are ignored. For merged downloads, the size is the sum of the
+// requested formats when yt-dlp does not report one for the whole.
+func parseSelectedFormat(stdout []byte) (*SelectedFormat, error) {
+	var line []byte
+	for _, l := range bytes.Split(stdout, []byte("\n")) {
+		if l = bytes.TrimSpace(l); bytes.HasPrefix(l, []byte("{")) {
+			line = l
+		}
+	}
+	if line == nil {
+		return nil, fmt.Errorf("no format info in yt-dlp output")
+	}
+
+	var info printedFormat
+	if err := json.Unmarshal(line, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse printed format info: %w", err)
+	}
+	format := &SelectedFormat{
+		FormatID: info.FormatID,
+		Ext:      info.Ext,
+		Width:    info.Width,
+		Height:   info.Height,
+		FPS:      info.FPS,
+		VCodec:   info.VCodec,
+		ACodec:   info.ACodec,
+		FileSize: info.size(),
+	}
+	if format.FileSize == 0 {
+		for _, requested := range info.Requested {
+			format.FileSize += requested.size()
+		}
+	}
+	return format, nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelectedFormat(t *testing.T) {
+	t.Run("Merged", func(t *testing.T) {
+		stdout := `[download] Destination: video.f137.mp4
+{"id":"abc123","format_id":"137+140","ext":"mp4","width":1920,"height":1080,"fps":30,"vcodec":"avc1.640028","acodec":"mp4a.40.2","filesize":null,"requested_formats":[{"format_id":"137","filesize":1000},{"format_id":"140","filesize_approx":250.5}]}
+`
+		format, err := parseSelectedFormat([]byte(stdout))
+		assert.NoError(t, err)
+		assert.Equal(t, &SelectedFormat{
+			FormatID: "137+140",
+			Ext:      "mp4",
+			Width:    1920,
+			Height:   1080,
+			FPS:      30,
+			VCodec:   "avc1.640028",
+			ACodec:   "mp4a.40.2",
+			FileSize: 1250,
+		}, format)
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		format, err := parseSelectedFormat([]byte(`{"format_id":"18","ext":"mp4","height":360,"vcodec":"avc1","acodec":"mp4a","filesize":42}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "18", format.FormatID)
+		assert.Equal(t, 360, format.Height)
+		assert.Equal(t, int64(42), format.FileSize)
+	})
+
+	t.Run("NoJSON", func(t *testing.T) {
+		_, err := parseSelectedFormat([]byte("[download] 100% of 10.00MiB\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestDownloadVideoToFile_SelectedFormat(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude+`
+printf 'video' > "$out"
+echo '{"id":"abc123","format_id":"136+140","ext":"mp4","height":720,"vcodec":"avc1.4d401f","acodec":"mp4a.40.2","filesize":5000}'
+`)
+
+	_, videoInfo, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.NoError(t, err)
+	if assert.NotNil(t, videoInfo.SelectedFormat) {
+		assert.Equal(t, "136+140", videoInfo.SelectedFormat.FormatID)
+		assert.Equal(t, 720, videoInfo.SelectedFormat.Height)
+		assert.Equal(t, "avc1.4d401f", videoInfo.SelectedFormat.VCodec)
+		assert.Equal(t, "mp4a.40.2", videoInfo.SelectedFormat.ACodec)
+		assert.Equal(t, int64(5000), videoInfo.SelectedFormat.FileSize)
+	}
+}
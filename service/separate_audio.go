@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ValidateSeparateAudio checks that a download with the video and audio in
+// separate files can honour the other options. Everything that works on the
+// merged file, and the onExists strategies, which name a single file, are
+// ruled out.
+func ValidateSeparateAudio(opts VideoOptions) error {
+	switch {
+	case opts.Progressive:
+		return errors.New("separate audio cannot be combined with progressive, which downloads a single pre-muxed file")
+	case opts.AllAudioLanguages:
+		return errors.New("separate audio cannot be combined with allAudioLanguages")
+	case opts.FormatID != "":
+		return errors.New("separate audio cannot be combined with formatId, which picks the formats itself")
+	case opts.BurnSubtitles:
+		return errors.New("separate audio cannot be combined with burnSubtitles")
+	case opts.Watermark != "":
+		return errors.New("separate audio cannot be combined with a watermark")
+	case opts.TargetSizeMB != 0:
+		return errors.New("separate audio cannot be combined with targetSizeMB")
+	case opts.OnExists != "" && opts.OnExists != OnExistsUnique:
+		return fmt.Errorf("separate audio downloads are always uniquely named, onExists %q is not supported", opts.OnExists)
+	}
+	return nil
+}
+
+// DownloadSeparateStreams downloads the best video-only and the best
+// audio-only formats of url as two files, skipping the merge into one, for
+// clients that process them separately (e.g. dubbing). The video respects
+// the requested resolution and codec; both files keep the container yt-dlp
+// downloaded them in. Their names share a prefix, with ".video" and ".audio"
+// before the extension.
+func (d *Downloader) DownloadSeparateStreams(ctx context.Context, url string, opts VideoOptions, progressID string) (videoPath, audioPath string, _ *VideoInfo, _ []string, err error) {
+	ctx, span := startSpan(ctx, "download separate streams", url,
+		attribute.String("media.resolution", opts.withDefaults().Resolution))
+	defer func() { endSpan(span, videoPath, err) }()
+
+	if err := ValidateSeparateAudio(opts); err != nil {
+		return "", "", nil, nil, err
+	}
+	if err := ValidateFormatSort(opts.FormatSort); err != nil {
+		return "", "", nil, nil, err
+	}
+	release, err := d.downloadLimit.acquire(ctx)
+	if err != nil {
+		return "", "", nil, nil, err
+	}
+	defer release()
+
+	videoInfo, err := d.GetVideoInfo(ctx, url, progressID)
+	if err != nil {
+		return "", "", nil, nil, fmt.Errorf("failed to get video info: %w", err)
+	}
+	if err := d.checkDuration(videoInfo, progressID); err != nil {
+		return "", "", nil, nil, err
+	}
+
+	opts, qualityWarning, err := resolveQuality(opts, videoInfo.Formats)
+	if err != nil {
+		d.progressManager.SendError(progressID, "Requested quality not available", err)
+		return "", "", nil, nil, err
+	}
+	var warnings []string
+	if qualityWarning != "" {
+		slog.Warn("Requested quality not available, using the closest", "videoID", videoInfo.ID, "warning", qualityWarning)
+		d.progressManager.SendEvent(ProgressEvent{ID: progressID, Status: "warning", Message: qualityWarning})
+		warnings = append(warnings, qualityWarning)
+	}
+	opts = opts.withDefaults()
+
+	// Both files share the unique name a merged download would get, in the
+	// video directory
+	path, err := d.outputPath(MediaVideo, videoInfo.ID, "%(ext)s", OnExistsUnique)
+	if err != nil {
+		d.progressManager.SendError(progressID, "Cannot write video file", err)
+		return "", "", nil, nil, err
+	}
+	prefix := strings.TrimSuffix(path, ".%(ext)s")
+
+	d.progressManager.SendEvent(ProgressEvent{
+		ID:         progressID,
+		Status:     "downloading",
+		Message:    "Downloading video stream...",
+		Percentage: 25,
+	})
+	video := fmt.Sprintf("bestvideo[height<=%s][vcodec*=%s]/bestvideo", opts.Resolution, opts.Codec)
+	videoPath, videoWarnings, err := d.downloadSingleFormat(ctx, url, video, opts.FormatSort, prefix+".video.%(ext)s")
+	if err != nil {
+		d.progressManager.SendError(progressID, "Video download failed", err)
+		return "", "", nil, nil, err
+	}
+	warnings = append(warnings, videoWarnings...)
+
+	d.progressManager.SendEvent(ProgressEvent{
+		ID:         progressID,
+		Status:     "downloading",
+		Message:    "Downloading audio stream...",
+		Percentage: 60,
+	})
+	audioPath, audioWarnings, err := d.downloadSingleFormat(ctx, url, "bestaudio", "", prefix+".audio.%(ext)s")
+	if err != nil {
+		os.Remove(videoPath)
+		d.progressManager.SendError(progressID, "Audio download failed", err)
+		return "", "", nil, nil, err
+	}
+	warnings = append(warnings, audioWarnings...)
+
+	d.progressManager.SendComplete(progressID, "Video and audio downloaded separately", videoInfo, warnings...)
+	slog.Info("Video and audio downloaded separately", "videoPath", videoPath, "audioPath", audioPath)
+	return videoPath, audioPath, videoInfo, warnings, nil
+}
+
+// downloadSingleFormat downloads one format of url, as is, to the output
+// template and returns the path yt-dlp wrote.
+func (d *Downloader) downloadSingleFormat(ctx context.Context, url, format, formatSort, output string) (string, []string, error) {
+	args := []string{"--format", format}
+	if formatSort != "" {
+		args = append(args, "--format-sort", formatSort)
+	}
+	args = d.fileDownloadArgs(url, append(args,
+		"--output", output,
+		"--no-progress",
+		"--no-playlist",
+		"--print", "after_move:filepath", // The template's extension is only known once downloaded
+	)...)
+
+	cmd := exec.CommandContext(ctx, d.config().YTDLPPath, args...)
+	slog.Debug(fmt.Sprintf("Executing yt-dlp for separate stream download: %s %s", d.config().YTDLPPath, strings.Join(args, " ")))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	warnings, ytdlpErr := checkYTDLPRun(ctx, "separate stream download", cmd.Run(), stderr.String(), "")
+	if ytdlpErr != nil {
+		return "", nil, ytdlpErr
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	path := strings.TrimSpace(lines[len(lines)-1])
+	if _, err := os.Stat(path); path == "" || err != nil {
+		return "", nil, fmt.Errorf("downloaded file for format %s not found, yt-dlp reported %q", format, path)
+	}
+	return path, warnings, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// separateStreamsScript logs the args of its downloads to $ARGS_LOG and
+// writes the requested format to the output template, as mp4 for video and
+// m4a for audio, printing the path it wrote.
+const separateStreamsScript = fakeInfoPrelude + `
+echo "$@" >> "$ARGS_LOG"
+ext=mp4
+case "$*" in *"--format bestaudio "*) ext=m4a ;; esac
+path=$(printf '%s' "$out" | sed "s/%(ext)s/$ext/")
+printf "$ext" > "$path"
+echo "$path"
+`
+
+func TestDownloadSeparateStreams(t *testing.T) {
+	argsLog := filepath.Join(t.TempDir(), "args.log")
+	t.Setenv("ARGS_LOG", argsLog)
+	d := newFakeDownloader(t, separateStreamsScript)
+
+	videoPath, audioPath, info, _, err := d.DownloadSeparateStreams(context.Background(), "https://example.com/v", VideoOptions{Resolution: "720"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", info.ID)
+
+	// Two files side by side, with the content of their own format
+	assert.True(t, strings.HasSuffix(videoPath, "-abc123.video.mp4"), videoPath)
+	assert.True(t, strings.HasSuffix(audioPath, "-abc123.audio.m4a"), audioPath)
+	assert.Equal(t, strings.TrimSuffix(videoPath, ".video.mp4"), strings.TrimSuffix(audioPath, ".audio.m4a"))
+	content, err := os.ReadFile(videoPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "mp4", string(content))
+	content, err = os.ReadFile(audioPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "m4a", string(content))
+
+	// One yt-dlp run per format, none of which merges or recodes
+	logged, err := os.ReadFile(argsLog)
+	assert.NoError(t, err)
+	runs := strings.Split(strings.TrimSpace(string(logged)), "\n")
+	if assert.Len(t, runs, 2) {
+		assert.Contains(t, runs[0], "--format bestvideo[height<=720][vcodec*=avc1]/bestvideo ")
+		assert.Contains(t, runs[1], "--format bestaudio ")
+	}
+	for _, run := range runs {
+		assert.NotContains(t, run, "+")
+		assert.NotContains(t, run, "--merge-output-format")
+		assert.NotContains(t, run, "--recode-video")
+	}
+}
+
+func TestValidateSeparateAudio(t *testing.T) {
+	assert.NoError(t, ValidateSeparateAudio(VideoOptions{Resolution: "1080", OnExists: OnExistsUnique}))
+	assert.Error(t, ValidateSeparateAudio(VideoOptions{Progressive: true}))
+	assert.Error(t, ValidateSeparateAudio(VideoOptions{BurnSubtitles: true}))
+	assert.Error(t, ValidateSeparateAudio(VideoOptions{Watermark: "logo.png"}))
+	assert.Error(t, ValidateSeparateAudio(VideoOptions{TargetSizeMB: 25}))
+	assert.ErrorContains(t, ValidateSeparateAudio(VideoOptions{OnExists: OnExistsOverwrite}), "always uniquely named")
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Size of one thumbnail in a storyboard sprite; videos are letterboxed
+	// into it so every tile has the same geometry.
+	storyboardTileWidth  = 160
+	storyboardTileHeight = 90
+	// storyboardColumns is how many thumbnails make up a row of the sprite.
+	storyboardColumns = 10
+	// MaxStoryboardTiles bounds the size of a sprite: for long videos the
+	// interval is stretched to stay under it.
+	MaxStoryboardTiles = 400
+	// MaxStoryboardInterval is the longest interval a storyboard can be
+	// asked for.
+	MaxStoryboardInterval = time.Minute
+)
+
+// storyboardSteps are the intervals, in seconds, storyboards are made with.
+// Requested intervals are rounded up to one of them, so a video only ever
+// gets a handful of sprites however clients ask.
+var storyboardSteps = []int{1, 2, 5, 10, 15, 30, 60}
+
+var (
+	// ffmpegDurationPattern finds the duration ffmpeg prints for its input.
+	ffmpegDurationPattern = regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+	// storyboardSpritePattern matches the names of storyboard sprites,
+	// including those still being rendered.
+	storyboardSpritePattern = regexp.MustCompile(`\.storyboard-\d+s(\.tmp)?\.jpg$`)
+)
+
+// storyboardStep rounds seconds up to the next storyboard step, or to a
+// whole number of minutes past the last one.
+func storyboardStep(seconds int) int {
+	for _, step := range storyboardSteps {
+		if seconds <= step {
+			return step
+		}
+	}
+	return (seconds + 59) / 60 * 60
+}
+
+// IsStoryboardSprite reports whether path names a storyboard sprite rather
+// than a download.
+func IsStoryboardSprite(path string) bool {
+	return storyboardSpritePattern.MatchString(filepath.Base(path))
+}
+
+// RemoveStoryboards deletes the storyboard sprites generated for the video
+// at videoPath, e.g. once the video itself is deleted.
+func RemoveStoryboards(videoPath string) error {
+	entries, err := os.ReadDir(filepath.Dir(videoPath))
+	if err != nil {
+		return err
+	}
+	prefix := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath)) + ".storyboard-"
+	var errs []error
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, prefix) && IsStoryboardSprite(name) {
+			if err := os.Remove(filepath.Join(filepath.Dir(videoPath), name)); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GenerateStoryboard makes a sprite sheet of thumbnails taken every interval
+// from a downloaded video, for scrubber previews, and returns its path with
+// a WebVTT track mapping each time range to its region of the sprite. The
+// cues refer to the sprite by its file name. The interval is rounded up to
+// one of storyboardSteps. The sprite is written next to the video and reused
+// until the video changes; rendering it takes a download slot.
+func (d *Downloader) GenerateStoryboard(ctx context.Context, path string, interval time.Duration) (spritePath string, vtt string, err error) {
+	if interval < time.Second || interval > MaxStoryboardInterval {
+		return "", "", fmt.Errorf("invalid storyboard interval %s, expected between 1s and %s", interval, MaxStoryboardInterval)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", "", err
+	}
+	duration, err := d.mediaDuration(ctx, path)
+	if err != nil {
+		return "", "", err
+	}
+	if duration <= 0 {
+		return "", "", fmt.Errorf("%s has no duration to make a storyboard of", filepath.Base(path))
+	}
+
+	// Whole seconds keep sprite names and cue times simple
+	seconds := int(math.Ceil(interval.Seconds()))
+	seconds = storyboardStep(max(seconds, int(math.Ceil(duration.Seconds()/MaxStoryboardTiles))))
+	tiles := max(int(math.Ceil(duration.Seconds()/float64(seconds))), 1)
+	rows := (tiles + storyboardColumns - 1) / storyboardColumns
+
+	spritePath = fmt.Sprintf("%s.storyboard-%ds.jpg", strings.TrimSuffix(path, filepath.Ext(path)), seconds)
+	if !spriteFresh(spritePath, stat) {
+		// One render per sprite at a time; the others wait and reuse it
+		unlock := d.storyboardLocks.lock(spritePath)
+		defer unlock()
+		if !spriteFresh(spritePath, stat) {
+			release, err := d.downloadLimit.acquire(ctx)
+			if err != nil {
+				return "", "", err
+			}
+			defer release()
+			if err := d.renderStoryboard(ctx, path, spritePath, seconds, rows); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	return spritePath, storyboardVTT(filepath.Base(spritePath), duration, seconds, tiles), nil
+}
+
+// spriteFresh reports whether the sprite at spritePath exists and is newer
+// than the video described by video.
+func spriteFresh(spritePath string, video os.FileInfo) bool {
+	sprite, err := os.Stat(spritePath)
+	return err == nil && !sprite.ModTime().Before(video.ModTime())
+}
+
+// renderLocks serializes the renders of each sprite, which would otherwise
+// race on its temporary file. A path's lock only exists while renders of it
+// are running or waiting, so locks do not pile up as videos come and go.
+type renderLocks struct {
+	mu    sync.Mutex
+	locks map[string]*renderLock
+}
+
+// renderLock is the lock of a path and the number of renders holding or
+// waiting for it.
+type renderLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+func newRenderLocks() *renderLocks {
+	return &renderLocks{locks: make(map[string]*renderLock)}
+}
+
+// lock waits for the lock of path and returns the function releasing it.
+func (l *renderLocks) lock(path string) (unlock func()) {
+	l.mu.Lock()
+	rl, ok := l.locks[path]
+	if !ok {
+		rl = &renderLock{}
+		l.locks[path] = rl
+	}
+	rl.users++
+	l.mu.Unlock()
+
+	rl.mu.Lock()
+	return func() {
+		rl.mu.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if rl.users--; rl.users == 0 {
+			delete(l.locks, path)
+		}
+	}
+}
+
+// renderStoryboard runs ffmpeg to take a thumbnail every seconds and tile
+// them into a sprite of rows rows. It writes to a temporary file first, so
+// a failed run never leaves a partial sprite behind.
+func (d *Downloader) renderStoryboard(ctx context.Context, videoPath, spritePath string, seconds, rows int) error {
+	tmpPath := strings.TrimSuffix(spritePath, ".jpg") + ".tmp.jpg"
+	filter := fmt.Sprintf("fps=1/%d,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,tile=%dx%d",
+		seconds, storyboardTileWidth, storyboardTileHeight, storyboardTileWidth, storyboardTileHeight, storyboardColumns, rows)
+	args := []string{"-v", "error", "-y", "-i", videoPath, "-vf", filter, "-frames:v", "1", "-q:v", "5", tmpPath}
+	cmd := exec.CommandContext(ctx, d.config().FFMPEGPath, args...)
+	slog.Debug(fmt.Sprintf("Executing ffmpeg for storyboard: %s %s", d.config().FFMPEGPath, strings.Join(args, " ")))
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg storyboard failed: %w, stderr: %s", err, stderr.String())
+	}
+	if err := os.Rename(tmpPath, spritePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save storyboard sprite: %w", err)
+	}
+	return nil
+}
+
+// mediaDuration reads the duration of a media file from ffmpeg's description
+// of its input. ffmpeg exits with an error without an output file, so only
+// the missing duration is reported.
+func (d *Downloader) mediaDuration(ctx context.Context, path string) (time.Duration, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, d.config().FFMPEGPath, "-hide_banner", "-i", path)
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	match := ffmpegDurationPattern.FindStringSubmatch(stderr.String())
+	if match == nil {
+		return 0, fmt.Errorf("failed to read duration of %s: %v, stderr: %s", filepath.Base(path), runErr, stderr.String())
+	}
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	secs, _ := strconv.ParseFloat(match[3], 64)
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(secs*float64(time.Second)), nil
+}
+
+// storyboardVTT builds the WebVTT track of a sprite: one cue per tile, whose
+// payload is the sprite's URL with the tile's region as a media fragment.
+func storyboardVTT(spriteURL string, duration time.Duration, seconds, tiles int) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i := range tiles {
+		start := float64(i * seconds)
+		end := min(float64((i+1)*seconds), duration.Seconds())
+		x := i % storyboardColumns * storyboardTileWidth
+		y := i / storyboardColumns * storyboardTileHeight
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", vttTimestamp(start), vttTimestamp(end), spriteURL,
+			x, y, storyboardTileWidth, storyboardTileHeight)
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFFmpegStoryboard describes every input as $DURATION long and, when
+// given an output file, writes its arguments to it as the sprite, counting
+// renders in $RENDERS if set.
+const fakeFFmpegStoryboard = `#!/bin/sh
+for a in "$@"; do out="$a"; done
+case "$out" in *.jpg)
+	if [ -n "$RENDERS" ]; then echo render >> "$RENDERS"; sleep 0.1; fi
+	printf '%s\n' "$@" > "$out"; exit 0 ;;
+esac
+echo "  Duration: $DURATION, start: 0.000000, bitrate: 1 kb/s" >&2
+echo "At least one output file must be specified" >&2
+exit 1
+`
+
+func newStoryboardDownloader(t *testing.T, duration string) (*Downloader, string) {
+	t.Helper()
+	t.Setenv("DURATION", duration)
+	d := newFakeDownloader(t, fakeInfoPrelude)
+	withFakeFFmpeg(t, d, fakeFFmpegStoryboard)
+	videoPath := filepath.Join(d.config().DownloadDir, "clip.mp4")
+	assert.NoError(t, os.WriteFile(videoPath, []byte("video"), 0644))
+	return d, videoPath
+}
+
+func TestGenerateStoryboard(t *testing.T) {
+	d, videoPath := newStoryboardDownloader(t, "00:00:25.00")
+
+	spritePath, vtt, err := d.GenerateStoryboard(context.Background(), videoPath, 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(d.config().DownloadDir, "clip.storyboard-10s.jpg"), spritePath)
+	assert.Equal(t, `WEBVTT
+
+00:00:00.000 --> 00:00:10.000
+clip.storyboard-10s.jpg#xywh=0,0,160,90
+
+00:00:10.000 --> 00:00:20.000
+clip.storyboard-10s.jpg#xywh=160,0,160,90
+
+00:00:20.000 --> 00:00:25.000
+clip.storyboard-10s.jpg#xywh=320,0,160,90
+`, vtt)
+
+	args, err := os.ReadFile(spritePath)
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Split(string(args), "\n"),
+		"fps=1/10,scale=160:90:force_original_aspect_ratio=decrease,pad=160:90:(ow-iw)/2:(oh-ih)/2,tile=10x1")
+
+	// The sprite is reused while the video is unchanged
+	assert.NoError(t, os.WriteFile(spritePath, []byte("cached"), 0644))
+	_, _, err = d.GenerateStoryboard(context.Background(), videoPath, 10*time.Second)
+	assert.NoError(t, err)
+	cached, _ := os.ReadFile(spritePath)
+	assert.Equal(t, "cached", string(cached))
+}
+
+func TestGenerateStoryboard_LongVideoStretchesInterval(t *testing.T) {
+	d, videoPath := newStoryboardDownloader(t, "02:00:00.00")
+
+	spritePath, vtt, err := d.GenerateStoryboard(context.Background(), videoPath, time.Second)
+	assert.NoError(t, err)
+	// 18s keeps it under MaxStoryboardTiles, rounded up to the next step
+	assert.Equal(t, "clip.storyboard-30s.jpg", filepath.Base(spritePath))
+	assert.Equal(t, 240, strings.Count(vtt, "#xywh="))
+	assert.Contains(t, vtt, "01:59:30.000 --> 02:00:00.000\nclip.storyboard-30s.jpg#xywh=1440,2070,160,90\n")
+}
+
+func TestGenerateStoryboard_InvalidInterval(t *testing.T) {
+	d, videoPath := newStoryboardDownloader(t, "00:00:25.00")
+	_, _, err := d.GenerateStoryboard(context.Background(), videoPath, 500*time.Millisecond)
+	assert.ErrorContains(t, err, "invalid storyboard interval")
+	_, _, err = d.GenerateStoryboard(context.Background(), videoPath, MaxStoryboardInterval+time.Second)
+	assert.ErrorContains(t, err, "invalid storyboard interval")
+}
+
+func TestGenerateStoryboard_RoundsInterval(t *testing.T) {
+	d, videoPath := newStoryboardDownloader(t, "00:00:25.00")
+	for interval, sprite := range map[time.Duration]string{
+		time.Second:             "clip.storyboard-1s.jpg",
+		3 * time.Second:         "clip.storyboard-5s.jpg",
+		7 * time.Second:         "clip.storyboard-10s.jpg",
+		1500 * time.Millisecond: "clip.storyboard-2s.jpg",
+		MaxStoryboardInterval:   "clip.storyboard-60s.jpg",
+	} {
+		spritePath, _, err := d.GenerateStoryboard(context.Background(), videoPath, interval)
+		assert.NoError(t, err)
+		assert.Equal(t, sprite, filepath.Base(spritePath), interval)
+	}
+	assert.Equal(t, 120, storyboardStep(61))
+}
+
+func TestGenerateStoryboard_RendersOnce(t *testing.T) {
+	d, videoPath := newStoryboardDownloader(t, "00:00:25.00")
+	renders := filepath.Join(t.TempDir(), "renders")
+	t.Setenv("RENDERS", renders)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := d.GenerateStoryboard(context.Background(), videoPath, 10*time.Second)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	count, err := os.ReadFile(renders)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(count), "render"))
+	assert.Empty(t, d.storyboardLocks.locks, "locks are dropped once the renders are over")
+}
+
+func TestGenerateStoryboard_TooManyDownloads(t *testing.T) {
+	d, videoPath := newStoryboardDownloader(t, "00:00:25.00")
+	d.downloadLimit.setLimits(1, 0)
+	release, err := d.downloadLimit.acquire(context.Background())
+	assert.NoError(t, err)
+	defer release()
+
+	_, _, err = d.GenerateStoryboard(context.Background(), videoPath, 10*time.Second)
+	assert.ErrorIs(t, err, ErrTooManyDownloads)
+}
+
+func TestRemoveStoryboards(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"clip.mp4", "clip.storyboard-10s.jpg", "clip.storyboard-60s.tmp.jpg", "clip2.storyboard-10s.jpg", "clip.jpg"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	assert.NoError(t, RemoveStoryboards(filepath.Join(dir, "clip.mp4")))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"clip.jpg", "clip.mp4", "clip2.storyboard-10s.jpg"}, names)
+
+	assert.True(t, IsStoryboardSprite("/data/clip.storyboard-10s.jpg"))
+	assert.False(t, IsStoryboardSprite("/data/clip.jpg"))
+}
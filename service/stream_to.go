@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime/debug"
+)
+
+// StreamVideoTo streams video from url into w until the stream ends and
+// returns how many bytes were written. It is StreamVideo for callers that
+// only need the media written somewhere, e.g. an HTTP response, a multipart
+// part or a pipe to another program.
+//
+// When start is not nil, it is called with the video metadata once the
+// stream is ready and before anything is written, e.g. to set response
+// headers; an error from it ends the stream. If StreamVideoTo fails without
+// calling start, nothing was written to w. A failure of yt-dlp after the last
+// byte, or a panic in w, is returned as an error too.
+func (d *Downloader) StreamVideoTo(ctx context.Context, w io.Writer, url string, opts VideoOptions, progressID string, start func(*VideoInfo) error) (written int64, err error) {
+	stream, videoInfo, err := d.StreamVideo(ctx, url, opts, progressID)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := stream.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	if start != nil {
+		if err := start(videoInfo); err != nil {
+			return 0, err
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Recovered from panic while streaming", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic while streaming: %v", r)
+		}
+	}()
+	return io.Copy(w, stream)
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamVideoTo_Buffer(t *testing.T) {
+	d := newFakeDownloader(t, mediaScript)
+
+	var buf bytes.Buffer
+	var startedWith *VideoInfo
+	written, err := d.StreamVideoTo(context.Background(), &buf, "https://example.com/v", VideoOptions{}, "", func(info *VideoInfo) error {
+		assert.Zero(t, buf.Len(), "start must run before anything is written")
+		startedWith = info
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), written)
+	assert.Equal(t, "media", buf.String())
+	if assert.NotNil(t, startedWith) {
+		assert.Equal(t, "abc123", startedWith.ID)
+	}
+}
+
+func TestStreamVideoTo_StartFails(t *testing.T) {
+	d := newFakeDownloader(t, mediaScript)
+	d.streamLimit = newLimiter(1, 0, ErrTooManyStreams)
+
+	var buf bytes.Buffer
+	startErr := errors.New("no thanks")
+	_, err := d.StreamVideoTo(context.Background(), &buf, "https://example.com/v", VideoOptions{}, "", func(*VideoInfo) error {
+		return startErr
+	})
+	assert.ErrorIs(t, err, startErr)
+	assert.Zero(t, buf.Len())
+
+	// The stream was closed, giving its slot back
+	_, err = d.StreamVideoTo(context.Background(), &buf, "https://example.com/v", VideoOptions{}, "", nil)
+	assert.NoError(t, err)
+}
+
+func TestStreamVideoTo_CommandFails(t *testing.T) {
+	d := newFakeDownloader(t, streamFailsScript)
+
+	var buf bytes.Buffer
+	written, err := d.StreamVideoTo(context.Background(), &buf, "https://example.com/v", VideoOptions{}, "", nil)
+	assert.Equal(t, int64(7), written)
+	assert.Equal(t, "partial", buf.String())
+	var ytdlpErr *YTDLPError
+	assert.ErrorAs(t, err, &ytdlpErr)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// autoSubtitleLang is the preference-chain entry that stands for the video's
+// own spoken language, whatever it is.
+const autoSubtitleLang = "auto"
+
+// SubtitleLangs lists the subtitle languages available for a video.
+// yt-dlp reports subtitles as an object keyed by language code; only the
+// keys are kept so VideoInfo stays small enough to pass around in URLs.
+type SubtitleLangs []string
+
+// UnmarshalJSON accepts both yt-dlp's language-keyed object and a plain array
+// of language codes (the form SubtitleLangs marshals to).
+func (s *SubtitleLangs) UnmarshalJSON(data []byte) error {
+	var langs []string
+	if err := json.Unmarshal(data, &langs); err == nil {
+		*s = langs
+		return nil
+	}
+
+	var tracks map[string]json.RawMessage
+	if err := json.Unmarshal(data, &tracks); err != nil {
+		return err
+	}
+	langs = make([]string, 0, len(tracks))
+	for lang := range tracks {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	*s = langs
+	return nil
+}
+
+// SubtitleList is the subtitle languages available for a video, for clients
+// to choose one before downloading.
+type SubtitleList struct {
+	// Language is the video's own spoken language, which "auto" stands for
+	// in a preference chain, when the extractor knows it
+	Language string `json:"language,omitempty"`
+	// Manual are the subtitles uploaded with the video, and Automatic the
+	// captions generated from its speech
+	Manual    []string `json:"manual"`
+	Automatic []string `json:"automatic"`
+}
+
+// ListSubtitles returns the manual and automatic subtitle languages
+// available for url.
+func (d *Downloader) ListSubtitles(ctx context.Context, url string) (*SubtitleList, error) {
+	info, err := d.dumpInfo(ctx, url, "subtitle list")
+	if err != nil {
+		return nil, err
+	}
+	// Empty lists rather than null, so clients need not tell the two apart
+	return &SubtitleList{
+		Language:  info.Language,
+		Manual:    append([]string{}, info.Subtitles...),
+		Automatic: append([]string{}, info.AutomaticCaptions...),
+	}, nil
+}
+
+// parseSubtitleChain splits a comma-separated preference chain such as
+// "en, en-US, auto" into its trimmed, non-empty entries.
+func parseSubtitleChain(chain string) []string {
+	var langs []string
+	for _, lang := range strings.Split(chain, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// pickSubtitleLang walks the preference chain and returns the first language
+// the video has subtitles for. Manual subtitles win over automatic captions
+// for the same language; auto reports whether the pick is an automatic caption.
+func pickSubtitleLang(info *VideoInfo, chain []string) (lang string, auto bool, ok bool) {
+	for _, want := range chain {
+		if want == autoSubtitleLang {
+			if info.Language == "" {
+				continue
+			}
+			want = info.Language
+		}
+		if slices.Contains(info.Subtitles, want) {
+			return want, false, true
+		}
+		if slices.Contains(info.AutomaticCaptions, want) {
+			return want, true, true
+		}
+	}
+	return "", false, false
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleSubtitleInfo is a trimmed yt-dlp info JSON with caption tracks.
+const sampleSubtitleInfo = `{
+	"id": "abc123",
+	"title": "Sample",
+	"language": "fr",
+	"subtitles": {
+		"en-US": [{"ext": "vtt", "url": "https://example.com/en-US.vtt"}],
+		"de": [{"ext": "vtt", "url": "https://example.com/de.vtt"}]
+	},
+	"automatic_captions": {
+		"fr": [{"ext": "vtt", "url": "https://example.com/fr.vtt"}],
+		"en": [{"ext": "vtt", "url": "https://example.com/en.vtt"}]
+	}
+}`
+
+func TestSubtitleLangs_Unmarshal(t *testing.T) {
+	var info VideoInfo
+	assert.NoError(t, json.Unmarshal([]byte(sampleSubtitleInfo), &info))
+	assert.Equal(t, SubtitleLangs{"de", "en-US"}, info.Subtitles)
+	assert.Equal(t, SubtitleLangs{"en", "fr"}, info.AutomaticCaptions)
+
+	// Round-trips through our own compact array form.
+	data, err := json.Marshal(info)
+	assert.NoError(t, err)
+	var again VideoInfo
+	assert.NoError(t, json.Unmarshal(data, &again))
+	assert.Equal(t, info.Subtitles, again.Subtitles)
+	assert.Equal(t, info.AutomaticCaptions, again.AutomaticCaptions)
+}
+
+func TestPickSubtitleLang(t *testing.T) {
+	var info VideoInfo
+	assert.NoError(t, json.Unmarshal([]byte(sampleSubtitleInfo), &info))
+
+	tests := []struct {
+		name     string
+		chain    string
+		wantLang string
+		wantAuto bool
+		wantOK   bool
+	}{
+		{"FirstUnavailableFallsBack", "es, en-US, auto", "en-US", false, true},
+		{"ManualPreferredOverAuto", "de, en", "de", false, true},
+		{"AutoCaptionWhenNoManual", "en", "en", true, true},
+		{"AutoMeansVideoLanguage", "es, auto", "fr", true, true},
+		{"NothingAvailable", "es, it", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lang, auto, ok := pickSubtitleLang(&info, parseSubtitleChain(tt.chain))
+			assert.Equal(t, tt.wantLang, lang)
+			assert.Equal(t, tt.wantAuto, auto)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestListSubtitles(t *testing.T) {
+	d := newFakeDownloader(t, "#!/bin/sh\ncat <<'JSON'\n"+sampleSubtitleInfo+"\nJSON\n")
+
+	list, err := d.ListSubtitles(context.Background(), "https://example.com/v")
+	assert.NoError(t, err)
+	assert.Equal(t, &SubtitleList{
+		Language:  "fr",
+		Manual:    []string{"de", "en-US"},
+		Automatic: []string{"en", "fr"},
+	}, list)
+
+	// A video without subtitles lists none, as empty arrays
+	d = newFakeDownloader(t, fakeInfoPrelude)
+	list, err = d.ListSubtitles(context.Background(), "https://example.com/v")
+	assert.NoError(t, err)
+	data, err := json.Marshal(list)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"manual":[],"automatic":[]}`, string(data))
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// targetSizeAudioBitrate is the audio bitrate, in kbit/s, of a video
+	// encoded to a target size; the picture gets the rest of the budget.
+	targetSizeAudioBitrate = 128
+	// targetSizeHeadroom is the share of the target size given to the
+	// streams, leaving room for the container so the file stays under it.
+	targetSizeHeadroom = 0.97
+	// minTargetVideoBitrate is the lowest picture bitrate, in kbit/s, worth
+	// encoding: below it, the target size is refused as too small.
+	minTargetVideoBitrate = 100
+)
+
+// ValidateTargetSize checks a target file size in MB. Zero asks for no size
+// target and is always valid.
+func ValidateTargetSize(sizeMB int) error {
+	if sizeMB < 0 {
+		return fmt.Errorf("invalid target size %dMB, expected a positive number of MB", sizeMB)
+	}
+	return nil
+}
+
+// targetVideoBitrate computes the picture bitrate, in kbit/s, for a video of
+// the given duration to fit in sizeMB megabytes (MiB) along with its audio.
+func targetVideoBitrate(duration time.Duration, sizeMB int) (int, error) {
+	if duration <= 0 {
+		return 0, fmt.Errorf("cannot encode to a target size without the video's duration")
+	}
+	totalKbits := float64(sizeMB) * 1024 * 1024 * 8 / 1000
+	kbps := int(totalKbits*targetSizeHeadroom/duration.Seconds()) - targetSizeAudioBitrate
+	if kbps < minTargetVideoBitrate {
+		return 0, fmt.Errorf("target size %dMB is too small for a %s video: it leaves %d kbit/s for the picture, below the minimum of %d",
+			sizeMB, duration.Round(time.Second), kbps, minTargetVideoBitrate)
+	}
+	return kbps, nil
+}
+
+// targetSizeCodecs returns the video and audio encoders ffmpeg uses for a
+// target size encode into the given container.
+func targetSizeCodecs(format string) (video, audio string) {
+	if format == "webm" {
+		return "libvpx-vp9", "libopus"
+	}
+	return "libx264", "aac"
+}
+
+// twoPassArgs builds the ffmpeg arguments of both passes of an encode of
+// videoPath to outputPath at videoKbps. The first pass only analyses the
+// picture, writing its statistics under passLogPrefix for the second.
+func twoPassArgs(videoPath, outputPath, passLogPrefix, format string, videoKbps int) (pass1, pass2 []string) {
+	videoCodec, audioCodec := targetSizeCodecs(format)
+	bitrate := strconv.Itoa(videoKbps) + "k"
+	common := []string{"-y", "-i", videoPath, "-c:v", videoCodec, "-b:v", bitrate}
+
+	pass1 = append(append([]string{}, common...),
+		"-pass", "1", "-passlogfile", passLogPrefix,
+		"-an", "-f", "null", os.DevNull)
+	pass2 = append(append([]string{}, common...),
+		"-pass", "2", "-passlogfile", passLogPrefix,
+		"-c:a", audioCodec, "-b:a", strconv.Itoa(targetSizeAudioBitrate)+"k",
+		outputPath)
+	return pass1, pass2
+}
+
+// encodeToTargetSize re-encodes the downloaded video in two passes at the
+// bitrate that makes it about sizeMB megabytes, e.g. to fit an upload limit.
+// The file at videoPath is replaced.
+func (d *Downloader) encodeToTargetSize(ctx context.Context, videoPath string, sizeMB int, progressID string) (err error) {
+	ctx, span := startSpan(ctx, "encode target size", "", attribute.Int("media.target_size_mb", sizeMB))
+	defer func() { endSpan(span, videoPath, err) }()
+
+	duration, err := d.mediaDuration(ctx, videoPath)
+	if err != nil {
+		return err
+	}
+	videoKbps, err := targetVideoBitrate(duration, sizeMB)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	sizedPath := base + ".sized" + filepath.Ext(videoPath)
+	passLogPrefix := base + ".2pass"
+	defer func() {
+		// ffmpeg names its statistics files after the prefix
+		logs, _ := filepath.Glob(passLogPrefix + "*")
+		for _, logFile := range logs {
+			os.Remove(logFile)
+		}
+	}()
+
+	pass1, pass2 := twoPassArgs(videoPath, sizedPath, passLogPrefix, strings.TrimPrefix(filepath.Ext(videoPath), "."), videoKbps)
+	for i, args := range [][]string{pass1, pass2} {
+		d.progressManager.SendEvent(ProgressEvent{
+			ID:         progressID,
+			Status:     "encoding",
+			Message:    fmt.Sprintf("Encoding video to %dMB, pass %d of 2...", sizeMB, i+1),
+			Percentage: float64(90 + 5*i),
+		})
+
+		ffmpegCmd := exec.CommandContext(ctx, d.config().FFMPEGPath, args...)
+		slog.Debug(fmt.Sprintf("Executing ffmpeg for target size pass %d: %s %s", i+1, d.config().FFMPEGPath, strings.Join(args, " ")))
+
+		var ffmpegStderr bytes.Buffer
+		ffmpegCmd.Stderr = &ffmpegStderr
+		if err := ffmpegCmd.Run(); err != nil {
+			os.Remove(sizedPath)
+			return fmt.Errorf("ffmpeg target size pass %d failed: %w, stderr: %s", i+1, err, ffmpegStderr.String())
+		}
+	}
+
+	if err := os.Rename(sizedPath, videoPath); err != nil {
+		return fmt.Errorf("failed to replace video with its target size version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetVideoBitrate(t *testing.T) {
+	// 25MB over a minute: 209715 kbit, 97% of it over 60s, less the audio
+	kbps, err := targetVideoBitrate(time.Minute, 25)
+	assert.NoError(t, err)
+	assert.Equal(t, 3262, kbps)
+
+	kbps, err = targetVideoBitrate(10*time.Minute, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1228, kbps)
+
+	_, err = targetVideoBitrate(10*time.Minute, 8)
+	assert.ErrorContains(t, err, "target size 8MB is too small for a 10m0s video")
+	_, err = targetVideoBitrate(0, 25)
+	assert.Error(t, err)
+}
+
+func TestTwoPassArgs(t *testing.T) {
+	pass1, pass2 := twoPassArgs("/data/1-abc.mp4", "/data/1-abc.sized.mp4", "/data/1-abc.2pass", "mp4", 3262)
+	assert.Equal(t, []string{
+		"-y", "-i", "/data/1-abc.mp4", "-c:v", "libx264", "-b:v", "3262k",
+		"-pass", "1", "-passlogfile", "/data/1-abc.2pass",
+		"-an", "-f", "null", os.DevNull,
+	}, pass1)
+	assert.Equal(t, []string{
+		"-y", "-i", "/data/1-abc.mp4", "-c:v", "libx264", "-b:v", "3262k",
+		"-pass", "2", "-passlogfile", "/data/1-abc.2pass",
+		"-c:a", "aac", "-b:a", "128k",
+		"/data/1-abc.sized.mp4",
+	}, pass2)
+
+	_, pass2 = twoPassArgs("in.webm", "out.webm", "log", "webm", 500)
+	assert.Contains(t, pass2, "libvpx-vp9")
+	assert.Contains(t, pass2, "libopus")
+}
+
+// fakeFFmpegTwoPass describes every input as $DURATION long, writes the
+// arguments of a first pass, which has no output file, to $PASS1, and those
+// of a second pass to its output file.
+const fakeFFmpegTwoPass = `#!/bin/sh
+for a in "$@"; do out="$a"; done
+case " $* " in
+*" -pass 1 "*) printf '%s\n' "$@" > "$PASS1"; exit 0 ;;
+*" -pass 2 "*) printf '%s\n' "$@" > "$out"; exit 0 ;;
+esac
+echo "  Duration: $DURATION, start: 0.000000, bitrate: 1 kb/s" >&2
+echo "At least one output file must be specified" >&2
+exit 1
+`
+
+func TestDownloadVideoToFile_TargetSize(t *testing.T) {
+	d := newFakeDownloader(t, mediaScript)
+	withFakeFFmpeg(t, d, fakeFFmpegTwoPass)
+	pass1File := filepath.Join(t.TempDir(), "pass1")
+	t.Setenv("PASS1", pass1File)
+	t.Setenv("DURATION", "00:01:00.00")
+
+	// Buffered, so no event is dropped
+	d.progressManager = NewProgressManager(32)
+	events := make(chan []ProgressEvent)
+	ch := d.progressManager.RegisterClient("p1")
+	go func() { events <- drain(ch, 0) }()
+
+	filePath, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{TargetSizeMB: 25}, "p1")
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(pass1File)
+	assert.NoError(t, err)
+	pass1 := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Contains(t, pass1, "3262k")
+	assert.Contains(t, pass1, "-an")
+
+	data, err = os.ReadFile(filePath)
+	assert.NoError(t, err)
+	pass2 := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Contains(t, pass2, "3262k")
+	assert.Contains(t, pass2, "2")
+	assert.Equal(t, strings.TrimSuffix(filePath, ".mp4")+".sized.mp4", pass2[len(pass2)-1])
+	assert.NoFileExists(t, strings.TrimSuffix(filePath, ".mp4")+".sized.mp4")
+
+	var messages []string
+	for _, event := range <-events {
+		if event.Status == "encoding" {
+			messages = append(messages, event.Message)
+		}
+	}
+	assert.Equal(t, []string{"Encoding video to 25MB, pass 1 of 2...", "Encoding video to 25MB, pass 2 of 2..."}, messages)
+
+	_, _, _, err = d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{TargetSizeMB: 1}, "")
+	assert.ErrorContains(t, err, "target size 1MB is too small")
+	_, _, _, err = d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{TargetSizeMB: -1}, "")
+	assert.ErrorContains(t, err, "invalid target size")
+}
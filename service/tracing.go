@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	neturl "net/url"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of the download stages.
+const tracerName = "gostreampuller/service"
+
+// startSpan starts a span for one stage of handling url, as a child of the
+// request span in ctx. Only the URL's host is recorded, as full URLs can
+// carry tokens; url may be empty for stages working on local files.
+func startSpan(ctx context.Context, name, url string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if u, err := neturl.Parse(url); err == nil && u.Host != "" {
+		attrs = append(attrs, attribute.String("url.host", u.Host))
+	}
+	// The global provider is looked up every time, as it is only set up
+	// after startup (and does nothing unless OTEL_ENABLED)
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, recording err as its status, and the size of the file
+// at path as media.bytes when there is one.
+func endSpan(span trace.Span, path string, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if path != "" {
+		if info, statErr := os.Stat(path); statErr == nil {
+			span.SetAttributes(attribute.Int64("media.bytes", info.Size()))
+		}
+	}
+	span.End()
+}
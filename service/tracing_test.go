@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans routes the spans of the test to an in-memory exporter.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestDownloadVideoToFile_Spans(t *testing.T) {
+	exporter := recordSpans(t)
+	d := newFakeDownloader(t, mediaScript)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	_, _, _, err := d.DownloadVideoToFile(ctx, "https://example.com/v?token=secret", VideoOptions{Format: "webm"}, "")
+	parent.End()
+	assert.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	if assert.Contains(t, byName, "fetch info") && assert.Contains(t, byName, "download video") {
+		info, download := byName["fetch info"], byName["download video"]
+		assert.Equal(t, download.SpanContext.SpanID(), info.Parent.SpanID(), "the info fetch is a stage of the download")
+		assert.Equal(t, byName["request"].SpanContext.SpanID(), download.Parent.SpanID(), "the download joins the request trace")
+		assert.Equal(t, "example.com", spanAttr(download, "url.host").AsString())
+		assert.Equal(t, "webm", spanAttr(download, "media.format").AsString())
+		assert.Equal(t, int64(len("media")), spanAttr(download, "media.bytes").AsInt64())
+	}
+}
+
+func TestDownloadVideoToFile_SpanRecordsError(t *testing.T) {
+	exporter := recordSpans(t)
+	d := newFakeDownloader(t, streamFailsScript)
+
+	_, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{}, "")
+	assert.Error(t, err)
+
+	spans := exporter.GetSpans()
+	if assert.NotEmpty(t, spans) {
+		download := spans[len(spans)-1]
+		assert.Equal(t, "download video", download.Name)
+		assert.Equal(t, "Error", download.Status.Code.String())
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Corners a watermark can be placed in.
+const (
+	WatermarkTopLeft     = "top-left"
+	WatermarkTopRight    = "top-right"
+	WatermarkBottomLeft  = "bottom-left"
+	WatermarkBottomRight = "bottom-right"
+)
+
+// watermarkMargin is the gap in pixels between a watermark and the edges.
+const watermarkMargin = 10
+
+// watermarkOverlays maps each corner to its ffmpeg overlay position, where
+// W and H are the video's size and w and h the watermark's.
+var watermarkOverlays = map[string]string{
+	WatermarkTopLeft:     fmt.Sprintf("%d:%d", watermarkMargin, watermarkMargin),
+	WatermarkTopRight:    fmt.Sprintf("W-w-%d:%d", watermarkMargin, watermarkMargin),
+	WatermarkBottomLeft:  fmt.Sprintf("%d:H-h-%d", watermarkMargin, watermarkMargin),
+	WatermarkBottomRight: fmt.Sprintf("W-w-%d:H-h-%d", watermarkMargin, watermarkMargin),
+}
+
+// watermarkExts are the image types accepted as watermarks.
+var watermarkExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true}
+
+// ErrWatermarksDisabled is returned for a watermark request when no
+// ASSETS_DIR is configured.
+var ErrWatermarksDisabled = errors.New("watermarks are disabled, ASSETS_DIR is not set")
+
+// ValidateWatermark checks a watermark request: name must be an image in the
+// assets directory and position one of the corners (empty stands for
+// bottom-right). An empty name asks for no watermark and is always valid.
+func (d *Downloader) ValidateWatermark(name, position string) error {
+	if name == "" {
+		return nil
+	}
+	if position != "" && watermarkOverlays[position] == "" {
+		return fmt.Errorf("unknown watermark position %q, expected one of: %s, %s, %s, %s",
+			position, WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight)
+	}
+	_, err := d.watermarkPath(name)
+	return err
+}
+
+// watermarkPath resolves the watermark name to a file in the assets
+// directory. Names leaving the directory, directly or through a symlink,
+// are rejected so a client cannot read arbitrary files into a video.
+func (d *Downloader) watermarkPath(name string) (string, error) {
+	if d.config().AssetsDir == "" {
+		return "", ErrWatermarksDisabled
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid watermark %q: must be a path inside the assets directory", name)
+	}
+	if !watermarkExts[strings.ToLower(filepath.Ext(name))] {
+		return "", fmt.Errorf("invalid watermark %q: expected a png, jpg or webp image", name)
+	}
+
+	assetsDir, err := filepath.EvalSymlinks(d.config().AssetsDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve assets directory: %w", err)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(assetsDir, name))
+	if err != nil {
+		return "", fmt.Errorf("watermark %q not found", name)
+	}
+	if rel, err := filepath.Rel(assetsDir, path); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid watermark %q: must be a path inside the assets directory", name)
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("watermark %q not found", name)
+	}
+	return path, nil
+}
+
+// watermarkArgs builds the ffmpeg arguments overlaying imagePath on
+// videoPath in the given corner, writing the result to outputPath. The
+// audio is copied as is.
+func watermarkArgs(videoPath, imagePath, outputPath, position string) []string {
+	overlay, ok := watermarkOverlays[position]
+	if !ok {
+		overlay = watermarkOverlays[WatermarkBottomRight]
+	}
+	return []string{
+		"-y",
+		"-i", videoPath,
+		"-i", imagePath,
+		"-filter_complex", "[0:v][1:v]overlay=" + overlay,
+		"-c:a", "copy",
+		outputPath,
+	}
+}
+
+// applyWatermark re-encodes the downloaded video with the watermark image
+// overlaid. The file at videoPath is replaced.
+func (d *Downloader) applyWatermark(ctx context.Context, videoPath, name, position, progressID string) (err error) {
+	ctx, span := startSpan(ctx, "encode watermark", "", attribute.String("watermark.position", position))
+	defer func() { endSpan(span, videoPath, err) }()
+
+	imagePath, err := d.watermarkPath(name)
+	if err != nil {
+		return err
+	}
+
+	d.progressManager.SendEvent(ProgressEvent{
+		ID:         progressID,
+		Status:     "encoding",
+		Message:    "Adding watermark to video...",
+		Percentage: 85,
+	})
+
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	markedPath := base + ".watermarked" + filepath.Ext(videoPath)
+	ffmpegArgs := watermarkArgs(videoPath, imagePath, markedPath, position)
+	ffmpegCmd := exec.CommandContext(ctx, d.config().FFMPEGPath, ffmpegArgs...)
+	slog.Debug(fmt.Sprintf("Executing ffmpeg for watermark: %s %s", d.config().FFMPEGPath, strings.Join(ffmpegArgs, " ")))
+
+	var ffmpegStderr bytes.Buffer
+	ffmpegCmd.Stderr = &ffmpegStderr
+	if err := ffmpegCmd.Run(); err != nil {
+		os.Remove(markedPath)
+		return fmt.Errorf("ffmpeg watermark overlay failed: %w, stderr: %s", err, ffmpegStderr.String())
+	}
+
+	if err := os.Rename(markedPath, videoPath); err != nil {
+		return fmt.Errorf("failed to replace video with watermarked version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatermarkArgs(t *testing.T) {
+	args := watermarkArgs("/data/1-abc.mp4", "/assets/logo.png", "/data/1-abc.watermarked.mp4", WatermarkTopLeft)
+	assert.Equal(t, []string{
+		"-y",
+		"-i", "/data/1-abc.mp4",
+		"-i", "/assets/logo.png",
+		"-filter_complex", "[0:v][1:v]overlay=10:10",
+		"-c:a", "copy",
+		"/data/1-abc.watermarked.mp4",
+	}, args)
+
+	args = watermarkArgs("in.mp4", "logo.png", "out.mp4", "")
+	assert.Contains(t, args, "[0:v][1:v]overlay=W-w-10:H-h-10", "bottom-right is the default")
+}
+
+func TestValidateWatermark(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude)
+	assert.ErrorIs(t, d.ValidateWatermark("logo.png", ""), ErrWatermarksDisabled)
+
+	assets := t.TempDir()
+	d.config().AssetsDir = assets
+	assert.NoError(t, os.MkdirAll(filepath.Join(assets, "brand"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(assets, "brand", "logo.png"), []byte("png"), 0644))
+	outside := filepath.Join(t.TempDir(), "secret.png")
+	assert.NoError(t, os.WriteFile(outside, []byte("png"), 0644))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(assets, "escape.png")))
+
+	assert.NoError(t, d.ValidateWatermark("", "anything"))
+	assert.NoError(t, d.ValidateWatermark("brand/logo.png", ""))
+	assert.NoError(t, d.ValidateWatermark("brand/logo.png", WatermarkTopRight))
+
+	assert.ErrorContains(t, d.ValidateWatermark("brand/logo.png", "center"), "unknown watermark position")
+	assert.ErrorContains(t, d.ValidateWatermark("../"+filepath.Base(filepath.Dir(outside))+"/secret.png", ""), "inside the assets directory")
+	assert.ErrorContains(t, d.ValidateWatermark(outside, ""), "inside the assets directory")
+	assert.ErrorContains(t, d.ValidateWatermark("escape.png", ""), "inside the assets directory")
+	assert.ErrorContains(t, d.ValidateWatermark("brand", ""), "expected a png, jpg or webp image")
+	assert.ErrorContains(t, d.ValidateWatermark("missing.png", ""), "not found")
+}
+
+// fakeFFmpegArgs writes its arguments, one per line, to its output file.
+const fakeFFmpegArgs = `#!/bin/sh
+for out in "$@"; do :; done
+printf '%s\n' "$@" > "$out"
+`
+
+func TestDownloadVideoToFile_Watermark(t *testing.T) {
+	d := newFakeDownloader(t, mediaScript)
+	d.config().AssetsDir = t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(d.config().AssetsDir, "logo.png"), []byte("png"), 0644))
+	withFakeFFmpeg(t, d, fakeFFmpegArgs)
+
+	opts := VideoOptions{Watermark: "logo.png", WatermarkPosition: WatermarkBottomLeft}
+	filePath, _, _, err := d.DownloadVideoToFile(context.Background(), "https://example.com/v", opts, "")
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Contains(t, args, "-filter_complex")
+	assert.Contains(t, args, "[0:v][1:v]overlay=10:H-h-10")
+	assert.NoFileExists(t, strings.TrimSuffix(filePath, ".mp4")+".watermarked.mp4")
+
+	_, _, _, err = d.DownloadVideoToFile(context.Background(), "https://example.com/v", VideoOptions{Watermark: "../logo.png"}, "")
+	assert.ErrorContains(t, err, "inside the assets directory")
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxWaveformPoints bounds the points a waveform can be computed with.
+	MaxWaveformPoints = 10000
+	// waveformSampleRate is the rate audio is decoded at for waveforms;
+	// plenty to find peaks while keeping the PCM small.
+	waveformSampleRate = 8000
+	// waveformBlockSamples is how many samples are folded into one peak
+	// while decoding (10ms), so long files never sit in memory as PCM.
+	waveformBlockSamples = waveformSampleRate / 100
+	// waveformCacheSize is how many computed waveforms are kept.
+	waveformCacheSize = 128
+)
+
+// waveformKey identifies a computed waveform. The file's size and
+// modification time are part of it, so a replaced file is recomputed.
+type waveformKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+	points  int
+}
+
+// waveformCache keeps computed waveforms; they only change with the file.
+type waveformCache struct {
+	mu      sync.Mutex
+	entries map[waveformKey][]float64
+}
+
+func newWaveformCache() *waveformCache {
+	return &waveformCache{entries: make(map[waveformKey][]float64)}
+}
+
+func (c *waveformCache) get(key waveformKey) ([]float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	peaks, ok := c.entries[key]
+	return peaks, ok
+}
+
+func (c *waveformCache) set(key waveformKey, peaks []float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= waveformCacheSize {
+		// Waveforms are cheap to recompute, so any entry will do
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = peaks
+}
+
+// ComputeWaveform returns the waveform of a downloaded audio (or video) file
+// as points peak levels between 0 and 1, each the loudest sample of its
+// slice of the file. It is meant for drawing a scrubber. The audio is
+// decoded to mono PCM with ffmpeg, taking a download slot, and results are
+// cached until the file changes.
+func (d *Downloader) ComputeWaveform(ctx context.Context, path string, points int) ([]float64, error) {
+	if points < 1 || points > MaxWaveformPoints {
+		return nil, fmt.Errorf("invalid waveform points %d, expected between 1 and %d", points, MaxWaveformPoints)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := waveformKey{path: path, size: stat.Size(), modTime: stat.ModTime(), points: points}
+	if peaks, ok := d.waveforms.get(key); ok {
+		return peaks, nil
+	}
+
+	release, err := d.downloadLimit.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	args := []string{"-v", "error", "-i", path, "-vn", "-ac", "1", "-ar", fmt.Sprint(waveformSampleRate), "-f", "s16le", "-"}
+	cmd := exec.CommandContext(ctx, d.config().FFMPEGPath, args...)
+	slog.Debug(fmt.Sprintf("Executing ffmpeg for waveform: %s %s", d.config().FFMPEGPath, strings.Join(args, " ")))
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe for ffmpeg: %w", err)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg for waveform: %w", err)
+	}
+	blocks, readErr := blockPeaks(stdout, waveformBlockSamples)
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg waveform decode failed: %w, stderr: %s", err, stderr.String())
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	if len(blocks) == 0 {
+		return nil, errors.New("file has no audio to compute a waveform from")
+	}
+
+	peaks := downsamplePeaks(blocks, points)
+	d.waveforms.set(key, peaks)
+	return peaks, nil
+}
+
+// blockPeaks reads signed 16-bit little-endian mono PCM from r and returns
+// the peak level (0 to 1) of every blockSize samples.
+func blockPeaks(r io.Reader, blockSize int) ([]float64, error) {
+	var peaks []float64
+	var peak, n int
+	br := bufio.NewReader(r)
+	var sample [2]byte
+	for {
+		if _, err := io.ReadFull(br, sample[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read decoded audio: %w", err)
+		}
+		v := int(int16(binary.LittleEndian.Uint16(sample[:])))
+		if v < 0 {
+			v = -v
+		}
+		peak = max(peak, v)
+		if n++; n == blockSize {
+			peaks = append(peaks, float64(peak)/32768)
+			peak, n = 0, 0
+		}
+	}
+	if n > 0 {
+		peaks = append(peaks, float64(peak)/32768)
+	}
+	return peaks, nil
+}
+
+// downsamplePeaks folds peaks into points values, each the highest peak of
+// its share of the input. With fewer peaks than points, every point maps to
+// the peak under it.
+func downsamplePeaks(peaks []float64, points int) []float64 {
+	out := make([]float64, points)
+	for i := range out {
+		start := i * len(peaks) / points
+		end := max((i+1)*len(peaks)/points, start+1)
+		for _, p := range peaks[start:min(end, len(peaks))] {
+			out[i] = max(out[i], p)
+		}
+	}
+	return out
+}
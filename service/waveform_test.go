@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFFmpegPCM strips the 44-byte header of the WAV file passed with -i, as
+// if decoding it to raw PCM.
+const fakeFFmpegPCM = `#!/bin/sh
+prev=""
+for a in "$@"; do
+	case "$prev" in -i) tail -c +45 "$a" ;; esac
+	prev="$a"
+done
+`
+
+// writeSampleWAV writes a mono 16-bit WAV: a quiet first second and a loud
+// second one.
+func writeSampleWAV(t *testing.T, path string) {
+	t.Helper()
+	samples := make([]int16, 2*waveformSampleRate)
+	for i := range samples {
+		level := int16(1024)
+		if i >= waveformSampleRate {
+			level = 16384
+		}
+		if i%2 == 1 {
+			level = -level
+		}
+		samples[i] = level
+	}
+	data := make([]byte, 44, 44+2*len(samples))
+	copy(data, "RIFF")
+	copy(data[8:], "WAVEfmt ")
+	copy(data[36:], "data")
+	for _, s := range samples {
+		data = binary.LittleEndian.AppendUint16(data, uint16(s))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write sample file: %v", err)
+	}
+}
+
+func TestComputeWaveform(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude)
+	withFakeFFmpeg(t, d, fakeFFmpegPCM)
+	path := filepath.Join(d.config().DownloadDir, "abc123.wav")
+	writeSampleWAV(t, path)
+
+	peaks, err := d.ComputeWaveform(context.Background(), path, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1024.0 / 32768, 1024.0 / 32768, 0.5, 0.5}, peaks)
+
+	// Cached: ffmpeg is not run again for an unchanged file
+	assert.NoError(t, os.Remove(d.config().FFMPEGPath))
+	cached, err := d.ComputeWaveform(context.Background(), path, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, peaks, cached)
+
+	_, err = d.ComputeWaveform(context.Background(), path, 0)
+	assert.ErrorContains(t, err, "invalid waveform points")
+}
+
+func TestComputeWaveform_TooManyDownloads(t *testing.T) {
+	d := newFakeDownloader(t, fakeInfoPrelude)
+	withFakeFFmpeg(t, d, fakeFFmpegPCM)
+	path := filepath.Join(d.config().DownloadDir, "abc123.wav")
+	writeSampleWAV(t, path)
+
+	d.downloadLimit.setLimits(1, 0)
+	release, err := d.downloadLimit.acquire(context.Background())
+	assert.NoError(t, err)
+	_, err = d.ComputeWaveform(context.Background(), path, 4)
+	assert.ErrorIs(t, err, ErrTooManyDownloads)
+
+	// The slot is given back once the decode is done
+	release()
+	_, err = d.ComputeWaveform(context.Background(), path, 4)
+	assert.NoError(t, err)
+	release, err = d.downloadLimit.acquire(context.Background())
+	assert.NoError(t, err)
+	release()
+}
+
+func TestDownsamplePeaks(t *testing.T) {
+	assert.Equal(t, []float64{0.5, 0.9}, downsamplePeaks([]float64{0.1, 0.5, 0.9, 0.2}, 2))
+	// More points than peaks repeats them
+	assert.Equal(t, []float64{0.1, 0.1, 0.9, 0.9}, downsamplePeaks([]float64{0.1, 0.9}, 4))
+}
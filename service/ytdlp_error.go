@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ytdlpExitCodes counts failed yt-dlp runs by exit code. It is published
+// through expvar, i.e. on /debug/vars when debug mode is enabled.
+var ytdlpExitCodes = expvar.NewMap("ytdlp_exit_codes")
+
+// YTDLPError is returned when a yt-dlp run fails. ExitCode is -1 when the
+// process did not exit normally (e.g. it could not be started or was killed).
+type YTDLPError struct {
+	Op       string // What was being done, e.g. "info dump"
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *YTDLPError) Error() string {
+	return fmt.Sprintf("yt-dlp %s failed (exit code %d): %v, stderr: %s", e.Op, e.ExitCode, e.Err, e.Stderr)
+}
+
+func (e *YTDLPError) Unwrap() error {
+	return e.Err
+}
+
+// newYTDLPError logs and counts a failed yt-dlp run and wraps err with its exit code.
+func newYTDLPError(op string, err error, stderr string) *YTDLPError {
+	code := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	}
+	ytdlpExitCodes.Add(strconv.Itoa(code), 1)
+	slog.Error("yt-dlp "+op+" failed", "exit_code", code, "error", err, "stderr", stderr)
+	return &YTDLPError{Op: op, ExitCode: code, Stderr: stderr, Err: err}
+}
+
+// maxStderrTail bounds the stderr kept from a streaming yt-dlp run.
+const maxStderrTail = 16 << 10
+
+// tailBuffer keeps the last max bytes written to it, so the stderr of a
+// long-running stream can be reported on failure without growing unbounded.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// parseYTDLPStderr separates the "WARNING:" and "ERROR:" lines yt-dlp
+// printed, without their prefix. Other lines are ignored.
+func parseYTDLPStderr(stderr string) (warnings, errs []string) {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if msg, ok := strings.CutPrefix(line, "WARNING:"); ok {
+			warnings = append(warnings, strings.TrimSpace(msg))
+		} else if msg, ok := strings.CutPrefix(line, "ERROR:"); ok {
+			errs = append(errs, strings.TrimSpace(msg))
+		}
+	}
+	return warnings, errs
+}
+
+// checkYTDLPRun judges a finished yt-dlp download that wrote to outputPath.
+// yt-dlp sometimes exits non-zero over problems it only reported as
+// warnings; such a run still counts as a success when it was not cancelled,
+// printed no ERROR: line and left its output file behind. It returns the
+// warnings of a successful run, or the error of a failed one.
+func checkYTDLPRun(ctx context.Context, op string, err error, stderr, outputPath string) ([]string, *YTDLPError) {
+	warnings, errs := parseYTDLPStderr(stderr)
+	if err == nil {
+		return warnings, nil
+	}
+	if ctx.Err() == nil && len(errs) == 0 && len(warnings) > 0 {
+		var exitErr *exec.ExitError
+		if _, statErr := os.Stat(outputPath); statErr == nil && errors.As(err, &exitErr) {
+			slog.Warn("yt-dlp "+op+" exited with an error status after warnings only, keeping its output",
+				"exit_code", exitErr.ExitCode(), "warnings", warnings, "filePath", outputPath)
+			return warnings, nil
+		}
+	}
+	return nil, newYTDLPError(op, err, stderr)
+}
+
+// InfoTimeoutError is returned when fetching video info takes longer than the
+// configured INFO_TIMEOUT. It is distinct from the request's own deadline
+// expiring, which surfaces as a plain context error.
+type InfoTimeoutError struct {
+	URL     string
+	Timeout time.Duration
+}
+
+func (e *InfoTimeoutError) Error() string {
+	return fmt.Sprintf("fetching video info for %s timed out after %s", e.URL, e.Timeout)
+}
+
+// Unwrap lets errors.Is(err, context.DeadlineExceeded) match.
+func (e *InfoTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVideoInfo_CapturesExitCode(t *testing.T) {
+	d := newFakeDownloader(t, `#!/bin/sh
+echo "ERROR: Unsupported URL" >&2
+exit 3
+`)
+	before := exitCodeCount("3")
+
+	_, err := d.GetVideoInfo(context.Background(), "https://example.com/v", "")
+
+	var ytdlpErr *YTDLPError
+	assert.True(t, errors.As(err, &ytdlpErr), "expected a *YTDLPError, got %v", err)
+	assert.Equal(t, 3, ytdlpErr.ExitCode)
+	assert.Equal(t, "info dump", ytdlpErr.Op)
+	assert.Contains(t, err.Error(), "exit code 3")
+	assert.Contains(t, err.Error(), "Unsupported URL")
+	assert.Equal(t, before+1, exitCodeCount("3"))
+}
+
+func TestNewYTDLPError_NotStarted(t *testing.T) {
+	err := newYTDLPError("info dump", errors.New("executable file not found"), "")
+	assert.Equal(t, -1, err.ExitCode)
+}
+
+// exitCodeCount reads the ytdlp_exit_codes counter for code.
+func exitCodeCount(code string) int64 {
+	if v := ytdlpExitCodes.Get(code); v != nil {
+		return v.(*expvar.Int).Value()
+	}
+	return 0
+}
+
+func TestGetVideoInfo_Timeout(t *testing.T) {
+	d := newFakeDownloader(t, "#!/bin/sh\nexec sleep 5\n")
+	d.config().InfoTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err := d.GetVideoInfo(context.Background(), "https://example.com/v", "")
+	assert.Less(t, time.Since(start), 3*time.Second, "the info timeout should cut the fetch short")
+
+	var timeoutErr *InfoTimeoutError
+	assert.True(t, errors.As(err, &timeoutErr), "expected an *InfoTimeoutError, got %v", err)
+	assert.Equal(t, 100*time.Millisecond, timeoutErr.Timeout)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// GetStreamInfo shares the same fetch and limit
+	_, err = d.GetStreamInfo(context.Background(), "https://example.com/v", "", "", "", "")
+	assert.True(t, errors.As(err, &timeoutErr), "expected an *InfoTimeoutError, got %v", err)
+}
+
+func TestGetVideoInfo_ParentCancelIsNotInfoTimeout(t *testing.T) {
+	d := newFakeDownloader(t, "#!/bin/sh\nexec sleep 5\n")
+	d.config().InfoTimeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := d.GetVideoInfo(ctx, "https://example.com/v", "")
+
+	var timeoutErr *InfoTimeoutError
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &timeoutErr))
+}
+
+func TestParseYTDLPStderr(t *testing.T) {
+	warnings, errs := parseYTDLPStderr(`[youtube] abc123: Downloading webpage
+WARNING: [youtube] Falling back to generic n function search
+WARNING: Requested formats are incompatible for merge and will be merged into mkv
+ERROR: unable to download video data: HTTP Error 403
+`)
+	assert.Equal(t, []string{
+		"[youtube] Falling back to generic n function search",
+		"Requested formats are incompatible for merge and will be merged into mkv",
+	}, warnings)
+	assert.Equal(t, []string{"unable to download video data: HTTP Error 403"}, errs)
+}
+
+func TestDownloadAudioToFile_Warnings(t *testing.T) {
+	const warn = `echo "WARNING: [youtube] Falling back to generic n function search" >&2
+`
+	tests := []struct {
+		name         string
+		script       string
+		wantErr      bool
+		wantWarnings []string
+	}{
+		{
+			name:         "SuccessWithWarnings",
+			script:       fakeInfoPrelude + warn + `printf 'audio' > "$out"` + "\n",
+			wantWarnings: []string{"[youtube] Falling back to generic n function search"},
+		},
+		{
+			name:         "NonZeroExitAfterWarningsOnly",
+			script:       fakeInfoPrelude + warn + `printf 'audio' > "$out"; exit 1` + "\n",
+			wantWarnings: []string{"[youtube] Falling back to generic n function search"},
+		},
+		{
+			name:    "NonZeroExitWithoutOutput",
+			script:  fakeInfoPrelude + warn + "exit 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "Error",
+			script:  fakeInfoPrelude + warn + `printf 'audio' > "$out"; echo "ERROR: Postprocessing: audio conversion failed" >&2; exit 1` + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDownloader(t, tt.script)
+			filePath, _, warnings, err := d.DownloadAudioToFile(context.Background(), "https://example.com/v", AudioOptions{}, "")
+			if tt.wantErr {
+				var ytdlpErr *YTDLPError
+				assert.True(t, errors.As(err, &ytdlpErr), "expected a *YTDLPError, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.FileExists(t, filePath)
+			assert.Equal(t, tt.wantWarnings, warnings)
+		})
+	}
+}
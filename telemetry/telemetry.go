@@ -0,0 +1,50 @@
+// Package telemetry sets up OpenTelemetry tracing.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"gostreampuller/config"
+)
+
+// ServiceName is the service.name of the exported traces.
+const ServiceName = "gostreampuller"
+
+// Setup installs the global tracer provider exporting spans over OTLP/HTTP
+// when OTEL_ENABLED is set, along with W3C trace context propagation so
+// spans join the traces of calling services. The returned function flushes
+// and stops the exporter; it does nothing when tracing is disabled.
+func Setup(ctx context.Context, cfg *config.Config) (shutdown func(context.Context) error, err error) {
+	if !cfg.OTelEnabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.OTelEndpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTelEndpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build telemetry resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	slog.Info("OpenTelemetry tracing enabled", "endpoint", cfg.OTelEndpoint)
+	return provider.Shutdown, nil
+}